@@ -0,0 +1,71 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllowlist(t *testing.T) {
+	t.Run("Parse", testParseAllowlist)
+	t.Run("Middleware", testAllowlistMiddleware)
+}
+
+func testParseAllowlist(t *testing.T) {
+	prefixes, err := parseAllowlist([]string{"10.0.0.1", "fd00::1", "10.244.0.0/16", "10.1.2.3/8", "fd00:1::1/64"})
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.1/32"),
+		netip.MustParsePrefix("fd00::1/128"),
+		netip.MustParsePrefix("10.244.0.0/16"),
+		// Non-canonical ranges are masked to their network
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("fd00:1::/64"),
+	}, prefixes)
+
+	for entry, msg := range map[string]string{
+		"":              "invalid allowed address",
+		"10.0.0.256":    "invalid allowed address",
+		"localhost":     "invalid allowed address",
+		"10.0.0.0/33":   "invalid allowed CIDR",
+		"10.0.0.0/":     "invalid allowed CIDR",
+		"example.com/8": "invalid allowed CIDR",
+	} {
+		_, err := parseAllowlist([]string{"10.0.0.1", entry})
+		assert.ErrorContains(t, err, msg, entry)
+	}
+}
+
+func testAllowlistMiddleware(t *testing.T) {
+	allowed, err := parseAllowlist([]string{"10.0.0.1", "192.168.1.1/16", "fd00::/64"})
+	require.NoError(t, err)
+	handler := allowlistMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), allowed, slog.Default())
+
+	for remote, code := range map[string]int{
+		"10.0.0.1:1234":        http.StatusOK,
+		"10.0.0.2:1234":        http.StatusForbidden,
+		"192.168.200.1:1234":   http.StatusOK,
+		"192.169.0.1:1234":     http.StatusForbidden,
+		"[fd00::5]:1234":       http.StatusOK,
+		"[fd00:1::5]:1234":     http.StatusForbidden,
+		"[::ffff:10.0.0.1]:80": http.StatusOK,
+		"[::ffff:10.0.0.2]:80": http.StatusForbidden,
+		// Without a port, e.g. behind some listeners, the address is taken as is
+		"10.0.0.1":  http.StatusOK,
+		"fd00::5":   http.StatusOK,
+		"10.0.0.2":  http.StatusForbidden,
+		"":          http.StatusForbidden,
+		"not-an-ip": http.StatusForbidden,
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/records", nil)
+		r.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, code, w.Code, remote)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	provider "github.com/orbit-online/external-dns-inwx-webhook/provider"
+)
+
+// openAuditStore returns the audit store for spec: s3://bucket/prefix for an S3-compatible store
+// configured by the --audit-s3-* flags, sqlite:///path for a SQLite database, or a file path, optionally as
+// a file:// URL.
+func openAuditStore(spec string) (provider.AuditStore, error) {
+	u, err := url.Parse(spec)
+	if err != nil || u.Scheme == "" || len(u.Scheme) == 1 {
+		// Plain paths, including Windows drive letters
+		return provider.NewFileAuditStore(spec)
+	}
+	switch u.Scheme {
+	case "file":
+		return provider.NewFileAuditStore(u.Path)
+	case "sqlite":
+		return provider.NewSQLiteAuditStore(u.Path)
+	case "s3":
+		prefix := strings.TrimPrefix(u.Path, "/")
+		if prefix != "" && !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+		return provider.NewS3AuditStore(provider.S3Config{
+			Endpoint:        *auditS3Endpoint,
+			Region:          *auditS3Region,
+			Bucket:          u.Host,
+			Prefix:          prefix,
+			AccessKeyID:     *auditS3AccessKeyID,
+			SecretAccessKey: *auditS3SecretAccessKey,
+			PathStyle:       *auditS3PathStyle,
+		})
+	}
+	return nil, fmt.Errorf("unsupported audit log %q: use a file path, file:///path, sqlite:///path or s3://bucket/prefix", spec)
+}
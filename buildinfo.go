@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/prometheus/common/version"
+)
+
+// appName identifies the webhook in the User-Agent of INWX API calls.
+const appName = "external-dns-inwx-webhook"
+
+// resolveBuildInfo fills in the build metadata not injected through -ldflags from the module and VCS
+// information the Go toolchain embeds, so that plain `go build` binaries are identifiable as well.
+func resolveBuildInfo() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	if version.Version == "" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		version.Version = info.Main.Version
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if version.Revision == "" {
+				version.Revision = setting.Value
+			}
+		case "vcs.time":
+			if version.BuildDate == "" {
+				version.BuildDate = setting.Value
+			}
+		}
+	}
+}
+
+// userAgent describes this build, e.g. "external-dns-inwx-webhook/v1.2.0 (commit 1a2b3c4; linux/arm64; go1.25.4)".
+func userAgent() string {
+	v, revision := version.Version, version.Revision
+	if v == "" {
+		v = "unknown"
+	}
+	if revision == "" {
+		revision = "unknown"
+	} else if len(revision) > 7 {
+		revision = revision[:7]
+	}
+	return fmt.Sprintf("%s/%s (commit %s; %s/%s; %s)", appName, v, revision, runtime.GOOS, runtime.GOARCH, runtime.Version())
+}
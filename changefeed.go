@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	provider "github.com/orbit-online/external-dns-inwx-webhook/provider"
+)
+
+// changeFeedKeepAlive is the interval of the comments keeping idle change feed connections open through
+// proxies.
+const changeFeedKeepAlive = 15 * time.Second
+
+// changesHandler streams the changes applied against INWX as server-sent events: a "change" event per
+// change, with its sequence number as ID and the change as JSON data. A client reconnecting with a
+// Last-Event-ID header, as EventSource does, first receives the retained changes it missed.
+func changesHandler(p *provider.INWXProvider, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var after uint64
+		if v := r.Header.Get("Last-Event-ID"); v != "" {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+				return
+			}
+			after = n
+		}
+		backlog, events, cancel := p.SubscribeChanges(after)
+		defer cancel()
+
+		rc := http.NewResponseController(w)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		for _, event := range backlog {
+			if err := writeChangeEvent(w, event); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		keepAlive := time.NewTicker(changeFeedKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					// Fallen behind or shutting down; the client reconnects and catches up.
+					logger.Debug("closing change feed connection", "remote", r.RemoteAddr)
+					return
+				}
+				if err := writeChangeEvent(w, event); err != nil {
+					return
+				}
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeChangeEvent(w http.ResponseWriter, event provider.ChangeEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: change\ndata: %s\n\n", event.Seq, data)
+	return err
+}
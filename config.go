@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/alecthomas/kingpin/v2"
+	provider "github.com/orbit-online/external-dns-inwx-webhook/provider"
+)
+
+// envPrefix is the prefix of every environment variable the webhook reads.
+const envPrefix = "INWX_WEBHOOK_"
+
+// legacyEnvPrefix is the prefix the environment variables had before they were moved under envPrefix.
+// They keep working, with a warning, until they are removed in a later release.
+const legacyEnvPrefix = "INWX_"
+
+// deprecatedFlags maps renamed flags to their replacement. The old names keep working, with a warning,
+// until they are removed in a later release; their environment variables are mapped accordingly.
+var deprecatedFlags = map[string]string{}
+
+// applyDeprecations rewrites deprecated flags in args and deprecated environment variables to their
+// replacements, and returns a warning for each use. It must run before the flags are parsed.
+func applyDeprecations(args []string) ([]string, []string) {
+	var warnings []string
+	rewritten := slices.Clone(args)
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "--") {
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		prefix := "--"
+		if trimmed, ok := strings.CutPrefix(name, "no-"); ok {
+			if _, deprecated := deprecatedFlags[trimmed]; deprecated {
+				name, prefix = trimmed, "--no-"
+			}
+		}
+		replacement, ok := deprecatedFlags[name]
+		if !ok {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("flag --%s is deprecated, use --%s instead", name, replacement))
+		rewritten[i] = prefix + replacement
+		if hasValue {
+			rewritten[i] += "=" + value
+		}
+	}
+
+	for _, old := range slices.Sorted(maps.Keys(deprecatedFlags)) {
+		oldEnvar, newEnvar := envarFor(old), envarFor(deprecatedFlags[old])
+		value, ok := os.LookupEnv(oldEnvar)
+		if !ok {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("environment variable %s is deprecated, use %s instead", oldEnvar, newEnvar))
+		if _, ok := os.LookupEnv(newEnvar); !ok {
+			_ = os.Setenv(newEnvar, value)
+		}
+	}
+	return rewritten, warnings
+}
+
+// envarFor returns the environment variable of a flag following the INWX_WEBHOOK_<FLAG_NAME> convention,
+// without the inwx- or webhook- prefix of the flag, e.g. INWX_WEBHOOK_USERNAME for --inwx-username.
+func envarFor(flag string) string {
+	flag = strings.TrimPrefix(strings.TrimPrefix(flag, "inwx-"), "webhook-")
+	return envPrefix + envarName(flag)
+}
+
+// legacyEnvarFor returns the environment variable a flag had before the INWX_WEBHOOK_ prefix, e.g.
+// INWX_USERNAME for --inwx-username.
+func legacyEnvarFor(flag string) string {
+	return legacyEnvPrefix + envarName(strings.TrimPrefix(flag, "inwx-"))
+}
+
+// envarName turns a flag name such as log.level into the LOG_LEVEL part of an environment variable.
+func envarName(flag string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(flag))
+}
+
+// configFlags returns the flags of app configuring the webhook, leaving out those of the subcommands and
+// the help and version flags.
+func configFlags(app *kingpin.Application) []*kingpin.FlagModel {
+	var flags []*kingpin.FlagModel
+	for _, flag := range app.Model().Flags {
+		switch {
+		case flag.Hidden, flag.Name == "help", flag.Name == "version", flag.Name == "help-config":
+			continue
+		}
+		flags = append(flags, flag)
+	}
+	return flags
+}
+
+// assignEnvars configures the environment variable of every flag of app from its name, so that the
+// variables follow one scheme. It must run before the flags are parsed.
+func assignEnvars(app *kingpin.Application) {
+	for _, flag := range configFlags(app) {
+		app.GetFlag(flag.Name).Envar(envarFor(flag.Name))
+	}
+}
+
+// applyLegacyEnvars maps the environment variables of the flags of app still set under their legacy name
+// to the current one, unless that is set as well, and returns a warning for each.
+func applyLegacyEnvars(app *kingpin.Application) []string {
+	var warnings []string
+	for _, flag := range configFlags(app) {
+		legacy := legacyEnvarFor(flag.Name)
+		value, ok := os.LookupEnv(legacy)
+		if !ok || legacy == flag.Envar {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("environment variable %s is deprecated, use %s instead", legacy, flag.Envar))
+		if _, ok := os.LookupEnv(flag.Envar); !ok {
+			_ = os.Setenv(flag.Envar, value)
+		}
+	}
+	return warnings
+}
+
+// printConfig writes every flag of app configuring the webhook with its environment variable, default and
+// current value to w, redacting secrets.
+func printConfig(w io.Writer, app *kingpin.Application) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FLAG\tENVIRONMENT VARIABLE\tDEFAULT\tVALUE")
+	for _, flag := range configFlags(app) {
+		value := flag.Value.String()
+		if isSecretFlag(flag.Name) && value != "" {
+			value = "<redacted>"
+		}
+		fmt.Fprintf(tw, "--%s\t%s\t%s\t%s\n", flag.Name, flag.Envar, strings.Join(flag.Default, ","), value)
+	}
+	_ = tw.Flush()
+}
+
+// isSecretFlag reports whether a flag holds a secret, as opposed to the path of a file holding one.
+func isSecretFlag(name string) bool {
+	// The URLs of chat webhooks carry their token.
+	if name == "notify-url" {
+		return true
+	}
+	return (strings.Contains(name, "password") || strings.Contains(name, "secret")) && !strings.HasSuffix(name, "-file")
+}
+
+// unknownEnvars returns a warning for each variable in environ with the INWX_ prefix that doesn't
+// configure any flag, under its current or legacy name, e.g. because of a typo, so that it isn't silently
+// ignored.
+func unknownEnvars(app *kingpin.Application, environ []string) []string {
+	known := []string{}
+	model := app.Model()
+	flags := model.Flags
+	for _, cmd := range model.Commands {
+		flags = append(flags, cmd.Flags...)
+	}
+	for _, flag := range flags {
+		if flag.Envar != "" {
+			known = append(known, flag.Envar)
+		}
+	}
+	for _, flag := range configFlags(app) {
+		known = append(known, legacyEnvarFor(flag.Name))
+	}
+	for old := range deprecatedFlags {
+		known = append(known, envarFor(old))
+	}
+
+	var warnings []string
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, legacyEnvPrefix) || slices.Contains(known, name) {
+			continue
+		}
+		if match := provider.ClosestMatch(name, known); match != "" {
+			warnings = append(warnings, fmt.Sprintf("ignoring unknown environment variable %s, did you mean %s?", name, match))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("ignoring unknown environment variable %s", name))
+		}
+	}
+	slices.Sort(warnings)
+	return warnings
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unsetenv unsets key for the rest of the test, restoring it afterwards, so that variables set by the code
+// under test don't leak into other tests.
+func unsetenv(t *testing.T, key string) {
+	t.Setenv(key, "")
+	_ = os.Unsetenv(key)
+}
+
+func TestConfig(t *testing.T) {
+	t.Run("Deprecations", testApplyDeprecations)
+	t.Run("UnknownEnvars", testUnknownEnvars)
+	t.Run("LegacyEnvars", testApplyLegacyEnvars)
+	t.Run("PrintConfig", testPrintConfig)
+}
+
+func testApplyDeprecations(t *testing.T) {
+	defer func(flags map[string]string) { deprecatedFlags = flags }(deprecatedFlags)
+	deprecatedFlags = map[string]string{"old-flag": "new-flag"}
+
+	t.Run("Args", func(t *testing.T) {
+		unsetenv(t, "INWX_WEBHOOK_OLD_FLAG")
+		args, warnings := applyDeprecations([]string{"--old-flag", "--old-flag=value", "--no-old-flag", "--no-other", "--other=old-flag", "old-flag", "--", "--old-flag"})
+		assert.Equal(t, []string{"--new-flag", "--new-flag=value", "--no-new-flag", "--no-other", "--other=old-flag", "old-flag", "--", "--old-flag"}, args)
+		assert.Equal(t, []string{
+			"flag --old-flag is deprecated, use --new-flag instead",
+			"flag --old-flag is deprecated, use --new-flag instead",
+			"flag --old-flag is deprecated, use --new-flag instead",
+		}, warnings)
+	})
+
+	t.Run("Envars", func(t *testing.T) {
+		t.Setenv("INWX_WEBHOOK_OLD_FLAG", "old")
+		unsetenv(t, "INWX_WEBHOOK_NEW_FLAG")
+		_, warnings := applyDeprecations(nil)
+		assert.Equal(t, []string{"environment variable INWX_WEBHOOK_OLD_FLAG is deprecated, use INWX_WEBHOOK_NEW_FLAG instead"}, warnings)
+		assert.Equal(t, "old", os.Getenv("INWX_WEBHOOK_NEW_FLAG"))
+
+		// The new variable wins when both are set
+		t.Setenv("INWX_WEBHOOK_NEW_FLAG", "new")
+		_, warnings = applyDeprecations(nil)
+		assert.Len(t, warnings, 1)
+		assert.Equal(t, "new", os.Getenv("INWX_WEBHOOK_NEW_FLAG"))
+	})
+}
+
+func testUnknownEnvars(t *testing.T) {
+	defer func(flags map[string]string) { deprecatedFlags = flags }(deprecatedFlags)
+	deprecatedFlags = map[string]string{"old-flag": "new-flag"}
+
+	app := kingpin.New("test", "")
+	app.Flag("inwx-username", "").String()
+	app.Flag("new-flag", "").String()
+	app.Command("snapshot", "").Flag("kubeconfig", "").Envar("KUBECONFIG").String()
+	assignEnvars(app)
+
+	warnings := unknownEnvars(app, []string{
+		"INWX_WEBHOOK_USERNAME=current",
+		"INWX_USERNAME=legacy",
+		"INWX_WEBHOOK_OLD_FLAG=deprecated",
+		"KUBECONFIG=/kubeconfig",
+		"PATH=/bin",
+		"INWX_WEBHOOK_USERNAM=typo",
+		"INWX_WEBHOOK_NEWFLAG=typo",
+		"INWX_SOMETHING_ELSE_ENTIRELY=unknown",
+	})
+	assert.Equal(t, []string{
+		"ignoring unknown environment variable INWX_SOMETHING_ELSE_ENTIRELY",
+		"ignoring unknown environment variable INWX_WEBHOOK_NEWFLAG, did you mean INWX_WEBHOOK_NEW_FLAG?",
+		"ignoring unknown environment variable INWX_WEBHOOK_USERNAM, did you mean INWX_WEBHOOK_USERNAME?",
+	}, warnings)
+}
+
+func testApplyLegacyEnvars(t *testing.T) {
+	assignEnvars(kingpin.CommandLine)
+
+	// Every environment variable of the flags before they were moved under the INWX_WEBHOOK_ prefix
+	for _, tt := range []struct {
+		legacy string
+		flag   string
+		envar  string
+	}{
+		{"INWX_LISTEN_ADDRESS", "listen-address", "INWX_WEBHOOK_LISTEN_ADDRESS"},
+		{"INWX_GRPC_LISTEN_ADDRESS", "grpc-listen-address", "INWX_WEBHOOK_GRPC_LISTEN_ADDRESS"},
+		{"INWX_METRICS_LISTEN_ADDRESS", "metrics-listen-address", "INWX_WEBHOOK_METRICS_LISTEN_ADDRESS"},
+		{"INWX_TLS_CONFIG", "tls-config", "INWX_WEBHOOK_TLS_CONFIG"},
+		{"INWX_WEBHOOK_SIGNING_SECRET", "webhook-signing-secret", "INWX_WEBHOOK_SIGNING_SECRET"},
+		{"INWX_WEBHOOK_SIGNATURE_MAX_SKEW", "webhook-signature-max-skew", "INWX_WEBHOOK_SIGNATURE_MAX_SKEW"},
+		{"INWX_WEBHOOK_ALLOWED_CIDR", "webhook-allowed-cidr", "INWX_WEBHOOK_ALLOWED_CIDR"},
+		{"INWX_DOMAIN_FILTER", "domain-filter", "INWX_WEBHOOK_DOMAIN_FILTER"},
+		{"INWX_FILTER", "filter", "INWX_WEBHOOK_FILTER"},
+		{"INWX_SANDBOX", "inwx-sandbox", "INWX_WEBHOOK_SANDBOX"},
+		{"INWX_USERNAME", "inwx-username", "INWX_WEBHOOK_USERNAME"},
+		{"INWX_USERNAME_FILE", "inwx-username-file", "INWX_WEBHOOK_USERNAME_FILE"},
+		{"INWX_PASSWORD", "inwx-password", "INWX_WEBHOOK_PASSWORD"},
+		{"INWX_PASSWORD_FILE", "inwx-password-file", "INWX_WEBHOOK_PASSWORD_FILE"},
+		{"INWX_TOTP_SECRET", "inwx-totp-secret", "INWX_WEBHOOK_TOTP_SECRET"},
+		{"INWX_TOTP_SECRET_FILE", "inwx-totp-secret-file", "INWX_WEBHOOK_TOTP_SECRET_FILE"},
+		{"INWX_CREDENTIALS_RELOAD_INTERVAL", "credentials-reload-interval", "INWX_WEBHOOK_CREDENTIALS_RELOAD_INTERVAL"},
+		{"INWX_ZONE_CONFIG", "zone-config", "INWX_WEBHOOK_ZONE_CONFIG"},
+		{"INWX_ALLOW_APEX_CHANGES", "allow-apex-changes", "INWX_WEBHOOK_ALLOW_APEX_CHANGES"},
+		{"INWX_FREEZE_RECORD", "freeze-record", "INWX_WEBHOOK_FREEZE_RECORD"},
+		{"INWX_REGISTRY", "registry", "INWX_WEBHOOK_REGISTRY"},
+		{"INWX_TXT_PREFIX", "txt-prefix", "INWX_WEBHOOK_TXT_PREFIX"},
+		{"INWX_TXT_SUFFIX", "txt-suffix", "INWX_WEBHOOK_TXT_SUFFIX"},
+		{"INWX_TXT_WILDCARD_REPLACEMENT", "txt-wildcard-replacement", "INWX_WEBHOOK_TXT_WILDCARD_REPLACEMENT"},
+		{"INWX_ATOMIC_OWNERSHIP", "atomic-ownership", "INWX_WEBHOOK_ATOMIC_OWNERSHIP"},
+		{"INWX_CREATE_FIRST", "create-first", "INWX_WEBHOOK_CREATE_FIRST"},
+		{"INWX_CREATE_PTR", "create-ptr", "INWX_WEBHOOK_CREATE_PTR"},
+		{"INWX_IGNORE_LABEL", "ignore-label", "INWX_WEBHOOK_IGNORE_LABEL"},
+		{"INWX_IGNORE_PROPERTY", "ignore-property", "INWX_WEBHOOK_IGNORE_PROPERTY"},
+		{"INWX_FLAP_WINDOW", "flap-window", "INWX_WEBHOOK_FLAP_WINDOW"},
+		{"INWX_FLAP_THRESHOLD", "flap-threshold", "INWX_WEBHOOK_FLAP_THRESHOLD"},
+		{"INWX_HISTORY_SIZE", "history-size", "INWX_WEBHOOK_HISTORY_SIZE"},
+		{"INWX_DUPLICATE_APPLY_WINDOW", "duplicate-apply-window", "INWX_WEBHOOK_DUPLICATE_APPLY_WINDOW"},
+		{"INWX_RECORDS_CACHE_TTL", "records-cache-ttl", "INWX_WEBHOOK_RECORDS_CACHE_TTL"},
+		{"INWX_STALE_RECORDS_MAX_AGE", "stale-records-max-age", "INWX_WEBHOOK_STALE_RECORDS_MAX_AGE"},
+		{"INWX_TRACE_NORMALIZATION", "trace-normalization", "INWX_WEBHOOK_TRACE_NORMALIZATION"},
+		{"INWX_NAME_COLLISION", "name-collision", "INWX_WEBHOOK_NAME_COLLISION"},
+		{"INWX_MAINTENANCE_WINDOW", "maintenance-window", "INWX_WEBHOOK_MAINTENANCE_WINDOW"},
+		{"INWX_FLAG_EMPTY_ZONES_AFTER", "flag-empty-zones-after", "INWX_WEBHOOK_FLAG_EMPTY_ZONES_AFTER"},
+		{"INWX_EMPTY_RECORDS_GUARD", "empty-records-guard", "INWX_WEBHOOK_EMPTY_RECORDS_GUARD"},
+		{"INWX_FEATURE_GATE", "feature-gate", "INWX_WEBHOOK_FEATURE_GATE"},
+		{"INWX_ALLOW_FEATURE_TOGGLING", "allow-feature-toggling", "INWX_WEBHOOK_ALLOW_FEATURE_TOGGLING"},
+		{"INWX_AUDIT_LOG", "audit-log", "INWX_WEBHOOK_AUDIT_LOG"},
+		{"INWX_AUDIT_S3_ENDPOINT", "audit-s3-endpoint", "INWX_WEBHOOK_AUDIT_S3_ENDPOINT"},
+		{"INWX_AUDIT_S3_REGION", "audit-s3-region", "INWX_WEBHOOK_AUDIT_S3_REGION"},
+		{"INWX_AUDIT_S3_PATH_STYLE", "audit-s3-path-style", "INWX_WEBHOOK_AUDIT_S3_PATH_STYLE"},
+		{"INWX_AUDIT_S3_ACCESS_KEY_ID", "audit-s3-access-key-id", "INWX_WEBHOOK_AUDIT_S3_ACCESS_KEY_ID"},
+		{"INWX_AUDIT_S3_SECRET_ACCESS_KEY", "audit-s3-secret-access-key", "INWX_WEBHOOK_AUDIT_S3_SECRET_ACCESS_KEY"},
+		{"INWX_REPORT_DIR", "report-dir", "INWX_WEBHOOK_REPORT_DIR"},
+		{"INWX_REPORT_FORMAT", "report-format", "INWX_WEBHOOK_REPORT_FORMAT"},
+		{"INWX_LOG_DEDUP_WINDOW", "log-dedup-window", "INWX_WEBHOOK_LOG_DEDUP_WINDOW"},
+		{"INWX_REQUEST_TIMEOUT", "request-timeout", "INWX_WEBHOOK_REQUEST_TIMEOUT"},
+		{"INWX_SHUTDOWN_TIMEOUT", "shutdown-timeout", "INWX_WEBHOOK_SHUTDOWN_TIMEOUT"},
+		{"INWX_SLOW_CALL_THRESHOLD", "slow-call-threshold", "INWX_WEBHOOK_SLOW_CALL_THRESHOLD"},
+		{"INWX_CLIENT", "inwx-client", "INWX_WEBHOOK_CLIENT"},
+		{"INWX_API_URL", "inwx-api-url", "INWX_WEBHOOK_API_URL"},
+		{"INWX_PROXY_URL", "inwx-proxy-url", "INWX_WEBHOOK_PROXY_URL"},
+		{"INWX_CA_FILE", "inwx-ca-file", "INWX_WEBHOOK_CA_FILE"},
+		{"INWX_TLS_MIN_VERSION", "inwx-tls-min-version", "INWX_WEBHOOK_TLS_MIN_VERSION"},
+		{"INWX_SESSION", "inwx-session", "INWX_WEBHOOK_SESSION"},
+		{"INWX_SESSION_KEEPALIVE", "inwx-session-keepalive", "INWX_WEBHOOK_SESSION_KEEPALIVE"},
+		{"INWX_EXPECTED_ACCOUNT_ID", "expected-account-id", "INWX_WEBHOOK_EXPECTED_ACCOUNT_ID"},
+		{"INWX_EXPECTED_ENVIRONMENT", "expected-environment", "INWX_WEBHOOK_EXPECTED_ENVIRONMENT"},
+		{"INWX_HEALTHZ_SESSION_CHECK_INTERVAL", "healthz-session-check-interval", "INWX_WEBHOOK_HEALTHZ_SESSION_CHECK_INTERVAL"},
+		{"INWX_MAX_IDLE_CONNS", "inwx-max-idle-conns", "INWX_WEBHOOK_MAX_IDLE_CONNS"},
+		{"INWX_IDLE_CONN_TIMEOUT", "inwx-idle-conn-timeout", "INWX_WEBHOOK_IDLE_CONN_TIMEOUT"},
+	} {
+		t.Run(tt.legacy, func(t *testing.T) {
+			flag := kingpin.CommandLine.GetFlag(tt.flag)
+			require.NotNil(t, flag)
+			assert.Equal(t, tt.envar, flag.Model().Envar)
+
+			t.Setenv(tt.legacy, "legacy")
+			if tt.envar == tt.legacy {
+				// The webhook- flags already had their current name
+				assert.Empty(t, applyLegacyEnvars(kingpin.CommandLine))
+				return
+			}
+			unsetenv(t, tt.envar)
+			assert.Equal(t, []string{"environment variable " + tt.legacy + " is deprecated, use " + tt.envar + " instead"}, applyLegacyEnvars(kingpin.CommandLine))
+			assert.Equal(t, "legacy", os.Getenv(tt.envar))
+
+			// The current name wins when both are set
+			t.Setenv(tt.envar, "current")
+			assert.Len(t, applyLegacyEnvars(kingpin.CommandLine), 1)
+			assert.Equal(t, "current", os.Getenv(tt.envar))
+		})
+	}
+}
+
+func testPrintConfig(t *testing.T) {
+	defer func(pw string, pwFile string, url string, secret string) {
+		*password, *passwordFile, *notifyURL, *signingSecret = pw, pwFile, url, secret
+	}(*password, *passwordFile, *notifyURL, *signingSecret)
+	*password, *passwordFile = "hunter2", "/secrets/password"
+	*notifyURL, *signingSecret = "https://hooks.example.com/T0/B0/token", "s3cret"
+	assignEnvars(kingpin.CommandLine)
+
+	var buf bytes.Buffer
+	printConfig(&buf, kingpin.CommandLine)
+	lines := map[string]string{}
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if name, _, ok := strings.Cut(line, " "); ok {
+			lines[name] = line
+		}
+	}
+	for _, flag := range []string{"--inwx-password", "--notify-url", "--webhook-signing-secret"} {
+		assert.Contains(t, lines[flag], "<redacted>", flag)
+	}
+	assert.Contains(t, lines["--inwx-password-file"], "/secrets/password")
+	assert.Contains(t, lines["--inwx-password"], "INWX_WEBHOOK_PASSWORD")
+	for _, secret := range []string{"hunter2", "token", "s3cret"} {
+		assert.NotContains(t, buf.String(), secret)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	provider "github.com/orbit-online/external-dns-inwx-webhook/provider"
+)
+
+// defaultFlapsLimit is the number of records returned by /debug/flaps without a limit parameter.
+const defaultFlapsLimit = 20
+
+// defaultHistoryLimit is the number of operations returned by /debug/history without a limit parameter.
+const defaultHistoryLimit = 20
+
+// writeJSON writes v as an indented JSON response.
+func writeJSON(w http.ResponseWriter, v any, logger *slog.Logger) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		logger.Error("failed to encode debug response", "error", err.Error())
+	}
+}
+
+// flapsHandler serves the records that changed most often within the flap detection window.
+// The number of records can be limited with ?limit=N; 0 returns all of them.
+func flapsHandler(p *provider.INWXProvider, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, ok := limitParam(w, r, defaultFlapsLimit)
+		if !ok {
+			return
+		}
+		writeJSON(w, p.Flaps(limit), logger)
+	}
+}
+
+// historyHandler serves the most recently applied operations with their changes, newest first. The
+// number of operations can be limited with ?limit=N; 0 returns all of those kept.
+func historyHandler(p *provider.INWXProvider, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, ok := limitParam(w, r, defaultHistoryLimit)
+		if !ok {
+			return
+		}
+		writeJSON(w, p.History(limit), logger)
+	}
+}
+
+// limitParam returns the limit query parameter of r, or def without one. An invalid limit is answered
+// with 400 Bad Request.
+func limitParam(w http.ResponseWriter, r *http.Request, def int) (int, bool) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		http.Error(w, "invalid limit", http.StatusBadRequest)
+		return 0, false
+	}
+	return n, true
+}
+
+// cacheHandler serves the state of the records and zones caches.
+func cacheHandler(p *provider.INWXProvider, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, p.CacheState(), logger)
+	}
+}
+
+// rateLimitHandler serves the state of the per-zone mutation rate limiters.
+func rateLimitHandler(p *provider.INWXProvider, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, p.RateLimits(), logger)
+	}
+}
+
+// featuresHandler serves the state of the experimental features. If allowToggle is set, a feature can be
+// toggled with POST ?name=<feature>&enabled=true|false.
+func featuresHandler(features *provider.Features, allowToggle bool, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			if !allowToggle {
+				http.Error(w, "runtime feature toggling is disabled", http.StatusForbidden)
+				return
+			}
+			name := provider.Feature(r.URL.Query().Get("name"))
+			enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+			if err != nil {
+				http.Error(w, "invalid enabled", http.StatusBadRequest)
+				return
+			}
+			if err := features.Set(name, enabled); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			logger.Info("feature toggled", "feature", name, "enabled", enabled)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, features.List(), logger)
+	}
+}
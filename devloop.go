@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	provider "github.com/orbit-online/external-dns-inwx-webhook/provider"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// devloopPollInterval is how often the devloop command checks the endpoints file for changes.
+const devloopPollInterval = 500 * time.Millisecond
+
+// checkDevloopFlags rejects running the devloop command against the production INWX API, where an empty or
+// truncated endpoints file would change live zones.
+func checkDevloopFlags() error {
+	if len(*devloopMockZones) == 0 && !*sandbox {
+		return errors.New("devloop only runs against an in-memory API with --mock-zone or the INWX sandbox with --inwx-sandbox")
+	}
+	return nil
+}
+
+// runDevloop reconciles the endpoints file against INWX, or the in-memory API, like external-dns would:
+// at start, whenever the file changes, and at least every --interval, until ctx is done. Every diff and
+// the outcome of its changes are printed to out.
+func runDevloop(ctx context.Context, p *provider.INWXProvider, out io.Writer, logger *slog.Logger) error {
+	var modified time.Time
+	var size int64
+	var reconciled time.Time
+	ticker := time.NewTicker(devloopPollInterval)
+	defer ticker.Stop()
+	for {
+		if info, err := os.Stat(*devloopEndpointsFile); err != nil {
+			logger.Error("unable to read endpoints file", "error", err.Error())
+		} else if !info.ModTime().Equal(modified) || info.Size() != size || time.Since(reconciled) >= *devloopInterval {
+			modified, size, reconciled = info.ModTime(), info.Size(), time.Now()
+			if err := reconcileEndpointsFile(ctx, p, out); err != nil {
+				logger.Error("reconcile failed", "error", err.Error())
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcileEndpointsFile plans the changes from the current records to the endpoints of the file, prints
+// them and applies them. Records missing from the file are only deleted with --sync.
+func reconcileEndpointsFile(ctx context.Context, p *provider.INWXProvider, out io.Writer) error {
+	desired, err := readEndpointsFile(*devloopEndpointsFile)
+	if err != nil {
+		return err
+	}
+	if desired, err = p.AdjustEndpoints(desired); err != nil {
+		return err
+	}
+	current, err := p.Records(provider.WithConsistency(ctx, provider.ConsistencyStrong))
+	if err != nil {
+		return fmt.Errorf("unable to read current records: %w", err)
+	}
+	var policy plan.Policy = &plan.UpsertOnlyPolicy{}
+	if *devloopSync {
+		policy = &plan.SyncPolicy{}
+	}
+	changes := (&plan.Plan{
+		Current:        current,
+		Desired:        desired,
+		Policies:       []plan.Policy{policy},
+		ManagedRecords: *devloopRecordTypes,
+	}).Calculate().Changes
+
+	stamp := time.Now().Format(time.TimeOnly)
+	if !changes.HasChanges() {
+		fmt.Fprintf(out, "%s in sync, %d records\n", stamp, len(current))
+		return nil
+	}
+	fmt.Fprintf(out, "%s %d to create, %d to update, %d to delete\n", stamp, len(changes.Create), len(changes.UpdateNew), len(changes.Delete))
+	for _, ep := range changes.Create {
+		fmt.Fprintf(out, "+ %s %s %d %s\n", ep.DNSName, ep.RecordType, ep.RecordTTL, strings.Join(ep.Targets, ","))
+	}
+	for i, ep := range changes.UpdateNew {
+		old := changes.UpdateOld[i]
+		fmt.Fprintf(out, "~ %s %s %d %s -> %d %s\n", ep.DNSName, ep.RecordType, old.RecordTTL, strings.Join(old.Targets, ","),
+			ep.RecordTTL, strings.Join(ep.Targets, ","))
+	}
+	for _, ep := range changes.Delete {
+		fmt.Fprintf(out, "- %s %s %d %s\n", ep.DNSName, ep.RecordType, ep.RecordTTL, strings.Join(ep.Targets, ","))
+	}
+
+	ctx, recorder := provider.WithChangeRecorder(ctx)
+	err = p.ApplyChanges(ctx, changes)
+	for _, change := range recorder.Changes() {
+		detail := ""
+		switch {
+		case change.Skipped != "":
+			detail = ": " + change.Skipped
+		case change.Error != "":
+			detail = ": " + change.Error
+		}
+		fmt.Fprintf(out, "  %-8s %s %s %s %s %s%s\n", change.Outcome, change.Action, change.Zone, change.Name, change.Type, change.Content, detail)
+	}
+	return err
+}
+
+// readEndpointsFile reads a JSON list of endpoints, or the output of the snapshot command.
+func readEndpointsFile(path string) ([]*endpoint.Endpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var snapshot desiredSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return nil, fmt.Errorf("unable to parse endpoints file %s: %w", path, err)
+		}
+		return snapshot.Endpoints, nil
+	}
+	var endpoints []*endpoint.Endpoint
+	if err := json.Unmarshal(data, &endpoints); err != nil {
+		return nil, fmt.Errorf("unable to parse endpoints file %s: %w", path, err)
+	}
+	return endpoints, nil
+}
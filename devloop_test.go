@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	provider "github.com/orbit-online/external-dns-inwx-webhook/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestDevloop(t *testing.T) {
+	t.Run("CheckFlags", testCheckDevloopFlags)
+	t.Run("Reconcile", testReconcileEndpointsFile)
+}
+
+func testCheckDevloopFlags(t *testing.T) {
+	defer func(zones []string, sb bool) { *devloopMockZones, *sandbox = zones, sb }(*devloopMockZones, *sandbox)
+
+	*devloopMockZones, *sandbox = nil, false
+	assert.ErrorContains(t, checkDevloopFlags(), "--inwx-sandbox")
+
+	*sandbox = true
+	assert.NoError(t, checkDevloopFlags())
+
+	*devloopMockZones, *sandbox = []string{"example.com"}, false
+	assert.NoError(t, checkDevloopFlags())
+}
+
+func testReconcileEndpointsFile(t *testing.T) {
+	defer func(file string, types []string, sync bool) {
+		*devloopEndpointsFile, *devloopRecordTypes, *devloopSync = file, types, sync
+	}(*devloopEndpointsFile, *devloopRecordTypes, *devloopSync)
+
+	p := provider.NewINWXProvider(&[]string{"example.com"}, "", "", false, slog.Default(), provider.WithInMemoryAPI("example.com"))
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("foo.example.com", "A", 300, "192.0.2.1"),
+		endpoint.NewEndpointWithTTL("bar.example.com", "A", 300, "192.0.2.2"),
+	}}))
+	records := func() map[string]string {
+		eps, err := p.Records(provider.WithConsistency(context.TODO(), provider.ConsistencyStrong))
+		require.NoError(t, err)
+		byName := map[string]string{}
+		for _, ep := range eps {
+			byName[ep.DNSName] = ep.Targets.String()
+		}
+		return byName
+	}
+
+	*devloopEndpointsFile = filepath.Join(t.TempDir(), "endpoints.json")
+	*devloopRecordTypes = []string{"A", "AAAA", "CNAME"}
+	*devloopSync = false
+	require.NoError(t, os.WriteFile(*devloopEndpointsFile, []byte(`[
+		{"dnsName": "foo.example.com", "recordType": "A", "recordTTL": 300, "targets": ["192.0.2.10"]},
+		{"dnsName": "baz.example.com", "recordType": "A", "recordTTL": 300, "targets": ["192.0.2.3"]}
+	]`), 0o600))
+
+	// Records missing from the file survive
+	require.NoError(t, reconcileEndpointsFile(context.TODO(), p, io.Discard))
+	assert.Equal(t, map[string]string{"foo.example.com": "192.0.2.10", "bar.example.com": "192.0.2.2", "baz.example.com": "192.0.2.3"}, records())
+
+	// An empty file doesn't wipe the zone either
+	require.NoError(t, os.WriteFile(*devloopEndpointsFile, []byte(`[]`), 0o600))
+	require.NoError(t, reconcileEndpointsFile(context.TODO(), p, io.Discard))
+	assert.Len(t, records(), 3)
+
+	// They are deleted with --sync only
+	require.NoError(t, os.WriteFile(*devloopEndpointsFile, []byte(`[
+		{"dnsName": "foo.example.com", "recordType": "A", "recordTTL": 300, "targets": ["192.0.2.10"]}
+	]`), 0o600))
+	*devloopSync = true
+	require.NoError(t, reconcileEndpointsFile(context.TODO(), p, io.Discard))
+	assert.Equal(t, map[string]string{"foo.example.com": "192.0.2.10"}, records())
+}
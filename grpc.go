@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	provider "github.com/orbit-online/external-dns-inwx-webhook/provider"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// grpcServicePath prefixes the paths of the methods of the Webhook service in webhook.proto.
+const grpcServicePath = "/externaldns.webhook.v1.Webhook/"
+
+// maxGRPCMessageSize bounds the size of a request message.
+const maxGRPCMessageSize = 10 << 20
+
+// gRPC status codes, see https://grpc.github.io/grpc/core/md_doc_statuscodes.html.
+const (
+	grpcOK                = 0
+	grpcCanceled          = 1
+	grpcInvalidArgument   = 3
+	grpcDeadlineExceeded  = 4
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+	grpcUnavailable       = 14
+)
+
+// grpcStatus is an error carrying the gRPC status code to end a call with.
+type grpcStatus struct {
+	code    int
+	message string
+}
+
+func (s *grpcStatus) Error() string {
+	return s.message
+}
+
+// statusOf returns the status code and message to end a call failed with err with.
+func statusOf(err error) (int, string) {
+	var status *grpcStatus
+	switch {
+	case err == nil:
+		return grpcOK, ""
+	case errors.As(err, &status):
+		return status.code, status.message
+	case errors.Is(err, provider.ErrShutdown):
+		return grpcUnavailable, err.Error()
+	case errors.Is(err, context.DeadlineExceeded):
+		return grpcDeadlineExceeded, err.Error()
+	case errors.Is(err, context.Canceled):
+		return grpcCanceled, err.Error()
+	default:
+		return grpcInternal, err.Error()
+	}
+}
+
+// grpcHandler serves the Webhook service of webhook.proto, the gRPC variant of the webhook API, for
+// clients preferring typed stubs and streaming over JSON. It speaks the gRPC protocol over HTTP/2 itself
+// and needs no gRPC runtime.
+func grpcHandler(p *provider.INWXProvider, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		contentType := r.Header.Get("Content-Type")
+		if r.Method != http.MethodPost || (contentType != "application/grpc" && contentType != "application/grpc+proto") {
+			http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+			return
+		}
+		ctx := r.Context()
+		if timeout, ok := parseGRPCTimeout(r.Header.Get("Grpc-Timeout")); ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		w.Header().Set("Content-Type", "application/grpc")
+		w.WriteHeader(http.StatusOK)
+		// Send the headers right away, before the status is known; it follows in the trailers.
+		_ = http.NewResponseController(w).Flush()
+		code, message := statusOf(serveGRPC(ctx, w, r, p, logger))
+		w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+		if message != "" {
+			w.Header().Set(http.TrailerPrefix+"Grpc-Message", encodeGRPCMessage(message))
+		}
+	}
+}
+
+// serveGRPC calls the method named by the path of r.
+func serveGRPC(ctx context.Context, w http.ResponseWriter, r *http.Request, p *provider.INWXProvider, logger *slog.Logger) error {
+	method, ok := strings.CutPrefix(r.URL.Path, grpcServicePath)
+	if !ok {
+		return &grpcStatus{grpcUnimplemented, "unknown service " + r.URL.Path}
+	}
+	req, err := readGRPCMessage(r.Body)
+	if err != nil {
+		return err
+	}
+
+	switch method {
+	case "Negotiate":
+		data, err := json.Marshal(p.GetDomainFilter())
+		if err != nil {
+			return err
+		}
+		var filter struct {
+			Include      []string `json:"include"`
+			Exclude      []string `json:"exclude"`
+			RegexInclude string   `json:"regexInclude"`
+			RegexExclude string   `json:"regexExclude"`
+		}
+		if err := json.Unmarshal(data, &filter); err != nil {
+			return err
+		}
+		var resp []byte
+		resp = appendStrings(resp, 1, filter.Include)
+		resp = appendStrings(resp, 2, filter.Exclude)
+		resp = appendString(resp, 3, filter.RegexInclude)
+		resp = appendString(resp, 4, filter.RegexExclude)
+		return writeGRPCMessage(w, resp)
+
+	case "Records":
+		var name string
+		if err := walkFields(req, func(num protowire.Number, typ protowire.Type, data []byte, _ uint64) error {
+			if num == 1 && typ == protowire.BytesType {
+				name = string(data)
+			}
+			return nil
+		}); err != nil {
+			return &grpcStatus{grpcInvalidArgument, "invalid request: " + err.Error()}
+		}
+		consistency, err := provider.ParseConsistency(name)
+		if err != nil {
+			return &grpcStatus{grpcInvalidArgument, err.Error()}
+		}
+		records, err := p.Records(provider.WithConsistency(ctx, consistency))
+		if err != nil {
+			logger.Error("failed to get records", "error", err.Error())
+			return err
+		}
+		for _, record := range records {
+			if err := writeGRPCMessage(w, appendEndpoint(nil, record)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "ApplyChanges":
+		changes, err := consumeChanges(req)
+		if err != nil {
+			return &grpcStatus{grpcInvalidArgument, "invalid changes: " + err.Error()}
+		}
+		ctx, recorder := provider.WithChangeRecorder(ctx)
+		err = p.ApplyChanges(ctx, changes)
+		ids := recorder.IDs()
+		if len(ids) > 0 {
+			w.Header().Set(http.TrailerPrefix+changeIDsHeader, strings.Join(ids, ","))
+			logger.Info("applied changes", "change_ids", strings.Join(ids, ","), "trace_id", provider.TraceID(ctx))
+		}
+		if err != nil {
+			logger.Error("failed to apply changes", "error", err.Error())
+			return err
+		}
+		return writeGRPCMessage(w, appendStrings(nil, 1, ids))
+
+	case "AdjustEndpoints":
+		eps, err := consumeEndpoints(req, 1)
+		if err != nil {
+			return &grpcStatus{grpcInvalidArgument, "invalid endpoints: " + err.Error()}
+		}
+		adjusted, err := p.AdjustEndpoints(eps)
+		if err != nil {
+			logger.Error("failed to adjust endpoints", "error", err.Error())
+			return err
+		}
+		return writeGRPCMessage(w, appendEndpoints(nil, 1, adjusted))
+
+	default:
+		return &grpcStatus{grpcUnimplemented, "unknown method " + method}
+	}
+}
+
+// readGRPCMessage reads the single, uncompressed request message of a unary or server-streaming call.
+func readGRPCMessage(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, &grpcStatus{grpcInvalidArgument, "unable to read request message: " + err.Error()}
+	}
+	if prefix[0] != 0 {
+		return nil, &grpcStatus{grpcUnimplemented, "compressed messages are not supported"}
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > maxGRPCMessageSize {
+		return nil, &grpcStatus{grpcResourceExhausted, fmt.Sprintf("request message larger than %d bytes", maxGRPCMessageSize)}
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, &grpcStatus{grpcInvalidArgument, "unable to read request message: " + err.Error()}
+	}
+	return msg, nil
+}
+
+// writeGRPCMessage writes and flushes a response message, so that streamed messages reach the client
+// right away.
+func writeGRPCMessage(w http.ResponseWriter, msg []byte) error {
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	if _, err := w.Write(append(frame, msg...)); err != nil {
+		return err
+	}
+	return http.NewResponseController(w).Flush()
+}
+
+// parseGRPCTimeout parses the value of a grpc-timeout header, e.g. 100m for 100 milliseconds.
+func parseGRPCTimeout(value string) (time.Duration, bool) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, false
+	}
+	units := map[byte]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second, 'm': time.Millisecond, 'u': time.Microsecond, 'n': time.Nanosecond}
+	unit, ok := units[value[len(value)-1]]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(value[:len(value)-1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// encodeGRPCMessage percent-encodes a status message for the grpc-message trailer.
+func encodeGRPCMessage(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		if c := message[i]; c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
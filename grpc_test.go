@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	provider "github.com/orbit-online/external-dns-inwx-webhook/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+func TestGRPC(t *testing.T) {
+	t.Run("Wire", testGRPCWire)
+	t.Run("Timeout", testParseGRPCTimeout)
+	t.Run("Message", testEncodeGRPCMessage)
+	t.Run("Handler", testGRPCHandler)
+}
+
+// webhookDescriptor describes the messages of webhook.proto the codec is checked against.
+func webhookDescriptor(t *testing.T) protoreflect.FileDescriptor {
+	field := func(name string, num int32, label descriptorpb.FieldDescriptorProto_Label, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{Name: proto.String(name), JsonName: proto.String(name), Number: proto.Int32(num), Label: label.Enum(), Type: typ.Enum()}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	const (
+		optional  = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		repeated  = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+		str       = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		int64Type = descriptorpb.FieldDescriptorProto_TYPE_INT64
+		message   = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	)
+	endpointType := ".externaldns.webhook.v1.Endpoint"
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("webhook.proto"),
+		Package: proto.String("externaldns.webhook.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("ProviderSpecificProperty"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, optional, str, ""), field("value", 2, optional, str, ""),
+			}},
+			{Name: proto.String("Endpoint"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("dns_name", 1, optional, str, ""),
+				field("targets", 2, repeated, str, ""),
+				field("record_type", 3, optional, str, ""),
+				field("set_identifier", 4, optional, str, ""),
+				field("record_ttl", 5, optional, int64Type, ""),
+				field("labels", 6, repeated, message, endpointType+".LabelsEntry"),
+				field("provider_specific", 7, repeated, message, ".externaldns.webhook.v1.ProviderSpecificProperty"),
+			}, NestedType: []*descriptorpb.DescriptorProto{
+				{Name: proto.String("LabelsEntry"), Field: []*descriptorpb.FieldDescriptorProto{
+					field("key", 1, optional, str, ""), field("value", 2, optional, str, ""),
+				}, Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)}},
+			}},
+			{Name: proto.String("Changes"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("create", 1, repeated, message, endpointType),
+				field("update_old", 2, repeated, message, endpointType),
+				field("update_new", 3, repeated, message, endpointType),
+				field("delete", 4, repeated, message, endpointType),
+			}},
+		},
+	}, nil)
+	require.NoError(t, err)
+	return file
+}
+
+func testGRPCWire(t *testing.T) {
+	file := webhookDescriptor(t)
+	ep := &endpoint.Endpoint{
+		DNSName:          "www.example.com",
+		Targets:          endpoint.Targets{"192.0.2.1", "192.0.2.2"},
+		RecordType:       "A",
+		SetIdentifier:    "blue",
+		RecordTTL:        300,
+		Labels:           endpoint.Labels{"owner": "default", "resource": "ingress/default/web"},
+		ProviderSpecific: endpoint.ProviderSpecific{{Name: "inwx/ignore", Value: "true"}},
+	}
+
+	// The encoded endpoint is what the protobuf runtime reads from webhook.proto
+	decoded := dynamicpb.NewMessage(file.Messages().ByName("Endpoint"))
+	require.NoError(t, proto.Unmarshal(appendEndpoint(nil, ep), decoded))
+	fields := decoded.Descriptor().Fields()
+	assert.Equal(t, "www.example.com", decoded.Get(fields.ByName("dns_name")).String())
+	assert.Equal(t, 2, decoded.Get(fields.ByName("targets")).List().Len())
+	assert.Equal(t, int64(300), decoded.Get(fields.ByName("record_ttl")).Int())
+	assert.Equal(t, "default", decoded.Get(fields.ByName("labels")).Map().Get(protoreflect.ValueOfString("owner").MapKey()).String())
+	assert.Equal(t, 1, decoded.Get(fields.ByName("provider_specific")).List().Len())
+
+	// and what the runtime writes decodes back to the same endpoint
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(decoded)
+	require.NoError(t, err)
+	roundTripped, err := consumeEndpoint(data)
+	require.NoError(t, err)
+	assert.Equal(t, ep, roundTripped)
+
+	// Changes decode into their lists; updates must pair up
+	changes := dynamicpb.NewMessage(file.Messages().ByName("Changes"))
+	changeFields := changes.Descriptor().Fields()
+	for _, name := range []protoreflect.Name{"create", "update_old", "update_new", "delete"} {
+		changes.Mutable(changeFields.ByName(name)).List().Append(protoreflect.ValueOfMessage(decoded))
+	}
+	data, err = proto.Marshal(changes)
+	require.NoError(t, err)
+	consumed, err := consumeChanges(data)
+	require.NoError(t, err)
+	for _, list := range [][]*endpoint.Endpoint{consumed.Create, consumed.UpdateOld, consumed.UpdateNew, consumed.Delete} {
+		require.Len(t, list, 1)
+		assert.Equal(t, ep, list[0])
+	}
+	changes.Mutable(changeFields.ByName("update_new")).List().Append(protoreflect.ValueOfMessage(decoded))
+	data, err = proto.Marshal(changes)
+	require.NoError(t, err)
+	_, err = consumeChanges(data)
+	assert.ErrorContains(t, err, "don't pair up")
+
+	// Truncated messages are rejected
+	_, err = consumeEndpoint(appendEndpoint(nil, ep)[:5])
+	assert.Error(t, err)
+}
+
+func testParseGRPCTimeout(t *testing.T) {
+	for value, expected := range map[string]time.Duration{
+		"1H":       time.Hour,
+		"2M":       2 * time.Minute,
+		"30S":      30 * time.Second,
+		"100m":     100 * time.Millisecond,
+		"5u":       5 * time.Microsecond,
+		"7n":       7 * time.Nanosecond,
+		"99999999": 0, // no unit
+	} {
+		timeout, ok := parseGRPCTimeout(value)
+		assert.Equal(t, expected != 0, ok, value)
+		assert.Equal(t, expected, timeout, value)
+	}
+	for _, value := range []string{"", "S", "1x", "-1S", "1.5S", "123456789S"} {
+		_, ok := parseGRPCTimeout(value)
+		assert.False(t, ok, value)
+	}
+}
+
+func testEncodeGRPCMessage(t *testing.T) {
+	assert.Equal(t, "invalid changes", encodeGRPCMessage("invalid changes"))
+	assert.Equal(t, "100%25 done%0Anext", encodeGRPCMessage("100% done\nnext"))
+	assert.Equal(t, "caf%C3%A9", encodeGRPCMessage("café"))
+}
+
+// grpcCall is the outcome of a gRPC call: the response messages and the status of the trailers.
+type grpcCall struct {
+	messages [][]byte
+	status   int
+	message  string
+	trailer  http.Header
+}
+
+func testGRPCHandler(t *testing.T) {
+	p := provider.NewINWXProvider(&[]string{"example.com"}, "", "", false, slog.Default(), provider.WithInMemoryAPI("example.com"))
+	server := httptest.NewUnstartedServer(grpcHandler(p, slog.Default()))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	call := func(method string, flag byte, msg []byte, header http.Header) grpcCall {
+		frame := make([]byte, 5, 5+len(msg))
+		frame[0] = flag
+		binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+		req, err := http.NewRequestWithContext(context.TODO(), http.MethodPost, server.URL+grpcServicePath+method, bytes.NewReader(append(frame, msg...)))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/grpc")
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		resp, err := server.Client().Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, 2, resp.ProtoMajor, "gRPC is served over HTTP/2")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		var result grpcCall
+		for len(body) > 0 {
+			require.GreaterOrEqual(t, len(body), 5)
+			require.Zero(t, body[0], "responses are uncompressed")
+			size := int(binary.BigEndian.Uint32(body[1:5]))
+			require.GreaterOrEqual(t, len(body), 5+size)
+			result.messages = append(result.messages, body[5:5+size])
+			body = body[5+size:]
+		}
+		result.status, err = strconv.Atoi(resp.Trailer.Get("Grpc-Status"))
+		require.NoError(t, err)
+		result.message = resp.Trailer.Get("Grpc-Message")
+		result.trailer = resp.Trailer
+		return result
+	}
+	stringFields := func(msg []byte, num protowire.Number) []string {
+		var values []string
+		require.NoError(t, walkFields(msg, func(n protowire.Number, typ protowire.Type, data []byte, _ uint64) error {
+			if n == num && typ == protowire.BytesType {
+				values = append(values, string(data))
+			}
+			return nil
+		}))
+		return values
+	}
+	a := func(name string, target string) *endpoint.Endpoint {
+		return endpoint.NewEndpointWithTTL(name, "A", 300, target)
+	}
+
+	t.Run("Negotiate", func(t *testing.T) {
+		result := call("Negotiate", 0, nil, nil)
+		assert.Equal(t, grpcOK, result.status)
+		assert.Len(t, result.messages, 1)
+	})
+
+	t.Run("ApplyChanges", func(t *testing.T) {
+		var changes []byte
+		changes = appendBytes(changes, 1, appendEndpoint(nil, a("foo.example.com", "192.0.2.1")))
+		changes = appendBytes(changes, 1, appendEndpoint(nil, a("bar.example.com", "192.0.2.2")))
+		result := call("ApplyChanges", 0, changes, nil)
+		require.Equal(t, grpcOK, result.status, result.message)
+		require.Len(t, result.messages, 1)
+		ids := stringFields(result.messages[0], 1)
+		assert.Len(t, ids, 2)
+		assert.Equal(t, ids[0]+","+ids[1], result.trailer.Get(changeIDsHeader))
+	})
+
+	t.Run("Records", func(t *testing.T) {
+		// Every record is streamed in a message of its own
+		result := call("Records", 0, appendString(nil, 1, "strong"), nil)
+		require.Equal(t, grpcOK, result.status, result.message)
+		var names []string
+		for _, msg := range result.messages {
+			ep, err := consumeEndpoint(msg)
+			require.NoError(t, err)
+			names = append(names, ep.DNSName)
+		}
+		assert.ElementsMatch(t, []string{"foo.example.com", "bar.example.com"}, names)
+
+		result = call("Records", 0, appendString(nil, 1, "eventual"), nil)
+		assert.Equal(t, grpcInvalidArgument, result.status)
+	})
+
+	t.Run("AdjustEndpoints", func(t *testing.T) {
+		result := call("AdjustEndpoints", 0, appendBytes(nil, 1, appendEndpoint(nil, a("baz.example.com", "192.0.2.3"))), nil)
+		require.Equal(t, grpcOK, result.status, result.message)
+		require.Len(t, result.messages, 1)
+		eps, err := consumeEndpoints(result.messages[0], 1)
+		require.NoError(t, err)
+		require.Len(t, eps, 1)
+		assert.Equal(t, "baz.example.com", eps[0].DNSName)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		// Unpaired updates are rejected before they reach the provider
+		unpaired := appendBytes(nil, 2, appendEndpoint(nil, a("foo.example.com", "192.0.2.1")))
+		result := call("ApplyChanges", 0, unpaired, nil)
+		assert.Equal(t, grpcInvalidArgument, result.status)
+		assert.Contains(t, result.message, "update_new")
+
+		assert.Equal(t, grpcUnimplemented, call("Delete", 0, nil, nil).status)
+		assert.Equal(t, grpcUnimplemented, call("Negotiate", 1, nil, nil).status)
+		assert.Equal(t, grpcInvalidArgument, call("AdjustEndpoints", 0, []byte{0x0a, 0x05}, nil).status)
+
+		// An expired grpc-timeout ends the call with DEADLINE_EXCEEDED
+		result = call("Records", 0, appendString(nil, 1, "strong"), http.Header{"Grpc-Timeout": {"1n"}})
+		assert.Equal(t, grpcDeadlineExceeded, result.status, result.message)
+
+		resp, err := server.Client().Post(server.URL+grpcServicePath+"Negotiate", "application/json", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+	})
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// The messages of webhook.proto are encoded by hand with protowire, so that the handful of types doesn't
+// need generated code.
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return appendBytes(b, num, []byte(s))
+}
+
+func appendStrings(b []byte, num protowire.Number, ss []string) []byte {
+	for _, s := range ss {
+		b = appendBytes(b, num, []byte(s))
+	}
+	return b
+}
+
+func appendBytes(b []byte, num protowire.Number, data []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, data)
+}
+
+func appendEndpoint(b []byte, ep *endpoint.Endpoint) []byte {
+	b = appendString(b, 1, ep.DNSName)
+	b = appendStrings(b, 2, ep.Targets)
+	b = appendString(b, 3, ep.RecordType)
+	b = appendString(b, 4, ep.SetIdentifier)
+	if ep.RecordTTL != 0 {
+		b = protowire.AppendTag(b, 5, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(ep.RecordTTL))
+	}
+	for _, key := range slices.Sorted(maps.Keys(ep.Labels)) {
+		b = appendBytes(b, 6, appendString(appendString(nil, 1, key), 2, ep.Labels[key]))
+	}
+	for _, property := range ep.ProviderSpecific {
+		b = appendBytes(b, 7, appendString(appendString(nil, 1, property.Name), 2, property.Value))
+	}
+	return b
+}
+
+func appendEndpoints(b []byte, num protowire.Number, eps []*endpoint.Endpoint) []byte {
+	for _, ep := range eps {
+		b = appendBytes(b, num, appendEndpoint(nil, ep))
+	}
+	return b
+}
+
+// walkFields calls field for every length-delimited and varint field of the message b, passing the
+// contents of the former and the value of the latter. Fields of other wire types are skipped.
+func walkFields(b []byte, field func(num protowire.Number, typ protowire.Type, data []byte, v uint64) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		var data []byte
+		var v uint64
+		switch typ {
+		case protowire.BytesType:
+			data, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if typ != protowire.BytesType && typ != protowire.VarintType {
+			continue
+		}
+		if err := field(num, typ, data, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// consumePair decodes a message of two string fields, as used for map entries and provider-specific
+// properties.
+func consumePair(b []byte) (string, string, error) {
+	var first, second string
+	err := walkFields(b, func(num protowire.Number, typ protowire.Type, data []byte, _ uint64) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			first = string(data)
+		case num == 2 && typ == protowire.BytesType:
+			second = string(data)
+		}
+		return nil
+	})
+	return first, second, err
+}
+
+func consumeEndpoint(b []byte) (*endpoint.Endpoint, error) {
+	ep := &endpoint.Endpoint{}
+	err := walkFields(b, func(num protowire.Number, typ protowire.Type, data []byte, v uint64) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			ep.DNSName = string(data)
+		case num == 2 && typ == protowire.BytesType:
+			ep.Targets = append(ep.Targets, string(data))
+		case num == 3 && typ == protowire.BytesType:
+			ep.RecordType = string(data)
+		case num == 4 && typ == protowire.BytesType:
+			ep.SetIdentifier = string(data)
+		case num == 5 && typ == protowire.VarintType:
+			ep.RecordTTL = endpoint.TTL(int64(v))
+		case num == 6 && typ == protowire.BytesType:
+			key, value, err := consumePair(data)
+			if err != nil {
+				return err
+			}
+			if ep.Labels == nil {
+				ep.Labels = endpoint.Labels{}
+			}
+			ep.Labels[key] = value
+		case num == 7 && typ == protowire.BytesType:
+			name, value, err := consumePair(data)
+			if err != nil {
+				return err
+			}
+			ep.ProviderSpecific = append(ep.ProviderSpecific, endpoint.ProviderSpecificProperty{Name: name, Value: value})
+		}
+		return nil
+	})
+	return ep, err
+}
+
+// consumeEndpoints decodes the endpoints in field num of the message b, e.g. of an
+// AdjustEndpointsRequest.
+func consumeEndpoints(b []byte, num protowire.Number) ([]*endpoint.Endpoint, error) {
+	var eps []*endpoint.Endpoint
+	err := walkFields(b, func(n protowire.Number, typ protowire.Type, data []byte, _ uint64) error {
+		if n != num || typ != protowire.BytesType {
+			return nil
+		}
+		ep, err := consumeEndpoint(data)
+		eps = append(eps, ep)
+		return err
+	})
+	return eps, err
+}
+
+// consumeChanges decodes a Changes message. Every update must come as a pair of old and new endpoint.
+func consumeChanges(b []byte) (*plan.Changes, error) {
+	changes := &plan.Changes{}
+	lists := map[protowire.Number]*[]*endpoint.Endpoint{
+		1: &changes.Create,
+		2: &changes.UpdateOld,
+		3: &changes.UpdateNew,
+		4: &changes.Delete,
+	}
+	err := walkFields(b, func(num protowire.Number, typ protowire.Type, data []byte, _ uint64) error {
+		list, ok := lists[num]
+		if !ok || typ != protowire.BytesType {
+			return nil
+		}
+		ep, err := consumeEndpoint(data)
+		*list = append(*list, ep)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(changes.UpdateOld) != len(changes.UpdateNew) {
+		return nil, fmt.Errorf("%d update_old endpoints don't pair up with %d update_new endpoints", len(changes.UpdateOld), len(changes.UpdateNew))
+	}
+	return changes, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	provider "github.com/orbit-online/external-dns-inwx-webhook/provider"
+)
+
+// healthHeader carries the health state of the provider in the negotiation response, so that external-dns
+// logs and proxies see it without querying the metrics server.
+const healthHeader = "X-Inwx-Health"
+
+// recordTypesHeader lists the record types the provider supports in the negotiation response.
+const recordTypesHeader = "X-Inwx-Record-Types"
+
+// livenessHandler serves /healthz: the process is alive until the provider is shut down and, with
+// checkSession, while it can log in to INWX, so that invalid credentials or an INWX outage fail the probe
+// instead of stale records being served silently. The login result is cached by the provider.
+func livenessHandler(p *provider.INWXProvider, checkSession bool, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !p.Health().Alive() {
+			http.Error(w, provider.ErrShutdown.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if checkSession {
+			if err := p.CheckSession(r.Context()); err != nil {
+				logger.Warn("liveness probe failed", "error", err.Error())
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(http.StatusText(http.StatusOK)))
+	}
+}
+
+// readinessHandler serves /readyz: ready unless the provider is failing, with the state as the body.
+func readinessHandler(p *provider.INWXProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		health := p.Health()
+		if !health.Ready() {
+			http.Error(w, string(health.State), http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(health.State))
+	}
+}
+
+// statusHandler serves /status: the health of the provider and every component as JSON.
+func statusHandler(p *provider.INWXProvider, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, p.Health(), logger)
+	}
+}
+
+// negotiateHandler adds the health state of the provider and the record types it supports to the
+// negotiation response.
+func negotiateHandler(p *provider.INWXProvider, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(healthHeader, string(p.Health().State))
+		w.Header().Set(recordTypesHeader, strings.Join(provider.SupportedRecordTypes(), ","))
+		next(w, r)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxDedupEntries bounds the number of distinct errors tracked by a dedupHandler.
+const maxDedupEntries = 1024
+
+// dedupHandler suppresses identical recurring error records exponentially: the 1st, 2nd, 4th,
+// 8th, ... occurrence is passed through, annotated with the total number of occurrences and how
+// many were suppressed since the last one, but at least one occurrence per window, so that a
+// persistent error keeps showing up. An error not seen for the whole window starts over; the
+// occurrences suppressed since it was passed through last are then summarized in a record of its
+// own, as are those of errors dropped to bound the memory use.
+type dedupHandler struct {
+	next   slog.Handler
+	window time.Duration
+	state  *dedupState
+	scope  string
+}
+
+type dedupState struct {
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+	// nextSweep is when expired entries are looked for next.
+	nextSweep time.Time
+}
+
+type dedupEntry struct {
+	count    int
+	emitAt   int
+	emitted  int
+	last     time.Time
+	lastEmit time.Time
+	// record and next are the last occurrence and the handler it was meant for, to summarize the
+	// suppressed occurrences with.
+	record slog.Record
+	next   slog.Handler
+}
+
+func newDedupHandler(next slog.Handler, window time.Duration) *dedupHandler {
+	return &dedupHandler{
+		next:   next,
+		window: window,
+		state:  &dedupState{entries: map[string]*dedupEntry{}},
+	}
+}
+
+func (h *dedupHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *dedupHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelError {
+		// Other records only drive the summaries of errors that stopped recurring.
+		h.summarize(ctx, h.state.sweep(r.Time, h.window), r.Time)
+		return h.next.Handle(ctx, r)
+	}
+
+	var key strings.Builder
+	key.WriteString(h.scope)
+	key.WriteString(r.Message)
+	r.Attrs(func(a slog.Attr) bool {
+		fmt.Fprintf(&key, " %s=%v", a.Key, a.Value)
+		return true
+	})
+
+	count, suppressed, emit, expired := h.state.observe(key.String(), r, h.next, h.window)
+	h.summarize(ctx, expired, r.Time)
+	if !emit {
+		return nil
+	}
+	if count > 1 {
+		r = r.Clone()
+		r.AddAttrs(slog.Int("occurrences", count), slog.Int("suppressed", suppressed))
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// summarize emits a record for each of entries, which were dropped with suppressed occurrences: its last
+// occurrence, annotated with the total number of occurrences, how many were suppressed since it was
+// passed through last, and when it was seen last.
+func (h *dedupHandler) summarize(ctx context.Context, entries []*dedupEntry, now time.Time) {
+	for _, e := range entries {
+		r := e.record.Clone()
+		r.Time = now
+		r.AddAttrs(slog.Int("occurrences", e.count), slog.Int("suppressed", e.count-e.emitted), slog.Time("last_seen", e.last))
+		_ = e.next.Handle(ctx, r)
+	}
+}
+
+// observe registers the occurrence r of key, meant for next, and reports whether it should be emitted,
+// along with the entries dropped meanwhile that have suppressed occurrences to summarize.
+func (s *dedupState) observe(key string, r slog.Record, next slog.Handler, window time.Duration) (count int, suppressed int, emit bool, dropped []*dedupEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := r.Time
+	dropped = s.sweepLocked(now, window)
+	e, ok := s.entries[key]
+	if ok && now.Sub(e.last) > window {
+		delete(s.entries, key)
+		dropped = appendPending(dropped, e)
+		ok = false
+	}
+	if !ok {
+		if len(s.entries) >= maxDedupEntries {
+			dropped = append(dropped, s.prune(now, window)...)
+		}
+		e = &dedupEntry{emitAt: 1}
+		s.entries[key] = e
+	}
+	e.count++
+	e.last = now
+	e.record, e.next = r.Clone(), next
+	if e.count < e.emitAt && now.Sub(e.lastEmit) < window {
+		return e.count, 0, false, dropped
+	}
+	suppressed = e.count - e.emitted - 1
+	e.emitted = e.count
+	e.lastEmit = now
+	for e.emitAt <= e.count {
+		e.emitAt *= 2
+	}
+	return e.count, suppressed, true, dropped
+}
+
+// sweep drops the entries not seen for the whole window, at most every quarter of the window, returning
+// those with suppressed occurrences to summarize.
+func (s *dedupState) sweep(now time.Time, window time.Duration) []*dedupEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sweepLocked(now, window)
+}
+
+func (s *dedupState) sweepLocked(now time.Time, window time.Duration) []*dedupEntry {
+	if now.Before(s.nextSweep) {
+		return nil
+	}
+	s.nextSweep = now.Add(window / 4)
+	var dropped []*dedupEntry
+	for key, e := range s.entries {
+		if now.Sub(e.last) > window {
+			delete(s.entries, key)
+			dropped = appendPending(dropped, e)
+		}
+	}
+	return dropped
+}
+
+// prune drops expired entries, or everything if the table is still full afterwards, returning those
+// with suppressed occurrences to summarize.
+func (s *dedupState) prune(now time.Time, window time.Duration) []*dedupEntry {
+	var dropped []*dedupEntry
+	for key, e := range s.entries {
+		if now.Sub(e.last) > window {
+			delete(s.entries, key)
+			dropped = appendPending(dropped, e)
+		}
+	}
+	if len(s.entries) >= maxDedupEntries {
+		for _, e := range s.entries {
+			dropped = appendPending(dropped, e)
+		}
+		clear(s.entries)
+	}
+	return dropped
+}
+
+// appendPending appends e to entries if it has suppressed occurrences.
+func appendPending(entries []*dedupEntry, e *dedupEntry) []*dedupEntry {
+	if e.count > e.emitted {
+		return append(entries, e)
+	}
+	return entries
+}
+
+func (h *dedupHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	scope := h.scope
+	for _, a := range attrs {
+		scope += fmt.Sprintf("%s=%v ", a.Key, a.Value)
+	}
+	return &dedupHandler{next: h.next.WithAttrs(attrs), window: h.window, state: h.state, scope: scope}
+}
+
+func (h *dedupHandler) WithGroup(name string) slog.Handler {
+	return &dedupHandler{next: h.next.WithGroup(name), window: h.window, state: h.state, scope: h.scope + name + "."}
+}
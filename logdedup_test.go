@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingHandler keeps the records it handles, with the attributes it was given through WithAttrs.
+type recordingHandler struct {
+	mu      *sync.Mutex
+	records *[]slog.Record
+	attrs   []slog.Attr
+}
+
+func newRecordingHandler() recordingHandler {
+	return recordingHandler{mu: &sync.Mutex{}, records: &[]slog.Record{}}
+}
+
+func (h recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r = r.Clone()
+	r.AddAttrs(h.attrs...)
+	*h.records = append(*h.records, r)
+	return nil
+}
+
+func (h recordingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return recordingHandler{mu: h.mu, records: h.records, attrs: append(append([]slog.Attr{}, h.attrs...), attrs...)}
+}
+
+func (h recordingHandler) WithGroup(string) slog.Handler { return h }
+
+func (h recordingHandler) take() []slog.Record {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	records := *h.records
+	*h.records = nil
+	return records
+}
+
+// attrsOf returns the attributes of r as strings.
+func attrsOf(r slog.Record) map[string]string {
+	attrs := map[string]string{}
+	r.Attrs(func(a slog.Attr) bool {
+		attrs[a.Key] = a.Value.String()
+		return true
+	})
+	return attrs
+}
+
+func TestDedupHandler(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	record := func(at time.Time, level slog.Level, msg string, attrs ...slog.Attr) slog.Record {
+		r := slog.NewRecord(at, level, msg, 0)
+		r.AddAttrs(attrs...)
+		return r
+	}
+
+	t.Run("Exponential", func(t *testing.T) {
+		rec := newRecordingHandler()
+		h := newDedupHandler(rec, 10*time.Minute)
+		for i := range 10 {
+			require.NoError(t, h.Handle(context.TODO(), record(start.Add(time.Duration(i)*time.Second), slog.LevelError, "failed", slog.String("zone", "example.com"))))
+		}
+		records := rec.take()
+		require.Len(t, records, 4)
+		var occurrences, suppressed []string
+		for _, r := range records {
+			occurrences = append(occurrences, attrsOf(r)["occurrences"])
+			suppressed = append(suppressed, attrsOf(r)["suppressed"])
+		}
+		assert.Equal(t, []string{"", "2", "4", "8"}, occurrences)
+		assert.Equal(t, []string{"", "0", "1", "3"}, suppressed)
+
+		// Other levels and other errors are not suppressed
+		for range 3 {
+			require.NoError(t, h.Handle(context.TODO(), record(start.Add(11*time.Second), slog.LevelWarn, "failed", slog.String("zone", "example.com"))))
+		}
+		require.NoError(t, h.Handle(context.TODO(), record(start.Add(11*time.Second), slog.LevelError, "failed", slog.String("zone", "example.org"))))
+		assert.Len(t, rec.take(), 4)
+	})
+
+	t.Run("AtLeastOncePerWindow", func(t *testing.T) {
+		// An error recurring every 30s shows up at least every window, also after a day
+		rec := newRecordingHandler()
+		h := newDedupHandler(rec, 10*time.Minute)
+		var emitted []time.Time
+		for i := range 24 * 60 * 2 {
+			at := start.Add(time.Duration(i) * 30 * time.Second)
+			require.NoError(t, h.Handle(context.TODO(), record(at, slog.LevelError, "failed")))
+			for _, r := range rec.take() {
+				emitted = append(emitted, r.Time)
+			}
+		}
+		require.NotEmpty(t, emitted)
+		for i := 1; i < len(emitted); i++ {
+			assert.LessOrEqual(t, emitted[i].Sub(emitted[i-1]), 10*time.Minute, emitted[i])
+		}
+		assert.LessOrEqual(t, start.Add(24*time.Hour).Sub(emitted[len(emitted)-1]), 10*time.Minute)
+	})
+
+	t.Run("SummaryOnExpiry", func(t *testing.T) {
+		rec := newRecordingHandler()
+		h := newDedupHandler(rec, 10*time.Minute).WithAttrs([]slog.Attr{slog.String("component", "provider")})
+		for i := range 5 {
+			require.NoError(t, h.Handle(context.TODO(), record(start.Add(time.Duration(i)*time.Second), slog.LevelError, "failed")))
+		}
+		assert.Len(t, rec.take(), 3)
+
+		// Once the error stopped, the next record of any level flushes the suppressed occurrence
+		later := start.Add(20 * time.Minute)
+		require.NoError(t, h.Handle(context.TODO(), record(later, slog.LevelInfo, "reconciled")))
+		records := rec.take()
+		require.Len(t, records, 2)
+		assert.Equal(t, "failed", records[0].Message)
+		assert.Equal(t, later, records[0].Time)
+		attrs := attrsOf(records[0])
+		assert.Equal(t, "5", attrs["occurrences"])
+		assert.Equal(t, "1", attrs["suppressed"])
+		assert.Equal(t, start.Add(4*time.Second).String(), attrs["last_seen"])
+		assert.Equal(t, "provider", attrs["component"], "summaries go through the handler of the error")
+		assert.Equal(t, "reconciled", records[1].Message)
+
+		// Nothing is left to summarize, and a recurrence starts over
+		require.NoError(t, h.Handle(context.TODO(), record(later.Add(time.Hour), slog.LevelInfo, "reconciled")))
+		require.NoError(t, h.Handle(context.TODO(), record(later.Add(time.Hour), slog.LevelError, "failed")))
+		records = rec.take()
+		require.Len(t, records, 2)
+		assert.Empty(t, attrsOf(records[1])["occurrences"])
+	})
+
+	t.Run("SummaryOnRecurrence", func(t *testing.T) {
+		// An error recurring after the window summarizes its earlier occurrences before starting over
+		rec := newRecordingHandler()
+		h := newDedupHandler(rec, 10*time.Minute)
+		for range 3 {
+			require.NoError(t, h.Handle(context.TODO(), record(start, slog.LevelError, "failed")))
+		}
+		rec.take()
+		require.NoError(t, h.Handle(context.TODO(), record(start.Add(time.Hour), slog.LevelError, "failed")))
+		records := rec.take()
+		require.Len(t, records, 2)
+		assert.Equal(t, "3", attrsOf(records[0])["occurrences"])
+		assert.Equal(t, "1", attrsOf(records[0])["suppressed"])
+		assert.Empty(t, attrsOf(records[1])["occurrences"])
+	})
+
+	t.Run("SummaryOnPrune", func(t *testing.T) {
+		rec := newRecordingHandler()
+		h := newDedupHandler(rec, 10*time.Minute)
+		for i := range maxDedupEntries {
+			for range 3 {
+				require.NoError(t, h.Handle(context.TODO(), record(start, slog.LevelError, "failed", slog.String("id", strconv.Itoa(i)))))
+			}
+		}
+		rec.take()
+
+		// A full table is cleared, summarizing every entry with suppressed occurrences
+		require.NoError(t, h.Handle(context.TODO(), record(start, slog.LevelError, "failed", slog.String("id", "new"))))
+		records := rec.take()
+		require.Len(t, records, maxDedupEntries+1)
+		for _, r := range records[:maxDedupEntries] {
+			assert.Equal(t, "1", attrsOf(r)["suppressed"])
+		}
+		assert.Equal(t, "new", attrsOf(records[maxDedupEntries])["id"])
+	})
+}
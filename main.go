@@ -1,15 +1,28 @@
 package main
 
 import (
+	"context"
+	"crypto/x509"
+	_ "embed"
+	"errors"
+	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
+	"net/netip"
 	"os"
+	"os/signal"
+	"runtime"
 	"strings"
+	"syscall"
 	"time"
+	// The time zones of --timestamp-timezone don't depend on the tzdata of the image.
+	_ "time/tzdata"
 
 	"github.com/alecthomas/kingpin/v2"
 	provider "github.com/orbit-online/external-dns-inwx-webhook/provider"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	cversion "github.com/prometheus/client_golang/prometheus/collectors/version"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/promslog"
@@ -20,35 +33,267 @@ import (
 	webhook "sigs.k8s.io/external-dns/provider/webhook/api"
 )
 
+//go:embed dashboards/external-dns-inwx.json
+var dashboardJSON []byte
+
 var (
 	// The default recommended port for the provider endpoints is 8888, and should listen only on localhost (ie: only accessible for external-dns).
-	listenAddr = kingpin.Flag("listen-address", "The address this plugin listens on").Default("localhost:8888").Envar("INWX_LISTEN_ADDRESS").String()
+	listenAddr = kingpin.Flag("listen-address", "The address this plugin listens on").Default("localhost:8888").String()
 	// The default recommended port for the exposed endpoints is 8080, and it should be bound to all interfaces (0.0.0.0)
-	metricsListenAddr = kingpin.Flag("metrics-listen-address", "The address this plugin provides metrics on").Default(":8080").Envar("INWX_METRICS_LISTEN_ADDRESS").String()
-	tlsConfig         = kingpin.Flag("tls-config", "Path to TLS config file.").Envar("INWX_TLS_CONFIG").Default("").String()
+	grpcListenAddr    = kingpin.Flag("grpc-listen-address", "The address the gRPC variant of the webhook API listens on; empty disables").Default("").String()
+	metricsListenAddr = kingpin.Flag("metrics-listen-address", "The address this plugin provides metrics on").Default(":8080").String()
+	tlsConfig         = kingpin.Flag("tls-config", "Path to TLS config file.").Default("").String()
+
+	signingSecret    = kingpin.Flag("webhook-signing-secret", "Shared secret mutating webhook requests must be signed with (HMAC-SHA256, see README); empty disables verification").Default("").String()
+	signatureMaxSkew = kingpin.Flag("webhook-signature-max-skew", "Maximum age of a webhook request signature, and how far it may lie in the future").Default("5m").Duration()
+	allowedCIDRs     = kingpin.Flag("webhook-allowed-cidr", "Only accept webhook requests from this CIDR range or address; specify multiple times for multiple ranges; all addresses by default").Strings()
+
+	domainFilter   = kingpin.Flag("domain-filter", "Limit possible target zones by a domain suffix; specify multiple times for multiple domains").Strings()
+	namePrefixes   = kingpin.Flag("name-filter-prefix", "Only manage DNS names starting with this prefix, e.g. preview-; specify multiple times for multiple prefixes").Strings()
+	nameSuffixes   = kingpin.Flag("name-filter-suffix", "Only manage DNS names ending in this suffix, e.g. .apps.example.com, skipping zones that can't hold such names; specify multiple times for multiple suffixes").Strings()
+	filterRules    = kingpin.Flag("filter", "Include or exclude DNS names by rule, evaluated in order after --domain-filter with the last matching rule deciding: include:<domain>, exclude:<domain>, include-regex:<regex> or exclude-regex:<regex>; specify multiple times for multiple rules").Strings()
+	excludeDomains = kingpin.Flag("exclude-domains", "Never read or modify this zone or any name below it, even if it falls under an included domain; specify multiple times for multiple domains").Strings()
+	sandbox        = kingpin.Flag("inwx-sandbox", "Operate on the INWX sandbox database").Default("false").Bool()
+	username       = kingpin.Flag("inwx-username", "The login username for the INWX API; required unless --inwx-username-file is given").Default("").String()
+	usernameFile   = kingpin.Flag("inwx-username-file", "Path to a file holding the login username for the INWX API, e.g. a mounted Kubernetes secret").Default("").String()
+	password       = kingpin.Flag("inwx-password", "The login password for the INWX API; required unless --inwx-password-file is given").Default("").String()
+	passwordFile   = kingpin.Flag("inwx-password-file", "Path to a file holding the login password for the INWX API, e.g. a mounted Kubernetes secret").Default("").String()
+	totpSecret     = kingpin.Flag("inwx-totp-secret", "The shared secret of an INWX account with two-factor authentication, to unlock it after every login").Default("").String()
+	totpFile       = kingpin.Flag("inwx-totp-secret-file", "Path to a file holding the shared secret of an INWX account with two-factor authentication, e.g. a mounted Kubernetes secret").Default("").String()
+	reloadCreds    = kingpin.Flag("credentials-reload-interval", "How often to read the credentials files again, logging in with rotated credentials without a restart; 0 disables").Default("1m").Duration()
+
+	zoneConfigFile   = kingpin.Flag("zone-config", "Path to a YAML file with global and per-zone settings (TTL, policy, rate limit, protected names, dry-run)").Default("").String()
+	tenantsConfig    = kingpin.Flag("tenants-config", "Path to a YAML file defining tenants, each with its own INWX credentials, domain filter and zone config, served under /tenants/<name> instead of a single provider; empty disables").Default("").String()
+	defaultTTL       = kingpin.Flag("default-ttl", "TTL of the records of endpoints without a TTL, in seconds; 0 leaves it to INWX; can be overridden per zone in the zone config").Default("0").Int()
+	minTTL           = kingpin.Flag("min-ttl", "Lowest TTL INWX stores, in seconds; lower TTLs are raised to it when external-dns adjusts the endpoints, 0 disables").Default("300").Int()
+	maxTTL           = kingpin.Flag("max-ttl", "Highest TTL allowed, in seconds; higher TTLs are lowered to it when external-dns adjusts the endpoints, 0 disables").Default("0").Int()
+	apexAlias        = kingpin.Flag("apex-alias", "Write CNAME endpoints at the zone apex as INWX ALIAS records instead of failing; endpoints can override this with the inwx/alias provider-specific property").Default("false").Bool()
+	allowApexChanges = kingpin.Flag("allow-apex-changes", "Allow A, AAAA and TXT changes at the zone apex; can be overridden per zone in the zone config").Default("false").Bool()
+	recordLimit      = kingpin.Flag("zone-record-limit", "Number of records a zone may hold, as allowed by the INWX account; changes approaching or exceeding it are logged, 0 disables; can be overridden per zone in the zone config").Default("0").Int()
+	blockOverLimit   = kingpin.Flag("block-over-record-limit", "Skip the creates of changes that would exceed --zone-record-limit instead of only warning about them; can be overridden per zone in the zone config").Default("false").Bool()
+	freezeRecord     = kingpin.Flag("freeze-record", "Name, relative to the zone, of a TXT record whose presence freezes the zone; empty disables").Default(provider.DefaultFreezeRecord).String()
+
+	registry               = kingpin.Flag("registry", "The external-dns registry in use: legacy (built-in heuristics), txt (mirror the external-dns TXT registry settings) or noop (no ownership records, also for registries that store ownership outside of DNS)").Default("legacy").Enum("legacy", "txt", "noop")
+	txtPrefix              = kingpin.Flag("txt-prefix", "The --txt-prefix external-dns is configured with; requires --registry=txt").Default("").String()
+	txtSuffix              = kingpin.Flag("txt-suffix", "The --txt-suffix external-dns is configured with; requires --registry=txt").Default("").String()
+	txtWildcardReplacement = kingpin.Flag("txt-wildcard-replacement", "The --txt-wildcard-replacement external-dns is configured with; requires --registry=txt").Default("").String()
+	txtOwnerID             = kingpin.Flag("txt-owner-id", "The --txt-owner-id external-dns is configured with; the ownership record carrying it is kept when consolidating duplicates").Default("").String()
+	duplicateOwnership     = kingpin.Flag("duplicate-ownership-records", "What to do with ownership records referring to the same endpoint as another one, e.g. after an owner ID rename, in the zones touched by changes: ignore, warn or consolidate (delete all but one)").Default("warn").Enum("ignore", "warn", "consolidate")
+	recordExpiry           = kingpin.Flag("record-expiry-interval", "How often to delete the records of endpoints whose inwx/expires-after provider-specific property has passed; 0 ignores the property").Default("30s").Duration()
+	migrateOwnerFrom       = kingpin.Flag("migrate-owner-from", "Rewrite the ownership records of this owner ID to --txt-owner-id before the first read of the records, e.g. after renaming the cluster; empty disables").Default("").String()
+	atomicOwnership        = kingpin.Flag("atomic-ownership", "Create every record immediately followed by its ownership TXT record, deleting the record again if the ownership record fails").Default("false").Bool()
+	createFirst            = kingpin.Flag("create-first", "Apply creates before deletes, so that names moving between records keep resolving; deletes conflicting with a create at the same name, such as a CNAME replaced by an A record, still go first").Default("false").Bool()
+	createMissingZones     = kingpin.Flag("create-missing-zones", "Create the domains of --domain-filter that no zone hosted at INWX covers yet, as described by the zoneTemplate selected for them in the zone config").Default("false").Bool()
+	createPTR              = kingpin.Flag("create-ptr", "Maintain PTR records for the addresses of A and AAAA records in the reverse zones (in-addr.arpa, ip6.arpa) hosted at INWX").Default("false").Bool()
+
+	ignoreLabels     = kingpin.Flag("ignore-label", "Skip endpoints carrying this label (key=value); specify multiple times for multiple labels").StringMap()
+	ignoreProperties = kingpin.Flag("ignore-property", "Skip endpoints carrying this provider-specific property (name=value); specify multiple times for multiple properties").Default("inwx/ignore=true", "webhook/inwx-ignore=true").StringMap()
+
+	flapWindow    = kingpin.Flag("flap-window", "Sliding window over which record changes are counted for flap detection; 0 disables").Default("1h").Duration()
+	flapThreshold = kingpin.Flag("flap-threshold", "Number of changes within the flap window after which a record is reported as flapping").Default("5").Int()
+
+	progressThreshold    = kingpin.Flag("progress-threshold", "Log the progress of applies of at least this many record mutations, with an ETA; 0 disables").Default("100").Int()
+	progressInterval     = kingpin.Flag("progress-interval", "How often to log the progress of applies reaching --progress-threshold").Default("10s").Duration()
+	historySize          = kingpin.Flag("history-size", "Number of applied operations kept in memory for /debug/history; 0 disables").Default("100").Int()
+	duplicateApplyWindow = kingpin.Flag("duplicate-apply-window", "Skip change sets identical to one applied successfully within this window; 0 disables").Default("30s").Duration()
+	recordsCacheTTL      = kingpin.Flag("records-cache-ttl", "Serve the records read from INWX from memory for this long, also while changes are applied; 0 disables").Default("0s").Duration()
+	staleRecordsMaxAge   = kingpin.Flag("stale-records-max-age", "Serve the last records read successfully, if at most this old, when listing the records fails; 0 disables").Default("0s").Duration()
+	traceNormalization   = kingpin.Flag("trace-normalization", "Log every step of turning an endpoint into an INWX record and back, from the TXT unquoting over the zone match, record name and TTL to the final INWX request").Default("false").Bool()
+	nameCollision        = kingpin.Flag("name-collision", "What to do with desired endpoints of the same name and type but different targets, e.g. from two sources: merge (combine their targets), first (keep the first) or error (fail the reconcile)").Default("merge").Enum("merge", "first", "error")
+	maintenanceWindows   = kingpin.Flag("maintenance-window", "A scheduled INWX maintenance window as <start>/<end> or <start>/<duration> in RFC 3339, e.g. 2026-11-03T22:00:00Z/4h, during which the last known-good records are served and changes are deferred; specify multiple times for multiple windows").Strings()
+	emptyZonesAfter      = kingpin.Flag("flag-empty-zones-after", "Flag zones holding no records besides SOA and NS for this long in the logs and metrics, e.g. those of torn down preview environments; 0 disables").Default("0s").Duration()
+	emptyRecordsGuard    = kingpin.Flag("empty-records-guard", "Reject a read returning no records after at least this many were read before, as it points to an API anomaly; 0 disables").Default("10").Int()
+	maxDeletes           = kingpin.Flag("max-deletes", "Hold back changes deleting more records than this until they are approved through POST /approve/<plan hash> on the webhook listener; 0 disables").Default("0").Int()
+
+	featureGates         = kingpin.Flag("feature-gate", "Enable or disable an experimental feature (name=true|false); specify multiple times for multiple features").StringMap()
+	allowFeatureToggling = kingpin.Flag("allow-feature-toggling", "Allow toggling experimental features at runtime through POST /debug/features on the metrics server").Default("false").Bool()
+
+	auditLog               = kingpin.Flag("audit-log", "Where to write the audit log of applied changes: a file path, file:///path, sqlite:///path or s3://bucket/prefix; empty disables").Default("").String()
+	auditS3Endpoint        = kingpin.Flag("audit-s3-endpoint", "Endpoint of the S3-compatible store of an s3:// audit log").Default("https://s3.amazonaws.com").String()
+	auditS3Region          = kingpin.Flag("audit-s3-region", "Region of the S3-compatible store of an s3:// audit log").Default("us-east-1").String()
+	auditS3PathStyle       = kingpin.Flag("audit-s3-path-style", "Address the bucket of an s3:// audit log in the path instead of the host name, as most self-hosted stores require").Default("false").Bool()
+	auditS3AccessKeyID     = kingpin.Flag("audit-s3-access-key-id", "Access key ID for an s3:// audit log").Default("").String()
+	auditS3SecretAccessKey = kingpin.Flag("audit-s3-secret-access-key", "Secret access key for an s3:// audit log").Default("").String()
+
+	notifyURL         = kingpin.Flag("notify-url", "URL to POST a summary to whenever applying changes fails, e.g. a Slack incoming webhook; empty disables").Default("").String()
+	notifyFormat      = kingpin.Flag("notify-format", "Payload of the failure notifications: generic (the report as JSON) or slack").Default("generic").Enum("generic", "slack")
+	admissionPolicy   = kingpin.Flag("admission-policy-url", "URL of an Open Policy Agent decision, e.g. http://opa:8181/v1/data/externaldns/admission, asked about every record mutation; denied mutations are skipped; empty disables").Default("").String()
+	reportDir         = kingpin.Flag("report-dir", "Directory to write a reconciliation report of every apply to, e.g. for pipelines attaching DNS change summaries to pull requests; empty disables").Default("").String()
+	timestampFormat   = kingpin.Flag("timestamp-format", "Format of the times in the audit log, reports and change feed: rfc3339, rfc3339nano, rfc1123z, datetime or a Go time layout, e.g. \"02.01.2006 15:04:05 MST\"").Default("rfc3339nano").String()
+	timestampTimezone = kingpin.Flag("timestamp-timezone", "Time zone of the times in the audit log, reports and change feed, e.g. Europe/Berlin or Local").Default("UTC").String()
+	reportFormats     = kingpin.Flag("report-format", "Format of the reconciliation reports: json or markdown; specify multiple times for multiple formats").Default("json").Enums("json", "markdown")
+
+	logDedupWindow    = kingpin.Flag("log-dedup-window", "Exponentially suppress identical error logs recurring within this window; 0 disables").Default("10m").Duration()
+	requestTimeout    = kingpin.Flag("request-timeout", "Deadline of /records requests without an X-Request-Timeout header; changes left when it comes close are reported as not applied; 0 disables").Default("0s").Duration()
+	shutdownTimeout   = kingpin.Flag("shutdown-timeout", "How long to wait for in-flight operations to complete on shutdown").Default("30s").Duration()
+	slowCallThreshold = kingpin.Flag("slow-call-threshold", "Log INWX API calls taking at least this long at warn level; 0 disables").Default("5s").Duration()
+
+	apiClient        = kingpin.Flag("inwx-client", "Client talking to the INWX API: goinwx (XML-RPC through the goinwx library) or jsonrpc (built-in DomRobot JSON-RPC client)").Default("goinwx").Enum("goinwx", "jsonrpc")
+	apiURL           = kingpin.Flag("inwx-api-url", "Send the INWX API calls to this URL instead of the production or --inwx-sandbox endpoint, e.g. a proxy gateway or a mock server; it must speak the protocol of --inwx-client").String()
+	proxyURL         = kingpin.Flag("inwx-proxy-url", "Send the INWX API calls through this http, https or socks5 proxy instead of the one of the HTTPS_PROXY and NO_PROXY environment variables").String()
+	caFile           = kingpin.Flag("inwx-ca-file", "PEM bundle of CA certificates trusted for the INWX API in addition to the system ones, e.g. of a TLS-intercepting egress proxy").String()
+	tlsMinVersion    = kingpin.Flag("inwx-tls-min-version", "Minimum TLS version for the INWX API: 1.2 or 1.3").Default("1.2").Enum("1.2", "1.3")
+	sessionScope     = kingpin.Flag("inwx-session", "How long an INWX API session lasts: per-reconcile (a login per records or apply request), per-operation (a login per API call) or persistent (a single login, reused until it expires)").Default("persistent").Enum("per-reconcile", "per-operation", "persistent")
+	sessionKeepalive = kingpin.Flag("inwx-session-keepalive", "Keep a persistent INWX session alive by a cheap API call whenever it was idle for this long; 0 disables").Default("5m").Duration()
+	expectedAccount  = kingpin.Flag("expected-account-id", "Refuse to run with INWX credentials of any account but this one; 0 disables the check").Default("0").Int()
+	expectedEnv      = kingpin.Flag("expected-environment", "Refuse to run against any INWX environment but this one: production, sandbox or the --inwx-api-url").String()
+	sessionCheck     = kingpin.Flag("healthz-session-check-interval", "Fail /healthz while logging in to INWX fails, checking at most once per this interval; 0 disables the check").Default("1m").Duration()
+	maxIdleConns     = kingpin.Flag("inwx-max-idle-conns", "Maximum number of idle connections to the INWX API kept open for reuse").Default("4").Int()
+	idleConnTimeout  = kingpin.Flag("inwx-idle-conn-timeout", "How long an idle connection to the INWX API is kept open for reuse").Default("90s").Duration()
+
+	helpConfig = kingpin.Flag("help-config", "Print every option with its environment variable, default and current value, and exit").Bool()
+
+	serveCmd = kingpin.Command("serve", "Run the webhook and metrics servers").Default()
+
+	migrateCmd            = kingpin.Command("migrate", "Import records exported from another provider into INWX, previewing the plan first")
+	migrateZoneFile       = migrateCmd.Flag("from-zonefile", "Path to an RFC 1035 zone file, e.g. exported from Cloudflare or Route53").String()
+	migrateZone           = migrateCmd.Flag("zone", "The zone (origin) of the zone file").String()
+	migrateProviderExport = migrateCmd.Flag("from-provider-export", "Path to a JSON list of external-dns endpoints, e.g. the GET /records response of another webhook provider").String()
+	migrateApply          = migrateCmd.Flag("apply", "Apply the plan instead of only previewing it").Bool()
+
+	migrateOwnerCmd    = kingpin.Command("migrate-owner", "Rewrite the ownership records of one external-dns owner ID to another in every managed zone, previewing the rewrites first")
+	migrateOwnerSource = migrateOwnerCmd.Flag("from", "The owner ID to rewrite").Required().String()
+	migrateOwnerTarget = migrateOwnerCmd.Flag("to", "The owner ID to rewrite to; defaults to --txt-owner-id").String()
+	migrateOwnerApply  = migrateOwnerCmd.Flag("apply", "Rewrite the records instead of only previewing the rewrites").Bool()
 
-	domainFilter = kingpin.Flag("domain-filter", "Limit possible target zones by a domain suffix; specify multiple times for multiple domains").Envar("INWX_DOMAIN_FILTER").Strings()
-	sandbox      = kingpin.Flag("inwx-sandbox", "Operate on the INWX sandbox database").Default("false").Envar("INWX_SANDBOX").Bool()
-	username     = kingpin.Flag("inwx-username", "The login username for the INWX API").Required().Envar("INWX_USERNAME").String()
-	password     = kingpin.Flag("inwx-password", "The login password for the INWX API").Required().Envar("INWX_PASSWORD").String()
+	snapshotCmd              = kingpin.Command("snapshot", "Print the endpoints the external-dns sources in a cluster would send to this provider; requires a build with -tags snapshot")
+	snapshotKubeConfig       = snapshotCmd.Flag("kubeconfig", "Path to the kubeconfig; defaults to the in-cluster configuration").Envar("KUBECONFIG").String()
+	snapshotSources          = snapshotCmd.Flag("source", "The external-dns source to read (service, ingress, crd, gateway-httproute, ...); specify multiple times for multiple sources").Default("service", "ingress").Strings()
+	snapshotNamespace        = snapshotCmd.Flag("namespace", "Limit sources to this namespace; all namespaces by default").String()
+	snapshotLabelFilter      = snapshotCmd.Flag("label-filter", "The --label-filter external-dns is configured with").String()
+	snapshotAnnotationFilter = snapshotCmd.Flag("annotation-filter", "The --annotation-filter external-dns is configured with").String()
+	snapshotCRDAPIVersion    = snapshotCmd.Flag("crd-source-apiversion", "API version of the CRD for the crd source").Default("externaldns.k8s.io/v1alpha1").String()
+	snapshotCRDKind          = snapshotCmd.Flag("crd-source-kind", "Kind of the CRD for the crd source").Default("DNSEndpoint").String()
+
+	devloopCmd           = kingpin.Command("devloop", "Reconcile a local endpoints file against INWX, or an in-memory INWX API, whenever it changes, printing every diff, to develop without a cluster")
+	devloopEndpointsFile = devloopCmd.Flag("endpoints-file", "Path to a JSON list of the desired endpoints, or the output of the snapshot command").Required().String()
+	devloopMockZones     = devloopCmd.Flag("mock-zone", "Serve the INWX API from memory with this zone instead of talking to INWX, without credentials; specify multiple times for multiple zones").Strings()
+	devloopInterval      = devloopCmd.Flag("interval", "Reconcile at least this often even if the endpoints file is unchanged, to undo drift").Default("1m").Duration()
+	devloopSync          = devloopCmd.Flag("sync", "Delete the records of the managed types missing from the endpoints file, like --policy=sync of external-dns; only creates and updates are applied by default").Default("false").Bool()
+	devloopRecordTypes   = devloopCmd.Flag("managed-record-types", "Record types to manage, like the --managed-record-types of external-dns; specify multiple times for multiple types").Default("A", "AAAA", "CNAME").Strings()
 )
 
 func main() {
 
+	resolveBuildInfo()
 	promslogConfig := &promslog.Config{}
 	flag.AddFlags(kingpin.CommandLine, promslogConfig)
 	kingpin.Version(version.Info())
-	kingpin.Parse()
+	assignEnvars(kingpin.CommandLine)
+	args, configWarnings := applyDeprecations(os.Args[1:])
+	configWarnings = append(configWarnings, applyLegacyEnvars(kingpin.CommandLine)...)
+	command := kingpin.MustParse(kingpin.CommandLine.Parse(args))
+	configWarnings = append(configWarnings, unknownEnvars(kingpin.CommandLine, os.Environ())...)
+	if *helpConfig {
+		printConfig(os.Stdout, kingpin.CommandLine)
+		return
+	}
 
 	var logger = promslog.New(promslogConfig)
-	logger.Info("starting external-dns INWX webhook plugin", "version", version.Version, "revision", version.Revision)
-	logger.Debug("configuration", "api-key", strings.Repeat("*", len(*username)), "api-password", strings.Repeat("*", len(*password)))
+	if *logDedupWindow > 0 {
+		logger = slog.New(newDedupHandler(logger.Handler(), *logDedupWindow))
+	}
+	slog.SetDefault(logger)
+	logger.Info("starting external-dns INWX webhook plugin", "version", version.Version, "revision", version.Revision, "platform", runtime.GOOS+"/"+runtime.GOARCH, "user_agent", userAgent())
+	for _, warning := range configWarnings {
+		logger.Warn(warning)
+	}
+
+	shutdownTracing, err := setupTracing(logger)
+	if err != nil {
+		logger.Error("Failed to set up tracing", "error", err.Error())
+		os.Exit(1)
+	}
+
+	if *tenantsConfig != "" {
+		if err := checkTenantFlags(command); err != nil {
+			logger.Error("invalid configuration", "error", err.Error())
+			os.Exit(1)
+		}
+		tenants, err := buildTenants(*tenantsConfig, logger)
+		if err != nil {
+			logger.Error("Failed to create tenants", "error", err.Error())
+			os.Exit(1)
+		}
+		serve(tenants, shutdownTracing, logger)
+		return
+	}
+
+	// The snapshot talks to the cluster only, so it runs before any credentials are read.
+	if command == snapshotCmd.FullCommand() {
+		if err := runSnapshot(os.Stdout, logger); err != nil {
+			logger.Error("snapshot failed", "error", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	if command == devloopCmd.FullCommand() {
+		if err := checkDevloopFlags(); err != nil {
+			logger.Error("invalid configuration", "error", err.Error())
+			os.Exit(1)
+		}
+	}
+	inwxProvider, err := buildProvider(logger)
+	if err != nil {
+		logger.Error("Failed to create provider", "error", err.Error())
+		os.Exit(1)
+	}
+	if err := inwxProvider.VerifyAccount(); err != nil {
+		logger.Error("Refusing to run", "error", err.Error())
+		os.Exit(1)
+	}
+
+	switch command {
+	case migrateCmd.FullCommand():
+		if err := runMigrate(inwxProvider, os.Stdout, logger); err != nil {
+			logger.Error("migration failed", "error", err.Error())
+			os.Exit(1)
+		}
+		return
+	case migrateOwnerCmd.FullCommand():
+		if err := runMigrateOwner(inwxProvider, os.Stdout, logger); err != nil {
+			logger.Error("owner migration failed", "error", err.Error())
+			os.Exit(1)
+		}
+		return
+	case devloopCmd.FullCommand():
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := runDevloop(ctx, inwxProvider, os.Stdout, logger); err != nil {
+			logger.Error("devloop failed", "error", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
 
-	prometheus.DefaultRegisterer.MustRegister(cversion.NewCollector("external_dns_inwx"))
+	allowed, err := parseAllowlist(*allowedCIDRs)
+	if err != nil {
+		logger.Error("invalid webhook allowlist", "error", err.Error())
+		os.Exit(1)
+	}
+	serve([]servedTenant{{
+		provider:          inwxProvider,
+		logger:            logger,
+		readCredentials:   readCredentials,
+		reloadCredentials: *usernameFile != "" || *passwordFile != "" || *totpFile != "",
+		signingSecret:     []byte(*signingSecret),
+		allowed:           allowed,
+	}}, shutdownTracing, logger)
+}
+
+// serve runs the webhook and metrics servers until a signal arrives: for a single provider at the root of
+// the listeners, for several tenants each under its prefix.
+func serve(tenants []servedTenant, shutdownTracing func(context.Context) error, logger *slog.Logger) {
+	prometheus.DefaultRegisterer.MustRegister(cversion.NewCollector(provider.MetricsNamespace))
+	// Replace the default Go collector with one also exporting the GC, memory and scheduler metrics of the
+	// runtime, to see how the webhook behaves during big syncs.
+	prometheus.DefaultRegisterer.Unregister(collectors.NewGoCollector())
+	prometheus.DefaultRegisterer.MustRegister(collectors.NewGoCollector(collectors.WithGoCollectorRuntimeMetrics(
+		collectors.MetricsGC, collectors.MetricsMemory, collectors.MetricsScheduler)))
+	gatherer := registerTenantMetrics(tenants)
 
-	metricsMux := buildMetricsServer(prometheus.DefaultGatherer, logger)
 	metricsServer := http.Server{
-		Handler:           metricsMux,
+		Handler:           buildTenantsMetricsServer(gatherer, tenants, logger),
 		ReadHeaderTimeout: 5 * time.Second}
 
 	metricsFlags := web.FlagConfig{
@@ -57,13 +302,8 @@ func main() {
 		WebConfigFile:      tlsConfig,
 	}
 
-	webhookMux, err := buildWebhookServer(logger)
-	if err != nil {
-		logger.Error("Failed to create provider", "error", err.Error())
-		os.Exit(1)
-	}
 	webhookServer := http.Server{
-		Handler:           webhookMux,
+		Handler:           buildTenantsWebhookServer(tenants),
 		ReadHeaderTimeout: 5 * time.Second}
 
 	webhookFlags := web.FlagConfig{
@@ -72,37 +312,97 @@ func main() {
 		WebConfigFile:      tlsConfig,
 	}
 
-	var wg errgroup.Group
+	// gRPC needs HTTP/2, also without TLS.
+	grpcServer := http.Server{
+		Handler:           protectWebhook(grpcHandler(tenants[0].provider, logger), tenants[0].signingSecret, tenants[0].allowed, logger),
+		ReadHeaderTimeout: 5 * time.Second,
+		Protocols:         new(http.Protocols)}
+	grpcServer.Protocols.SetHTTP2(true)
+	grpcServer.Protocols.SetUnencryptedHTTP2(true)
+	grpcFlags := web.FlagConfig{
+		WebListenAddresses: &[]string{*grpcListenAddr},
+		WebSystemdSocket:   new(bool),
+		WebConfigFile:      tlsConfig,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// A server failing to start also triggers the shutdown of the others.
+	wg, ctx := errgroup.WithContext(ctx)
 
 	wg.Go(func() error {
 		logger.Info("Started external-dns-inwx-webhook metrics server", "address", metricsListenAddr)
-		return web.ListenAndServe(&metricsServer, &metricsFlags, logger)
+		return ignoreServerClosed(web.ListenAndServe(&metricsServer, &metricsFlags, logger))
 	})
 	wg.Go(func() error {
 		logger.Info("Started external-dns-inwx-webhook webhook server", "address", listenAddr)
-		return web.ListenAndServe(&webhookServer, &webhookFlags, logger)
+		return ignoreServerClosed(web.ListenAndServe(&webhookServer, &webhookFlags, logger))
+	})
+	if *grpcListenAddr != "" {
+		wg.Go(func() error {
+			logger.Info("Started external-dns-inwx-webhook gRPC server", "address", *grpcListenAddr)
+			return ignoreServerClosed(web.ListenAndServe(&grpcServer, &grpcFlags, logger))
+		})
+	}
+	for _, tenant := range tenants {
+		if *reloadCreds > 0 && tenant.reloadCredentials {
+			wg.Go(func() error {
+				watchCredentials(ctx, tenant.provider, tenant.readCredentials, *reloadCreds, tenant.logger)
+				return nil
+			})
+		}
+	}
+	wg.Go(func() error {
+		<-ctx.Done()
+		logger.Info("shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+		// Stop accepting webhook requests first, then let the providers finish the operations in flight.
+		errs := []error{webhookServer.Shutdown(shutdownCtx), grpcServer.Shutdown(shutdownCtx)}
+		for _, tenant := range tenants {
+			errs = append(errs, tenant.provider.Shutdown(shutdownCtx))
+		}
+		return errors.Join(append(errs, metricsServer.Shutdown(shutdownCtx), shutdownTracing(shutdownCtx))...)
 	})
 
-	if err = wg.Wait(); err != nil {
+	if err := wg.Wait(); err != nil {
 		logger.Error("run server group error", "error", err.Error())
 		os.Exit(1)
 	}
 }
 
-func buildMetricsServer(registry prometheus.Gatherer, logger *slog.Logger) *http.ServeMux {
+// ignoreServerClosed treats the error returned by a server stopped through Shutdown as a clean exit.
+func ignoreServerClosed(err error) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func buildMetricsServer(registry prometheus.Gatherer, p *provider.INWXProvider, logger *slog.Logger) *http.ServeMux {
 	mux := http.NewServeMux()
 
 	var healthzPath = "/healthz"
+	var readyzPath = "/readyz"
+	var statusPath = "/status"
 	var metricsPath = "/metrics"
+	var dashboardPath = "/debug/dashboard.json"
+	var flapsPath = "/debug/flaps"
+	var historyPath = "/debug/history"
+	var featuresPath = "/debug/features"
+	var cachePath = "/debug/cache"
+	var rateLimitPath = "/debug/ratelimit"
+	var changesPath = "/changes"
 	var rootPath = "/"
 
-	// Add the exposed "/healthz" endpoint that is used by liveness and readiness probes.
+	// Add the exposed "/healthz" endpoint that is used by liveness probes, "/readyz" for readiness probes
+	// and the composite health behind both at "/status".
 	// References:
 	//   1. https://kubernetes-sigs.github.io/external-dns/v0.17.0/docs/tutorials/webhook-provider/#implementation-requirements
-	mux.HandleFunc(healthzPath, func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte(http.StatusText(http.StatusOK)))
-	})
+	mux.HandleFunc(healthzPath, livenessHandler(p, *sessionCheck > 0, logger))
+	mux.HandleFunc(readyzPath, readinessHandler(p))
+	mux.HandleFunc(statusPath, statusHandler(p, logger))
 
 	// Add metricsPath
 	mux.Handle(metricsPath, promhttp.HandlerFor(
@@ -111,16 +411,64 @@ func buildMetricsServer(registry prometheus.Gatherer, logger *slog.Logger) *http
 			EnableOpenMetrics: true,
 		}))
 
+	// Add the embedded Grafana dashboard for the exposed metrics
+	mux.HandleFunc(dashboardPath, dashboardHandler)
+
+	// Add the most frequently changing records
+	mux.HandleFunc(flapsPath, flapsHandler(p, logger))
+	// Add the most recently applied operations
+	mux.HandleFunc(historyPath, historyHandler(p, logger))
+	// Add the state of the caches and the rate limiters
+	mux.HandleFunc(cachePath, cacheHandler(p, logger))
+	mux.HandleFunc(rateLimitPath, rateLimitHandler(p, logger))
+
+	// Add the stream of applied changes
+	mux.HandleFunc(changesPath, changesHandler(p, logger))
+	// Add the experimental features and, if allowed, their runtime toggling
+	mux.HandleFunc(featuresPath, featuresHandler(p.Features(), *allowFeatureToggling, logger))
+
 	// Add index
 	landingConfig := web.LandingConfig{
 		Name:        "external-dns-inwx-webhook",
 		Description: "external-dns webhook provider for INWX",
 		Version:     version.Info(),
 		Links: []web.LandingLinks{
+			{
+				Address: statusPath,
+				Text:    "Health status",
+			},
 			{
 				Address: metricsPath,
 				Text:    "Metrics",
 			},
+			{
+				Address: dashboardPath,
+				Text:    "Grafana dashboard",
+			},
+			{
+				Address: changesPath,
+				Text:    "Applied changes (server-sent events)",
+			},
+			{
+				Address: flapsPath,
+				Text:    "Flapping records",
+			},
+			{
+				Address: historyPath,
+				Text:    "Operation history",
+			},
+			{
+				Address: featuresPath,
+				Text:    "Experimental features",
+			},
+			{
+				Address: cachePath,
+				Text:    "Cache state",
+			},
+			{
+				Address: rateLimitPath,
+				Text:    "Rate limiter state",
+			},
 		},
 	}
 	landingPage, err := web.NewLandingPage(landingConfig)
@@ -132,23 +480,272 @@ func buildMetricsServer(registry prometheus.Gatherer, logger *slog.Logger) *http
 	return mux
 }
 
-func buildWebhookServer(logger *slog.Logger) (*http.ServeMux, error) {
+// dashboardHandler serves the embedded Grafana dashboard.
+func dashboardHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(dashboardJSON)
+}
+
+// buildProvider builds the provider of the flags.
+func buildProvider(logger *slog.Logger) (*provider.INWXProvider, error) {
+	// The in-memory API of the devloop command needs no credentials.
+	var creds provider.Credentials
+	if len(*devloopMockZones) == 0 {
+		var err error
+		if creds, err = readCredentials(); err != nil {
+			return nil, err
+		}
+	}
+	settings := provider.Tenant{
+		DomainFilter:      *domainFilter,
+		ZoneConfig:        *zoneConfigFile,
+		ExpectedAccountID: *expectedAccount,
+		TXTOwnerID:        *txtOwnerID,
+		AuditLog:          *auditLog,
+	}
+	return newProvider(settings, creds, *reportDir, logger)
+}
+
+// newProvider builds a provider with the credentials creds, the settings of a tenant, or of the flags for a
+// single provider, and the flags for everything else. The extra options are applied last.
+func newProvider(settings provider.Tenant, creds provider.Credentials, reportDir string, logger *slog.Logger, extra ...provider.Option) (*provider.INWXProvider, error) {
+	var zoneConfig *provider.ZoneConfig
+	if settings.ZoneConfig != "" {
+		var err error
+		if zoneConfig, err = provider.LoadZoneConfig(settings.ZoneConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	states := map[provider.Feature]bool{}
+	if zoneConfig != nil {
+		maps.Copy(states, zoneConfig.Features)
+	}
+	gates, err := provider.ParseFeatureGates(*featureGates)
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(states, gates)
+	features, err := provider.NewFeatures(states)
+	if err != nil {
+		return nil, err
+	}
+
+	if *defaultTTL < 0 {
+		return nil, fmt.Errorf("--default-ttl must not be negative")
+	}
+	if *minTTL < 0 || *maxTTL < 0 {
+		return nil, fmt.Errorf("--min-ttl and --max-ttl must not be negative")
+	}
+	if *maxTTL > 0 && *maxTTL < *minTTL {
+		return nil, fmt.Errorf("--max-ttl must not be lower than --min-ttl")
+	}
+	client, err := provider.ParseAPIClient(*apiClient)
+	if err != nil {
+		return nil, err
+	}
+	scope, err := provider.ParseSessionScope(*sessionScope)
+	if err != nil {
+		return nil, err
+	}
+	collisions, err := provider.ParseCollisionStrategy(*nameCollision)
+	if err != nil {
+		return nil, err
+	}
+	duplicates, err := provider.ParseDuplicateOwnershipPolicy(*duplicateOwnership)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debug("configuration", "api-key", strings.Repeat("*", len(creds.Username)), "api-password", strings.Repeat("*", len(creds.Password)))
+	opts := []provider.Option{
+		provider.WithAPIClient(client),
+		provider.WithSessionScope(scope, *sessionKeepalive),
+		provider.WithSessionCheckInterval(*sessionCheck),
+		provider.WithExpectedAccount(settings.ExpectedAccountID, *expectedEnv),
+		provider.WithTOTPSecret(creds.TOTPSecret),
+		provider.WithSlowCallThreshold(*slowCallThreshold),
+		provider.WithConnectionPool(*maxIdleConns, *idleConnTimeout),
+		provider.WithUserAgent(userAgent()),
+		provider.WithIgnoreLabels(*ignoreLabels),
+		provider.WithIgnoreProperties(*ignoreProperties),
+		provider.WithZoneConfig(zoneConfig),
+		provider.WithDefaultTTL(*defaultTTL),
+		provider.WithTTLRange(*minTTL, *maxTTL),
+		provider.WithAllowApexChanges(*allowApexChanges),
+		provider.WithApexAlias(*apexAlias),
+		provider.WithRecordLimit(*recordLimit, *blockOverLimit),
+		provider.WithFreezeRecord(*freezeRecord),
+		provider.WithFlapDetection(*flapWindow, *flapThreshold),
+		provider.WithProgressLogging(*progressThreshold, *progressInterval),
+		provider.WithDuplicateApplyWindow(*duplicateApplyWindow),
+		provider.WithHistorySize(*historySize),
+		provider.WithAtomicOwnership(*atomicOwnership),
+		provider.WithCreateFirst(*createFirst),
+		provider.WithPTRRecords(*createPTR),
+		provider.WithCreateMissingZones(*createMissingZones),
+		provider.WithRecordsCacheTTL(*recordsCacheTTL),
+		provider.WithStaleRecordsFallback(*staleRecordsMaxAge),
+		provider.WithEmptyRecordsGuard(*emptyRecordsGuard),
+		provider.WithMaxDeletes(*maxDeletes),
+		provider.WithEmptyZoneReporting(*emptyZonesAfter),
+		provider.WithNormalizationTrace(*traceNormalization),
+		provider.WithFeatures(features),
+		provider.WithCollisionStrategy(collisions),
+		provider.WithDuplicateOwnership(duplicates, settings.TXTOwnerID),
+		provider.WithOwnerMigration(*migrateOwnerFrom, settings.TXTOwnerID),
+		provider.WithRecordExpiry(*recordExpiry),
+	}
+	if *apiURL != "" {
+		url, err := provider.ParseAPIURL(*apiURL)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, provider.WithAPIURL(url))
+	}
+	minVersion, err := provider.ParseTLSVersion(*tlsMinVersion)
+	if err != nil {
+		return nil, err
+	}
+	var rootCAs *x509.CertPool
+	if *caFile != "" {
+		if rootCAs, err = provider.LoadCABundle(*caFile); err != nil {
+			return nil, err
+		}
+	}
+	opts = append(opts, provider.WithTLS(rootCAs, minVersion))
+	if *proxyURL != "" {
+		proxy, err := provider.ParseProxyURL(*proxyURL)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, provider.WithProxy(proxy))
+	}
+	if len(*filterRules) > 0 {
+		rules := make([]provider.FilterRule, 0, len(*filterRules))
+		for _, value := range *filterRules {
+			rule, err := provider.ParseFilterRule(value)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, rule)
+		}
+		opts = append(opts, provider.WithFilterRules(rules...))
+	}
+	if len(*excludeDomains) > 0 {
+		opts = append(opts, provider.WithExcludeDomains(*excludeDomains...))
+	}
+	if len(*namePrefixes) > 0 || len(*nameSuffixes) > 0 {
+		opts = append(opts, provider.WithNameAffixes(*namePrefixes, *nameSuffixes))
+	}
+	if len(*maintenanceWindows) > 0 {
+		windows := make([]provider.MaintenanceWindow, 0, len(*maintenanceWindows))
+		for _, value := range *maintenanceWindows {
+			window, err := provider.ParseMaintenanceWindow(value)
+			if err != nil {
+				return nil, err
+			}
+			windows = append(windows, window)
+		}
+		opts = append(opts, provider.WithMaintenanceWindows(windows...))
+	}
+	if settings.AuditLog != "" {
+		store, err := openAuditStore(settings.AuditLog)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, provider.WithAuditStore(store))
+	}
+	if *notifyURL != "" {
+		format, err := provider.ParseNotifyFormat(*notifyFormat)
+		if err != nil {
+			return nil, err
+		}
+		notifier, err := provider.NewWebhookNotifier(*notifyURL, format, nil)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, provider.WithNotifier(notifier))
+	}
+	if *admissionPolicy != "" {
+		policy, err := provider.NewOPAPolicy(*admissionPolicy, nil)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, provider.WithAdmissionPolicy(policy))
+	}
+	layout, err := provider.ParseTimestampLayout(*timestampFormat)
+	if err != nil {
+		return nil, err
+	}
+	location, err := time.LoadLocation(*timestampTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp time zone: %w", err)
+	}
+	opts = append(opts, provider.WithTimestampFormat(layout, location))
+	if reportDir != "" {
+		formats := make([]provider.ReportFormat, 0, len(*reportFormats))
+		for _, name := range *reportFormats {
+			format, err := provider.ParseReportFormat(name)
+			if err != nil {
+				return nil, err
+			}
+			formats = append(formats, format)
+		}
+		opts = append(opts, provider.WithReports(reportDir, formats...))
+	}
+	if len(*devloopMockZones) > 0 {
+		opts = append(opts, provider.WithInMemoryAPI(*devloopMockZones...))
+	}
+	switch *registry {
+	case "txt":
+		opts = append(opts, provider.WithRegistry(provider.NewTXTRegistry(*txtPrefix, *txtSuffix, *txtWildcardReplacement)))
+	case "noop":
+		opts = append(opts, provider.WithRegistry(provider.NoopRegistry{}))
+	}
+
+	opts = append(opts, extra...)
+	return provider.NewINWXProvider(&settings.DomainFilter, creds.Username, creds.Password, *sandbox, logger, opts...), nil
+}
+
+// protectWebhook wraps a handler of the webhook API with the trace context, and the verification of the
+// request signatures made with secret and the address allowlist, if set.
+func protectWebhook(handler http.Handler, secret []byte, allowed []netip.Prefix, logger *slog.Logger) http.Handler {
+	handler = traceMiddleware(handler)
+	if len(secret) > 0 {
+		handler = signatureMiddleware(handler, secret, *signatureMaxSkew, logger)
+	}
+	if len(allowed) > 0 {
+		handler = allowlistMiddleware(handler, allowed, logger)
+	}
+	return handler
+}
+
+func buildWebhookServer(inwxProvider *provider.INWXProvider, logger *slog.Logger) http.Handler {
 	mux := http.NewServeMux()
 
 	var rootPath = "/"
 	var recordsPath = "/records"
 	var adjustEndpointsPath = "/adjustendpoints"
+	var validatePath = "/validate"
+	var approvePath = "/approve/"
+	var refreshPath = "/refresh"
 
 	p := webhook.WebhookServer{
-		Provider: provider.NewINWXProvider(domainFilter, *username, *password, *sandbox, logger),
+		Provider: inwxProvider,
 	}
 
 	// Add negotiatePath
-	mux.HandleFunc(rootPath, p.NegotiateHandler)
+	mux.HandleFunc(rootPath, negotiateHandler(inwxProvider, p.NegotiateHandler))
 	// Add adjustEndpointsPath
-	mux.HandleFunc(adjustEndpointsPath, p.AdjustEndpointsHandler)
+	mux.HandleFunc(adjustEndpointsPath, adjustEndpointsHandler(inwxProvider, p.AdjustEndpointsHandler, logger))
 	// Add recordsPath
-	mux.HandleFunc(recordsPath, p.RecordsHandler)
+	mux.Handle(recordsPath, deadlineMiddleware(recordsHandler(&p, logger), *requestTimeout))
+	// Add validatePath
+	mux.HandleFunc(validatePath, validateHandler(inwxProvider, logger))
+	// Add approvePath
+	mux.HandleFunc(approvePath, approveHandler(inwxProvider, approvePath, logger))
+	// Add refreshPath
+	mux.HandleFunc(refreshPath, refreshHandler(inwxProvider, logger))
 
-	return mux, nil
+	return compatibilityMiddleware(inwxProvider, mux)
 }
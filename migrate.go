@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	provider "github.com/orbit-online/external-dns-inwx-webhook/provider"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// runMigrate imports the records of another provider into INWX, printing the plan first and only
+// applying it with --apply.
+func runMigrate(p *provider.INWXProvider, out io.Writer, logger *slog.Logger) error {
+	desired, err := loadMigrationSource(logger)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	current, err := p.Records(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to read current records: %w", err)
+	}
+
+	changes := provider.MigrationPlan(current, desired)
+	for _, ep := range changes.Create {
+		fmt.Fprintf(out, "create  %s %s %d %s\n", ep.DNSName, ep.RecordType, ep.RecordTTL, strings.Join(ep.Targets, ","))
+	}
+	for i, ep := range changes.UpdateNew {
+		fmt.Fprintf(out, "update  %s %s %s -> %s\n", ep.DNSName, ep.RecordType, strings.Join(changes.UpdateOld[i].Targets, ","), strings.Join(ep.Targets, ","))
+	}
+	unchanged := len(desired) - len(changes.Create) - len(changes.UpdateNew)
+	fmt.Fprintf(out, "%d to create, %d to update, %d unchanged\n", len(changes.Create), len(changes.UpdateNew), unchanged)
+
+	if !changes.HasChanges() {
+		return nil
+	}
+	if !*migrateApply {
+		fmt.Fprintln(out, "Preview only; re-run with --apply to import these records.")
+		return nil
+	}
+
+	ctx, recorder := provider.WithChangeRecorder(ctx)
+	err = p.ApplyChanges(ctx, changes)
+	for _, change := range recorder.Changes() {
+		if change.Skipped != "" {
+			logger.Info("change skipped", "change_id", change.ID, "name", change.Name, "type", change.Type, "reason", change.Skipped)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Imported %d changes.\n", len(recorder.Changes()))
+	return nil
+}
+
+// loadMigrationSource reads the endpoints to import from --from-zonefile or --from-provider-export.
+func loadMigrationSource(logger *slog.Logger) ([]*endpoint.Endpoint, error) {
+	switch {
+	case *migrateZoneFile != "" && *migrateProviderExport != "":
+		return nil, errors.New("--from-zonefile and --from-provider-export are mutually exclusive")
+	case *migrateZoneFile != "":
+		if *migrateZone == "" {
+			return nil, errors.New("--zone is required with --from-zonefile")
+		}
+		f, err := os.Open(*migrateZoneFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		endpoints, skipped, err := provider.ParseZoneFile(f, *migrateZone)
+		for _, rr := range skipped {
+			logger.Info("skipping zone file record managed by INWX or not supported", "record", rr)
+		}
+		return endpoints, err
+	case *migrateProviderExport != "":
+		data, err := os.ReadFile(*migrateProviderExport)
+		if err != nil {
+			return nil, err
+		}
+		var endpoints []*endpoint.Endpoint
+		if err := json.Unmarshal(data, &endpoints); err != nil {
+			return nil, fmt.Errorf("unable to parse provider export %s: %w", *migrateProviderExport, err)
+		}
+		return endpoints, nil
+	}
+	return nil, errors.New("one of --from-zonefile or --from-provider-export is required")
+}
+
+// runMigrateOwner rewrites the ownership records of the owner --from to the owner --to, printing the
+// rewrites first and only applying them with --apply.
+func runMigrateOwner(p *provider.INWXProvider, out io.Writer, logger *slog.Logger) error {
+	to := *migrateOwnerTarget
+	if to == "" {
+		to = *txtOwnerID
+	}
+	if to == "" {
+		return errors.New("--to or --txt-owner-id is required")
+	}
+
+	ctx := context.Background()
+	rewrites, err := p.OwnerRewrites(ctx, *migrateOwnerSource, to)
+	if err != nil {
+		return err
+	}
+	for _, rewrite := range rewrites {
+		fmt.Fprintf(out, "rewrite  %s %s %s -> %s\n", rewrite.Zone, rewrite.Name, rewrite.OldContent, rewrite.Content)
+	}
+	fmt.Fprintf(out, "%d ownership records to rewrite from %s to %s\n", len(rewrites), *migrateOwnerSource, to)
+
+	if len(rewrites) == 0 {
+		return nil
+	}
+	if !*migrateOwnerApply {
+		fmt.Fprintln(out, "Preview only; re-run with --apply to rewrite these records.")
+		return nil
+	}
+
+	ctx, recorder := provider.WithChangeRecorder(ctx)
+	_, err = p.MigrateOwner(ctx, *migrateOwnerSource, to)
+	for _, change := range recorder.Changes() {
+		if change.Skipped != "" {
+			logger.Info("change skipped", "change_id", change.ID, "name", change.Name, "type", change.Type, "reason", change.Skipped)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Rewrote %d ownership records.\n", len(recorder.Changes()))
+	return nil
+}
@@ -0,0 +1,88 @@
+package inwx
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ErrAccountMismatch is returned by every login to an INWX account or environment other than the expected
+// one, so that e.g. a staging configuration with production credentials never changes production records.
+var ErrAccountMismatch = errors.New("logged in to an unexpected INWX account")
+
+// Account identifies the INWX account the provider is logged in to and the API environment, so that
+// credentials pointing at the wrong account or environment are noticed right away.
+type Account struct {
+	CustomerID int `json:"customer_id"`
+	AccountID  int `json:"account_id"`
+	// Environment is "production", "sandbox", or the URL the API calls are sent to instead.
+	Environment string `json:"environment"`
+}
+
+// environmentOf names the API environment of the sandbox setting and API URL override.
+func environmentOf(sandbox bool, apiURL string) string {
+	switch {
+	case apiURL != "":
+		return apiURL
+	case sandbox:
+		return "sandbox"
+	default:
+		return "production"
+	}
+}
+
+// loggedInTo remembers the account of a login, logging it whenever it differs from the previous one, such
+// as at startup or after the credentials were rotated.
+func (w *ClientWrapper) loggedInTo(result loginResult) {
+	account := Account{CustomerID: result.customerID, AccountID: result.accountID, Environment: w.environment}
+	if previous := w.account.Swap(&account); previous == nil || *previous != account {
+		w.logger.Info("logged in to INWX", "customer_id", account.CustomerID, "account_id", account.AccountID, "environment", account.Environment)
+	}
+}
+
+// loggedInAccount returns the account of the last login, if any.
+func (w *ClientWrapper) loggedInAccount() (Account, bool) {
+	if account := w.account.Load(); account != nil {
+		return *account, true
+	}
+	return Account{}, false
+}
+
+// Account returns the INWX account the provider logged in to last, if it logged in at all.
+func (p *INWXProvider) Account() (Account, bool) {
+	return p.client.loggedInAccount()
+}
+
+// VerifyAccount returns ErrAccountMismatch if the provider is logged in to another INWX account or
+// environment than the expected one. Before the first successful login there is nothing to verify.
+func (p *INWXProvider) VerifyAccount() error {
+	account, ok := p.Account()
+	if !ok {
+		return nil
+	}
+	if expected := p.config.expectedAccountID; expected != 0 && account.AccountID != expected {
+		return fmt.Errorf("%w: account ID %d, expected %d", ErrAccountMismatch, account.AccountID, expected)
+	}
+	if expected := p.config.expectedEnvironment; expected != "" && account.Environment != expected {
+		return fmt.Errorf("%w: environment %s, expected %s", ErrAccountMismatch, account.Environment, expected)
+	}
+	return nil
+}
+
+// intValue returns a numeric field of an API response, which the XML-RPC and JSON-RPC clients decode as
+// different types, as an int; 0 if it is missing or not a number.
+func intValue(v any) int {
+	switch v := v.(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,52 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestAccount(t *testing.T) {
+	assert.Equal(t, "production", environmentOf(false, ""))
+	assert.Equal(t, "sandbox", environmentOf(true, ""))
+	assert.Equal(t, "http://inwx-gateway.corp.example/xmlrpc/", environmentOf(true, "http://inwx-gateway.corp.example/xmlrpc/"))
+
+	// XML-RPC decodes numbers as int64, JSON-RPC as float64
+	for _, v := range []any{12345, int64(12345), float64(12345), "12345"} {
+		assert.Equal(t, 12345, intValue(v), v)
+	}
+	assert.Equal(t, 0, intValue(nil))
+}
+
+func TestExpectedAccount(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	p.config.expectedAccountID = 4711
+	p.config.expectedEnvironment = "sandbox"
+
+	// Nothing to verify before the first login
+	require.NoError(t, p.VerifyAccount())
+
+	w.account = &Account{CustomerID: 1, AccountID: 4711, Environment: "sandbox"}
+	require.NoError(t, p.VerifyAccount())
+	_, err := p.Records(context.TODO())
+	require.NoError(t, err)
+
+	w.account = &Account{CustomerID: 2, AccountID: 815, Environment: "sandbox"}
+	err = p.VerifyAccount()
+	require.ErrorIs(t, err, ErrAccountMismatch)
+	assert.Contains(t, err.Error(), "account ID 815, expected 4711")
+
+	w.account = &Account{CustomerID: 1, AccountID: 4711, Environment: "production"}
+	changes := &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.com", "A", "192.0.2.1")}}
+	require.ErrorIs(t, p.ApplyChanges(context.TODO(), changes), ErrAccountMismatch)
+	records, err := w.getRecords("example.com")
+	require.NoError(t, err)
+	assert.Empty(t, *records)
+	assert.Equal(t, HealthFailing, p.Health().State)
+}
@@ -0,0 +1,105 @@
+package inwx
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// Adjustment is a change AdjustEndpoints made to a desired endpoint, or a problem it found with one, so
+// that endpoints don't disappear or change without a trace.
+type Adjustment struct {
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	SetIdentifier string `json:"setIdentifier,omitempty"`
+	// Action is "dropped" for endpoints removed, "modified" for endpoints changed, and "flagged" for
+	// endpoints passed on unchanged although applying them will fail. Flagged endpoints aren't dropped, as
+	// external-dns would then delete the records they describe.
+	Action string `json:"action"`
+	// Reason is domain_filter, expired, normalized, collision, ttl_override, invalid_ttl_override,
+	// default_ttl, ttl_clamped, unsupported_type, unsupported_combination, invalid_content,
+	// invalid_redirect or invalid_expiry.
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// adjustFunc records an adjustment of an endpoint with a message built from format and args.
+type adjustFunc func(ep *endpoint.Endpoint, action string, reason string, format string, args ...any)
+
+const (
+	adjustmentDropped  = "dropped"
+	adjustmentModified = "modified"
+	adjustmentFlagged  = "flagged"
+)
+
+// AdjustEndpoints drops the endpoints outside of the domain filter, normalizes the names and targets of
+// the others, applies the TTL overrides of the endpoints and the default TTL to those without a TTL, clamps
+// the TTLs to the configured range, then applies the name collision strategy and flags the combinations of
+// endpoints INWX can't store. The properties are consumed, so that external-dns doesn't plan to
+// update the records over properties the records read from INWX lack. Every adjustment is logged and
+// counted; see AdjustEndpointsWithReasons.
+func (p *INWXProvider) AdjustEndpoints(endpoints []*endpoint.Endpoint) ([]*endpoint.Endpoint, error) {
+	adjusted, _, err := p.AdjustEndpointsWithReasons(endpoints)
+	return adjusted, err
+}
+
+// AdjustEndpointsWithReasons is AdjustEndpoints also returning what it changed, and the endpoints with
+// unsupported types or invalid targets it passed on.
+func (p *INWXProvider) AdjustEndpointsWithReasons(endpoints []*endpoint.Endpoint) ([]*endpoint.Endpoint, []Adjustment, error) {
+	adjustments := []Adjustment{}
+	var adjust adjustFunc = func(ep *endpoint.Endpoint, action string, reason string, format string, args ...any) {
+		adjustments = append(adjustments, p.adjusted(ep, action, reason, fmt.Sprintf(format, args...)))
+	}
+	adjusted := make([]*endpoint.Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if !p.managesName(ep.DNSName) {
+			adjust(ep, adjustmentDropped, "domain_filter", "%s is outside of the domain filter", ep.DNSName)
+			continue
+		}
+		ep = p.normalizeEndpoint(ep, adjust)
+		if p.config.expiryInterval > 0 {
+			if p.expiries.expired(ep, now(p.config.clock)) {
+				adjust(ep, adjustmentDropped, "expired", "the records of %s %s expired and were deleted", ep.DNSName, ep.RecordType)
+				continue
+			}
+			if _, _, err := expiresAfter(ep); err != nil {
+				adjust(ep, adjustmentFlagged, "invalid_expiry", "ignoring invalid expiry: %v", err)
+			}
+		}
+		if !SupportedRecordType(ep.RecordType) {
+			adjust(ep, adjustmentFlagged, "unsupported_type", "record type %s is not supported, expected one of %s", ep.RecordType,
+				strings.Join(supportedRecordTypes, ", "))
+		} else {
+			for _, target := range ep.Targets {
+				if err := validateTarget(ep, target); err != nil {
+					adjust(ep, adjustmentFlagged, "invalid_content", "invalid %s target %q: %v", ep.RecordType, target, err)
+				}
+			}
+			if _, err := parseRedirect(ep); err != nil {
+				adjust(ep, adjustmentFlagged, "invalid_redirect", "ignoring invalid redirect: %v", err)
+			}
+		}
+		adjusted = append(adjusted, p.clampTTL(p.applyEndpointDefaultTTL(p.applyTTLOverride(ep, adjust), adjust), adjust))
+	}
+	resolved, err := p.resolveCollisions(adjusted, adjust)
+	if err == nil {
+		p.flagUnsupportedCombinations(resolved, adjust)
+	}
+	return resolved, adjustments, err
+}
+
+// adjusted logs and counts an adjustment of ep: changes at debug level, as they are expected, drops and
+// problems at warn level.
+func (p *INWXProvider) adjusted(ep *endpoint.Endpoint, action string, reason string, message string) Adjustment {
+	p.metrics.adjustedEndpointsTotal.WithLabelValues(action, reason).Inc()
+	level := slog.LevelWarn
+	if action == adjustmentModified {
+		level = slog.LevelDebug
+	}
+	p.logger.Log(context.Background(), level, "adjusted endpoint", "action", action, "reason", reason, "name", ep.DNSName, "type", ep.RecordType,
+		"set_identifier", ep.SetIdentifier, "message", message)
+	return Adjustment{Name: ep.DNSName, Type: ep.RecordType, SetIdentifier: ep.SetIdentifier, Action: action, Reason: reason, Message: message}
+}
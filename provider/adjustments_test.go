@@ -0,0 +1,97 @@
+package inwx
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+func TestAdjustEndpointsWithReasons(t *testing.T) {
+	_, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	p.config.collisionStrategy = CollisionPreferFirst
+	eps := []*endpoint.Endpoint{
+		endpoint.NewEndpoint("www.example.org", "A", "192.0.2.1"),
+		endpoint.NewEndpointWithTTL("www.example.com", "A", 300, "192.0.2.1").WithProviderSpecific("inwx/ttl", "600"),
+		endpoint.NewEndpoint("www.example.com", "A", "192.0.2.2"),
+		endpoint.NewEndpoint("api.example.com", "A", "192.0.2.300").WithProviderSpecific("inwx/ttl", "soon"),
+		endpoint.NewEndpoint("example.com", "DNAME", "example.net"),
+	}
+	adjusted, adjustments, err := p.AdjustEndpointsWithReasons(eps)
+	require.NoError(t, err)
+
+	// Flagged endpoints are kept
+	require.Len(t, adjusted, 3)
+	assert.Equal(t, []string{"www.example.com", "api.example.com", "example.com"},
+		[]string{adjusted[0].DNSName, adjusted[1].DNSName, adjusted[2].DNSName})
+	assert.Equal(t, endpoint.TTL(600), adjusted[0].RecordTTL)
+	assert.Empty(t, adjusted[1].ProviderSpecific)
+
+	reasons := []string{}
+	for _, adjustment := range adjustments {
+		reasons = append(reasons, adjustment.Action+"/"+adjustment.Reason+" "+adjustment.Name)
+	}
+	assert.Equal(t, []string{
+		"dropped/domain_filter www.example.org",
+		"modified/ttl_override www.example.com",
+		"flagged/invalid_content api.example.com",
+		"modified/invalid_ttl_override api.example.com",
+		"flagged/unsupported_type example.com",
+		"dropped/collision www.example.com",
+	}, reasons)
+	assert.Equal(t, "TTL 300 overridden with 600 by inwx/ttl", adjustments[1].Message)
+	assert.Contains(t, adjustments[2].Message, `invalid A target "192.0.2.300"`)
+
+	// AdjustEndpoints returns the same endpoints
+	plain, err := p.AdjustEndpoints(eps)
+	require.NoError(t, err)
+	assert.Equal(t, adjusted, plain)
+}
+
+func TestNormalizeEndpoints(t *testing.T) {
+	_, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	// Endpoints decoded from the webhook requests keep their trailing dots, unlike those of NewEndpoint
+	eps := []*endpoint.Endpoint{
+		{DNSName: "WWW.Example.com.", RecordType: "AAAA", Targets: endpoint.Targets{"2001:DB8:0:0::1", "2001:db8::1"}},
+		{DNSName: "alias.example.com", RecordType: "CNAME", Targets: endpoint.Targets{"LB.Example.net."}},
+		{DNSName: "example.com", RecordType: "MX", Targets: endpoint.Targets{"10 Mail.Example.com."}},
+		{DNSName: "_sip._tcp.example.com", RecordType: "SRV", Targets: endpoint.Targets{"10 5 5060 sip.example.com."}},
+		endpoint.NewEndpoint("old.example.com", "CNAME", "https://New.example.org/Path").WithProviderSpecific("inwx/url-redirect", "301"),
+		endpoint.NewEndpoint("txt.example.com", "TXT", "Mixed Case."),
+		endpoint.NewEndpoint("ok.example.com", "A", "192.0.2.1"),
+	}
+	adjusted, adjustments, err := p.AdjustEndpointsWithReasons(eps)
+	require.NoError(t, err)
+	require.Len(t, adjusted, 7)
+
+	// Names and targets are stored the way INWX reports them back, so that external-dns plans no updates
+	assert.Equal(t, "www.example.com", adjusted[0].DNSName)
+	assert.Equal(t, endpoint.Targets{"2001:db8::1"}, adjusted[0].Targets)
+	assert.Equal(t, endpoint.Targets{"lb.example.net"}, adjusted[1].Targets)
+	assert.Equal(t, endpoint.Targets{"10 mail.example.com"}, adjusted[2].Targets)
+	assert.Equal(t, endpoint.Targets{"10 5 5060 sip.example.com"}, adjusted[3].Targets)
+	assert.Equal(t, "WWW.Example.com.", eps[0].DNSName)
+	// URLs and TXT contents are case sensitive, and unchanged endpoints are passed on as they are
+	assert.Same(t, eps[4], adjusted[4])
+	assert.Same(t, eps[5], adjusted[5])
+	assert.Same(t, eps[6], adjusted[6])
+	require.Len(t, adjustments, 4)
+	assert.Equal(t, "normalized", adjustments[0].Reason)
+	assert.Equal(t, "WWW.Example.com. 2001:DB8:0:0::1;2001:db8::1 normalized to www.example.com 2001:db8::1", adjustments[0].Message)
+
+	// CNAME records can't have several targets nor coexist with records of other types
+	adjusted, adjustments, err = p.AdjustEndpointsWithReasons([]*endpoint.Endpoint{
+		endpoint.NewEndpoint("multi.example.com", "CNAME", "a.example.net", "b.example.net"),
+		endpoint.NewEndpoint("mixed.example.com", "CNAME", "a.example.net"),
+		endpoint.NewEndpoint("mixed.example.com", "A", "192.0.2.1"),
+		endpoint.NewEndpoint("mixed.example.com", "TXT", "heritage=external-dns"),
+	})
+	require.NoError(t, err)
+	require.Len(t, adjusted, 4)
+	require.Len(t, adjustments, 2)
+	assert.Equal(t, "unsupported_combination", adjustments[0].Reason)
+	assert.Equal(t, "multi.example.com", adjustments[0].Name)
+	assert.Equal(t, "a CNAME record can't coexist with the A records of its name", adjustments[1].Message)
+}
@@ -0,0 +1,131 @@
+package inwx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// admissionTimeout bounds a decision of the admission policy, which is asked before every mutation.
+const admissionTimeout = 5 * time.Second
+
+// AdmissionInput is the operation an admission policy decides on.
+type AdmissionInput struct {
+	Action string `json:"action"`
+	Zone   string `json:"zone"`
+	// Name is the record name relative to the zone, "" at the apex; FQDN is the full name.
+	Name       string `json:"name"`
+	FQDN       string `json:"fqdn"`
+	Type       string `json:"type"`
+	Content    string `json:"content"`
+	OldContent string `json:"oldContent,omitempty"`
+	TTL        int    `json:"ttl,omitempty"`
+	// Owner is the --txt-owner-id of the external-dns the webhook writes for, if set.
+	Owner string `json:"owner,omitempty"`
+}
+
+// AdmissionDecision is the verdict of an admission policy on an operation.
+type AdmissionDecision struct {
+	Allow bool `json:"allow"`
+	// Reason tells why an operation was denied.
+	Reason string `json:"reason,omitempty"`
+}
+
+// AdmissionPolicy decides on every create, update and delete that passed the zone settings, so that
+// organizational rules beyond the built-in protections can be enforced. Denied operations are skipped like
+// protected records; an error fails the operation, so that external-dns retries it.
+type AdmissionPolicy interface {
+	Admit(ctx context.Context, input AdmissionInput) (AdmissionDecision, error)
+}
+
+// OPAPolicy asks an Open Policy Agent for admission decisions through its data API.
+type OPAPolicy struct {
+	url    string
+	client *http.Client
+}
+
+// NewOPAPolicy returns an OPAPolicy querying the decision document at rawURL, e.g.
+// http://opa:8181/v1/data/externaldns/admission. A nil client means http.DefaultClient.
+func NewOPAPolicy(rawURL string, client *http.Client) (*OPAPolicy, error) {
+	if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid admission policy URL %q, expected an http or https URL", rawURL)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &OPAPolicy{url: rawURL, client: client}, nil
+}
+
+// Admit posts input to OPA. The decision document is either a boolean or an object with an allow boolean
+// and an optional reason. An undefined decision, e.g. because no rule of the package matched, denies.
+func (o *OPAPolicy) Admit(ctx context.Context, input AdmissionInput) (AdmissionDecision, error) {
+	data, err := json.Marshal(map[string]any{"input": input})
+	if err != nil {
+		return AdmissionDecision{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(data))
+	if err != nil {
+		return AdmissionDecision{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return AdmissionDecision{}, fmt.Errorf("unable to query admission policy: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return AdmissionDecision{}, fmt.Errorf("unable to query admission policy: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return AdmissionDecision{}, fmt.Errorf("unable to query admission policy: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var result struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return AdmissionDecision{}, fmt.Errorf("invalid admission policy response: %w", err)
+	}
+	if len(result.Result) == 0 || string(result.Result) == "null" {
+		return AdmissionDecision{Reason: "admission policy decision is undefined"}, nil
+	}
+	var decision AdmissionDecision
+	if err := json.Unmarshal(result.Result, &decision.Allow); err == nil {
+		return decision, nil
+	}
+	if err := json.Unmarshal(result.Result, &decision); err != nil {
+		return AdmissionDecision{}, fmt.Errorf("invalid admission policy decision %s, expected a boolean or an object with allow and reason", result.Result)
+	}
+	return decision, nil
+}
+
+// admit asks the admission policy, if any, about change, returning why it was denied, if it was.
+func (p *INWXProvider) admit(ctx context.Context, change AppliedChange) (string, error) {
+	if p.config.admission == nil {
+		return "", nil
+	}
+	fqdn := change.Zone
+	if change.Name != "" {
+		fqdn = change.Name + "." + change.Zone
+	}
+	ctx, cancel := context.WithTimeout(ctx, admissionTimeout)
+	defer cancel()
+	decision, err := p.config.admission.Admit(ctx, AdmissionInput{Action: change.Action, Zone: change.Zone, Name: change.Name, FQDN: fqdn,
+		Type: change.Type, Content: change.Content, OldContent: change.OldContent, TTL: change.TTL, Owner: p.config.txtOwnerID})
+	if err != nil {
+		return "", err
+	}
+	if decision.Allow {
+		return "", nil
+	}
+	if decision.Reason == "" {
+		decision.Reason = "denied by the admission policy"
+	}
+	return decision.Reason, nil
+}
@@ -0,0 +1,95 @@
+package inwx
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestAdmission(t *testing.T) {
+	t.Run("OPA", testOPAPolicy)
+	t.Run("ApplyChanges", testAdmissionApplyChanges)
+}
+
+// opaServer returns a server answering OPA data API queries with decide, and the inputs it was asked about.
+func opaServer(t *testing.T, decide func(input AdmissionInput) string) (*httptest.Server, *[]AdmissionInput) {
+	var inputs []AdmissionInput
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var query struct {
+			Input AdmissionInput `json:"input"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&query))
+		inputs = append(inputs, query.Input)
+		_, _ = w.Write([]byte(decide(query.Input)))
+	}))
+	t.Cleanup(server.Close)
+	return server, &inputs
+}
+
+func testOPAPolicy(t *testing.T) {
+	_, err := NewOPAPolicy("opa:8181", nil)
+	assert.ErrorContains(t, err, "invalid admission policy URL")
+
+	for response, expected := range map[string]AdmissionDecision{
+		`{"result": true}`:  {Allow: true},
+		`{"result": false}`: {},
+		`{"result": {"allow": false, "reason": "no MX"}}`: {Reason: "no MX"},
+		`{"result": {"allow": true}}`:                     {Allow: true},
+		`{}`:                                              {Reason: "admission policy decision is undefined"},
+	} {
+		server, _ := opaServer(t, func(AdmissionInput) string { return response })
+		policy, err := NewOPAPolicy(server.URL+"/v1/data/externaldns/admission", nil)
+		require.NoError(t, err)
+		decision, err := policy.Admit(context.TODO(), AdmissionInput{Action: "create"})
+		require.NoError(t, err, response)
+		assert.Equal(t, expected, decision, response)
+	}
+
+	server, _ := opaServer(t, func(AdmissionInput) string { return `{"result": "yes"}` })
+	policy, err := NewOPAPolicy(server.URL, nil)
+	require.NoError(t, err)
+	_, err = policy.Admit(context.TODO(), AdmissionInput{})
+	assert.ErrorContains(t, err, "invalid admission policy decision")
+}
+
+func testAdmissionApplyChanges(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	p.config.txtOwnerID = "cluster-a"
+	server, inputs := opaServer(t, func(input AdmissionInput) string {
+		if input.Type == "MX" {
+			return `{"result": {"allow": false, "reason": "MX records are managed by the mail team"}}`
+		}
+		return `{"result": {"allow": true}}`
+	})
+	p.config.admission, _ = NewOPAPolicy(server.URL, nil)
+
+	// Denied operations are skipped like protected records
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	require.NoError(t, p.ApplyChanges(ctx, &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("www.example.com", "A", "192.0.2.1"),
+		endpoint.NewEndpoint("example.com", "MX", "10 mail.example.com"),
+	}}))
+	changes := recorder.Changes()
+	require.Len(t, changes, 2)
+	assert.Equal(t, "applied", changes[0].Outcome)
+	assert.Equal(t, "admission", changes[1].Skipped)
+	assert.Len(t, *w.db["example.com"], 1)
+	assert.Equal(t, AdmissionInput{Action: "create", Zone: "example.com", Name: "www", FQDN: "www.example.com", Type: "A", Content: "192.0.2.1", Owner: "cluster-a"},
+		(*inputs)[0])
+	assert.Equal(t, "example.com", (*inputs)[1].FQDN)
+
+	// An unreachable policy fails the operation, so that external-dns retries it
+	server.Close()
+	err := p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("api.example.com", "A", "192.0.2.2")}})
+	assert.ErrorContains(t, err, "encountered 1 errors")
+	assert.Len(t, *w.db["example.com"], 1)
+}
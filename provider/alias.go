@@ -0,0 +1,54 @@
+package inwx
+
+import (
+	"strconv"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// INWX rejects CNAME records at the zone apex, as they would hide its SOA and NS records, but offers ALIAS
+// records resolving to the addresses of their target instead. CNAME endpoints at the apex are written as
+// ALIAS records if enabled, and ALIAS records at the apex are reported as CNAME endpoints, so that
+// external-dns, which knows no ALIAS records, finds what it created.
+
+// aliasRecordType is the INWX record type of apex aliases.
+const aliasRecordType = "ALIAS"
+
+// AliasProperties are the provider-specific properties deciding whether a CNAME endpoint at the zone apex
+// is written as an ALIAS record, overriding WithApexAlias: "true" or "false". "webhook/inwx-alias" is what
+// external-dns derives from the external-dns.alpha.kubernetes.io/webhook-inwx-alias annotation,
+// "inwx/alias" can be set directly on DNSEndpoint resources.
+var AliasProperties = []string{"inwx/alias", "webhook/inwx-alias"}
+
+// endpointType returns the record type external-dns knows a record of type recordType named name by.
+// Redirects are reported as CNAME endpoints, see redirect.go.
+func endpointType(name string, recordType string) string {
+	if (recordType == aliasRecordType && name == "") || recordType == urlRecordType {
+		return endpoint.RecordTypeCNAME
+	}
+	return recordType
+}
+
+// inwxRecordType returns the INWX record type to write ep as under the record name name.
+func (p *INWXProvider) inwxRecordType(ep *endpoint.Endpoint, name string) string {
+	if redirectType(ep) != "" {
+		p.traceNormalization("record_type", "dns_name", ep.DNSName, "type", ep.RecordType, "inwx_type", urlRecordType)
+		return urlRecordType
+	}
+	if ep.RecordType != endpoint.RecordTypeCNAME || name != "" {
+		return ep.RecordType
+	}
+	alias := p.config.apexAlias
+	for _, property := range AliasProperties {
+		if value, ok := ep.GetProviderSpecificProperty(property); ok {
+			if enabled, err := strconv.ParseBool(value); err == nil {
+				alias = enabled
+			}
+		}
+	}
+	if alias {
+		p.traceNormalization("record_type", "dns_name", ep.DNSName, "type", ep.RecordType, "inwx_type", aliasRecordType)
+		return aliasRecordType
+	}
+	return ep.RecordType
+}
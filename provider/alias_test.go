@@ -0,0 +1,55 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestApexAlias(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	p.config.apexAlias = true
+
+	apex := endpoint.NewEndpointWithTTL("example.com", "CNAME", 300, "lb.example.net")
+	www := endpoint.NewEndpointWithTTL("www.example.com", "CNAME", 300, "lb.example.net")
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	require.NoError(t, p.ApplyChanges(ctx, &plan.Changes{Create: []*endpoint.Endpoint{apex, www}}))
+
+	// Only the apex is written as an ALIAS record, and changes report it as the CNAME it stands for
+	stored := *w.db["example.com"]
+	require.Len(t, stored, 2)
+	assert.Equal(t, "ALIAS", stored[0].Type)
+	assert.Equal(t, "CNAME", stored[1].Type)
+	assert.Equal(t, "CNAME", recorder.Changes()[0].Type)
+
+	// Records reports it as a CNAME, so external-dns plans nothing
+	p.records.invalidate()
+	records, err := p.Records(context.TODO())
+	require.NoError(t, err)
+	changes := (&plan.Plan{Current: records, Desired: []*endpoint.Endpoint{apex, www}, Policies: []plan.Policy{&plan.SyncPolicy{}},
+		ManagedRecords: []string{"CNAME"}}).Calculate().Changes
+	assert.False(t, changes.HasChanges())
+
+	// Updates and deletes find the ALIAS record
+	updated := endpoint.NewEndpointWithTTL("example.com", "CNAME", 300, "lb2.example.net")
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{UpdateOld: []*endpoint.Endpoint{apex}, UpdateNew: []*endpoint.Endpoint{updated}}))
+	assert.Equal(t, "ALIAS", (*w.db["example.com"])[0].Type)
+	assert.Equal(t, "lb2.example.net", (*w.db["example.com"])[0].Content)
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Delete: []*endpoint.Endpoint{updated}}))
+	records, err = p.Records(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "www.example.com", records[0].DNSName)
+
+	// The property overrides the option
+	assert.Equal(t, "CNAME", p.inwxRecordType(apex.DeepCopy().WithProviderSpecific("webhook/inwx-alias", "false"), ""))
+	p.config.apexAlias = false
+	assert.Equal(t, "CNAME", p.inwxRecordType(apex, ""))
+	assert.Equal(t, "ALIAS", p.inwxRecordType(apex.DeepCopy().WithProviderSpecific("inwx/alias", "true"), ""))
+}
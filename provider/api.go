@@ -0,0 +1,127 @@
+package inwx
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// The provider works with these types instead of those of a library talking to INWX, so that the
+// library can be upgraded, or replaced by e.g. an official SDK, by adding a domRobot implementation.
+
+// domRobot is a client of the INWX DomRobot API. Errors reported by the API are returned as apiError.
+type domRobot interface {
+	// login logs in, reporting the account and whether it has two-factor authentication and must be unlocked.
+	login(username string, password string) (loginResult, error)
+	// unlock unlocks an account with two-factor authentication with the current TAN.
+	unlock(tan string) error
+	logout() error
+	// accountInfo is a cheap authenticated call, to check that the session is valid.
+	accountInfo() error
+	// nameserverInfo returns the records of the zone domain.
+	nameserverInfo(domain string) ([]zoneRecord, error)
+	// nameserverList returns a page of the zones of the account, and the total number of zones.
+	nameserverList(page int, pageLimit int) (zones []string, count int, err error)
+	// nameserverCreate creates the master zone of request.
+	nameserverCreate(request *zoneRequest) error
+	createRecord(request *recordRequest) error
+	updateRecord(recID string, request *recordRequest) error
+	deleteRecord(recID string) error
+}
+
+// loginResult is what account.login reports about the account logged in to.
+type loginResult struct {
+	locked     bool
+	customerID int
+	accountID  int
+}
+
+// APIClient names an implementation of the client talking to the INWX API.
+type APIClient string
+
+const (
+	// APIClientGoinwx speaks XML-RPC through the goinwx library.
+	APIClientGoinwx APIClient = "goinwx"
+	// APIClientJSONRPC speaks DomRobot JSON-RPC directly, for API fields and methods goinwx lacks.
+	APIClientJSONRPC APIClient = "jsonrpc"
+)
+
+// ParseAPIClient returns the APIClient named s.
+func ParseAPIClient(s string) (APIClient, error) {
+	switch c := APIClient(s); c {
+	case APIClientGoinwx, APIClientJSONRPC:
+		return c, nil
+	default:
+		return "", fmt.Errorf("unknown INWX API client %q, expected goinwx or jsonrpc", s)
+	}
+}
+
+// ParseAPIURL checks that s is an absolute http or https URL to send the INWX API calls to.
+func ParseAPIURL(s string) (string, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid INWX API URL %q: %w", s, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid INWX API URL %q, expected an http or https URL", s)
+	}
+	return u.String(), nil
+}
+
+// zoneRequest is a zone to create at INWX.
+type zoneRequest struct {
+	Domain      string
+	Nameservers []string
+	// SOAEmail is the responsible mailbox of the SOA record; INWX uses its own when it is empty.
+	SOAEmail string
+}
+
+// zoneRecord is a record of an INWX zone.
+type zoneRecord struct {
+	ID       string
+	Name     string
+	Type     string
+	Content  string
+	TTL      int
+	Priority int
+	// RedirectType is the redirect type of URL records, e.g. HEADER301.
+	RedirectType string
+}
+
+// recordRequest is the record to create, or to update a record to, in the zone Domain.
+type recordRequest struct {
+	Domain   string
+	Name     string
+	Type     string
+	Content  string
+	TTL      int
+	Priority int
+	// RedirectType is the redirect type of URL records, e.g. HEADER301.
+	RedirectType string
+}
+
+// apiError is an error reported by the INWX API, e.g. code 2302 for a record that exists already.
+type apiError struct {
+	Code       int
+	Message    string
+	ReasonCode string
+	Reason     string
+}
+
+// errorCode returns the INWX result code of err as the value of the "code" label, or "transport" for
+// errors not reported by the API, such as connection failures.
+func errorCode(err error) string {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return strconv.Itoa(apiErr.Code)
+	}
+	return "transport"
+}
+
+func (e *apiError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("(%d) %s. Reason: (%s) %s", e.Code, e.Message, e.ReasonCode, e.Reason)
+	}
+	return fmt.Sprintf("(%d) %s", e.Code, e.Message)
+}
@@ -0,0 +1,69 @@
+package inwx
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// planHash returns a hash identifying the content of a change set.
+func planHash(changes *plan.Changes) string {
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// applyDedup remembers the change sets that were applied successfully within a short window, so that
+// identical change sets re-sent by external-dns (e.g. after a client-side timeout) are not applied twice.
+type applyDedup struct {
+	mu      sync.Mutex
+	window  time.Duration
+	applied map[string]time.Time
+}
+
+func newApplyDedup(window time.Duration) *applyDedup {
+	return &applyDedup{window: window, applied: map[string]time.Time{}}
+}
+
+// recentlyApplied reports whether the change set with the given hash succeeded within the window.
+func (d *applyDedup) recentlyApplied(hash string, now time.Time) bool {
+	if d == nil || d.window <= 0 || hash == "" {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	at, ok := d.applied[hash]
+	return ok && now.Sub(at) <= d.window
+}
+
+// remember records the successful application of the change set with the given hash.
+func (d *applyDedup) remember(hash string, now time.Time) {
+	if d == nil || d.window <= 0 || hash == "" {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for h, at := range d.applied {
+		if now.Sub(at) > d.window {
+			delete(d.applied, h)
+		}
+	}
+	d.applied[hash] = now
+}
+
+// len returns the number of change sets remembered, including expired ones not dropped yet.
+func (d *applyDedup) len() int {
+	if d == nil {
+		return 0
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.applied)
+}
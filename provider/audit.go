@@ -0,0 +1,117 @@
+package inwx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// AuditEntry is a change applied against INWX, or deliberately skipped, as written to the audit log.
+type AuditEntry struct {
+	Time    Timestamp `json:"time"`
+	TraceID string    `json:"traceId,omitempty"`
+	// PlanHash identifies the change set the change is part of.
+	PlanHash string `json:"planHash,omitempty"`
+	AppliedChange
+}
+
+// AuditStore persists the audit log. Append is called once per ApplyChanges with all of its changes;
+// Close is called when the provider shuts down.
+type AuditStore interface {
+	Append(ctx context.Context, entries []AuditEntry) error
+	Close() error
+}
+
+// marshalAuditEntries encodes entries as JSON lines.
+func marshalAuditEntries(entries []AuditEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// FileAuditStore appends the audit log to a local file as JSON lines. Rotation is left to the usual
+// tools, e.g. logrotate with copytruncate.
+type FileAuditStore struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileAuditStore opens path for appending, creating it if it doesn't exist.
+func NewFileAuditStore(path string) (*FileAuditStore, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open audit log: %w", err)
+	}
+	return &FileAuditStore{file: file}, nil
+}
+
+// Append writes entries in a single write and syncs them to disk.
+func (s *FileAuditStore) Append(_ context.Context, entries []AuditEntry) error {
+	data, err := marshalAuditEntries(entries)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(data); err != nil {
+		return fmt.Errorf("unable to write audit log: %w", err)
+	}
+	return s.file.Sync()
+}
+
+func (s *FileAuditStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// writeAudit appends the changes collected by recorder to the audit store. The changes were applied
+// already, so a failure is only logged and counted. Without an audit store, every change is logged as an
+// audit record instead, which is machine-parseable with --log.format=json.
+func (p *INWXProvider) writeAudit(ctx context.Context, recorder *ChangeRecorder, hash string) {
+	changes := recorder.Changes()
+	if len(changes) == 0 {
+		return
+	}
+	at, traceID := p.timestamp(now(p.config.clock)), TraceID(ctx)
+	entries := make([]AuditEntry, 0, len(changes))
+	for _, change := range changes {
+		entries = append(entries, AuditEntry{Time: at, TraceID: traceID, PlanHash: hash, AppliedChange: change})
+	}
+	if p.config.auditStore == nil {
+		for _, entry := range entries {
+			p.logger.Info("audit", auditLogArgs(entry)...)
+		}
+		return
+	}
+	if err := p.config.auditStore.Append(context.WithoutCancel(ctx), entries); err != nil {
+		p.metrics.auditWriteErrorsTotal.Inc()
+		p.logger.Error("failed to write audit log", "err", err, "changes", len(entries))
+	}
+}
+
+// auditLogArgs returns the attributes an audit entry is logged with, leaving out those that are empty.
+func auditLogArgs(entry AuditEntry) []any {
+	args := []any{"change_id", entry.ID, "record_key", entry.RecordKey, "action", entry.Action, "zone", entry.Zone, "name", entry.Name, "type", entry.Type,
+		"content", entry.Content, "outcome", entry.Outcome}
+	for _, attr := range [][2]string{
+		{"old_content", entry.OldContent}, {"old_record_key", entry.OldRecordKey}, {"record_id", entry.RecordID}, {"skipped", entry.Skipped},
+		{"error", entry.Error}, {"severity", entry.Severity}, {"trace_id", entry.TraceID}, {"plan_hash", entry.PlanHash},
+	} {
+		if attr[1] != "" {
+			args = append(args, attr[0], attr[1])
+		}
+	}
+	if entry.TTL != 0 {
+		args = append(args, "ttl", entry.TTL)
+	}
+	return args
+}
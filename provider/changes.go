@@ -0,0 +1,318 @@
+package inwx
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// changeAction identifies the kind of mutation performed against INWX.
+type changeAction string
+
+const (
+	actionCreate changeAction = "create"
+	actionUpdate changeAction = "update"
+	actionDelete changeAction = "delete"
+)
+
+// changeID returns a stable, content-addressed identifier for a single mutation.
+// The same action on the same zone/name/type/content always yields the same ID,
+// which makes it possible to correlate log lines, alerts and webhook responses.
+func changeID(action changeAction, zone string, name string, recordType string, content string) string {
+	h := sha256.New()
+	for _, part := range []string{string(action), zone, name, recordType, content} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// recordKey returns a stable identifier for the record of zone with name, type and content. INWX record
+// IDs change whenever a record is recreated, e.g. by a delete and create or in the web panel, while the key
+// only depends on what the record holds, so that external tooling can reference records consistently.
+func recordKey(zone string, name string, recordType string, content string) string {
+	h := sha256.New()
+	for _, part := range []string{strings.ToLower(zone), strings.ToLower(name), recordType, content} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// AppliedChange describes a single mutation performed (or deliberately skipped) during ApplyChanges.
+type AppliedChange struct {
+	ID      string `json:"id"`
+	Action  string `json:"action"`
+	Zone    string `json:"zone"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Content string `json:"content"`
+	// TTL is the TTL the record is created or updated with, 0 for deletes.
+	TTL int `json:"ttl,omitempty"`
+	// RecordID is the INWX ID of the record updated or deleted. INWX doesn't report it for creates.
+	RecordID string `json:"recordId,omitempty"`
+	// RecordKey is the stable identifier of the record created, updated to or deleted, see recordKey. Unlike
+	// RecordID it is known for creates and survives the record being recreated.
+	RecordKey string `json:"recordKey"`
+	// OldContent is the content of the record before an update.
+	OldContent string `json:"oldContent,omitempty"`
+	// OldRecordKey is the stable identifier of the record before an update.
+	OldRecordKey string `json:"oldRecordKey,omitempty"`
+	// Outcome is "applied", "skipped", "warning" or "error".
+	Outcome string `json:"outcome"`
+	Skipped string `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Severity is "warning" for a failure that leaves INWX in the desired state anyway, such as creating
+	// a record that already exists, and "error" otherwise.
+	Severity string `json:"severity,omitempty"`
+}
+
+// ChangeRecorder collects the changes applied while handling a single request.
+type ChangeRecorder struct {
+	mu      sync.Mutex
+	changes []AppliedChange
+}
+
+type changeRecorderKey struct{}
+
+// WithChangeRecorder returns a context that collects every change applied by
+// ApplyChanges into the returned recorder.
+func WithChangeRecorder(ctx context.Context) (context.Context, *ChangeRecorder) {
+	r := &ChangeRecorder{}
+	return context.WithValue(ctx, changeRecorderKey{}, r), r
+}
+
+// Changes returns a copy of the changes recorded so far.
+func (r *ChangeRecorder) Changes() []AppliedChange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]AppliedChange(nil), r.changes...)
+}
+
+// IDs returns the change IDs recorded so far, in the order they were applied.
+func (r *ChangeRecorder) IDs() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]string, 0, len(r.changes))
+	for _, c := range r.changes {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+type appliedRecorderKey struct{}
+
+// withAppliedRecorder returns a context collecting the changes applied with it for the audit log and
+// the report, independently of a ChangeRecorder of the caller.
+func withAppliedRecorder(ctx context.Context) (context.Context, *ChangeRecorder) {
+	r := &ChangeRecorder{}
+	return context.WithValue(ctx, appliedRecorderKey{}, r), r
+}
+
+func (r *ChangeRecorder) add(change AppliedChange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changes = append(r.changes, change)
+}
+
+// applyChange performs the mutation described by change against INWX, logging and recording it under its
+// change ID. Mutations disallowed by the zone settings or denied by the admission policy are skipped, and
+// dry-run zones only log what would change. Errors are wrapped with the change ID so that failures can be
+// correlated with the request that caused them.
+func (p *INWXProvider) applyChange(ctx context.Context, change AppliedChange, do func() error) error {
+	action, zone, name, recordType, content := changeAction(change.Action), change.Zone, change.Name, change.Type, change.Content
+	id := changeID(action, zone, name, recordType, content)
+	change.ID = id
+	change.RecordKey = recordKey(zone, name, recordType, content)
+	if action == actionUpdate {
+		change.OldRecordKey = recordKey(zone, name, recordType, change.OldContent)
+	}
+
+	settings := p.settingsFor(zone)
+	skipped := settings.skipReason(action, name, recordType)
+	// INWX would reject records of types the provider doesn't support; existing ones can still be deleted.
+	if action != actionDelete && !SupportedRecordType(recordType) {
+		skipped = "unsupported_type"
+	}
+	if frozenByRecord(ctx, zone) {
+		skipped = "frozen"
+	}
+	if skipped == "" && action == actionCreate && blockedByRecordLimit(ctx, zone) {
+		skipped = "record_limit"
+	}
+	if skipped == "" && settings.dryRun {
+		skipped = "dry_run"
+	}
+	if skipped == "" && outOfTime(ctx) {
+		skipped = "deadline"
+	}
+	var err error
+	if skipped == "" {
+		var denied string
+		if denied, err = p.admit(ctx, change); denied != "" {
+			p.logger.Warn("change denied by the admission policy", "change_id", id, "action", action, "zone", zone, "name", name, "type", recordType,
+				"content", content, "reason", denied)
+			skipped = "admission"
+		}
+	}
+	if skipped != "" {
+		p.logger.Info("skipping change", "change_id", id, "reason", skipped, "action", action, "zone", zone, "name", name, "type", recordType, "content", content)
+		p.metrics.skippedChangesTotal.WithLabelValues(zone, string(action), skipped).Inc()
+		change.Skipped, change.Outcome = skipped, "skipped"
+		p.recordChange(ctx, change)
+		stepProgress(ctx, change)
+		if skipped == "deadline" {
+			return fmt.Errorf("change %s: %w", id, ErrDeadlineExceeded)
+		}
+		return nil
+	}
+
+	if err == nil {
+		err = p.waitForRateLimit(ctx, zone, settings.rateLimit)
+	}
+	if err == nil {
+		err = classifyChangeError(action, traceCall(ctx, "nameserver."+string(action)+"Record", do,
+			attribute.String("dns.zone", zone), attribute.String("dns.name", name), attribute.String("dns.type", recordType),
+			attribute.String("inwx.change_id", id)))
+	}
+	if err != nil {
+		change.Error, change.Severity = err.Error(), severity(err)
+		change.Outcome = change.Severity
+		if isWarning(err) {
+			err = &changeWarning{fmt.Errorf("change %s: %w", id, err)}
+		} else {
+			err = fmt.Errorf("change %s: %w", id, err)
+		}
+	} else {
+		change.Outcome = "applied"
+		p.logger.Debug("applied change", "change_id", id, "record_key", change.RecordKey, "action", action, "zone", zone, "name", name, "type", recordType, "content", content)
+		p.drift.expect(zone, name, recordType, content)
+		if p.flaps.observe(zone, name, recordType, now(p.config.clock)) {
+			p.logger.Warn("record is flapping, check the sources producing it", "zone", zone, "name", name, "type", recordType,
+				"changes", p.flaps.threshold, "window", p.flaps.window)
+		}
+	}
+	inc(ctx, p.metrics.changesTotal.WithLabelValues(zone, string(action), resultLabel(err)))
+	p.recordChange(ctx, change)
+	stepProgress(ctx, change)
+	return err
+}
+
+func (p *INWXProvider) recordChange(ctx context.Context, change AppliedChange) {
+	if r, ok := ctx.Value(changeRecorderKey{}).(*ChangeRecorder); ok {
+		r.add(change)
+	}
+	if r, ok := ctx.Value(appliedRecorderKey{}).(*ChangeRecorder); ok {
+		r.add(change)
+	}
+	p.publishChange(ctx, change)
+}
+
+func (p *INWXProvider) createRecord(ctx context.Context, rec *recordRequest) error {
+	p.applyDefaultTTL(rec)
+	p.traceNormalization("payload", "action", actionCreate, "domain", rec.Domain, "name", rec.Name, "type", rec.Type, "ttl", rec.TTL, "content", rec.Content)
+	change := AppliedChange{Action: string(actionCreate), Zone: rec.Domain, Name: rec.Name, Type: endpointType(rec.Name, rec.Type), Content: rec.Content, TTL: rec.TTL}
+	return p.applyChange(ctx, change, func() error {
+		if err := p.client.createRecord(rec); err != nil {
+			return err
+		}
+		p.records.created(withRedirect(p.cachedEndpoint(rec.Domain, rec.Name, rec.Type, rec.TTL, rec.Content), rec.RedirectType))
+		return nil
+	})
+}
+
+// updateRecord updates the record recID, whose content was oldContent, to rec.
+func (p *INWXProvider) updateRecord(ctx context.Context, recID string, oldContent string, rec *recordRequest) error {
+	p.applyDefaultTTL(rec)
+	p.traceNormalization("payload", "action", actionUpdate, "id", recID, "domain", rec.Domain, "name", rec.Name, "type", rec.Type, "ttl", rec.TTL, "content", rec.Content)
+	change := AppliedChange{Action: string(actionUpdate), Zone: rec.Domain, Name: rec.Name, Type: endpointType(rec.Name, rec.Type), Content: rec.Content, TTL: rec.TTL,
+		RecordID: recID, OldContent: oldContent}
+	return p.applyChange(ctx, change, func() error {
+		if err := p.client.updateRecord(recID, rec); err != nil {
+			return err
+		}
+		p.records.updated(recID, withRedirect(p.cachedEndpoint(rec.Domain, rec.Name, rec.Type, rec.TTL, rec.Content), rec.RedirectType))
+		return nil
+	})
+}
+
+func (p *INWXProvider) deleteRecord(ctx context.Context, zone string, name string, recordType string, content string, recID string) error {
+	p.traceNormalization("payload", "action", actionDelete, "id", recID, "domain", zone, "name", name, "type", recordType, "content", content)
+	change := AppliedChange{Action: string(actionDelete), Zone: zone, Name: name, Type: recordType, Content: content, RecordID: recID}
+	return p.applyChange(ctx, change, func() error {
+		err := p.client.deleteRecord(recID)
+		if isObjectDoesNotExistError(err) {
+			recID, err = p.deleteStaleRecord(ctx, zone, name, recordType, content, recID, err)
+		}
+		if err != nil {
+			return err
+		}
+		p.records.deleted(recID, p.cachedEndpoint(zone, name, recordType, 0, content))
+		return nil
+	})
+}
+
+// deleteStaleRecord deletes a record whose ID went stale between reading and deleting it, e.g. because
+// other automation recreated it, by resolving its current ID from its name, type and content. If the
+// record is gone for good, notFound is returned. The ID of the record deleted is returned.
+func (p *INWXProvider) deleteStaleRecord(ctx context.Context, zone string, name string, recordType string, content string, staleID string, notFound error) (string, error) {
+	records, err := p.getRecords(ctx, zone)
+	if err != nil {
+		return staleID, notFound
+	}
+	recID := findExactRecord(findRecordsByNameAndType(name, records, recordType), content)
+	if recID == "" || recID == staleID {
+		return staleID, notFound
+	}
+	slog.Info("record ID went stale, deleting the record by its current ID", "zone", zone, "name", name, "type", recordType,
+		"content", content, "stale_id", staleID, "id", recID)
+	p.metrics.staleRecordIDsTotal.Inc()
+	return recID, p.client.deleteRecord(recID)
+}
+
+// cachedEndpoint returns the endpoint a record is listed as by Records.
+func (p *INWXProvider) cachedEndpoint(zone string, name string, recordType string, ttl int, content string) *endpoint.Endpoint {
+	recordType = endpointType(name, recordType)
+	return endpoint.NewEndpointWithTTL(p.registry.EndpointName(name, zone, recordType), recordType, endpoint.TTL(ttl), content)
+}
+
+// applyDefaultTTL sets the zone's default TTL on requests whose endpoint has no TTL configured.
+func (p *INWXProvider) applyDefaultTTL(rec *recordRequest) {
+	if rec.TTL != 0 {
+		p.traceNormalization("ttl", "domain", rec.Domain, "name", rec.Name, "type", rec.Type, "ttl", rec.TTL, "source", "endpoint")
+		return
+	}
+	rec.TTL = p.settingsFor(rec.Domain).ttl
+	if rec.TTL == 0 {
+		p.traceNormalization("ttl", "domain", rec.Domain, "name", rec.Name, "type", rec.Type, "ttl", rec.TTL, "source", "inwx_default")
+	} else {
+		p.traceNormalization("ttl", "domain", rec.Domain, "name", rec.Name, "type", rec.Type, "ttl", rec.TTL, "source", "zone_default")
+	}
+}
+
+// conflictingDeletes splits the deletes of changes into those that must precede the creates, because a
+// create replaces the deleted record with one of a conflicting type at the same name, and the others.
+// A CNAME can't coexist with any other record of its name, so INWX rejects such a create while the old
+// record exists.
+func conflictingDeletes(changes *plan.Changes) (conflicting []*endpoint.Endpoint, others []*endpoint.Endpoint) {
+	for _, del := range changes.Delete {
+		if slices.ContainsFunc(changes.Create, func(create *endpoint.Endpoint) bool {
+			return normalizeName(create.DNSName) == normalizeName(del.DNSName) && create.RecordType != del.RecordType &&
+				(create.RecordType == endpoint.RecordTypeCNAME || del.RecordType == endpoint.RecordTypeCNAME)
+		}) {
+			conflicting = append(conflicting, del)
+		} else {
+			others = append(others, del)
+		}
+	}
+	return conflicting, others
+}
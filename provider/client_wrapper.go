@@ -2,86 +2,210 @@ package inwx
 
 import (
 	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync/atomic"
 	"time"
 
-	inwx "github.com/nrdcg/goinwx"
+	"sigs.k8s.io/external-dns/endpoint"
 )
 
 const zonesCacheTTL = 5 * time.Minute
 
+// ClientWrapper talks to INWX through a DomRobot client, caching the zone list and reporting slow calls.
 type ClientWrapper struct {
-	client         *inwx.Client
-	zonesCache     []string
-	zonesCacheTime time.Time
+	api               domRobot
+	transport         http.RoundTripper
+	logger            *slog.Logger
+	metrics           *metrics
+	slowCallThreshold time.Duration
+	zonesCache        atomic.Pointer[zonesSnapshot]
+	clock             Clock
+	rand              *lockedRand
+	session           *session
+	credentials       atomic.Pointer[Credentials]
+	environment       string
+	account           atomic.Pointer[Account]
+}
+
+// zonesSnapshot is an immutable list of zones, replaced as a whole when the cache expires.
+type zonesSnapshot struct {
+	zones   []string
+	expires time.Time
 }
 
 type AbstractClientWrapper interface {
-	login() (*inwx.LoginResponse, error)
+	login() error
 	logout() error
-	getRecords(domain string) (*[]inwx.NameserverRecord, error)
+	getRecords(domain string) (*[]zoneRecord, error)
 	getZones() (*[]string, error)
-	createRecord(request *inwx.NameserverRecordRequest) error
-	updateRecord(recID string, request *inwx.NameserverRecordRequest) error
+	// forgetZones drops the cached zone list, so that the next getZones reads it from INWX.
+	forgetZones()
+	// cachedZones returns the number of cached zones and when they expire, if any are cached.
+	cachedZones() (count int, expires time.Time, ok bool)
+	// setCredentials replaces the credentials, which are used from the next login on.
+	setCredentials(credentials Credentials)
+	// loggedInAccount returns the account of the last login, if any.
+	loggedInAccount() (Account, bool)
+	// checkSession makes sure that the session is still valid before an apply.
+	checkSession() error
+	// createZone creates the zone of request, dropping the cached zone list.
+	createZone(request *zoneRequest) error
+	createRecord(request *recordRequest) error
+	updateRecord(recID string, request *recordRequest) error
 	deleteRecord(recID string) error
+	close()
+}
+
+// call runs a single INWX API call within the session, see inSession.
+func (w *ClientWrapper) call(method string, zone string, fn func() error) error {
+	return w.inSession(func() error {
+		return w.timed(method, zone, fn)
+	})
 }
 
-func (w *ClientWrapper) login() (*inwx.LoginResponse, error) {
-	return w.client.Account.Login()
+// timed runs fn as the INWX API call method, counting it, its errors and its latency, and reporting it
+// when it exceeds the slow call threshold.
+func (w *ClientWrapper) timed(method string, zone string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	elapsed := time.Since(start)
+	w.metrics.apiCallsTotal.WithLabelValues(method).Inc()
+	w.metrics.apiCallDuration.WithLabelValues(method).Observe(elapsed.Seconds())
+	if err != nil {
+		w.metrics.apiErrorsTotal.WithLabelValues(method, errorCode(err)).Inc()
+	}
+	if w.slowCallThreshold > 0 && elapsed >= w.slowCallThreshold {
+		w.metrics.slowCallsTotal.WithLabelValues(method).Inc()
+		w.logger.Warn("slow INWX API call", "method", method, "zone", zone, "duration", elapsed, "threshold", w.slowCallThreshold, "err", err)
+	}
+	return err
 }
 
+// login starts the session of a Records or ApplyChanges request. Per-operation sessions log in for
+// every call instead, and persistent ones only if they aren't logged in yet.
+func (w *ClientWrapper) login() error {
+	switch w.session.scopeOf() {
+	case SessionPerOperation:
+		return nil
+	case SessionPersistent:
+		return w.persistentLogin()
+	default:
+		return w.authenticate()
+	}
+}
+
+// logout ends the session of a Records or ApplyChanges request, unless it outlives the request.
 func (w *ClientWrapper) logout() error {
-	return w.client.Account.Logout()
+	if w.session.scopeOf() != SessionPerReconcile {
+		return nil
+	}
+	return w.timed("account.logout", "", w.api.logout)
 }
 
-func (w *ClientWrapper) getRecords(domain string) (*[]inwx.NameserverRecord, error) {
-	zone, err := w.client.Nameservers.Info(&inwx.NameserverInfoRequest{Domain: domain})
+func (w *ClientWrapper) getRecords(domain string) (*[]zoneRecord, error) {
+	var records []zoneRecord
+	err := w.call("nameserver.info", domain, func() (err error) {
+		records, err = w.api.nameserverInfo(domain)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("unable to retrieve records for zone %s: %w", domain, err)
 	}
-	return &zone.Records, nil
+	// Records written quoted, e.g. by earlier versions, are read as their text.
+	for i, rec := range records {
+		if rec.Type == endpoint.RecordTypeTXT {
+			records[i].Content = unquoteTXT(rec.Content)
+		}
+		records[i].Content = endpointContent(records[i])
+	}
+	return &records, nil
 }
 
 func (w *ClientWrapper) getZones() (*[]string, error) {
-	if w.zonesCache != nil && time.Since(w.zonesCacheTime) < zonesCacheTTL {
-		zones := w.zonesCache
+	if cached := w.zonesCache.Load(); cached != nil && now(w.clock).Before(cached.expires) {
+		zones := slices.Clone(cached.zones)
 		return &zones, nil
 	}
 
 	zones := []string{}
 	page := 1
 	for {
-		response, err := w.client.Nameservers.ListWithParams(&inwx.NameserverListRequest{
-			Domain:    "*",
-			Page:      page,
-			PageLimit: 100,
+		var domains []string
+		var count int
+		err := w.call("nameserver.list", "", func() (err error) {
+			domains, count, err = w.api.nameserverList(page, 100)
+			return err
 		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to list nameserver zones (page %d): %w", page, err)
 		}
-		for _, domain := range response.Domains {
-			zones = append(zones, domain.Domain)
-		}
-		if len(response.Domains) == 0 || len(zones) >= response.Count {
+		zones = append(zones, domains...)
+		if len(domains) == 0 || len(zones) >= count {
 			break
 		}
 		page++
 	}
 
-	w.zonesCache = zones
-	w.zonesCacheTime = time.Now()
+	// The expiry is jittered so that replicas don't all refresh their zones at once.
+	w.zonesCache.Store(&zonesSnapshot{zones: slices.Clone(zones), expires: now(w.clock).Add(w.rand.jitter(zonesCacheTTL, 0.1))})
 
 	return &zones, nil
 }
 
-func (w *ClientWrapper) createRecord(request *inwx.NameserverRecordRequest) error {
-	_, err := w.client.Nameservers.CreateRecord(request)
+func (w *ClientWrapper) forgetZones() {
+	w.zonesCache.Store(nil)
+}
+
+func (w *ClientWrapper) cachedZones() (int, time.Time, bool) {
+	cached := w.zonesCache.Load()
+	if cached == nil {
+		return 0, time.Time{}, false
+	}
+	return len(cached.zones), cached.expires, true
+}
+
+func (w *ClientWrapper) createZone(request *zoneRequest) error {
+	err := w.call("nameserver.create", request.Domain, func() error {
+		return w.api.nameserverCreate(request)
+	})
+	if err == nil {
+		w.forgetZones()
+	}
 	return err
 }
 
-func (w *ClientWrapper) updateRecord(recID string, request *inwx.NameserverRecordRequest) error {
-	return w.client.Nameservers.UpdateRecord(recID, request)
+func (w *ClientWrapper) createRecord(request *recordRequest) error {
+	request, err := inwxRecordRequest(request)
+	if err != nil {
+		return err
+	}
+	return w.call("nameserver.createRecord", request.Domain, func() error {
+		return w.api.createRecord(request)
+	})
+}
+
+func (w *ClientWrapper) updateRecord(recID string, request *recordRequest) error {
+	request, err := inwxRecordRequest(request)
+	if err != nil {
+		return err
+	}
+	return w.call("nameserver.updateRecord", request.Domain, func() error {
+		return w.api.updateRecord(recID, request)
+	})
 }
 
 func (w *ClientWrapper) deleteRecord(recID string) error {
-	return w.client.Nameservers.DeleteRecord(recID)
+	return w.call("nameserver.deleteRecord", "", func() error {
+		return w.api.deleteRecord(recID)
+	})
+}
+
+// close ends a persistent session and releases the idle connections to the INWX API.
+func (w *ClientWrapper) close() {
+	w.endSession()
+	if t, ok := w.transport.(interface{ CloseIdleConnections() }); ok {
+		t.CloseIdleConnections()
+	}
 }
@@ -0,0 +1,71 @@
+package inwx
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientWrapper(t *testing.T) {
+	responses := map[string]string{
+		"nameserver.info": `<struct>
+			<member><name>code</name><value><int>1000</int></value></member>
+			<member><name>resData</name><value><struct><member><name>record</name><value><array><data>
+				<value><struct>
+					<member><name>id</name><value><string>1</string></value></member>
+					<member><name>name</name><value><string>foo</string></value></member>
+					<member><name>type</name><value><string>TXT</string></value></member>
+					<member><name>content</name><value><string>"v=spf1 -all"</string></value></member>
+					<member><name>TTL</name><value><int>300</int></value></member>
+				</struct></value>
+			</data></array></value></member></struct></value></member>
+		</struct>`,
+		"nameserver.createRecord": `<struct>
+			<member><name>code</name><value><int>2302</int></value></member>
+			<member><name>msg</name><value><string>Object exists</string></value></member>
+		</struct>`,
+	}
+	transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		for method, response := range responses {
+			if strings.Contains(string(body), "<methodName>"+method+"</methodName>") {
+				xml := "<?xml version=\"1.0\"?><methodResponse><params><param><value>" + response + "</value></param></params></methodResponse>"
+				return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"text/xml"}}, Body: io.NopCloser(strings.NewReader(xml)), Request: r}, nil
+			}
+		}
+		return nil, errors.New("unexpected request")
+	})
+	w := &ClientWrapper{api: goinwxClient{newINWXClient(true, "", transport, slog.Default())}, logger: slog.Default(), metrics: newMetrics()}
+	calls := testutil.ToFloat64(w.metrics.apiCallsTotal.WithLabelValues("nameserver.createRecord"))
+	errs := testutil.ToFloat64(w.metrics.apiErrorsTotal.WithLabelValues("nameserver.createRecord", "2302"))
+	transportErrs := testutil.ToFloat64(w.metrics.apiErrorsTotal.WithLabelValues("nameserver.deleteRecord", "transport"))
+
+	// Records are converted, with TXT contents unquoted
+	records, err := w.getRecords("example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []zoneRecord{{ID: "1", Name: "foo", Type: "TXT", Content: "v=spf1 -all", TTL: 300}}, *records)
+
+	// API errors are converted, so that they are classified without knowing the client library
+	err = w.createRecord(&recordRequest{Domain: "example.com", Name: "foo", Type: "A", Content: "192.0.2.1"})
+	var apiErr *apiError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, codeObjectExists, apiErr.Code)
+	assert.Equal(t, "(2302) Object exists", err.Error())
+	assert.True(t, isWarning(classifyChangeError(actionCreate, err)))
+
+	// Calls are counted by method, errors by INWX result code
+	assert.Error(t, w.deleteRecord("1"))
+	assert.Equal(t, calls+1, testutil.ToFloat64(w.metrics.apiCallsTotal.WithLabelValues("nameserver.createRecord")))
+	assert.Equal(t, errs+1, testutil.ToFloat64(w.metrics.apiErrorsTotal.WithLabelValues("nameserver.createRecord", "2302")))
+	assert.Equal(t, transportErrs+1, testutil.ToFloat64(w.metrics.apiErrorsTotal.WithLabelValues("nameserver.deleteRecord", "transport")))
+}
@@ -0,0 +1,53 @@
+package inwx
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Clock tells the time for everything time-dependent in the provider: cache TTLs, duplicate apply
+// suppression, flap detection and the zones cache. Replace it with WithClock to control time in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock returning the current system time.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// now returns the time of clock, or the system time if there is none.
+func now(clock Clock) time.Time {
+	if clock == nil {
+		return time.Now()
+	}
+	return clock.Now()
+}
+
+// lockedRand makes a *rand.Rand, which is not safe for concurrent use, shareable between requests.
+type lockedRand struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func newLockedRand(r *rand.Rand) *lockedRand {
+	if r == nil {
+		r = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+	return &lockedRand{rand: r}
+}
+
+// jitter returns d varied randomly by up to fraction of it in either direction, so that retries and
+// refreshes of several replicas don't happen in lockstep.
+func (r *lockedRand) jitter(d time.Duration, fraction float64) time.Duration {
+	if r == nil || d <= 0 || fraction <= 0 {
+		return d
+	}
+	r.mu.Lock()
+	f := r.rand.Float64()
+	r.mu.Unlock()
+	return d + time.Duration((2*f-1)*fraction*float64(d))
+}
@@ -0,0 +1,84 @@
+package inwx
+
+import (
+	"fmt"
+	"slices"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// CollisionStrategy decides what happens to desired endpoints of the same name and type, e.g. produced by
+// two sources, which the creates would otherwise write to the zone in an arbitrary order.
+type CollisionStrategy string
+
+const (
+	// CollisionMerge combines the targets of the colliding endpoints into the first one.
+	CollisionMerge CollisionStrategy = "merge"
+	// CollisionPreferFirst keeps the first of the colliding endpoints and drops the others.
+	CollisionPreferFirst CollisionStrategy = "first"
+	// CollisionError rejects the endpoints, failing the reconcile until the sources are fixed.
+	CollisionError CollisionStrategy = "error"
+)
+
+// ParseCollisionStrategy returns the CollisionStrategy named s.
+func ParseCollisionStrategy(s string) (CollisionStrategy, error) {
+	switch strategy := CollisionStrategy(s); strategy {
+	case CollisionMerge, CollisionPreferFirst, CollisionError:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("unknown name collision strategy %q, expected merge, first or error", s)
+	}
+}
+
+// collisionKey identifies the endpoints colliding with each other.
+type collisionKey struct {
+	name          string
+	recordType    string
+	setIdentifier string
+}
+
+// resolveCollisions applies the collision strategy to endpoints of the same name, type and set identifier
+// with different targets or TTLs. Exact duplicates are dropped with every strategy. The endpoints of the
+// caller are left alone.
+func (p *INWXProvider) resolveCollisions(endpoints []*endpoint.Endpoint, adjust adjustFunc) ([]*endpoint.Endpoint, error) {
+	strategy := p.config.collisionStrategy
+	if strategy == "" {
+		strategy = CollisionMerge
+	}
+	resolved := make([]*endpoint.Endpoint, 0, len(endpoints))
+	first := map[collisionKey]int{}
+	merged := map[int]bool{}
+	for _, ep := range endpoints {
+		key := collisionKey{name: ep.DNSName, recordType: ep.RecordType, setIdentifier: ep.SetIdentifier}
+		i, ok := first[key]
+		if !ok {
+			first[key] = len(resolved)
+			resolved = append(resolved, ep)
+			continue
+		}
+		kept := resolved[i]
+		if kept.Targets.Same(ep.Targets) && kept.RecordTTL == ep.RecordTTL {
+			continue
+		}
+		switch strategy {
+		case CollisionError:
+			return nil, fmt.Errorf("endpoints %s %s collide: targets %v and %v", ep.DNSName, ep.RecordType, kept.Targets, ep.Targets)
+		case CollisionPreferFirst:
+			adjust(ep, adjustmentDropped, "collision", "collides with an endpoint of the same name and type, keeping its targets %v, dropping %v",
+				kept.Targets, ep.Targets)
+		default:
+			adjust(ep, adjustmentDropped, "collision", "collides with an endpoint of the same name and type, merging %v into its targets %v with TTL %d",
+				ep.Targets, kept.Targets, kept.RecordTTL)
+			if !merged[i] {
+				kept = kept.DeepCopy()
+				resolved[i], merged[i] = kept, true
+			}
+			for _, target := range ep.Targets {
+				if !slices.Contains(kept.Targets, target) {
+					kept.Targets = append(kept.Targets, target)
+				}
+			}
+		}
+	}
+	return resolved, nil
+}
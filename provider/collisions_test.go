@@ -0,0 +1,64 @@
+package inwx
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+func TestNameCollisions(t *testing.T) {
+	endpoints := func() []*endpoint.Endpoint {
+		return []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("foo.example.com", "A", 300, "192.0.2.1"),
+			endpoint.NewEndpointWithTTL("bar.example.com", "A", 300, "192.0.2.3"),
+			endpoint.NewEndpointWithTTL("foo.example.com", "A", 300, "192.0.2.2", "192.0.2.1"),
+			endpoint.NewEndpointWithTTL("bar.example.com", "A", 300, "192.0.2.3"),
+			endpoint.NewEndpointWithTTL("foo.example.com", "AAAA", 300, "2001:db8::1"),
+		}
+	}
+	_, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+
+	t.Run("Merge", func(t *testing.T) {
+		p.config.collisionStrategy = CollisionMerge
+		eps := endpoints()
+		adjusted, err := p.AdjustEndpoints(eps)
+		require.NoError(t, err)
+		require.Len(t, adjusted, 3)
+		assert.Equal(t, endpoint.Targets{"192.0.2.1", "192.0.2.2"}, adjusted[0].Targets)
+		assert.Same(t, eps[1], adjusted[1])
+		assert.Same(t, eps[4], adjusted[2])
+		// The endpoints of the caller are left alone
+		assert.Equal(t, endpoint.Targets{"192.0.2.1"}, eps[0].Targets)
+	})
+
+	t.Run("PreferFirst", func(t *testing.T) {
+		p.config.collisionStrategy = CollisionPreferFirst
+		eps := endpoints()
+		adjusted, err := p.AdjustEndpoints(eps)
+		require.NoError(t, err)
+		assert.Equal(t, []*endpoint.Endpoint{eps[0], eps[1], eps[4]}, adjusted)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		p.config.collisionStrategy = CollisionError
+		_, err := p.AdjustEndpoints(endpoints())
+		assert.ErrorContains(t, err, "foo.example.com A collide")
+
+		// Exact duplicates don't collide
+		eps := endpoints()
+		adjusted, err := p.AdjustEndpoints([]*endpoint.Endpoint{eps[1], eps[3]})
+		require.NoError(t, err)
+		assert.Len(t, adjusted, 1)
+	})
+
+	t.Run("ParseCollisionStrategy", func(t *testing.T) {
+		strategy, err := ParseCollisionStrategy("first")
+		require.NoError(t, err)
+		assert.Equal(t, CollisionPreferFirst, strategy)
+		_, err = ParseCollisionStrategy("last")
+		assert.Error(t, err)
+	})
+}
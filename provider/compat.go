@@ -0,0 +1,159 @@
+package inwx
+
+import (
+	"fmt"
+	"mime"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Protocol mismatches between external-dns and the webhook only surface as JSON errors deep in a
+// reconcile. The external-dns version and webhook protocol of the requests are therefore checked against
+// a compatibility matrix, and unsupported combinations are reported in the logs, the health and the
+// metrics.
+
+// Compatibility statuses.
+const (
+	CompatibilitySupported   = "supported"
+	CompatibilityUntested    = "untested"
+	CompatibilityUnsupported = "unsupported"
+	CompatibilityUnknown     = "unknown"
+)
+
+// webhookMediaType is the media type of the webhook API, whose version parameter is the protocol version.
+const webhookMediaType = "application/external.dns.webhook+json"
+
+// webhookProtocolVersion is the version of the webhook protocol the webhook speaks.
+const webhookProtocolVersion = "1"
+
+// compatibilityMatrix rates the external-dns releases from the version of each entry up to the next one.
+var compatibilityMatrix = []struct {
+	from    [3]int
+	status  string
+	message string
+}{
+	{[3]int{0, 0, 0}, CompatibilityUnsupported, "external-dns supports webhook providers since v0.14.0"},
+	{[3]int{0, 14, 0}, CompatibilitySupported, ""},
+	{[3]int{0, 21, 0}, CompatibilityUntested, "newer than external-dns v0.20, the latest release tested"},
+}
+
+// externalDNSUserAgent matches the external-dns version in a User-Agent header.
+var externalDNSUserAgent = regexp.MustCompile(`(?i)external-dns/v?(\d+)\.(\d+)\.(\d+)`)
+
+// ExternalDNSClient is an external-dns instance as far as its requests tell.
+type ExternalDNSClient struct {
+	// Version is the external-dns version, e.g. 0.15.1, if its User-Agent names it.
+	Version string `json:"version,omitempty"`
+	// Protocol is the webhook protocol version of its media type.
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// ParseExternalDNSClient identifies the external-dns instance sending a request with the given
+// User-Agent and Accept or Content-Type header.
+func ParseExternalDNSClient(userAgent string, mediaType string) ExternalDNSClient {
+	var client ExternalDNSClient
+	if m := externalDNSUserAgent.FindStringSubmatch(userAgent); m != nil {
+		client.Version = m[1] + "." + m[2] + "." + m[3]
+	}
+	if t, params, err := mime.ParseMediaType(mediaType); err == nil && t == webhookMediaType {
+		client.Protocol = params["version"]
+	}
+	return client
+}
+
+// Compatibility is an external-dns instance rated by the compatibility matrix.
+type Compatibility struct {
+	ExternalDNSClient
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// CheckCompatibility rates client by the compatibility matrix. Clients not naming their version are only
+// checked for the webhook protocol.
+func CheckCompatibility(client ExternalDNSClient) Compatibility {
+	c := Compatibility{ExternalDNSClient: client, Status: CompatibilityUnknown}
+	if client.Protocol != "" && client.Protocol != webhookProtocolVersion {
+		c.Status = CompatibilityUnsupported
+		c.Message = fmt.Sprintf("webhook protocol version %s is not supported, the webhook speaks version %s", client.Protocol, webhookProtocolVersion)
+		return c
+	}
+	version, ok := parseVersion(client.Version)
+	if !ok {
+		if client.Protocol != "" {
+			c.Message = "external-dns doesn't name its version, only the webhook protocol was checked"
+		}
+		return c
+	}
+	for _, entry := range compatibilityMatrix {
+		if compareVersions(version, entry.from) >= 0 {
+			c.Status, c.Message = entry.status, entry.message
+		}
+	}
+	return c
+}
+
+// parseVersion parses a major.minor.patch version.
+func parseVersion(s string) ([3]int, bool) {
+	var version [3]int
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return version, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return version, false
+		}
+		version[i] = n
+	}
+	return version, true
+}
+
+func compareVersions(a [3]int, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] - b[i]
+		}
+	}
+	return 0
+}
+
+// compatibilityTracker holds the rating of the last external-dns instance seen.
+type compatibilityTracker struct {
+	mu   sync.Mutex
+	last *Compatibility
+}
+
+// ObserveClient rates the external-dns instance sending a request and reports it in the client
+// compatibility metric and the compatibility health component, which is degraded for unsupported
+// combinations. A rating differing from the last one is logged, at warn level if untested or unsupported. Requests
+// identifying neither a version nor a protocol, e.g. of curl, are ignored.
+func (p *INWXProvider) ObserveClient(client ExternalDNSClient) Compatibility {
+	c := CheckCompatibility(client)
+	if client == (ExternalDNSClient{}) {
+		return c
+	}
+	p.compat.mu.Lock()
+	defer p.compat.mu.Unlock()
+	if p.compat.last != nil && *p.compat.last == c {
+		return c
+	}
+	p.compat.last = &c
+
+	p.metrics.clientCompatibility.Reset()
+	p.metrics.clientCompatibility.WithLabelValues(c.Version, c.Protocol, c.Status).Set(1)
+	state := HealthOK
+	if c.Status == CompatibilityUnsupported {
+		state = HealthDegraded
+	}
+	p.health.observe(componentCompatibility, state, c.Message, now(p.config.clock))
+	args := []any{"external_dns_version", c.Version, "protocol", c.Protocol, "status", c.Status, "message", c.Message}
+	if c.Status == CompatibilityUnsupported || c.Status == CompatibilityUntested {
+		p.logger.Warn("external-dns client may not be compatible with this webhook", args...)
+	} else {
+		p.logger.Info("external-dns client identified", args...)
+	}
+	return c
+}
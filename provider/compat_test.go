@@ -0,0 +1,69 @@
+package inwx
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompatibility(t *testing.T) {
+	t.Run("Parse", testParseExternalDNSClient)
+	t.Run("Matrix", testCompatibilityMatrix)
+	t.Run("Observe", testObserveClient)
+}
+
+func testParseExternalDNSClient(t *testing.T) {
+	assert.Equal(t, ExternalDNSClient{Version: "0.15.1", Protocol: "1"},
+		ParseExternalDNSClient("external-dns/v0.15.1", "application/external.dns.webhook+json;version=1"))
+	// external-dns sends Go's default User-Agent, only its media type tells the protocol
+	assert.Equal(t, ExternalDNSClient{Protocol: "1"},
+		ParseExternalDNSClient("Go-http-client/1.1", "application/external.dns.webhook+json;version=1"))
+	assert.Equal(t, ExternalDNSClient{}, ParseExternalDNSClient("curl/8.5.0", "application/json"))
+	assert.Equal(t, ExternalDNSClient{}, ParseExternalDNSClient("", ""))
+}
+
+func testCompatibilityMatrix(t *testing.T) {
+	for client, expected := range map[ExternalDNSClient]string{
+		{Version: "0.13.6", Protocol: "1"}: CompatibilityUnsupported,
+		{Version: "0.14.0", Protocol: "1"}: CompatibilitySupported,
+		{Version: "0.20.0"}:                CompatibilitySupported,
+		{Version: "0.21.0", Protocol: "1"}: CompatibilityUntested,
+		{Version: "1.0.0", Protocol: "1"}:  CompatibilityUntested,
+		{Version: "0.15.0", Protocol: "2"}: CompatibilityUnsupported,
+		{Protocol: "2"}:                    CompatibilityUnsupported,
+		{Protocol: "1"}:                    CompatibilityUnknown,
+	} {
+		assert.Equal(t, expected, CheckCompatibility(client).Status, "%+v", client)
+	}
+	assert.Contains(t, CheckCompatibility(ExternalDNSClient{Version: "0.13.6"}).Message, "since v0.14.0")
+}
+
+func testObserveClient(t *testing.T) {
+	_, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	component := func() ComponentHealth {
+		for _, c := range p.Health().Components {
+			if c.Name == componentCompatibility {
+				return c
+			}
+		}
+		return ComponentHealth{}
+	}
+
+	// Requests not identifying external-dns are ignored
+	p.ObserveClient(ExternalDNSClient{})
+	assert.Empty(t, component().Name)
+	assert.Zero(t, testutil.CollectAndCount(p.metrics.clientCompatibility))
+
+	p.ObserveClient(ExternalDNSClient{Version: "0.15.1", Protocol: "1"})
+	assert.Equal(t, HealthOK, component().State)
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.metrics.clientCompatibility.WithLabelValues("0.15.1", "1", CompatibilitySupported)))
+
+	// An unsupported client degrades the component, and the metric reports only the last client
+	p.ObserveClient(ExternalDNSClient{Version: "0.15.1", Protocol: "2"})
+	assert.Equal(t, HealthDegraded, component().State)
+	assert.Contains(t, component().Message, "protocol version 2")
+	assert.Equal(t, 1, testutil.CollectAndCount(p.metrics.clientCompatibility))
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.metrics.clientCompatibility.WithLabelValues("0.15.1", "2", CompatibilityUnsupported)))
+}
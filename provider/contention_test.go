@@ -0,0 +1,151 @@
+package inwx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// The benchmarks below exercise the structures shared between concurrent webhook requests. Run them
+// with -cpu to compare contention, e.g. go test -run=^$ -bench=Parallel -cpu=1,4,16 ./provider/
+
+// runWhile runs fn in a loop on another goroutine until the returned stop function is called.
+func runWhile(fn func()) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				fn()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func benchmarkEndpoints(n int) []*endpoint.Endpoint {
+	endpoints := make([]*endpoint.Endpoint, 0, n)
+	for i := range n {
+		endpoints = append(endpoints, endpoint.NewEndpoint(fmt.Sprintf("host%d.example.com", i), "A", "1.1.1.1"))
+	}
+	return endpoints
+}
+
+func BenchmarkRecordsCacheParallel(b *testing.B) {
+	cache := newRecordsCache(time.Hour, 0)
+	endpoints := benchmarkEndpoints(1000)
+	cache.store(endpoints, nil, nil, time.Now(), cache.current())
+
+	// An apply replacing the records concurrently must not hold up the readers.
+	stop := runWhile(func() {
+		cache.invalidate()
+		cache.store(endpoints, nil, nil, time.Now(), cache.current())
+	})
+	defer stop()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = cache.get(time.Now())
+		}
+	})
+}
+
+func BenchmarkRecordsDuringApplyParallel(b *testing.B) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.New(slog.DiscardHandler))
+	p.records = newRecordsCache(time.Hour, 0)
+	w.CreateZone("example.com")
+	if _, err := p.Records(context.TODO()); err != nil {
+		b.Fatal(err)
+	}
+
+	// Simulate a long running apply holding an in-flight operation.
+	done, err := p.lifecycle.begin()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer done()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := p.Records(context.TODO()); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+func BenchmarkZonesCacheParallel(b *testing.B) {
+	w := &ClientWrapper{logger: slog.New(slog.DiscardHandler)}
+	w.zonesCache.Store(&zonesSnapshot{zones: []string{"example.com", "example.org", "example.net"}, expires: time.Now().Add(time.Hour)})
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := w.getZones(); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+func BenchmarkRateLimiterLookupParallel(b *testing.B) {
+	_, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.New(slog.DiscardHandler))
+	zones := []string{"example.com", "example.org", "example.net", "example.io"}
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			// A huge limit never blocks, so only the limiter lookup is measured.
+			if err := p.waitForRateLimit(context.TODO(), zones[i%len(zones)], 1e9); err != nil {
+				b.Error(err)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkFlapTrackerParallel(b *testing.B) {
+	tracker := newFlapTracker(time.Hour, 5)
+	stop := runWhile(func() { _ = tracker.top(flapMetricsLimit, time.Now()) })
+	defer stop()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			tracker.observe("example.com", fmt.Sprintf("host%d", i%100), "A", time.Now())
+			i++
+		}
+	})
+}
+
+func BenchmarkMetricsParallel(b *testing.B) {
+	errFailed := errors.New("failed")
+	_, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.New(slog.DiscardHandler))
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			var err error
+			if i%10 == 0 {
+				err = errFailed
+			}
+			p.observeOperation(context.TODO(), "records", time.Now(), err)
+			p.metrics.changesTotal.WithLabelValues("example.com", string(actionCreate), resultLabel(err)).Inc()
+			i++
+		}
+	})
+}
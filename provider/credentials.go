@@ -0,0 +1,35 @@
+package inwx
+
+// Credentials are what the provider logs in to INWX with.
+type Credentials struct {
+	Username string
+	Password string
+	// TOTPSecret unlocks an account with two-factor authentication; empty if there is none.
+	TOTPSecret TOTPSecret
+}
+
+// currentCredentials returns the credentials to log in with, none if they were never set.
+func (w *ClientWrapper) currentCredentials() Credentials {
+	if creds := w.credentials.Load(); creds != nil {
+		return *creds
+	}
+	return Credentials{}
+}
+
+// setCredentials replaces the credentials. Requests log in with them from now on; a persistent session
+// is logged in again with them on its next use.
+func (w *ClientWrapper) setCredentials(credentials Credentials) {
+	w.credentials.Store(&credentials)
+	if w.session.scopeOf() == SessionPersistent {
+		w.session.mu.Lock()
+		w.session.loggedIn = false
+		w.session.mu.Unlock()
+	}
+}
+
+// SetCredentials replaces the credentials the provider logs in to INWX with, e.g. after they were rotated,
+// without restarting it.
+func (p *INWXProvider) SetCredentials(credentials Credentials) {
+	p.client.setCredentials(credentials)
+	p.logger.Info("INWX credentials changed, logging in with the new ones from now on")
+}
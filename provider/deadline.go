@@ -0,0 +1,32 @@
+package inwx
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrDeadlineExceeded is returned by ApplyChanges if the deadline of its context left too little time to
+// apply every change. The changes not applied are recorded as skipped with the reason "deadline".
+var ErrDeadlineExceeded = errors.New("request deadline reached before all changes were applied")
+
+// deadlineReserve is the time kept free before the deadline of a request for a last INWX call to return
+// and the partial result to be written, instead of the request being cut off in the middle of an apply.
+const deadlineReserve = 2 * time.Second
+
+// outOfTime reports whether the deadline of ctx, if any, is too close to start another INWX call.
+func outOfTime(ctx context.Context) bool {
+	deadline, ok := ctx.Deadline()
+	return ok && time.Until(deadline) < deadlineReserve
+}
+
+// countDeadlineErrors returns the number of errs caused by the request deadline.
+func countDeadlineErrors(errs []error) int {
+	n := 0
+	for _, err := range errs {
+		if errors.Is(err, ErrDeadlineExceeded) {
+			n++
+		}
+	}
+	return n
+}
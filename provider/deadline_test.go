@@ -0,0 +1,47 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestDeadline(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	changes := &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("foo.example.com", "A", "192.0.2.1"),
+		endpoint.NewEndpoint("bar.example.com", "A", "192.0.2.2"),
+	}}
+
+	// Too close to the deadline, no change is started and all of them are reported as not applied
+	ctx, cancel := context.WithTimeout(context.Background(), deadlineReserve/2)
+	defer cancel()
+	ctx, recorder := WithChangeRecorder(ctx)
+	err := p.ApplyChanges(ctx, changes)
+	require.ErrorIs(t, err, ErrDeadlineExceeded)
+	assert.Contains(t, err.Error(), "2 changes not applied")
+	require.Len(t, recorder.Changes(), 2)
+	for _, change := range recorder.Changes() {
+		assert.Equal(t, "deadline", change.Skipped)
+	}
+	assert.Empty(t, *w.db["example.com"])
+
+	// With enough time left, they are applied
+	ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	require.NoError(t, p.ApplyChanges(ctx, changes))
+	assert.Len(t, *w.db["example.com"], 2)
+
+	// Records aren't read past the deadline
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = p.Records(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,177 @@
+package inwx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// external-dns deletes every record it owns that its sources stop producing, so a source returning
+// nothing by mistake, e.g. after a broken namespace filter, wipes the zones. With a deletion limit, change
+// sets deleting more records are held back until an operator approves them by their plan hash.
+
+// ErrDeletionApprovalRequired is returned by ApplyChanges for changes deleting more records than allowed
+// by WithMaxDeletes, until they are approved with ApproveDeletions.
+var ErrDeletionApprovalRequired = errors.New("deletions require approval")
+
+// ErrNoPendingDeletions is returned by ApproveDeletions for a plan hash no changes are held back under.
+var ErrNoPendingDeletions = errors.New("no deletions pending approval")
+
+// deletionApprovalTTL is how long changes held back stay pending after external-dns last tried to apply
+// them, and how long an approval is valid for.
+const deletionApprovalTTL = time.Hour
+
+// skippedApprovalRequired is the skip reason of the deletes of changes held back.
+const skippedApprovalRequired = "approval_required"
+
+// PendingDeletion is a change set held back by the deletion limit.
+type PendingDeletion struct {
+	PlanHash string `json:"planHash"`
+	// Reason explains why the changes are held back.
+	Reason string `json:"reason"`
+	// Deletes are the records the changes would delete.
+	Deletes  []AppliedChange `json:"deletes"`
+	Approved bool            `json:"approved"`
+	// Expires is when the changes are forgotten, or the approval lapses.
+	Expires time.Time `json:"expires"`
+}
+
+// deletionGuard holds the change sets held back by the deletion limit, by plan hash. The zero value is
+// ready to use.
+type deletionGuard struct {
+	mu      sync.Mutex
+	pending map[string]*PendingDeletion
+}
+
+// hold records the deletes of the changes with hash as pending and reports whether they are held back,
+// i.e. not approved. An approval is used up by the changes it was given for.
+func (g *deletionGuard) hold(hash string, reason string, deletes []AppliedChange, now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.prune(now)
+	if pending, ok := g.pending[hash]; ok && pending.Approved {
+		delete(g.pending, hash)
+		return false
+	}
+	if g.pending == nil {
+		g.pending = map[string]*PendingDeletion{}
+	}
+	g.pending[hash] = &PendingDeletion{PlanHash: hash, Reason: reason, Deletes: deletes, Expires: now.Add(deletionApprovalTTL)}
+	return true
+}
+
+// approve approves the changes held back under hash.
+func (g *deletionGuard) approve(hash string, now time.Time) (*PendingDeletion, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.prune(now)
+	pending, ok := g.pending[hash]
+	if !ok {
+		return nil, fmt.Errorf("%w under plan hash %q", ErrNoPendingDeletions, hash)
+	}
+	pending.Approved, pending.Expires = true, now.Add(deletionApprovalTTL)
+	approved := *pending
+	return &approved, nil
+}
+
+// list returns the changes held back or approved, in the order of their plan hashes.
+func (g *deletionGuard) list(now time.Time) []PendingDeletion {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.prune(now)
+	list := make([]PendingDeletion, 0, len(g.pending))
+	for _, hash := range slices.Sorted(maps.Keys(g.pending)) {
+		list = append(list, *g.pending[hash])
+	}
+	return list
+}
+
+// prune forgets the changes whose time expired. g.mu must be held.
+func (g *deletionGuard) prune(now time.Time) {
+	for hash, pending := range g.pending {
+		if !now.Before(pending.Expires) {
+			delete(g.pending, hash)
+		}
+	}
+}
+
+// checkDeletions holds back changes with hash deleting more records than the deletion limit allows,
+// unless they were approved. The deletes are recorded as skipped with the reason approval_required, so
+// that they reach the audit log and the report along with the plan hash to approve.
+func (p *INWXProvider) checkDeletions(ctx context.Context, zones *[]string, changes *plan.Changes, hash string) error {
+	if p.config.maxDeletes <= 0 {
+		return nil
+	}
+	var deletes []AppliedChange
+	deleted := func(zone string, name string, recordType string, content string) {
+		deletes = append(deletes, AppliedChange{
+			ID:        changeID(actionDelete, zone, name, recordType, content),
+			Action:    string(actionDelete),
+			Zone:      zone,
+			Name:      name,
+			Type:      recordType,
+			Content:   content,
+			RecordKey: recordKey(zone, name, recordType, content),
+			Outcome:   "skipped",
+			Skipped:   skippedApprovalRequired,
+		})
+	}
+	for _, ep := range changes.Delete {
+		if zone, err := p.zoneFor(zones, ep); err == nil {
+			for _, target := range ep.Targets {
+				deleted(zone, p.registry.RecordName(ep.DNSName, zone), ep.RecordType, target)
+			}
+		}
+	}
+	// Updates delete the records of the targets they drop.
+	for i, oldEp := range changes.UpdateOld {
+		if i >= len(changes.UpdateNew) || len(oldEp.Targets) <= len(changes.UpdateNew[i].Targets) {
+			continue
+		}
+		if zone, err := p.zoneFor(zones, oldEp); err == nil {
+			for _, target := range oldEp.Targets[len(changes.UpdateNew[i].Targets):] {
+				deleted(zone, p.registry.RecordName(oldEp.DNSName, zone), oldEp.RecordType, target)
+			}
+		}
+	}
+	if len(deletes) <= p.config.maxDeletes {
+		return nil
+	}
+	reason := fmt.Sprintf("%d records would be deleted, more than the limit of %d", len(deletes), p.config.maxDeletes)
+	if !p.deletions.hold(hash, reason, deletes, now(p.config.clock)) {
+		p.logger.Warn("applying deletions above the limit, as they were approved", "plan_hash", hash, "deletes", len(deletes),
+			"max_deletes", p.config.maxDeletes)
+		return nil
+	}
+	p.logger.Warn("holding back changes deleting too many records until they are approved", "plan_hash", hash,
+		"deletes", len(deletes), "max_deletes", p.config.maxDeletes)
+	p.metrics.heldDeletionsTotal.Inc()
+	for _, change := range deletes {
+		p.recordChange(ctx, change)
+	}
+	return fmt.Errorf("%w: %s, approve plan %s to apply them", ErrDeletionApprovalRequired, reason, hash)
+}
+
+// PendingDeletions returns the change sets held back by the deletion limit, and those approved but not
+// applied yet.
+func (p *INWXProvider) PendingDeletions() []PendingDeletion {
+	return p.deletions.list(now(p.config.clock))
+}
+
+// ApproveDeletions approves the change set held back under hash, so that it is applied the next time
+// external-dns submits it, which it does with its next reconcile. The approval is used up by the changes,
+// and lapses if they don't arrive within an hour.
+func (p *INWXProvider) ApproveDeletions(hash string) (*PendingDeletion, error) {
+	approved, err := p.deletions.approve(hash, now(p.config.clock))
+	if err != nil {
+		return nil, err
+	}
+	p.logger.Warn("deletions approved", "plan_hash", hash, "deletes", len(approved.Deletes))
+	return approved, nil
+}
@@ -0,0 +1,135 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestDeletionGuard(t *testing.T) {
+	t.Run("Approval", testDeletionApproval)
+	t.Run("Updates", testDeletionGuardUpdates)
+	t.Run("Expiry", testDeletionApprovalExpiry)
+}
+
+// deletionGuardProvider returns a provider allowing 2 deletes, with www, api and mail records.
+func deletionGuardProvider(t *testing.T) (*MockClientWrapper, *INWXProvider, []*endpoint.Endpoint) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	p.config.clock = &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	p.config.maxDeletes = 2
+	for _, name := range []string{"www", "api", "mail"} {
+		require.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: name, Type: "A", Content: "192.0.2.1", TTL: 300}))
+	}
+	records, err := p.Records(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	return w, p, records
+}
+
+func testDeletionApproval(t *testing.T) {
+	w, p, records := deletionGuardProvider(t)
+
+	// Deletes within the limit are applied
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Delete: records[:1]}))
+	stored, err := w.getRecords("example.com")
+	require.NoError(t, err)
+	assert.Len(t, *stored, 2)
+	assert.Empty(t, p.PendingDeletions())
+
+	require.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: strings.TrimSuffix(records[0].DNSName, ".example.com"), Type: "A", Content: "192.0.2.1", TTL: 300}))
+	changes := &plan.Changes{Delete: records}
+	hash := planHash(changes)
+
+	// Deletes above the limit are held back and recorded as skipped
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	err = p.ApplyChanges(ctx, changes)
+	require.ErrorIs(t, err, ErrDeletionApprovalRequired)
+	assert.Contains(t, err.Error(), hash)
+	stored, err = w.getRecords("example.com")
+	require.NoError(t, err)
+	assert.Len(t, *stored, 3)
+	require.Len(t, recorder.Changes(), 3)
+	for _, change := range recorder.Changes() {
+		assert.Equal(t, "delete", change.Action)
+		assert.Equal(t, "skipped", change.Outcome)
+		assert.Equal(t, "approval_required", change.Skipped)
+		assert.Equal(t, recordKey("example.com", change.Name, "A", "192.0.2.1"), change.RecordKey)
+	}
+	pending := p.PendingDeletions()
+	require.Len(t, pending, 1)
+	assert.Equal(t, hash, pending[0].PlanHash)
+	assert.Equal(t, "3 records would be deleted, more than the limit of 2", pending[0].Reason)
+	assert.Equal(t, recorder.Changes(), pending[0].Deletes)
+	assert.False(t, pending[0].Approved)
+
+	// Trying again doesn't get them through
+	require.ErrorIs(t, p.ApplyChanges(context.TODO(), changes), ErrDeletionApprovalRequired)
+	stored, err = w.getRecords("example.com")
+	require.NoError(t, err)
+	assert.Len(t, *stored, 3)
+
+	// Only changes held back can be approved
+	_, err = p.ApproveDeletions("0123456789abcdef")
+	require.ErrorIs(t, err, ErrNoPendingDeletions)
+	approved, err := p.ApproveDeletions(hash)
+	require.NoError(t, err)
+	assert.True(t, approved.Approved)
+
+	// The approved changes are applied once
+	require.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	stored, err = w.getRecords("example.com")
+	require.NoError(t, err)
+	assert.Empty(t, *stored)
+	assert.Empty(t, p.PendingDeletions())
+}
+
+func testDeletionGuardUpdates(t *testing.T) {
+	w, p, _ := deletionGuardProvider(t)
+	for _, content := range []string{"192.0.2.2", "192.0.2.3"} {
+		require.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "www", Type: "A", Content: content, TTL: 300}))
+	}
+	www := endpoint.NewEndpointWithTTL("www.example.com", "A", 300, "192.0.2.1", "192.0.2.2", "192.0.2.3")
+
+	// Updates dropping targets delete their records
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	updated := endpoint.NewEndpointWithTTL("www.example.com", "A", 300, "192.0.2.1")
+	err := p.ApplyChanges(ctx, &plan.Changes{UpdateOld: []*endpoint.Endpoint{www}, UpdateNew: []*endpoint.Endpoint{updated},
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("mail.example.com", "A", "192.0.2.1")}})
+	require.ErrorIs(t, err, ErrDeletionApprovalRequired)
+	require.Len(t, recorder.Changes(), 3)
+	assert.Equal(t, "mail", recorder.Changes()[0].Name)
+	assert.Equal(t, "192.0.2.2", recorder.Changes()[1].Content)
+	assert.Equal(t, "192.0.2.3", recorder.Changes()[2].Content)
+	stored, err := w.getRecords("example.com")
+	require.NoError(t, err)
+	assert.Len(t, *stored, 5)
+}
+
+func testDeletionApprovalExpiry(t *testing.T) {
+	_, p, records := deletionGuardProvider(t)
+	clock := p.config.clock.(*fakeClock)
+	changes := &plan.Changes{Delete: records}
+	hash := planHash(changes)
+	require.ErrorIs(t, p.ApplyChanges(context.TODO(), changes), ErrDeletionApprovalRequired)
+
+	// Changes external-dns stopped submitting are forgotten
+	clock.advance(deletionApprovalTTL)
+	assert.Empty(t, p.PendingDeletions())
+	_, err := p.ApproveDeletions(hash)
+	require.ErrorIs(t, err, ErrNoPendingDeletions)
+
+	// So are approvals not used in time
+	require.ErrorIs(t, p.ApplyChanges(context.TODO(), changes), ErrDeletionApprovalRequired)
+	_, err = p.ApproveDeletions(hash)
+	require.NoError(t, err)
+	clock.advance(deletionApprovalTTL)
+	require.ErrorIs(t, p.ApplyChanges(context.TODO(), changes), ErrDeletionApprovalRequired)
+}
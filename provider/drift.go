@@ -0,0 +1,103 @@
+package inwx
+
+import (
+	"sync"
+)
+
+// driftRecord is the state of a record as last seen in INWX.
+type driftRecord struct {
+	name, recordType, content string
+	ttl                       int
+}
+
+// driftKey identifies a mutation by its target state.
+type driftKey struct {
+	name, recordType, content string
+}
+
+// driftTracker detects records changed outside of the webhook, e.g. edits in the INWX web panel, by
+// diffing every refresh of a zone against the previous one. Mutations made by the webhook itself are
+// registered with expect and not reported.
+type driftTracker struct {
+	mu       sync.Mutex
+	zones    map[string]map[string]driftRecord
+	expected map[string]map[driftKey]bool
+}
+
+func newDriftTracker() *driftTracker {
+	return &driftTracker{zones: map[string]map[string]driftRecord{}, expected: map[string]map[driftKey]bool{}}
+}
+
+// ManualChange is a record change in INWX that wasn't made by the webhook.
+type ManualChange struct {
+	Zone string `json:"zone"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Change is "created", "updated" or "deleted".
+	Change     string `json:"change"`
+	OldContent string `json:"oldContent,omitempty"`
+	NewContent string `json:"newContent,omitempty"`
+}
+
+// expect registers a mutation of the webhook resulting in (or, for deletes, removing) content.
+func (t *driftTracker) expect(zone string, name string, recordType string, content string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.expected[zone] == nil {
+		t.expected[zone] = map[driftKey]bool{}
+	}
+	t.expected[zone][driftKey{name, recordType, content}] = true
+}
+
+// observe replaces the known state of zone with records and returns the changes since the previous
+// observation that weren't made by the webhook. The first observation of a zone only sets the baseline.
+func (t *driftTracker) observe(zone string, records []zoneRecord) []ManualChange {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	current := make(map[string]driftRecord, len(records))
+	for _, rec := range records {
+		current[rec.ID] = driftRecord{name: rec.Name, recordType: rec.Type, content: rec.Content, ttl: rec.TTL}
+	}
+	previous, seen := t.zones[zone]
+	expected := t.expected[zone]
+	t.zones[zone] = current
+	delete(t.expected, zone)
+	if !seen {
+		return nil
+	}
+
+	var changes []ManualChange
+	report := func(rec driftRecord, change string, oldContent string, newContent string) {
+		content := newContent
+		if change == "deleted" {
+			content = oldContent
+		}
+		if expected[driftKey{rec.name, rec.recordType, content}] {
+			return
+		}
+		changes = append(changes, ManualChange{Zone: zone, Name: rec.name, Type: rec.recordType, Change: change, OldContent: oldContent, NewContent: newContent})
+	}
+	for _, rec := range records {
+		now := current[rec.ID]
+		before, ok := previous[rec.ID]
+		switch {
+		case !ok:
+			report(now, "created", "", now.content)
+		case before != now:
+			report(now, "updated", before.content, now.content)
+		}
+	}
+	for id, before := range previous {
+		if _, ok := current[id]; !ok {
+			report(before, "deleted", before.content, "")
+		}
+	}
+	return changes
+}
@@ -0,0 +1,89 @@
+package inwx
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// emptyZoneTracker flags zones that have held no records besides their SOA and NS records for a while,
+// e.g. zones of preview environments that were torn down, so that they can be cleaned up. Zones are
+// only flagged; the webhook doesn't create zones and never deletes them.
+type emptyZoneTracker struct {
+	mu      sync.Mutex
+	after   time.Duration
+	since   map[string]time.Time
+	flagged map[string]bool
+	gauge   *prometheus.GaugeVec
+}
+
+// newEmptyZoneTracker returns a tracker flagging zones empty for at least after in gauge, or nil if after
+// is 0.
+func newEmptyZoneTracker(after time.Duration, gauge *prometheus.GaugeVec) *emptyZoneTracker {
+	if after <= 0 {
+		return nil
+	}
+	return &emptyZoneTracker{after: after, since: map[string]time.Time{}, flagged: map[string]bool{}, gauge: gauge}
+}
+
+// isEmptyZone reports whether records hold nothing but the SOA and NS records every zone has.
+func isEmptyZone(records []zoneRecord) bool {
+	for _, rec := range records {
+		if rec.Type != "SOA" && rec.Type != "NS" {
+			return false
+		}
+	}
+	return true
+}
+
+// observe registers the records of zone read at the given time. It returns when the zone became empty
+// and whether it just reached the threshold and was flagged.
+func (t *emptyZoneTracker) observe(zone string, records []zoneRecord, at time.Time) (time.Time, bool) {
+	if t == nil {
+		return time.Time{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !isEmptyZone(records) {
+		t.forget(zone)
+		return time.Time{}, false
+	}
+	since, ok := t.since[zone]
+	if !ok {
+		since = at
+		t.since[zone] = at
+	}
+	if t.flagged[zone] || at.Sub(since) < t.after {
+		return since, false
+	}
+	t.flagged[zone] = true
+	t.gauge.WithLabelValues(zone).Set(1)
+	return since, true
+}
+
+// retain forgets the zones no longer hosted at INWX.
+func (t *emptyZoneTracker) retain(zones []string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	hosted := make(map[string]bool, len(zones))
+	for _, zone := range zones {
+		hosted[zone] = true
+	}
+	for zone := range t.since {
+		if !hosted[zone] {
+			t.forget(zone)
+		}
+	}
+}
+
+func (t *emptyZoneTracker) forget(zone string) {
+	if t.flagged[zone] {
+		t.gauge.DeleteLabelValues(zone)
+	}
+	delete(t.since, zone)
+	delete(t.flagged, zone)
+}
@@ -0,0 +1,51 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmptyZones(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com", "preview.example.org"}, slog.Default())
+	p.config.clock = clock
+	p.emptyZones = newEmptyZoneTracker(time.Hour, p.metrics.emptyZones)
+	w.CreateZone("example.com")
+	w.CreateZone("preview.example.org")
+	for _, zone := range []string{"example.com", "preview.example.org"} {
+		require.NoError(t, w.createRecord(&recordRequest{Domain: zone, Type: "SOA", Content: "ns.inwx.de hostmaster.inwx.de 2024010101 10800 3600 604800 3600"}))
+		require.NoError(t, w.createRecord(&recordRequest{Domain: zone, Type: "NS", Content: "ns.inwx.de"}))
+	}
+	require.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "foo", Type: "A", Content: "1.1.1.1"}))
+	flagged := func(zone string) float64 { return testutil.ToFloat64(p.metrics.emptyZones.WithLabelValues(zone)) }
+
+	_, err := p.Records(context.TODO())
+	require.NoError(t, err)
+	clock.advance(time.Hour - time.Second)
+	_, err = p.Records(context.TODO())
+	require.NoError(t, err)
+	assert.Zero(t, flagged("preview.example.org"))
+
+	// Only the zone without records besides SOA and NS is flagged once the period has passed
+	clock.advance(time.Second)
+	_, err = p.Records(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, flagged("preview.example.org"))
+	assert.Zero(t, flagged("example.com"))
+
+	// A record appearing in the zone clears the flag and restarts the period
+	require.NoError(t, w.createRecord(&recordRequest{Domain: "preview.example.org", Name: "app", Type: "A", Content: "2.2.2.2"}))
+	_, err = p.Records(context.TODO())
+	require.NoError(t, err)
+	assert.Zero(t, flagged("preview.example.org"))
+	assert.NotContains(t, p.emptyZones.since, "preview.example.org")
+
+	// Nothing is tracked when disabled
+	assert.Nil(t, newEmptyZoneTracker(0, nil))
+}
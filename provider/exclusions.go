@@ -0,0 +1,92 @@
+package inwx
+
+import (
+	"log/slog"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// DefaultIgnoreProperties are the provider-specific properties that exempt an endpoint from INWX
+// management unless configured otherwise. "webhook/inwx-ignore" is what external-dns derives from the
+// external-dns.alpha.kubernetes.io/webhook-inwx-ignore annotation, "inwx/ignore" can be set directly
+// on DNSEndpoint resources.
+var DefaultIgnoreProperties = map[string]string{
+	"inwx/ignore":         "true",
+	"webhook/inwx-ignore": "true",
+}
+
+// isIgnored reports whether the endpoint carries one of the configured ignore labels or properties.
+func (p *INWXProvider) isIgnored(ep *endpoint.Endpoint) bool {
+	for key, value := range p.config.ignoreLabels {
+		if v, ok := ep.Labels[key]; ok && v == value {
+			return true
+		}
+	}
+	for name, value := range p.config.ignoreProperties {
+		if v, ok := ep.GetProviderSpecificProperty(name); ok && v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// filterIgnored returns a copy of changes without the endpoints that are exempt from INWX management or
+// outside of the domain filter. Updates are dropped as a pair when either side is ignored.
+func (p *INWXProvider) filterIgnored(changes *plan.Changes) *plan.Changes {
+	filtered := &plan.Changes{}
+	keep := func(ep *endpoint.Endpoint) bool {
+		if !p.managesName(ep.DNSName) {
+			slog.Debug("skipping endpoint outside of the domain filter", "name", ep.DNSName, "type", ep.RecordType)
+			return false
+		}
+		if p.isIgnored(ep) {
+			slog.Debug("skipping ignored endpoint", "name", ep.DNSName, "type", ep.RecordType)
+			return false
+		}
+		return true
+	}
+	for _, ep := range changes.Create {
+		if keep(ep) {
+			filtered.Create = append(filtered.Create, ep)
+		}
+	}
+	for i, oldEp := range changes.UpdateOld {
+		newEp := changes.UpdateNew[i]
+		if keep(oldEp) && keep(newEp) {
+			filtered.UpdateOld = append(filtered.UpdateOld, oldEp)
+			filtered.UpdateNew = append(filtered.UpdateNew, newEp)
+		}
+	}
+	for _, ep := range changes.Delete {
+		if keep(ep) {
+			filtered.Delete = append(filtered.Delete, ep)
+		}
+	}
+	return filtered
+}
+
+// ExcludedEndpoint is a source endpoint this provider wouldn't manage, with the reason why.
+type ExcludedEndpoint struct {
+	Endpoint *endpoint.Endpoint `json:"endpoint"`
+	Reason   string             `json:"reason"`
+}
+
+// DesiredEndpoints splits the endpoints produced by external-dns sources into the ones this provider
+// would be asked to manage, after AdjustEndpoints, and the ones it would leave alone.
+func (p *INWXProvider) DesiredEndpoints(endpoints []*endpoint.Endpoint) ([]*endpoint.Endpoint, []ExcludedEndpoint, error) {
+	managed := []*endpoint.Endpoint{}
+	excluded := []ExcludedEndpoint{}
+	for _, ep := range endpoints {
+		switch {
+		case !p.managesName(ep.DNSName):
+			excluded = append(excluded, ExcludedEndpoint{Endpoint: ep, Reason: "domain_filter"})
+		case p.isIgnored(ep):
+			excluded = append(excluded, ExcludedEndpoint{Endpoint: ep, Reason: "ignored"})
+		default:
+			managed = append(managed, ep)
+		}
+	}
+	managed, err := p.AdjustEndpoints(managed)
+	return managed, excluded, err
+}
@@ -0,0 +1,179 @@
+package inwx
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// ExpiryProperties are the provider-specific properties making INWX delete the records of an endpoint the
+// given duration, e.g. "15m", after creating them, even if external-dns stops sending the endpoint, e.g.
+// for verification and ACME challenge records. "webhook/inwx-expires-after" is what external-dns derives
+// from the external-dns.alpha.kubernetes.io/webhook-inwx-expires-after annotation, "inwx/expires-after"
+// can be set directly on DNSEndpoint resources.
+var ExpiryProperties = []string{"inwx/expires-after", "webhook/inwx-expires-after"}
+
+// expiredRetention is how long endpoints are dropped from the desired endpoints after their records
+// expired, so that external-dns, still sending them, doesn't create them again right away.
+const expiredRetention = 24 * time.Hour
+
+// expiry is the scheduled deletion of the records of an endpoint.
+type expiry struct {
+	targets endpoint.Targets
+	at      time.Time
+	// deleted is when the records were deleted, zero until then.
+	deleted time.Time
+}
+
+// expiryStore holds the scheduled deletions by name and type. They are kept in memory only, so records
+// created before a restart aren't deleted. The zero value is ready to use.
+type expiryStore struct {
+	mu       sync.Mutex
+	expiries map[passthroughKey]*expiry
+}
+
+// expiresAfter returns the duration of the expiry property of ep, if any. Invalid values are reported as
+// an error.
+func expiresAfter(ep *endpoint.Endpoint) (time.Duration, bool, error) {
+	for _, property := range ep.ProviderSpecific {
+		if slices.Contains(ExpiryProperties, property.Name) {
+			d, err := time.ParseDuration(property.Value)
+			if err == nil && d <= 0 {
+				err = errors.New("duration must be positive")
+			}
+			return d, true, err
+		}
+	}
+	return 0, false, nil
+}
+
+// applied schedules the deletion of the records created by changes with an expiry property, unless one
+// is scheduled already, and forgets the deletions of the endpoints deleted.
+func (s *expiryStore) applied(changes *plan.Changes, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ep := range changes.Delete {
+		if e, ok := s.expiries[passthroughKeyOf(ep)]; ok && e.deleted.IsZero() {
+			delete(s.expiries, passthroughKeyOf(ep))
+		}
+	}
+	for _, ep := range changes.Create {
+		d, ok, err := expiresAfter(ep)
+		if !ok || err != nil {
+			continue
+		}
+		if _, scheduled := s.expiries[passthroughKeyOf(ep)]; scheduled {
+			continue
+		}
+		if s.expiries == nil {
+			s.expiries = map[passthroughKey]*expiry{}
+		}
+		s.expiries[passthroughKeyOf(ep)] = &expiry{targets: slices.Clone(ep.Targets), at: at.Add(d)}
+	}
+}
+
+// due returns the endpoints whose records are due for deletion at the given time.
+func (s *expiryStore) due(at time.Time) []*endpoint.Endpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	due := []*endpoint.Endpoint{}
+	for key, e := range s.expiries {
+		if e.deleted.IsZero() && !at.Before(e.at) {
+			due = append(due, endpoint.NewEndpoint(key.name, key.recordType, e.targets...))
+		}
+	}
+	slices.SortFunc(due, func(a, b *endpoint.Endpoint) int {
+		return cmp.Or(strings.Compare(a.DNSName, b.DNSName), strings.Compare(a.RecordType, b.RecordType))
+	})
+	return due
+}
+
+// deleted marks the records of ep as deleted at the given time.
+func (s *expiryStore) deleted(ep *endpoint.Endpoint, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.expiries[passthroughKeyOf(ep)]; ok {
+		e.deleted = at
+	}
+}
+
+// expired reports whether the records of ep were deleted for expiring. Deletions older than
+// expiredRetention are forgotten.
+func (s *expiryStore) expired(ep *endpoint.Endpoint, at time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.expiries[passthroughKeyOf(ep)]
+	if !ok || e.deleted.IsZero() {
+		return false
+	}
+	if at.Sub(e.deleted) >= expiredRetention {
+		delete(s.expiries, passthroughKeyOf(ep))
+		return false
+	}
+	return true
+}
+
+// deleteExpiredRecords deletes the records whose expiry is due. Records already gone count as deleted;
+// deletions that fail are retried with the next call.
+func (p *INWXProvider) deleteExpiredRecords(ctx context.Context) (err error) {
+	due := p.expiries.due(now(p.config.clock))
+	if len(due) == 0 {
+		return nil
+	}
+	done, err := p.lifecycle.begin()
+	if err != nil {
+		return err
+	}
+	defer done()
+	ctx, applied := withAppliedRecorder(ctx)
+	defer p.writeAudit(ctx, applied, "")
+
+	if err := p.login(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if err := p.client.logout(); err != nil {
+			slog.Error("error encountered while logging out", "err", err)
+		}
+	}()
+	zones, err := p.getZones(ctx)
+	if err != nil {
+		return err
+	}
+	errs := []error{}
+	for _, ep := range due {
+		hard, _ := splitErrors(p.applyDeletes(ctx, zones, []*endpoint.Endpoint{ep}))
+		if len(hard) > 0 {
+			errs = append(errs, hard...)
+			continue
+		}
+		p.logger.Info("deleted expired records", "name", ep.DNSName, "type", ep.RecordType, "targets", ep.Targets.String())
+		p.expiries.deleted(ep, now(p.config.clock))
+		p.passthrough.applied(&plan.Changes{Delete: []*endpoint.Endpoint{ep}})
+	}
+	return errors.Join(errs...)
+}
+
+// expireRecords deletes the records whose expiry is due every interval until stop is closed.
+func (p *INWXProvider) expireRecords(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := p.deleteExpiredRecords(context.Background()); err != nil && !errors.Is(err, ErrShutdown) {
+				p.logger.Warn("failed to delete expired records, retrying", "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestRecordExpiry(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	p.config.clock = clock
+	p.config.expiryInterval = time.Minute
+
+	challenge := endpoint.NewEndpointWithTTL("_acme-challenge.example.com", "TXT", 60, "token").
+		WithProviderSpecific("webhook/inwx-expires-after", "10m")
+	www := endpoint.NewEndpoint("www.example.com", "A", "192.0.2.1")
+	desired, adjustments, err := p.AdjustEndpointsWithReasons([]*endpoint.Endpoint{challenge, www})
+	require.NoError(t, err)
+	assert.Empty(t, adjustments)
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: desired}))
+
+	// Nothing is due before the expiry
+	clock.advance(9 * time.Minute)
+	require.NoError(t, p.deleteExpiredRecords(context.TODO()))
+	records, err := w.getRecords("example.com")
+	require.NoError(t, err)
+	assert.Len(t, *records, 2)
+
+	// Creating the endpoint again doesn't extend the expiry
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{challenge}}))
+
+	clock.advance(time.Minute)
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	require.NoError(t, p.deleteExpiredRecords(ctx))
+	require.Len(t, recorder.Changes(), 1)
+	assert.Equal(t, "delete", recorder.Changes()[0].Action)
+	assert.Equal(t, "_acme-challenge", recorder.Changes()[0].Name)
+	records, err = w.getRecords("example.com")
+	require.NoError(t, err)
+	require.Len(t, *records, 1)
+	assert.Equal(t, "www", (*records)[0].Name)
+
+	// external-dns still sending the endpoint doesn't bring it back
+	desired, adjustments, err = p.AdjustEndpointsWithReasons([]*endpoint.Endpoint{challenge, www})
+	require.NoError(t, err)
+	assert.Equal(t, []*endpoint.Endpoint{www}, desired)
+	require.Len(t, adjustments, 1)
+	assert.Equal(t, "expired", adjustments[0].Reason)
+
+	// Until the expired endpoint is forgotten
+	clock.advance(expiredRetention)
+	desired, err = p.AdjustEndpoints([]*endpoint.Endpoint{challenge})
+	require.NoError(t, err)
+	assert.Len(t, desired, 1)
+
+	// Invalid expiries are flagged and ignored
+	invalid := endpoint.NewEndpoint("_verify.example.com", "TXT", "token").WithProviderSpecific("inwx/expires-after", "soon")
+	_, adjustments, err = p.AdjustEndpointsWithReasons([]*endpoint.Endpoint{invalid})
+	require.NoError(t, err)
+	require.Len(t, adjustments, 1)
+	assert.Equal(t, "invalid_expiry", adjustments[0].Reason)
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{invalid}}))
+	assert.Empty(t, p.expiries.due(clock.Now().Add(365*24*time.Hour)))
+}
@@ -0,0 +1,101 @@
+package inwx
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Feature names an experimental behavior that ships disabled and can be toggled per deployment.
+type Feature string
+
+// Experimental features. They are all disabled by default.
+const (
+	// FeatureSetBasedDiff compares the targets of an updated endpoint as sets instead of pairing them up by
+	// position, so that only the records of targets that were added or removed are touched.
+	FeatureSetBasedDiff Feature = "set-based-diff"
+)
+
+// knownFeatures describes every feature that can be toggled.
+var knownFeatures = map[Feature]string{
+	FeatureSetBasedDiff: "Compare the targets of an updated endpoint as sets, touching only the records of added or removed targets",
+}
+
+// FeatureState is the current state of a single feature.
+type FeatureState struct {
+	Name        Feature `json:"name"`
+	Description string  `json:"description"`
+	Enabled     bool    `json:"enabled"`
+}
+
+// Features holds the feature states. Reads are lock-free so that checking a feature on a hot path
+// never waits for a toggle.
+type Features struct {
+	mu      sync.Mutex
+	enabled atomic.Pointer[map[Feature]bool]
+}
+
+// NewFeatures returns the feature states with the given features set and every other one disabled.
+func NewFeatures(states map[Feature]bool) (*Features, error) {
+	f := &Features{}
+	enabled := map[Feature]bool{}
+	for feature, on := range states {
+		if _, ok := knownFeatures[feature]; !ok {
+			return nil, fmt.Errorf("unknown feature %q", feature)
+		}
+		enabled[feature] = on
+	}
+	f.enabled.Store(&enabled)
+	return f, nil
+}
+
+// ParseFeatureGates parses name=true|false pairs, e.g. from --feature-gate flags.
+func ParseFeatureGates(gates map[string]string) (map[Feature]bool, error) {
+	states := map[Feature]bool{}
+	for name, value := range gates {
+		on, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for feature %s: %w", value, name, err)
+		}
+		states[Feature(name)] = on
+	}
+	return states, nil
+}
+
+// Enabled reports whether feature is enabled. A nil Features has every feature disabled.
+func (f *Features) Enabled(feature Feature) bool {
+	if f == nil {
+		return false
+	}
+	return (*f.enabled.Load())[feature]
+}
+
+// Set enables or disables feature at runtime.
+func (f *Features) Set(feature Feature, on bool) error {
+	if _, ok := knownFeatures[feature]; !ok {
+		return fmt.Errorf("unknown feature %q", feature)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	enabled := maps.Clone(*f.enabled.Load())
+	enabled[feature] = on
+	f.enabled.Store(&enabled)
+	return nil
+}
+
+// List returns the state of every known feature, sorted by name.
+func (f *Features) List() []FeatureState {
+	states := []FeatureState{}
+	for _, feature := range slices.Sorted(maps.Keys(knownFeatures)) {
+		states = append(states, FeatureState{Name: feature, Description: knownFeatures[feature], Enabled: f.Enabled(feature)})
+	}
+	return states
+}
+
+// Features returns the feature states of the provider, which can be toggled at runtime.
+func (p *INWXProvider) Features() *Features {
+	return p.config.features
+}
@@ -0,0 +1,108 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestFeatures(t *testing.T) {
+	t.Run("Toggle", testFeaturesToggle)
+	t.Run("ParseFeatureGates", testParseFeatureGates)
+	t.Run("ZoneConfig", testFeaturesZoneConfig)
+	t.Run("SetBasedDiff", testSetBasedDiff)
+}
+
+func testFeaturesToggle(t *testing.T) {
+	features, err := NewFeatures(map[Feature]bool{})
+	require.NoError(t, err)
+	assert.False(t, features.Enabled(FeatureSetBasedDiff))
+
+	require.NoError(t, features.Set(FeatureSetBasedDiff, true))
+	assert.True(t, features.Enabled(FeatureSetBasedDiff))
+	assert.Equal(t, []FeatureState{
+		{Name: FeatureSetBasedDiff, Description: knownFeatures[FeatureSetBasedDiff], Enabled: true},
+	}, features.List())
+	require.NoError(t, features.Set(FeatureSetBasedDiff, false))
+	assert.False(t, features.Enabled(FeatureSetBasedDiff))
+
+	assert.Error(t, features.Set("unknown", true))
+	_, err = NewFeatures(map[Feature]bool{"unknown": true})
+	assert.Error(t, err)
+
+	var disabled *Features
+	assert.False(t, disabled.Enabled(FeatureSetBasedDiff))
+}
+
+func testParseFeatureGates(t *testing.T) {
+	states, err := ParseFeatureGates(map[string]string{"set-based-diff": "true"})
+	require.NoError(t, err)
+	assert.Equal(t, map[Feature]bool{FeatureSetBasedDiff: true}, states)
+
+	_, err = ParseFeatureGates(map[string]string{"set-based-diff": "maybe"})
+	assert.Error(t, err)
+}
+
+func testFeaturesZoneConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zones.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("features:\n  set-based-diff: true\n"), 0o600))
+	cfg, err := LoadZoneConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[Feature]bool{FeatureSetBasedDiff: true}, cfg.Features)
+
+	require.NoError(t, os.WriteFile(path, []byte("features:\n  unknown: true\n"), 0o600))
+	_, err = LoadZoneConfig(path)
+	assert.ErrorContains(t, err, "unknown feature")
+}
+
+func testSetBasedDiff(t *testing.T) {
+	// update returns the record IDs by target before and after updating an endpoint, and the changes applied
+	update := func(features *Features) (map[string]string, map[string]string, []AppliedChange) {
+		w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+		p.config.features = features
+		w.CreateZone("example.com")
+		old := endpoint.NewEndpointWithTTL("www.example.com", "A", 300, "192.0.2.1", "192.0.2.2", "192.0.2.3")
+		require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{old}}))
+		ids := map[string]string{}
+		for _, rec := range *w.db["example.com"] {
+			ids[rec.Content] = rec.ID
+		}
+
+		ctx, recorder := WithChangeRecorder(context.TODO())
+		require.NoError(t, p.ApplyChanges(ctx, &plan.Changes{
+			UpdateOld: []*endpoint.Endpoint{old},
+			UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("www.example.com", "A", 300, "192.0.2.2", "192.0.2.3", "192.0.2.4")},
+		}))
+		recs, err := w.getRecords("example.com")
+		require.NoError(t, err)
+		targets := map[string]string{}
+		for _, rec := range *recs {
+			targets[rec.Content] = rec.ID
+		}
+		assert.Len(t, targets, 3)
+		return ids, targets, recorder.Changes()
+	}
+
+	// By position, every target shifts into the record of its predecessor
+	_, _, changes := update(nil)
+	assert.Len(t, changes, 3)
+
+	// As sets, only the removed target's record is updated to the added one
+	features, err := NewFeatures(map[Feature]bool{FeatureSetBasedDiff: true})
+	require.NoError(t, err)
+	before, after, changes := update(features)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "update", changes[0].Action)
+	assert.Equal(t, "192.0.2.1", changes[0].OldContent)
+	assert.Equal(t, "192.0.2.4", changes[0].Content)
+	assert.Equal(t, before["192.0.2.1"], after["192.0.2.4"])
+	assert.Equal(t, before["192.0.2.2"], after["192.0.2.2"])
+	assert.Equal(t, before["192.0.2.3"], after["192.0.2.3"])
+}
@@ -0,0 +1,111 @@
+package inwx
+
+import (
+	"context"
+	"sync"
+)
+
+// changeFeedHistory is the number of recent events kept for subscribers catching up after a reconnect.
+const changeFeedHistory = 256
+
+// changeFeedBuffer is the number of events buffered per subscriber before it is dropped as too slow.
+const changeFeedBuffer = 64
+
+// ChangeEvent is a change applied against INWX, or deliberately skipped, as published on the change
+// feed. Seq increases by one with every event.
+type ChangeEvent struct {
+	Seq uint64 `json:"seq"`
+	AuditEntry
+}
+
+// changeFeed fans the applied changes out to subscribers as they happen. The zero value is ready to use.
+type changeFeed struct {
+	mu          sync.Mutex
+	seq         uint64
+	history     []ChangeEvent
+	subscribers map[chan ChangeEvent]struct{}
+	closed      bool
+}
+
+// publish sends entry to every subscriber and returns the number of subscribers dropped. A subscriber
+// whose buffer is full is dropped, by closing its channel, rather than blocking the change; it can
+// resubscribe from the last event it received.
+func (f *changeFeed) publish(entry AuditEntry) (dropped int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0
+	}
+	f.seq++
+	event := ChangeEvent{Seq: f.seq, AuditEntry: entry}
+	if len(f.history) == changeFeedHistory {
+		f.history = append(f.history[:0], f.history[1:]...)
+	}
+	f.history = append(f.history, event)
+	for ch := range f.subscribers {
+		select {
+		case ch <- event:
+		default:
+			dropped++
+			delete(f.subscribers, ch)
+			close(ch)
+		}
+	}
+	return dropped
+}
+
+// subscribe returns the retained events after seq after, and a channel receiving the events published
+// from now on. The channel is closed when the subscriber falls behind or the feed is closed; cancel
+// ends the subscription.
+func (f *changeFeed) subscribe(after uint64) ([]ChangeEvent, <-chan ChangeEvent, func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var backlog []ChangeEvent
+	for _, event := range f.history {
+		if event.Seq > after {
+			backlog = append(backlog, event)
+		}
+	}
+	ch := make(chan ChangeEvent, changeFeedBuffer)
+	if f.closed {
+		close(ch)
+		return backlog, ch, func() {}
+	}
+	if f.subscribers == nil {
+		f.subscribers = map[chan ChangeEvent]struct{}{}
+	}
+	f.subscribers[ch] = struct{}{}
+	return backlog, ch, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.subscribers[ch]; ok {
+			delete(f.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// close ends all subscriptions, e.g. on shutdown.
+func (f *changeFeed) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	for ch := range f.subscribers {
+		delete(f.subscribers, ch)
+		close(ch)
+	}
+}
+
+// SubscribeChanges returns the recent changes after the event with sequence number after, 0 for all
+// retained ones, and a channel receiving the changes applied from now on, so that dashboards and
+// automations can react to DNS changes as they happen. The channel is closed when the subscriber falls
+// behind or the provider shuts down; cancel must be called when done.
+func (p *INWXProvider) SubscribeChanges(after uint64) (backlog []ChangeEvent, events <-chan ChangeEvent, cancel func()) {
+	return p.feed.subscribe(after)
+}
+
+// publishChange publishes a change applied with ctx on the change feed.
+func (p *INWXProvider) publishChange(ctx context.Context, change AppliedChange) {
+	dropped := p.feed.publish(AuditEntry{Time: p.timestamp(now(p.config.clock)), TraceID: TraceID(ctx), AppliedChange: change})
+	p.metrics.changeFeedDropsTotal.Add(float64(dropped))
+}
@@ -0,0 +1,81 @@
+package inwx
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestChangeFeed(t *testing.T) {
+	t.Run("Subscribe", testChangeFeedSubscribe)
+	t.Run("SlowSubscriber", testChangeFeedSlowSubscriber)
+	t.Run("Shutdown", testChangeFeedShutdown)
+}
+
+func testChangeFeedSubscribe(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	_, events, cancel := p.SubscribeChanges(0)
+	defer cancel()
+
+	ctx := WithTraceID(context.TODO(), "4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, p.ApplyChanges(ctx, &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("foo.example.com", "A", "1.1.1.1"),
+		endpoint.NewEndpoint("bar.example.com", "A", "2.2.2.2"),
+	}}))
+	first, second := <-events, <-events
+	assert.Equal(t, uint64(1), first.Seq)
+	assert.Equal(t, uint64(2), second.Seq)
+	assert.Equal(t, "create", first.Action)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", first.TraceID)
+	assert.ElementsMatch(t, []string{"foo", "bar"}, []string{first.Name, second.Name})
+
+	// A reconnecting subscriber catches up on the events it missed
+	backlog, _, cancelLate := p.SubscribeChanges(1)
+	defer cancelLate()
+	require.Len(t, backlog, 1)
+	assert.Equal(t, second, backlog[0])
+}
+
+func testChangeFeedSlowSubscriber(t *testing.T) {
+	var feed changeFeed
+	_, events, cancel := feed.subscribe(0)
+	defer cancel()
+	_, fast, cancelFast := feed.subscribe(0)
+	defer cancelFast()
+
+	for i := range changeFeedBuffer + 1 {
+		feed.publish(AuditEntry{AppliedChange: AppliedChange{ID: fmt.Sprint(i)}})
+		<-fast
+	}
+	received := 0
+	for range events {
+		received++
+	}
+	assert.Equal(t, changeFeedBuffer, received)
+
+	// The history is bounded
+	for range changeFeedHistory {
+		feed.publish(AuditEntry{})
+		<-fast
+	}
+	backlog, _, cancelLate := feed.subscribe(0)
+	defer cancelLate()
+	assert.Len(t, backlog, changeFeedHistory)
+	assert.Equal(t, uint64(changeFeedBuffer+2), backlog[0].Seq)
+}
+
+func testChangeFeedShutdown(t *testing.T) {
+	_, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	_, events, cancel := p.SubscribeChanges(0)
+	defer cancel()
+	require.NoError(t, p.Shutdown(context.TODO()))
+	_, ok := <-events
+	assert.False(t, ok)
+}
@@ -0,0 +1,207 @@
+package inwx
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// FilterRule includes or excludes the DNS names under a domain, or matching a regular expression.
+type FilterRule struct {
+	Exclude bool
+	// Domain matches itself and every name below it.
+	Domain string
+	// Regex matches the names it matches anywhere; anchor it to match whole names.
+	Regex *regexp.Regexp
+}
+
+// ParseFilterRule parses a rule of the form include:<domain>, exclude:<domain>, include-regex:<regex> or
+// exclude-regex:<regex>.
+func ParseFilterRule(s string) (FilterRule, error) {
+	kind, value, ok := strings.Cut(s, ":")
+	if !ok || value == "" {
+		return FilterRule{}, fmt.Errorf("invalid filter rule %q, expected include:<domain>, exclude:<domain>, include-regex:<regex> or exclude-regex:<regex>", s)
+	}
+	var rule FilterRule
+	switch kind {
+	case "include", "exclude":
+		rule.Domain = normalizeName(value)
+	case "include-regex", "exclude-regex":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return FilterRule{}, fmt.Errorf("invalid filter rule %q: %w", s, err)
+		}
+		rule.Regex = re
+	default:
+		return FilterRule{}, fmt.Errorf("invalid filter rule %q: unknown kind %q", s, kind)
+	}
+	rule.Exclude = strings.HasPrefix(kind, "exclude")
+	return rule, nil
+}
+
+func (r FilterRule) String() string {
+	kind := "include"
+	if r.Exclude {
+		kind = "exclude"
+	}
+	if r.Regex != nil {
+		return kind + "-regex:" + r.Regex.String()
+	}
+	return kind + ":" + r.Domain
+}
+
+func (r FilterRule) matches(name string) bool {
+	if r.Regex != nil {
+		return r.Regex.MatchString(name)
+	}
+	return name == r.Domain || strings.HasSuffix(name, "."+r.Domain)
+}
+
+// normalizeName returns name in lower case without a trailing dot.
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// NameFilter decides which DNS names the provider manages through ordered include and exclude rules,
+// e.g. everything under example.com except corp.example.com. The last rule matching a name decides; a
+// name no rule matches is managed only if there are no include rules. Names must also start with one of
+// the prefixes and end in one of the suffixes of the filter, if any, and must not be in one of its excluded
+// domains. A nil NameFilter matches every name.
+type NameFilter struct {
+	rules    []FilterRule
+	includes bool
+
+	prefixes []string
+	suffixes []string
+	excluded []string
+}
+
+// NewNameFilter returns a filter evaluating rules in order.
+func NewNameFilter(rules ...FilterRule) *NameFilter {
+	f := &NameFilter{rules: rules}
+	for _, rule := range rules {
+		f.includes = f.includes || !rule.Exclude
+	}
+	return f
+}
+
+// withAffixes restricts the filter to names starting with one of prefixes and ending in one of suffixes;
+// empty lists match every name.
+func (f *NameFilter) withAffixes(prefixes []string, suffixes []string) *NameFilter {
+	for _, prefix := range prefixes {
+		f.prefixes = append(f.prefixes, strings.ToLower(prefix))
+	}
+	for _, suffix := range suffixes {
+		f.suffixes = append(f.suffixes, normalizeName(suffix))
+	}
+	return f
+}
+
+// withExclusions excludes domains and every name below them regardless of the rules, e.g. zones of
+// --exclude-domains below an included parent domain.
+func (f *NameFilter) withExclusions(domains []string) *NameFilter {
+	for _, domain := range domains {
+		if domain = normalizeName(domain); domain != "" {
+			f.excluded = append(f.excluded, domain)
+		}
+	}
+	return f
+}
+
+// domainRules returns include rules for domains, e.g. of --domain-filter.
+func domainRules(domains []string) []FilterRule {
+	rules := make([]FilterRule, 0, len(domains))
+	for _, domain := range domains {
+		if domain = normalizeName(domain); domain != "" {
+			rules = append(rules, FilterRule{Domain: domain})
+		}
+	}
+	return rules
+}
+
+// Rules returns the rules of the filter in evaluation order.
+func (f *NameFilter) Rules() []FilterRule {
+	if f == nil {
+		return nil
+	}
+	return f.rules
+}
+
+// Match reports whether the provider manages name.
+func (f *NameFilter) Match(name string) bool {
+	if f == nil {
+		return true
+	}
+	name = normalizeName(name)
+	return !f.isExcluded(name) && f.matchRules(name) && f.matchAffixes(name)
+}
+
+// isExcluded reports whether name is one of the excluded domains or below one.
+func (f *NameFilter) isExcluded(name string) bool {
+	return slices.ContainsFunc(f.excluded, func(domain string) bool {
+		return name == domain || strings.HasSuffix(name, "."+domain)
+	})
+}
+
+func (f *NameFilter) matchRules(name string) bool {
+	match := !f.includes
+	for _, rule := range f.rules {
+		if rule.matches(name) {
+			match = !rule.Exclude
+		}
+	}
+	return match
+}
+
+func (f *NameFilter) matchAffixes(name string) bool {
+	hasPrefix := func(prefix string) bool { return strings.HasPrefix(name, prefix) }
+	hasSuffix := func(suffix string) bool { return strings.HasSuffix(name, suffix) }
+	return (len(f.prefixes) == 0 || slices.ContainsFunc(f.prefixes, hasPrefix)) &&
+		(len(f.suffixes) == 0 || slices.ContainsFunc(f.suffixes, hasSuffix))
+}
+
+// MatchZone reports whether zone may hold names the provider manages: the zone is matched itself, or an
+// include rule matches names inside of it, names of the zone may end in one of the suffixes, and the zone
+// isn't excluded. Zones that don't are never read.
+func (f *NameFilter) MatchZone(zone string) bool {
+	if f == nil {
+		return true
+	}
+	zone = normalizeName(zone)
+	if f.isExcluded(zone) {
+		return false
+	}
+	if len(f.suffixes) > 0 && !slices.ContainsFunc(f.suffixes, func(suffix string) bool {
+		// Either every name of the zone ends in the suffix, or names below it may.
+		return strings.HasSuffix(zone, suffix) || strings.HasSuffix(suffix, "."+zone)
+	}) {
+		return false
+	}
+	if f.matchRules(zone) {
+		return true
+	}
+	for _, rule := range f.rules {
+		if rule.Exclude {
+			continue
+		}
+		// A regular expression may match names in any zone.
+		if rule.Regex != nil || strings.HasSuffix(rule.Domain, "."+zone) {
+			return true
+		}
+	}
+	return false
+}
+
+// managesName reports whether the domain filter matches name, or the endpoint name refers to if name is
+// an ownership record, e.g. _edns.cname-example.com for example.com.
+func (p *INWXProvider) managesName(name string) bool {
+	if p.filter.Match(name) {
+		return true
+	}
+	if p.registry == nil {
+		return false
+	}
+	owned, _, ok := p.registry.OwnedEndpoint(name)
+	return ok && p.filter.Match(owned)
+}
@@ -0,0 +1,186 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestNameFilter(t *testing.T) {
+	t.Run("ParseFilterRule", testParseFilterRule)
+	t.Run("Match", testNameFilterMatch)
+	t.Run("RecordsAndApplyChanges", testNameFilterRecordsAndApplyChanges)
+	t.Run("Affixes", testNameFilterAffixes)
+	t.Run("Exclusions", testNameFilterExclusions)
+}
+
+func testParseFilterRule(t *testing.T) {
+	for _, s := range []string{"include:example.com", "exclude:corp.example.com", `include-regex:^api\.`, "exclude-regex:staging"} {
+		rule, err := ParseFilterRule(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, s, rule.String())
+	}
+
+	rule, err := ParseFilterRule("exclude:Corp.Example.com.")
+	require.NoError(t, err)
+	assert.Equal(t, FilterRule{Exclude: true, Domain: "corp.example.com"}, rule)
+
+	for _, s := range []string{"example.com", "include:", "allow:example.com", "include-regex:("} {
+		_, err := ParseFilterRule(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func testNameFilterMatch(t *testing.T) {
+	rules := domainRules([]string{"example.com", ""})
+	for _, s := range []string{"exclude:corp.example.com", "include:vpn.corp.example.com", `include-regex:^api\.example\.net$`} {
+		rule, err := ParseFilterRule(s)
+		require.NoError(t, err)
+		rules = append(rules, rule)
+	}
+	f := NewNameFilter(rules...)
+
+	for name, match := range map[string]bool{
+		"example.com":              true,
+		"www.example.com":          true,
+		"WWW.Example.com.":         true,
+		"myexample.com":            false,
+		"corp.example.com":         false,
+		"git.corp.example.com":     false,
+		"vpn.corp.example.com":     true,
+		"api.example.net":          true,
+		"www.example.net":          false,
+		"api.example.net.evil.org": false,
+	} {
+		assert.Equal(t, match, f.Match(name), name)
+	}
+
+	// Zones are read when they, or names inside of them, may match
+	assert.True(t, f.MatchZone("example.com"))
+	assert.True(t, f.MatchZone("corp.example.com"))
+	assert.True(t, f.MatchZone("example.org"))
+	assert.False(t, NewNameFilter(rules[:3]...).MatchZone("example.org"))
+	assert.False(t, NewNameFilter(rules[:2]...).MatchZone("corp.example.com"))
+
+	// Without include rules everything not excluded matches
+	f = NewNameFilter(rules[1])
+	assert.True(t, f.Match("example.org"))
+	assert.False(t, f.Match("git.corp.example.com"))
+	assert.True(t, (*NameFilter)(nil).Match("example.org"))
+}
+
+func testNameFilterRecordsAndApplyChanges(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	exclude, err := ParseFilterRule("exclude:corp.example.com")
+	require.NoError(t, err)
+	p.filter = NewNameFilter(append(p.filter.Rules(), exclude)...)
+	p.registry = NewTXTRegistry("_edns.", "", "")
+	p.config.allowApexChanges = true
+	for _, zone := range []string{"example.com", "example.org"} {
+		w.CreateZone(zone)
+		require.NoError(t, w.createRecord(&recordRequest{Domain: zone, Name: "www", Type: "A", Content: "1.1.1.1"}))
+	}
+	require.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "git.corp", Type: "A", Content: "2.2.2.2"}))
+
+	eps, err := p.Records(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, eps, 1)
+	assert.Equal(t, "www.example.com", eps[0].DNSName)
+
+	// Changes outside of the filter are dropped, apex ownership records are matched by their owner
+	owner := "heritage=external-dns,external-dns/owner=default"
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("example.com", "A", "3.3.3.3"),
+			endpoint.NewEndpoint("_edns.a-example.com", "TXT", owner),
+			endpoint.NewEndpoint("wiki.corp.example.com", "A", "4.4.4.4"),
+			endpoint.NewEndpoint("foo.example.org", "A", "5.5.5.5"),
+		},
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.org", "A", "1.1.1.1")},
+	}))
+	recs, err := w.getRecords("example.com")
+	require.NoError(t, err)
+	assert.Len(t, *recs, 4)
+	recs, err = w.getRecords("example.org")
+	require.NoError(t, err)
+	require.Len(t, *recs, 1)
+	assert.Equal(t, "www", (*recs)[0].Name)
+}
+
+func testNameFilterAffixes(t *testing.T) {
+	f := NewNameFilter(domainRules([]string{"example.com", "example.org"})...).withAffixes([]string{"preview-", "pr-"}, []string{".apps.example.com."})
+	for name, match := range map[string]bool{
+		"preview-1.apps.example.com": true,
+		"PR-2.Apps.Example.com":      true,
+		"www.apps.example.com":       false,
+		"preview-1.example.com":      false,
+		"preview-1.apps.example.org": false,
+	} {
+		assert.Equal(t, match, f.Match(name), name)
+	}
+
+	// Only zones that may hold names ending in a suffix are read
+	assert.True(t, f.MatchZone("example.com"))
+	assert.True(t, f.MatchZone("apps.example.com"))
+	assert.False(t, f.MatchZone("example.org"))
+
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com", "example.org"}, slog.Default())
+	p.filter = p.filter.withAffixes(nil, []string{".apps.example.com"})
+	for _, zone := range []string{"example.com", "example.org"} {
+		w.CreateZone(zone)
+		require.NoError(t, w.createRecord(&recordRequest{Domain: zone, Name: "www", Type: "A", Content: "192.0.2.1", TTL: 300}))
+	}
+	require.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "web.apps", Type: "A", Content: "192.0.2.2", TTL: 300}))
+	records, err := p.Records(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "web.apps.example.com", records[0].DNSName)
+}
+
+func testNameFilterExclusions(t *testing.T) {
+	include, err := ParseFilterRule("include:vpn.corp.example.com")
+	require.NoError(t, err)
+	f := NewNameFilter(append(domainRules([]string{"example.com"}), include)...).withExclusions([]string{"Corp.Example.com."})
+	for name, match := range map[string]bool{
+		"www.example.com":              true,
+		"corp.example.com":             false,
+		"vpn.corp.example.com":         false,
+		"www.notcorp.example.com":      true,
+		"www.corp.example.com.":        false,
+		"corp.example.com.example.com": true,
+	} {
+		assert.Equal(t, match, f.Match(name), name)
+	}
+
+	// Excluded zones are never read, even if a rule includes names in them
+	assert.True(t, f.MatchZone("example.com"))
+	assert.False(t, f.MatchZone("corp.example.com"))
+	assert.False(t, f.MatchZone("lab.corp.example.com"))
+
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	p.filter = p.filter.withExclusions([]string{"corp.example.com"})
+	for _, zone := range []string{"example.com", "corp.example.com"} {
+		w.CreateZone(zone)
+		require.NoError(t, w.createRecord(&recordRequest{Domain: zone, Name: "www", Type: "A", Content: "192.0.2.1", TTL: 300}))
+	}
+	records, err := p.Records(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "www.example.com", records[0].DNSName)
+
+	// Changes to excluded names are dropped
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("web.corp.example.com", "A", "192.0.2.2")},
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("www.corp.example.com", "A", "192.0.2.1")},
+	}))
+	recs, err := w.getRecords("corp.example.com")
+	require.NoError(t, err)
+	require.Len(t, *recs, 1)
+	assert.Equal(t, "www", (*recs)[0].Name)
+	assert.NotEmpty(t, (*recs)[0].ID)
+}
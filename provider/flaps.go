@@ -0,0 +1,154 @@
+package inwx
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// flapMetricsLimit caps the number of records exported through the churn metric.
+const flapMetricsLimit = 10
+
+// Flap summarizes how often a single record changed within the flap detection window.
+type Flap struct {
+	Zone       string    `json:"zone"`
+	Name       string    `json:"name"`
+	Type       string    `json:"type"`
+	Changes    int       `json:"changes"`
+	Flapping   bool      `json:"flapping"`
+	LastChange time.Time `json:"lastChange"`
+}
+
+type flapKey struct {
+	zone, name, recordType string
+}
+
+// flapTracker keeps the timestamps of recent changes per record over a sliding window.
+type flapTracker struct {
+	mu        sync.Mutex
+	window    time.Duration
+	threshold int
+	changes   map[flapKey][]time.Time
+}
+
+func newFlapTracker(window time.Duration, threshold int) *flapTracker {
+	return &flapTracker{window: window, threshold: threshold, changes: map[flapKey][]time.Time{}}
+}
+
+// observe registers a change of the record at the given time and reports whether the record
+// just reached the flap threshold.
+func (t *flapTracker) observe(zone string, name string, recordType string, at time.Time) bool {
+	if t == nil || t.window <= 0 {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := flapKey{zone, name, recordType}
+	t.changes[key] = append(t.expire(t.changes[key], at), at)
+	return len(t.changes[key]) == t.threshold
+}
+
+// len returns the number of records with changes tracked, including expired ones not dropped yet.
+func (t *flapTracker) len() int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.changes)
+}
+
+// expire drops the timestamps that fell out of the window ending at now.
+func (t *flapTracker) expire(times []time.Time, now time.Time) []time.Time {
+	i := 0
+	for i < len(times) && now.Sub(times[i]) > t.window {
+		i++
+	}
+	return times[i:]
+}
+
+// top returns up to limit records with the most changes inside the window, most changed first.
+// A limit of 0 returns all of them.
+func (t *flapTracker) top(limit int, now time.Time) []Flap {
+	if t == nil {
+		return []Flap{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	flaps := []Flap{}
+	for key, times := range t.changes {
+		times = t.expire(times, now)
+		if len(times) == 0 {
+			delete(t.changes, key)
+			continue
+		}
+		t.changes[key] = times
+		flaps = append(flaps, Flap{
+			Zone:       key.zone,
+			Name:       key.name,
+			Type:       key.recordType,
+			Changes:    len(times),
+			Flapping:   len(times) >= t.threshold,
+			LastChange: times[len(times)-1],
+		})
+	}
+	slices.SortFunc(flaps, func(a, b Flap) int {
+		return cmp.Or(
+			cmp.Compare(b.Changes, a.Changes),
+			cmp.Compare(a.Zone, b.Zone),
+			cmp.Compare(a.Name, b.Name),
+			cmp.Compare(a.Type, b.Type),
+		)
+	})
+	if limit > 0 && len(flaps) > limit {
+		flaps = flaps[:limit]
+	}
+	return flaps
+}
+
+// Flaps returns up to limit records that changed most often within the flap detection window.
+func (p *INWXProvider) Flaps(limit int) []Flap {
+	return p.flaps.top(limit, now(p.config.clock))
+}
+
+var (
+	recordChurnDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(MetricsNamespace, "", "record_churn"),
+		"Number of changes within the flap detection window for the most frequently changed records.",
+		[]string{"zone", "name", "type"}, nil,
+	)
+	flappingRecordsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(MetricsNamespace, "", "flapping_records"),
+		"Number of records that reached the flap threshold within the flap detection window.",
+		nil, nil,
+	)
+)
+
+// flapCollector exports the churn of the top flapping records at scrape time.
+type flapCollector struct {
+	tracker *flapTracker
+	clock   Clock
+}
+
+func (c flapCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- recordChurnDesc
+	ch <- flappingRecordsDesc
+}
+
+func (c flapCollector) Collect(ch chan<- prometheus.Metric) {
+	flaps := c.tracker.top(0, now(c.clock))
+	flapping := 0
+	for i, flap := range flaps {
+		if flap.Flapping {
+			flapping++
+		}
+		if i < flapMetricsLimit {
+			ch <- prometheus.MustNewConstMetric(recordChurnDesc, prometheus.GaugeValue, float64(flap.Changes), flap.Zone, flap.Name, flap.Type)
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(flappingRecordsDesc, prometheus.GaugeValue, float64(flapping))
+}
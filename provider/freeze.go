@@ -0,0 +1,53 @@
+package inwx
+
+import (
+	"context"
+	"slices"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// DefaultFreezeRecord is the name, relative to the zone, of the TXT record whose presence freezes a zone.
+const DefaultFreezeRecord = "_external-dns-freeze"
+
+type frozenZonesKey struct{}
+
+// checkFreezeRecords looks up the freeze record in every zone touched by the changes and returns a context
+// marking the zones containing it as frozen. Zones that can't be looked up are left to the mutations
+// themselves to fail.
+func (p *INWXProvider) checkFreezeRecords(ctx context.Context, zones *[]string, changes ...*plan.Changes) context.Context {
+	if p.config.freezeRecord == "" {
+		return ctx
+	}
+	touched := []string{}
+	for _, c := range changes {
+		for _, ep := range slices.Concat(c.Create, c.UpdateOld, c.UpdateNew, c.Delete) {
+			if zone, err := p.zoneFor(zones, ep); err == nil && !slices.Contains(touched, zone) {
+				touched = append(touched, zone)
+			}
+		}
+	}
+
+	frozen := map[string]bool{}
+	for _, zone := range touched {
+		records, err := p.getRecords(ctx, zone)
+		if err != nil {
+			continue
+		}
+		for _, rec := range *records {
+			if rec.Type == endpoint.RecordTypeTXT && rec.Name == p.config.freezeRecord {
+				p.logger.Warn("zone is frozen by its freeze record, skipping its changes", "zone", zone, "record", p.config.freezeRecord, "reason", rec.Content)
+				frozen[zone] = true
+				break
+			}
+		}
+	}
+	return context.WithValue(ctx, frozenZonesKey{}, frozen)
+}
+
+// frozenByRecord reports whether zone was found frozen by checkFreezeRecords.
+func frozenByRecord(ctx context.Context, zone string) bool {
+	frozen, _ := ctx.Value(frozenZonesKey{}).(map[string]bool)
+	return frozen[zone]
+}
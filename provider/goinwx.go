@@ -0,0 +1,101 @@
+package inwx
+
+import (
+	"errors"
+
+	inwx "github.com/nrdcg/goinwx"
+)
+
+// goinwxClient is the DomRobot client built on goinwx, speaking XML-RPC.
+type goinwxClient struct {
+	client *inwx.Client
+}
+
+func (c goinwxClient) login(username string, password string) (loginResult, error) {
+	// Account.Login can only log in with the credentials the client was created with.
+	response, err := c.client.Do(c.client.NewRequest("account.login", map[string]any{"user": username, "pass": password}))
+	if err != nil {
+		return loginResult{}, apiErrorOf(err)
+	}
+	return loginResult{locked: tfaEnabled(response["tfa"]), customerID: intValue(response["customerId"]), accountID: intValue(response["accountId"])}, nil
+}
+
+func (c goinwxClient) unlock(tan string) error {
+	return apiErrorOf(c.client.Account.Unlock(tan))
+}
+
+func (c goinwxClient) logout() error {
+	return apiErrorOf(c.client.Account.Logout())
+}
+
+func (c goinwxClient) accountInfo() error {
+	// goinwx doesn't wrap account.info; the account details aren't needed anyway.
+	_, err := c.client.Do(c.client.NewRequest("account.info", nil))
+	return apiErrorOf(err)
+}
+
+func (c goinwxClient) nameserverInfo(domain string) ([]zoneRecord, error) {
+	zone, err := c.client.Nameservers.Info(&inwx.NameserverInfoRequest{Domain: domain})
+	if err != nil {
+		return nil, apiErrorOf(err)
+	}
+	records := make([]zoneRecord, 0, len(zone.Records))
+	for _, rec := range zone.Records {
+		records = append(records, zoneRecord{ID: rec.ID, Name: rec.Name, Type: rec.Type, Content: rec.Content, TTL: rec.TTL, Priority: rec.Priority,
+			RedirectType: rec.URLRedirectType})
+	}
+	return records, nil
+}
+
+func (c goinwxClient) nameserverList(page int, pageLimit int) ([]string, int, error) {
+	response, err := c.client.Nameservers.ListWithParams(&inwx.NameserverListRequest{Domain: "*", Page: page, PageLimit: pageLimit})
+	if err != nil {
+		return nil, 0, apiErrorOf(err)
+	}
+	zones := make([]string, 0, len(response.Domains))
+	for _, domain := range response.Domains {
+		zones = append(zones, domain.Domain)
+	}
+	return zones, response.Count, nil
+}
+
+func (c goinwxClient) nameserverCreate(request *zoneRequest) error {
+	_, err := c.client.Nameservers.Create(&inwx.NameserverCreateRequest{Domain: request.Domain, Type: "MASTER", Nameservers: request.Nameservers,
+		SoaEmail: request.SOAEmail})
+	return apiErrorOf(err)
+}
+
+func (c goinwxClient) createRecord(request *recordRequest) error {
+	_, err := c.client.Nameservers.CreateRecord(nameserverRecordRequest(request))
+	return apiErrorOf(err)
+}
+
+func (c goinwxClient) updateRecord(recID string, request *recordRequest) error {
+	return apiErrorOf(c.client.Nameservers.UpdateRecord(recID, nameserverRecordRequest(request)))
+}
+
+func (c goinwxClient) deleteRecord(recID string) error {
+	return apiErrorOf(c.client.Nameservers.DeleteRecord(recID))
+}
+
+// nameserverRecordRequest converts a recordRequest for goinwx.
+func nameserverRecordRequest(request *recordRequest) *inwx.NameserverRecordRequest {
+	return &inwx.NameserverRecordRequest{
+		Domain:          request.Domain,
+		Name:            request.Name,
+		Type:            request.Type,
+		Content:         request.Content,
+		TTL:             request.TTL,
+		Priority:        request.Priority,
+		URLRedirectType: request.RedirectType,
+	}
+}
+
+// apiErrorOf returns an error of the INWX API reported by goinwx as apiError, and other errors as they are.
+func apiErrorOf(err error) error {
+	var errResp *inwx.ErrorResponse
+	if errors.As(err, &errResp) {
+		return &apiError{Code: errResp.Code, Message: errResp.Message, ReasonCode: errResp.ReasonCode, Reason: errResp.Reason}
+	}
+	return err
+}
@@ -0,0 +1,246 @@
+package inwx
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HealthState is the state of the provider or one of its components, from best to worst.
+type HealthState string
+
+const (
+	// HealthOK means the component works as intended.
+	HealthOK HealthState = "ok"
+	// HealthDegraded means the component works, but not as intended, e.g. serves stale records.
+	HealthDegraded HealthState = "degraded"
+	// HealthFailing means the component doesn't work.
+	HealthFailing HealthState = "failing"
+)
+
+// severity orders the states for aggregation and is exported as the value of the health metric.
+func (s HealthState) severity() int {
+	switch s {
+	case HealthDegraded:
+		return 1
+	case HealthFailing:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// Health components.
+const (
+	componentRecords     = "records"
+	componentApply       = "apply"
+	componentSession     = "session"
+	componentZones       = "zones"
+	componentMaintenance = "maintenance"
+	componentLifecycle   = "lifecycle"
+	// componentCompatibility rates the external-dns instance calling the webhook.
+	componentCompatibility = "compatibility"
+)
+
+// ComponentHealth is the state of one component, with the time it entered that state.
+type ComponentHealth struct {
+	Name    string      `json:"name"`
+	State   HealthState `json:"state"`
+	Message string      `json:"message,omitempty"`
+	Since   time.Time   `json:"since"`
+}
+
+// Health is the state of the provider: the worst state of its components.
+type Health struct {
+	State      HealthState       `json:"state"`
+	Components []ComponentHealth `json:"components"`
+	Checked    time.Time         `json:"checked"`
+	// Account is the INWX account logged in to last, if any.
+	Account *Account `json:"account,omitempty"`
+}
+
+// Alive reports whether the provider wasn't shut down.
+func (h Health) Alive() bool {
+	for _, c := range h.Components {
+		if c.Name == componentLifecycle && c.State == HealthFailing {
+			return false
+		}
+	}
+	return true
+}
+
+// Ready reports whether the provider can serve requests, possibly degraded.
+func (h Health) Ready() bool {
+	return h.State != HealthFailing
+}
+
+// healthTracker holds the last observed state of the components reporting their results. Components not
+// observed yet are left out.
+type healthTracker struct {
+	mu         sync.Mutex
+	components map[string]ComponentHealth
+}
+
+// observe records the state of a component, keeping the time it entered that state if it didn't change.
+func (t *healthTracker) observe(name string, state HealthState, message string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.components == nil {
+		t.components = map[string]ComponentHealth{}
+	}
+	since := at
+	if previous, ok := t.components[name]; ok && previous.State == state {
+		since = previous.Since
+	}
+	t.components[name] = ComponentHealth{Name: name, State: state, Message: message, Since: since}
+}
+
+// observeErr records a component as failing with err, or as ok without one.
+func (t *healthTracker) observeErr(name string, err error, at time.Time) {
+	if err != nil {
+		t.observe(name, HealthFailing, err.Error(), at)
+		return
+	}
+	t.observe(name, HealthOK, "", at)
+}
+
+// observeApply records the result of an apply, which is degraded if changes raced with other writers.
+func (t *healthTracker) observeApply(err error, warnings int, at time.Time) {
+	if err == nil && warnings > 0 {
+		t.observe(componentApply, HealthDegraded, fmt.Sprintf("%d changes raced with other writers", warnings), at)
+		return
+	}
+	t.observeErr(componentApply, err, at)
+}
+
+// sessionCheck caches the result of the last CheckSession.
+type sessionCheck struct {
+	mu      sync.Mutex
+	checked time.Time
+	err     error
+}
+
+// CheckSession logs in to INWX and confirms the session with a cheap authenticated call, so that invalid
+// credentials or an INWX outage are noticed although no reconcile is running. The result is reused for
+// the session check interval, and concurrent checks wait for the one in progress. During a maintenance
+// window INWX is expected to be unavailable, so the session isn't checked.
+func (p *INWXProvider) CheckSession(ctx context.Context) error {
+	if _, ok := p.inMaintenance(); ok {
+		return nil
+	}
+	p.sessionCheck.mu.Lock()
+	defer p.sessionCheck.mu.Unlock()
+	at := now(p.config.clock)
+	if !p.sessionCheck.checked.IsZero() && at.Sub(p.sessionCheck.checked) < p.config.sessionCheckInterval {
+		return p.sessionCheck.err
+	}
+
+	err := p.login(ctx)
+	if err == nil {
+		err = p.client.checkSession()
+		p.health.observeErr(componentSession, err, at)
+		if logoutErr := p.client.logout(); logoutErr != nil {
+			p.logger.Error("error encountered while logging out", "err", logoutErr)
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("INWX session check failed: %w", err)
+	}
+	p.sessionCheck.checked, p.sessionCheck.err = at, err
+	return err
+}
+
+// zonesListed records whether any of the zones listed matches the domain filter. Only successful listings
+// are observed, so that the provider stays ready through INWX errors once its zones were discovered.
+func (p *INWXProvider) zonesListed(zones []string) {
+	matching := 0
+	for _, zone := range zones {
+		if p.filter.MatchZone(zone) {
+			matching++
+		}
+	}
+	if matching == 0 {
+		p.health.observe(componentZones, HealthFailing, fmt.Sprintf("none of the %d zones of the account matches the domain filter", len(zones)), now(p.config.clock))
+		return
+	}
+	p.health.observe(componentZones, HealthOK, fmt.Sprintf("%d of %d zones match the domain filter", matching, len(zones)), now(p.config.clock))
+}
+
+// recordsServed records whether the records served last were current or the last known-good ones.
+func (p *INWXProvider) recordsServed(stale bool, reason string) {
+	if stale {
+		p.metrics.recordsStale.Set(1)
+		p.health.observe(componentRecords, HealthDegraded, reason, now(p.config.clock))
+		return
+	}
+	p.metrics.recordsStale.Set(0)
+	p.health.observe(componentRecords, HealthOK, "", now(p.config.clock))
+}
+
+// Health returns the state of the provider and its components: the last records read, the last apply and
+// the last INWX login, whether zones matching the domain filter were discovered, whether a maintenance
+// window is in progress, and whether the provider is shut down. Until the zones were listed successfully
+// the provider is failing, so that it isn't ready.
+func (p *INWXProvider) Health() Health {
+	checked := now(p.config.clock)
+
+	p.health.mu.Lock()
+	components := make([]ComponentHealth, 0, len(p.health.components)+3)
+	for _, c := range p.health.components {
+		components = append(components, c)
+	}
+	_, discovered := p.health.components[componentZones]
+	p.health.mu.Unlock()
+
+	if !discovered {
+		components = append(components, ComponentHealth{Name: componentZones, State: HealthFailing, Message: "zones not listed yet", Since: checked})
+	}
+	if window, ok := p.inMaintenance(); ok {
+		components = append(components, ComponentHealth{Name: componentMaintenance, State: HealthDegraded,
+			Message: "maintenance window " + window.String() + " in progress", Since: window.Start})
+	}
+	p.lifecycle.mu.Lock()
+	closed := p.lifecycle.closed
+	p.lifecycle.mu.Unlock()
+	if closed {
+		components = append(components, ComponentHealth{Name: componentLifecycle, State: HealthFailing, Message: ErrShutdown.Error(), Since: checked})
+	}
+
+	sort.Slice(components, func(i, j int) bool { return components[i].Name < components[j].Name })
+	health := Health{State: HealthOK, Components: components, Checked: checked}
+	if account, ok := p.Account(); ok {
+		health.Account = &account
+	}
+	for _, c := range components {
+		if c.State.severity() > health.State.severity() {
+			health.State = c.State
+		}
+	}
+	return health
+}
+
+var healthStateDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(MetricsNamespace, "", "health_state"),
+	"State of the provider (component \"\") and its components: 0 ok, 1 degraded, 2 failing.",
+	[]string{"component"}, nil)
+
+// healthCollector exports the health of the provider at scrape time.
+type healthCollector struct {
+	provider *INWXProvider
+}
+
+func (c healthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- healthStateDesc
+}
+
+func (c healthCollector) Collect(ch chan<- prometheus.Metric) {
+	health := c.provider.Health()
+	ch <- prometheus.MustNewConstMetric(healthStateDesc, prometheus.GaugeValue, float64(health.State.severity()), "")
+	for _, component := range health.Components {
+		ch <- prometheus.MustNewConstMetric(healthStateDesc, prometheus.GaugeValue, float64(component.State.severity()), component.Name)
+	}
+}
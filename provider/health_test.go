@@ -0,0 +1,173 @@
+package inwx
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestHealth(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	clock := &fakeClock{now: time.Date(2026, 11, 3, 12, 0, 0, 0, time.UTC)}
+	p.config.clock = clock
+	p.records = newRecordsCache(0, time.Hour)
+
+	states := func() map[string]HealthState {
+		states := map[string]HealthState{}
+		for _, c := range p.Health().Components {
+			states[c.Name] = c.State
+		}
+		return states
+	}
+
+	// Not ready until the zones were listed
+	health := p.Health()
+	assert.Equal(t, HealthFailing, health.State)
+	assert.Equal(t, map[string]HealthState{"zones": HealthFailing}, states())
+	assert.True(t, health.Alive())
+	assert.False(t, health.Ready())
+
+	_, err := p.Records(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]HealthState{"records": HealthOK, "session": HealthOK, "zones": HealthOK}, states())
+
+	// Serving the last known-good records is degraded, failing to serve any records is failing
+	clock.advance(time.Minute)
+	w.zonesErr = errors.New("connection refused")
+	_, err = p.Records(context.TODO())
+	require.NoError(t, err)
+	health = p.Health()
+	assert.Equal(t, HealthDegraded, health.State)
+	assert.True(t, health.Ready())
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.metrics.recordsStale))
+
+	p.records = newRecordsCache(0, 0)
+	_, err = p.Records(context.TODO())
+	require.Error(t, err)
+	health = p.Health()
+	assert.Equal(t, HealthFailing, health.State)
+	assert.False(t, health.Ready())
+	assert.Equal(t, "connection refused", health.Components[0].Message)
+	assert.Equal(t, clock.now, health.Components[0].Since)
+
+	// The last apply counts as well
+	w.zonesErr = nil
+	changes := &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.com", "A", "192.0.2.1")}}
+	require.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Equal(t, HealthOK, states()["apply"])
+	w.createErr = func(*recordRequest) error { return errors.New("quota exceeded") }
+	changes = &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("bar.example.com", "A", "192.0.2.2")}}
+	require.Error(t, p.ApplyChanges(context.TODO(), changes))
+	assert.Equal(t, HealthFailing, states()["apply"])
+
+	// A maintenance window in progress is degraded
+	w.createErr = nil
+	p.maintenance = newMaintenanceTracker([]MaintenanceWindow{{Start: clock.now, End: clock.now.Add(time.Hour)}})
+	assert.Equal(t, HealthDegraded, states()["maintenance"])
+
+	// The health is exported at scrape time
+	expected := `
+		# HELP external_dns_inwx_health_state State of the provider (component "") and its components: 0 ok, 1 degraded, 2 failing.
+		# TYPE external_dns_inwx_health_state gauge
+		external_dns_inwx_health_state{component=""} 2
+		external_dns_inwx_health_state{component="apply"} 2
+		external_dns_inwx_health_state{component="maintenance"} 1
+		external_dns_inwx_health_state{component="records"} 2
+		external_dns_inwx_health_state{component="session"} 0
+		external_dns_inwx_health_state{component="zones"} 0
+	`
+	require.NoError(t, testutil.CollectAndCompare(healthCollector{provider: p}, strings.NewReader(expected)))
+
+	// Once shut down, the provider is no longer alive
+	require.NoError(t, p.Close())
+	health = p.Health()
+	assert.False(t, health.Alive())
+	assert.Equal(t, HealthFailing, states()["lifecycle"])
+}
+
+func TestCheckSession(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	clock := &fakeClock{now: time.Date(2026, 11, 3, 12, 0, 0, 0, time.UTC)}
+	p.config.clock = clock
+	p.config.sessionCheckInterval = time.Minute
+
+	require.NoError(t, p.CheckSession(context.TODO()))
+	assert.Equal(t, 1, w.logins)
+
+	// The result is reused within the interval
+	w.loginErr = errors.New("authentication error")
+	require.NoError(t, p.CheckSession(context.TODO()))
+	assert.Equal(t, 1, w.logins)
+
+	clock.advance(time.Minute)
+	err := p.CheckSession(context.TODO())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "authentication error")
+	assert.Equal(t, 2, w.logins)
+	require.Error(t, p.CheckSession(context.TODO()))
+	assert.Equal(t, 2, w.logins)
+	session := func() HealthState {
+		for _, c := range p.Health().Components {
+			if c.Name == componentSession {
+				return c.State
+			}
+		}
+		return ""
+	}
+	assert.Equal(t, HealthFailing, session())
+
+	clock.advance(time.Minute)
+	w.loginErr = nil
+	require.NoError(t, p.CheckSession(context.TODO()))
+	assert.Equal(t, HealthOK, session())
+
+	// INWX isn't expected to be reachable during a maintenance window
+	clock.advance(time.Minute)
+	w.loginErr = errors.New("service unavailable")
+	p.maintenance = newMaintenanceTracker([]MaintenanceWindow{{Start: clock.now.Add(-time.Minute), End: clock.now.Add(time.Hour)}})
+	require.NoError(t, p.CheckSession(context.TODO()))
+	assert.Equal(t, 3, w.logins)
+}
+
+func TestZoneDiscovery(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.org")
+
+	zones := func() ComponentHealth {
+		for _, c := range p.Health().Components {
+			if c.Name == componentZones {
+				return c
+			}
+		}
+		return ComponentHealth{}
+	}
+
+	_, err := p.Records(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, HealthFailing, zones().State)
+	assert.Equal(t, "none of the 1 zones of the account matches the domain filter", zones().Message)
+	assert.False(t, p.Health().Ready())
+
+	w.CreateZone("example.com")
+	_, err = p.Records(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, HealthOK, zones().State)
+	assert.Equal(t, "1 of 2 zones match the domain filter", zones().Message)
+	assert.True(t, p.Health().Ready())
+
+	// Once discovered, failing to list the zones doesn't affect the readiness
+	w.zonesErr = errors.New("connection refused")
+	_, err = p.Records(context.TODO())
+	require.Error(t, err)
+	assert.Equal(t, HealthOK, zones().State)
+}
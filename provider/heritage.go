@@ -0,0 +1,163 @@
+package inwx
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// DuplicateOwnershipPolicy decides what happens to ownership records referring to the same endpoint, e.g.
+// left over after the owner ID of external-dns was renamed or the TXT registry switched to its new naming
+// format. external-dns reads only one of them, so the others make it plan deletes and updates of records
+// it doesn't own, or can't find.
+type DuplicateOwnershipPolicy string
+
+const (
+	// DuplicateOwnershipIgnore leaves duplicate ownership records alone.
+	DuplicateOwnershipIgnore DuplicateOwnershipPolicy = "ignore"
+	// DuplicateOwnershipWarn logs duplicate ownership records.
+	DuplicateOwnershipWarn DuplicateOwnershipPolicy = "warn"
+	// DuplicateOwnershipConsolidate deletes every duplicate ownership record but the canonical one.
+	DuplicateOwnershipConsolidate DuplicateOwnershipPolicy = "consolidate"
+)
+
+// ParseDuplicateOwnershipPolicy returns the DuplicateOwnershipPolicy named s.
+func ParseDuplicateOwnershipPolicy(s string) (DuplicateOwnershipPolicy, error) {
+	switch policy := DuplicateOwnershipPolicy(s); policy {
+	case DuplicateOwnershipIgnore, DuplicateOwnershipWarn, DuplicateOwnershipConsolidate:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown duplicate ownership policy %q, expected ignore, warn or consolidate", s)
+	}
+}
+
+// ownershipRecord is an ownership record with the endpoint it refers to.
+type ownershipRecord struct {
+	zoneRecord
+	// owned is the name of the endpoint, recordType its type, or "" for records in the old format of the
+	// TXT registry, which share the name of the endpoint and refer to every type.
+	owned      string
+	recordType string
+}
+
+// heritageOwner returns the owner ID of an ownership record, or "" if it carries none.
+func heritageOwner(content string) string {
+	for _, label := range strings.Split(strings.Trim(content, `"`), ",") {
+		if owner, ok := strings.CutPrefix(label, "external-dns/"+endpoint.OwnerLabelKey+"="); ok {
+			return owner
+		}
+	}
+	return ""
+}
+
+// duplicateOwnershipRecords returns the ownership records of zone referring to an endpoint that another,
+// canonical ownership record refers to as well. Old format records are duplicates of any new format
+// record of their name. Records outside of the domain filter are left alone.
+func (p *INWXProvider) duplicateOwnershipRecords(zone string, records []zoneRecord) []ownershipRecord {
+	byEndpoint := map[string]map[string][]ownershipRecord{}
+	for _, rec := range records {
+		if !isHeritageRecord(rec.Type, rec.Content) {
+			continue
+		}
+		name := p.registry.EndpointName(rec.Name, zone, rec.Type)
+		if !p.managesName(name) {
+			continue
+		}
+		owned, recordType, ok := p.registry.OwnedEndpoint(name)
+		if !ok {
+			owned, recordType = name, ""
+		}
+		owned = normalizeName(owned)
+		if byEndpoint[owned] == nil {
+			byEndpoint[owned] = map[string][]ownershipRecord{}
+		}
+		byEndpoint[owned][recordType] = append(byEndpoint[owned][recordType], ownershipRecord{zoneRecord: rec, owned: owned, recordType: recordType})
+	}
+
+	duplicates := []ownershipRecord{}
+	for _, owned := range slices.Sorted(maps.Keys(byEndpoint)) {
+		byType := byEndpoint[owned]
+		for _, recordType := range slices.Sorted(maps.Keys(byType)) {
+			recs := byType[recordType]
+			if recordType == "" && len(byType) > 1 {
+				duplicates = append(duplicates, recs...)
+				continue
+			}
+			canonical := p.canonicalOwnershipRecord(recs)
+			for i, rec := range recs {
+				if i != canonical {
+					duplicates = append(duplicates, rec)
+				}
+			}
+		}
+	}
+	return duplicates
+}
+
+// canonicalOwnershipRecord returns the index of the record to keep among ownership records of the same
+// endpoint: the newest one, i.e. the one with the highest record ID, of those carrying the owner ID of
+// external-dns, if known.
+func (p *INWXProvider) canonicalOwnershipRecord(recs []ownershipRecord) int {
+	rank := func(rec ownershipRecord) (bool, int) {
+		id, _ := strconv.Atoi(rec.ID)
+		return p.config.txtOwnerID != "" && heritageOwner(rec.Content) == p.config.txtOwnerID, id
+	}
+	canonical := 0
+	for i, rec := range recs[1:] {
+		owned, id := rank(rec)
+		bestOwned, bestID := rank(recs[canonical])
+		if owned != bestOwned {
+			if owned {
+				canonical = i + 1
+			}
+			continue
+		}
+		if cmp.Compare(id, bestID) > 0 {
+			canonical = i + 1
+		}
+	}
+	return canonical
+}
+
+// cleanupOwnershipRecords looks for duplicate ownership records in every zone touched by changes and,
+// depending on the policy, logs them or deletes them before the changes are applied. Deletes go through
+// the zone settings like any other change; failures are only logged, they are retried with the next
+// changes.
+func (p *INWXProvider) cleanupOwnershipRecords(ctx context.Context, zones *[]string, changes *plan.Changes) {
+	if p.config.duplicateOwnership == "" || p.config.duplicateOwnership == DuplicateOwnershipIgnore {
+		return
+	}
+	touched := []string{}
+	for _, ep := range slices.Concat(changes.Create, changes.UpdateOld, changes.UpdateNew, changes.Delete) {
+		if zone, err := p.zoneFor(zones, ep); err == nil && !slices.Contains(touched, zone) {
+			touched = append(touched, zone)
+		}
+	}
+	for _, zone := range touched {
+		records, err := p.getRecords(ctx, zone)
+		if err != nil {
+			continue
+		}
+		duplicates := p.duplicateOwnershipRecords(zone, *records)
+		p.metrics.duplicateOwnershipRecords.WithLabelValues(zone).Set(float64(len(duplicates)))
+		for _, dup := range duplicates {
+			if p.config.duplicateOwnership != DuplicateOwnershipConsolidate {
+				p.logger.Warn("duplicate ownership record", "zone", zone, "name", dup.Name, "id", dup.ID, "content", dup.Content,
+					"owned", dup.owned, "owned_type", dup.recordType)
+				continue
+			}
+			p.logger.Info("deleting duplicate ownership record", "zone", zone, "name", dup.Name, "id", dup.ID, "content", dup.Content,
+				"owned", dup.owned, "owned_type", dup.recordType)
+			if err := p.deleteRecord(ctx, zone, dup.Name, dup.Type, dup.Content, dup.ID); err != nil {
+				logChangeError("failed to delete duplicate ownership record", err, "zone", zone, "name", dup.Name, "id", dup.ID)
+			}
+		}
+	}
+}
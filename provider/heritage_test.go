@@ -0,0 +1,99 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestDuplicateOwnershipRecords(t *testing.T) {
+	t.Run("Detect", testDetectDuplicateOwnershipRecords)
+	t.Run("Consolidate", testConsolidateOwnershipRecords)
+}
+
+const (
+	heritageOld     = "heritage=external-dns,external-dns/owner=old,external-dns/resource=ingress/default/web"
+	heritageCurrent = "heritage=external-dns,external-dns/owner=default,external-dns/resource=ingress/default/web"
+)
+
+// zoneWithDuplicateOwnership returns a provider with the TXT registry and a zone in which www has an
+// ownership record of a renamed owner and one in the old format besides its current one.
+func zoneWithDuplicateOwnership(t *testing.T) (*MockClientWrapper, *INWXProvider) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	p.registry = NewTXTRegistry("", "", "")
+	w.CreateZone("example.com")
+	for _, rec := range []recordRequest{
+		{Name: "www", Type: "A", Content: "192.0.2.1"},
+		{Name: "a-www", Type: "TXT", Content: heritageCurrent},
+		{Name: "a-www", Type: "TXT", Content: heritageOld},
+		{Name: "www", Type: "TXT", Content: heritageCurrent},
+		{Name: "aaaa-www", Type: "TXT", Content: heritageCurrent},
+		// Old format records without a new format one are not duplicates
+		{Name: "api", Type: "TXT", Content: heritageCurrent},
+	} {
+		rec.Domain, rec.TTL = "example.com", 300
+		require.NoError(t, w.createRecord(&rec))
+	}
+	return w, p
+}
+
+func testDetectDuplicateOwnershipRecords(t *testing.T) {
+	w, p := zoneWithDuplicateOwnership(t)
+	records, err := w.getRecords("example.com")
+	require.NoError(t, err)
+
+	// Without the owner ID, the newest record is kept
+	duplicates := p.duplicateOwnershipRecords("example.com", *records)
+	require.Len(t, duplicates, 2)
+	assert.Equal(t, "www", duplicates[0].Name)
+	assert.Equal(t, "", duplicates[0].recordType)
+	assert.Equal(t, "a-www", duplicates[1].Name)
+	assert.Equal(t, heritageCurrent, duplicates[1].Content)
+	assert.Equal(t, "A", duplicates[1].recordType)
+
+	p.config.txtOwnerID = "default"
+	duplicates = p.duplicateOwnershipRecords("example.com", *records)
+	require.Len(t, duplicates, 2)
+	assert.Equal(t, heritageOld, duplicates[1].Content)
+
+	assert.Equal(t, "default", heritageOwner(heritageCurrent))
+	assert.Equal(t, "", heritageOwner("heritage=external-dns"))
+}
+
+func testConsolidateOwnershipRecords(t *testing.T) {
+	w, p := zoneWithDuplicateOwnership(t)
+	changes := &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("new.example.com", "A", "192.0.2.2")}}
+
+	// Duplicates are only logged by default
+	p.config.duplicateOwnership = DuplicateOwnershipWarn
+	require.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	records, err := w.getRecords("example.com")
+	require.NoError(t, err)
+	assert.Len(t, *records, 7)
+
+	p.config.duplicateOwnership, p.config.txtOwnerID = DuplicateOwnershipConsolidate, "default"
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	require.NoError(t, p.ApplyChanges(ctx, &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("other.example.com", "A", "192.0.2.3")}}))
+	require.Len(t, recorder.Changes(), 3)
+	// The duplicates are deleted before the changes
+	assert.Equal(t, "delete", recorder.Changes()[0].Action)
+	assert.Equal(t, "www", recorder.Changes()[0].Name)
+	assert.Equal(t, "delete", recorder.Changes()[1].Action)
+	assert.Equal(t, heritageOld, recorder.Changes()[1].Content)
+
+	records, err = w.getRecords("example.com")
+	require.NoError(t, err)
+	assert.Empty(t, p.duplicateOwnershipRecords("example.com", *records))
+	ownership := []string{}
+	for _, rec := range *records {
+		if rec.Type == "TXT" {
+			ownership = append(ownership, rec.Name)
+		}
+	}
+	assert.ElementsMatch(t, []string{"a-www", "aaaa-www", "api"}, ownership)
+}
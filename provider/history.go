@@ -0,0 +1,113 @@
+package inwx
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// AppliedOperation is an ApplyChanges request kept in the operation history.
+type AppliedOperation struct {
+	Started  time.Time `json:"started"`
+	Duration string    `json:"duration"`
+	// Result is "success", "warning" or "error" like the result label of the metrics.
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+	// Skipped is "duplicate" or "maintenance" if the changes weren't applied for that reason.
+	Skipped  string          `json:"skipped,omitempty"`
+	TraceID  string          `json:"trace_id,omitempty"`
+	PlanHash string          `json:"plan_hash,omitempty"`
+	Changes  []AppliedChange `json:"changes"`
+}
+
+// operationHistory is a ring buffer of the most recent applied operations.
+type operationHistory struct {
+	mu      sync.Mutex
+	entries []AppliedOperation
+	next    int
+	full    bool
+}
+
+func newOperationHistory(size int) *operationHistory {
+	if size <= 0 {
+		return nil
+	}
+	return &operationHistory{entries: make([]AppliedOperation, size)}
+}
+
+// add stores op, replacing the oldest one once the history is full.
+func (h *operationHistory) add(op AppliedOperation) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries[h.next] = op
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list returns up to limit operations, newest first; 0 returns all of them.
+func (h *operationHistory) list(limit int) []AppliedOperation {
+	ops := []AppliedOperation{}
+	if h == nil {
+		return ops
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := h.next
+	if h.full {
+		n = len(h.entries)
+	}
+	if limit <= 0 || limit > n {
+		limit = n
+	}
+	for i := 1; i <= limit; i++ {
+		ops = append(ops, h.entries[(h.next-i+len(h.entries))%len(h.entries)])
+	}
+	return ops
+}
+
+// len returns the number of operations kept.
+func (h *operationHistory) len() int {
+	if h == nil {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.full {
+		return len(h.entries)
+	}
+	return h.next
+}
+
+// recordOperation adds an apply started at start to the history, with the changes collected by applied.
+func (p *INWXProvider) recordOperation(ctx context.Context, start time.Time, err error, skipped string, hash string, applied *ChangeRecorder) {
+	if p.history == nil {
+		return
+	}
+	op := AppliedOperation{
+		Started:  start,
+		Duration: now(p.config.clock).Sub(start).String(),
+		Result:   resultLabel(err),
+		Skipped:  skipped,
+		TraceID:  TraceID(ctx),
+		PlanHash: hash,
+		Changes:  []AppliedChange{},
+	}
+	if err != nil {
+		op.Error = err.Error()
+	}
+	if applied != nil {
+		op.Changes = applied.Changes()
+	}
+	p.history.add(op)
+}
+
+// History returns up to limit of the most recently applied operations with their changes, newest first;
+// 0 returns all of those kept.
+func (p *INWXProvider) History(limit int) []AppliedOperation {
+	return p.history.list(limit)
+}
@@ -0,0 +1,74 @@
+package inwx
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestHistory(t *testing.T) {
+	t.Run("RingBuffer", func(t *testing.T) {
+		h := newOperationHistory(3)
+		assert.Empty(t, h.list(0))
+		for _, hash := range []string{"a", "b", "c", "d"} {
+			h.add(AppliedOperation{PlanHash: hash})
+		}
+		hashes := func(ops []AppliedOperation) []string {
+			var hashes []string
+			for _, op := range ops {
+				hashes = append(hashes, op.PlanHash)
+			}
+			return hashes
+		}
+		assert.Equal(t, []string{"d", "c", "b"}, hashes(h.list(0)))
+		assert.Equal(t, []string{"d", "c"}, hashes(h.list(2)))
+
+		assert.Nil(t, newOperationHistory(0))
+		assert.Empty(t, (*operationHistory)(nil).list(0))
+	})
+
+	t.Run("AppliedOperations", func(t *testing.T) {
+		w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+		w.CreateZone("example.com")
+		clock := &fakeClock{now: time.Date(2026, 11, 3, 12, 0, 0, 0, time.UTC)}
+		p.config.clock = clock
+		p.history = newOperationHistory(10)
+		p.applies = newApplyDedup(time.Minute)
+
+		changes := &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.com", "A", "192.0.2.1")}}
+		require.NoError(t, p.ApplyChanges(WithTraceID(context.TODO(), "4bf92f3577b34da6a3ce929d0e0e4736"), changes))
+		require.NoError(t, p.ApplyChanges(context.TODO(), changes))
+
+		w.createErr = func(*recordRequest) error { return errors.New("quota exceeded") }
+		failing := &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("bar.example.com", "A", "192.0.2.2")}}
+		require.Error(t, p.ApplyChanges(context.TODO(), failing))
+
+		// Applies without changes aren't kept
+		require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{}))
+
+		ops := p.History(0)
+		require.Len(t, ops, 3)
+		assert.Equal(t, "error", ops[0].Result)
+		assert.Contains(t, ops[0].Error, "encountered 1 errors")
+		require.Len(t, ops[0].Changes, 1)
+		assert.Equal(t, "quota exceeded", ops[0].Changes[0].Error)
+
+		assert.Equal(t, "duplicate", ops[1].Skipped)
+		assert.Empty(t, ops[1].Changes)
+
+		assert.Equal(t, "success", ops[2].Result)
+		assert.Equal(t, clock.now, ops[2].Started)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", ops[2].TraceID)
+		assert.Equal(t, planHash(changes), ops[2].PlanHash)
+		require.Len(t, ops[2].Changes, 1)
+		assert.Equal(t, "create", ops[2].Changes[0].Action)
+		assert.Equal(t, "foo", ops[2].Changes[0].Name)
+	})
+}
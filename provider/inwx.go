@@ -5,33 +5,96 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
-	inwx "github.com/nrdcg/goinwx"
-
+	"go.opentelemetry.io/otel/attribute"
 	"sigs.k8s.io/external-dns/endpoint"
 	"sigs.k8s.io/external-dns/plan"
 	"sigs.k8s.io/external-dns/provider"
 )
 
+// ErrNoTargets is returned for endpoints to create without any target.
+var ErrNoTargets = errors.New("endpoint has no targets")
+
 type INWXProvider struct {
 	provider.BaseProvider
-	client       AbstractClientWrapper
-	domainFilter *endpoint.DomainFilter
-	logger       *slog.Logger
+	client  AbstractClientWrapper
+	filter  *NameFilter
+	logger  *slog.Logger
+	metrics *metrics
+	config  config
+
+	// limiters holds a *zoneLimiter per zone.
+	limiters sync.Map
+
+	registry Registry
+	records  *recordsCache
+
+	flaps   *flapTracker
+	drift   *driftTracker
+	applies *applyDedup
+	feed    changeFeed
+
+	passthrough passthroughStore
+	deletions   deletionGuard
+	expiries    expiryStore
+	expiryStop  chan struct{}
+
+	emptyZones *emptyZoneTracker
+
+	maintenance *maintenanceTracker
+	history     *operationHistory
+
+	health       healthTracker
+	compat       compatibilityTracker
+	sessionCheck sessionCheck
+	lifecycle    lifecycle
 }
 
-func NewINWXProvider(domainFilter *[]string, username string, password string, sandbox bool, logger *slog.Logger) *INWXProvider {
+func NewINWXProvider(domainFilter *[]string, username string, password string, sandbox bool, logger *slog.Logger, opts ...Option) *INWXProvider {
+	cfg := defaultConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	m := newMetrics()
+	var client AbstractClientWrapper
+	if cfg.inMemoryZones != nil {
+		client = newInMemoryClient(cfg.inMemoryZones)
+	} else {
+		client = newClientWrapper(cfg, username, password, sandbox, logger, m)
+	}
 	p := &INWXProvider{
-		client:       &ClientWrapper{client: inwx.NewClient(username, password, &inwx.ClientOptions{Sandbox: sandbox})},
-		domainFilter: endpoint.NewDomainFilter(*domainFilter),
-		logger:       logger,
+		client: client,
+		filter: NewNameFilter(append(domainRules(*domainFilter), cfg.filterRules...)...).withAffixes(cfg.namePrefixes, cfg.nameSuffixes).
+			withExclusions(cfg.excludeDomains),
+		logger:     logger,
+		metrics:    m,
+		config:     cfg,
+		flaps:      newFlapTracker(cfg.flapWindow, cfg.flapThreshold),
+		drift:      newDriftTracker(),
+		applies:    newApplyDedup(cfg.duplicateApplyWindow),
+		emptyZones: newEmptyZoneTracker(cfg.emptyZonesAfter, m.emptyZones),
+		registry:   cfg.registry,
+		records:    newRecordsCache(cfg.recordsCacheTTL, cfg.staleRecordsMaxAge),
+
+		maintenance: newMaintenanceTracker(cfg.maintenanceWindows),
+		history:     newOperationHistory(cfg.historySize),
+	}
+	p.records.keepLastGood = p.maintenance != nil
+	if cfg.expiryInterval > 0 {
+		p.expiryStop = make(chan struct{})
+		go p.expireRecords(cfg.expiryInterval, p.expiryStop)
 	}
 
-	if _, err := p.client.login(); err != nil {
+	ctx := context.Background()
+	if err := p.login(ctx); err != nil {
 		logger.Error("startup zone check: failed to login", "err", err)
 	} else {
-		if zones, err := p.client.getZones(); err != nil {
+		if zones, err := p.getZones(ctx); err != nil {
 			logger.Error("startup zone check: failed to list zones", "err", err)
 		} else {
 			logger.Info("INWX zones available", "count", len(*zones), "zones", strings.Join(*zones, ", "))
@@ -44,47 +107,260 @@ func NewINWXProvider(domainFilter *[]string, username string, password string, s
 	return p
 }
 
-func (p *INWXProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
-	endpoints := make([]*endpoint.Endpoint, 0)
+// newClientWrapper returns the client talking to the INWX API as configured by cfg, counting its calls in m.
+func newClientWrapper(cfg config, username string, password string, sandbox bool, logger *slog.Logger, m *metrics) *ClientWrapper {
+	transport := cfg.transport
+	if transport == nil {
+		transport = newTransport(cfg.maxIdleConns, cfg.idleConnTimeout, cfg.proxy, cfg.rootCAs, cfg.minTLSVersion)
+	}
+	client := &ClientWrapper{
+		api:               newDomRobot(cfg.apiClient, sandbox, cfg.apiURL, withUserAgent(transport, cfg.userAgent), logger),
+		transport:         transport,
+		logger:            logger,
+		metrics:           m,
+		slowCallThreshold: cfg.slowCallThreshold,
+		clock:             cfg.clock,
+		rand:              newLockedRand(cfg.rand),
+		session:           newSession(cfg.sessionScope, cfg.sessionKeepalive),
+		environment:       environmentOf(sandbox, cfg.apiURL),
+	}
+	client.credentials.Store(&Credentials{Username: username, Password: password, TOTPSecret: cfg.totpSecret})
+	if cfg.sessionScope == SessionPersistent && cfg.sessionKeepalive > 0 {
+		go client.keepAlive()
+	}
+	return client
+}
+
+func (p *INWXProvider) Records(ctx context.Context) (result []*endpoint.Endpoint, err error) {
+	defer func(start time.Time) { p.observeOperation(ctx, "records", start, err) }(time.Now())
+	defer func() { result = p.passthrough.decorate(result) }()
+	ctx, end := startSpan(ctx, "Records")
+	defer func() { end(err) }()
+
+	done, err := p.lifecycle.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	p.migrateOwnerOnce(ctx)
+	strong := consistencyOf(ctx) == ConsistencyStrong
+	if strong {
+		p.logger.Debug("strongly consistent read requested, bypassing the caches")
+		p.client.forgetZones()
+	} else if endpoints, ok := p.records.get(now(p.config.clock)); ok {
+		p.logger.Debug("serving records from cache", "count", len(endpoints))
+		return endpoints, nil
+	}
+	if _, ok := p.inMaintenance(); ok && !strong {
+		if latest, age, ok := p.records.latest(now(p.config.clock)); ok {
+			p.logger.Debug("INWX maintenance window in progress, serving the last known-good records", "age", age, "count", len(latest))
+			p.recordsServed(true, "serving the last known-good records during a maintenance window")
+			return latest, nil
+		}
+		p.logger.Warn("INWX maintenance window in progress but no records were read before, reading them from INWX")
+	}
+	generation, fetched := p.records.current(), now(p.config.clock)
 
-	if _, err := p.client.login(); err != nil {
+	endpoints, ids, zones, err := p.listRecords(ctx)
+	if err == nil {
+		if previous, suspicious := p.records.suspiciouslyEmpty(len(endpoints), p.config.emptyRecordsThreshold); suspicious {
+			p.metrics.emptyRecordsRejectedTotal.Inc()
+			err = fmt.Errorf("INWX returned no records although %d were read before, refusing to report all of them as missing", previous)
+		}
+	}
+	if err != nil {
+		if stale, age, ok := p.records.stale(now(p.config.clock)); ok && !strong {
+			p.logger.Warn("failed to list records, serving the last known-good records", "err", err, "age", age, "count", len(stale))
+			p.metrics.staleRecordsServedTotal.Inc()
+			for zone := range p.records.servedAges(now(p.config.clock)) {
+				p.metrics.staleZoneReadsTotal.WithLabelValues(zone).Inc()
+			}
+			p.recordsServed(true, "serving the last known-good records: "+err.Error())
+			return stale, nil
+		}
+		p.health.observeErr(componentRecords, err, now(p.config.clock))
 		return nil, err
 	}
+	p.recordsServed(false, "")
+	p.records.store(endpoints, ids, zones, fetched, generation)
+	return endpoints, nil
+}
+
+// login logs in to INWX, recording the result as the health of the session. Logins to an unexpected
+// account fail.
+func (p *INWXProvider) login(ctx context.Context) error {
+	return traceCall(ctx, "account.login", func() error {
+		err := p.client.login()
+		if err == nil {
+			err = p.VerifyAccount()
+		}
+		p.health.observeErr(componentSession, err, now(p.config.clock))
+		return err
+	})
+}
+
+// getZones returns the zones of the account, from the zone cache if it is fresh.
+func (p *INWXProvider) getZones(ctx context.Context) (zones *[]string, err error) {
+	err = traceCall(ctx, "nameserver.list", func() (err error) {
+		zones, err = p.client.getZones()
+		if err == nil {
+			p.zonesListed(*zones)
+		}
+		return err
+	})
+	return zones, err
+}
+
+// getRecords returns the records of zone.
+func (p *INWXProvider) getRecords(ctx context.Context, zone string) (records *[]zoneRecord, err error) {
+	err = traceCall(ctx, "nameserver.info", func() (err error) {
+		records, err = p.client.getRecords(zone)
+		if err == nil {
+			for i, rec := range *records {
+				(*records)[i].Type = endpointType(rec.Name, rec.Type)
+			}
+			p.metrics.zoneRecords.WithLabelValues(zone).Set(float64(len(*records)))
+		}
+		return err
+	}, attribute.String("dns.zone", zone))
+	return records, err
+}
+
+// observeZone compares the records of zone just read from INWX with those read before, logging and
+// counting the changes made outside of the webhook, which it returns, and flags the zone if it is empty.
+func (p *INWXProvider) observeZone(zone string, records []zoneRecord) []ManualChange {
+	changes := p.drift.observe(zone, records)
+	for _, change := range changes {
+		p.logger.Warn("record changed outside of the webhook", "zone", change.Zone, "name", change.Name, "type", change.Type,
+			"change", change.Change, "old_content", change.OldContent, "new_content", change.NewContent)
+		p.metrics.manualChangesTotal.WithLabelValues(change.Zone, change.Change).Inc()
+	}
+	if since, flagged := p.emptyZones.observe(zone, records, now(p.config.clock)); flagged {
+		p.logger.Warn("zone holds no records besides SOA and NS, consider deleting it", "zone", zone, "empty_since", since)
+	}
+	return changes
+}
+
+// listRecords reads the records of every zone from INWX, returning them with their record IDs and the
+// time the records of each zone were read. Once ctx is done, it fails instead of spending the time left
+// on zones whose records would be thrown away.
+func (p *INWXProvider) listRecords(ctx context.Context) ([]*endpoint.Endpoint, []string, map[string]time.Time, error) {
+	endpoints := make([]*endpoint.Endpoint, 0)
+	ids := make([]string, 0)
+	read := map[string]time.Time{}
+
+	if err := p.login(ctx); err != nil {
+		return nil, nil, nil, err
+	}
 	defer func() {
 		if err := p.client.logout(); err != nil {
 			slog.Error("error encountered while logging out", "err", err)
 		}
 	}()
 
-	zones, err := p.client.getZones()
+	zones, err := p.getZones(ctx)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
+	p.emptyZones.retain(*zones)
 	for _, zone := range *zones {
-		records, err := p.client.getRecords(zone)
+		if !p.filter.MatchZone(zone) {
+			p.logger.Debug("skipping zone outside of the domain filter", "zone", zone)
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, nil, nil, fmt.Errorf("unable to query DNS zone info for zone '%v': %w", zone, err)
+		}
+		records, err := p.getRecords(ctx, zone)
 		if err != nil {
-			return nil, fmt.Errorf("unable to query DNS zone info for zone '%v': %v", zone, err)
+			return nil, nil, nil, fmt.Errorf("unable to query DNS zone info for zone '%v': %v", zone, err)
 		}
+		read[zone] = now(p.config.clock)
+		p.observeZone(zone, *records)
 		for _, rec := range *records {
-			name := fmt.Sprintf("%s.%s", rec.Name, zone)
-			ep := endpoint.NewEndpointWithTTL(name, rec.Type, endpoint.TTL(rec.TTL), rec.Content)
+			name := p.registry.EndpointName(rec.Name, zone, rec.Type)
+			if !p.managesName(name) {
+				continue
+			}
+			p.traceNormalization("endpoint_name", "zone", zone, "record_name", rec.Name, "type", rec.Type, "dns_name", name)
+			ep := withRedirect(endpoint.NewEndpointWithTTL(name, rec.Type, endpoint.TTL(rec.TTL), rec.Content), rec.RedirectType)
 			endpoints = append(endpoints, ep)
+			ids = append(ids, rec.ID)
 		}
 	}
 	for _, endpointItem := range endpoints {
 		p.logger.Debug("endpoints collected", "endpoints", endpointItem.String())
 	}
-	return endpoints, nil
+	return endpoints, ids, read, nil
 }
 
-func (p *INWXProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
+func (p *INWXProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) (err error) {
+	defer func(start time.Time) { p.observeOperation(ctx, "apply_changes", start, err) }(time.Now())
+	ctx, end := startSpan(ctx, "ApplyChanges")
+	defer func() { end(err) }()
+
+	done, err := p.lifecycle.begin()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	filtered := p.withoutPassthroughOnlyChanges(p.filterIgnored(changes))
+	changes = normalizeTXT(filtered)
+	p.traceTXT(filtered, changes)
 	if !changes.HasChanges() {
 		p.logger.Debug("no changes detected - nothing to do")
 		return nil
 	}
+	var applied *ChangeRecorder
+	var skipped, hash string
+	defer func(start time.Time) { p.recordOperation(ctx, start, err, skipped, hash, applied) }(now(p.config.clock))
+	if window, ok := p.inMaintenance(); ok {
+		p.logger.Info("INWX maintenance window in progress, deferring changes until it ends", "window", window.String(),
+			"creates", len(changes.Create), "updates", len(changes.UpdateNew), "deletes", len(changes.Delete))
+		p.metrics.maintenanceDeferredAppliesTotal.Inc()
+		skipped = "maintenance"
+		return nil
+	}
+
+	hash = planHash(changes)
+	if p.applies.recentlyApplied(hash, now(p.config.clock)) {
+		p.logger.Info("identical changes were applied successfully moments ago - skipping", "plan_hash", hash)
+		p.metrics.duplicateAppliesTotal.Inc()
+		skipped = "duplicate"
+		return nil
+	}
+	defer func() {
+		if err == nil {
+			p.applies.remember(hash, now(p.config.clock))
+		}
+	}()
+	defer p.passthrough.applied(changes)
+	if p.config.expiryInterval > 0 {
+		defer p.expiries.applied(changes, now(p.config.clock))
+	}
+	// Every change is audited, in the audit log or the log.
+	ctx, applied = withAppliedRecorder(ctx)
+	defer func(start time.Time) {
+		p.writeAudit(ctx, applied, hash)
+		p.writeReport(ctx, applied, hash, start, err)
+		p.notifyFailure(ctx, applied, hash, start, err)
+	}(now(p.config.clock))
+
+	// Records keeps serving the cached records while the changes are applied; every change is patched
+	// into them as it succeeds. After a failure, or a race with another writer, they are read again, as
+	// INWX may hold more than was reported.
+	var warnings []error
+	defer func() {
+		if err != nil || len(warnings) > 0 {
+			p.records.invalidate()
+		}
+		p.health.observeApply(err, len(warnings), now(p.config.clock))
+	}()
 
-	if _, err := p.client.login(); err != nil {
+	if err := p.login(ctx); err != nil {
 		return err
 	}
 	defer func() {
@@ -92,114 +368,60 @@ func (p *INWXProvider) ApplyChanges(ctx context.Context, changes *plan.Changes)
 			slog.Error("error encountered while logging out", "err", err)
 		}
 	}()
+	if err := p.client.checkSession(); err != nil {
+		p.health.observeErr(componentSession, err, now(p.config.clock))
+		return err
+	}
 
-	zones, err := p.client.getZones()
+	zones, err := p.getZones(ctx)
 	if err != nil {
 		return err
 	}
-
-	errs := []error{}
-
-	recordsCache := map[string]*[]inwx.NameserverRecord{}
-	for _, ep := range changes.Delete {
-		zone, err := getZone(zones, ep)
-		if err != nil {
-			errs = append(errs, err)
-			slog.Error("failed to find zone for endpoint", "err", err)
-		} else {
-			if _, ok := recordsCache[zone]; !ok {
-				if recs, err := p.client.getRecords(zone); err != nil {
-					errs = append(errs, err)
-					slog.Error("failed to query DNS zone info", "zone", zone, "err", err)
-					continue
-				} else {
-					recordsCache[zone] = recs
-				}
-			}
-			recIDs, err := getRecIDs(zone, recordsCache[zone], *ep)
-			if err != nil {
-				errs = append(errs, err)
-				slog.Error("failed to look up records to delete", "err", err)
-			}
-			for _, id := range recIDs {
-				if err = p.client.deleteRecord(id); err != nil {
-					errs = append(errs, err)
-					slog.Error("failed to delete record", "id", id, "ep", ep, "err", err)
-				}
-			}
-		}
+	var zoneErrs []error
+	if p.config.createMissingZones {
+		zones, zoneErrs = p.createMissingZones(ctx, zones)
 	}
+	if err := p.checkDeletions(ctx, zones, changes, hash); err != nil {
+		return err
+	}
+	reverse := p.reverseChanges(zones, changes)
+	ctx = p.checkFreezeRecords(ctx, zones, changes, reverse)
+	ctx = p.checkRecordLimits(ctx, zones, changes)
+	p.cleanupOwnershipRecords(ctx, zones, changes)
+	ctx = p.withApplyProgress(ctx, changes)
+	defer func() { finishProgress(ctx, err) }()
+
+	// Zones that couldn't be created fail the apply like the changes in them.
+	errs := zoneErrs
+
+	// Deletes go first, so that a record can be replaced by one of a conflicting type at the same name.
+	// With create-first ordering, only such conflicting deletes do, and the others follow the creates.
+	deletes, deferredDeletes := changes.Delete, []*endpoint.Endpoint(nil)
+	if p.config.createFirst {
+		deletes, deferredDeletes = conflictingDeletes(changes)
+	}
+	errs = append(errs, p.applyDeletes(ctx, zones, deletes)...)
 
-	recordsCache = map[string]*[]inwx.NameserverRecord{}
-	for _, ep := range changes.Create {
-		zone, err := getZone(zones, ep)
-		if err != nil {
-			errs = append(errs, err)
-			slog.Error("failed to find zone for endpoint", "err", err)
-			continue
-		}
-		if _, ok := recordsCache[zone]; !ok {
-			if recs, err := p.client.getRecords(zone); err != nil {
-				errs = append(errs, err)
-				slog.Error("failed to query DNS zone info", "zone", zone, "err", err)
-				continue
-			} else {
-				recordsCache[zone] = recs
-			}
-		}
-		name := extractRecordName(ep.DNSName, zone)
-		for _, target := range ep.Targets {
-			existing := findRecordsByNameAndType(zone, recordsCache[zone], ep.DNSName, ep.RecordType)
-
-			rec := &inwx.NameserverRecordRequest{
-				Domain:  zone,
-				Name:    name,
-				Type:    ep.RecordType,
-				TTL:     int(ep.RecordTTL),
-				Content: target,
-			}
-
-			// If exact record (same content) already exists, skip
-			if findExactRecord(existing, target) != "" {
-				slog.Debug("record already exists, skipping create", "name", ep.DNSName, "type", ep.RecordType, "content", target)
-				continue
-			}
-
-			// If there's exactly one existing record with this name+type and the
-			// endpoint has a single target, update instead of creating a duplicate
-			if len(existing) == 1 && len(ep.Targets) == 1 {
-				slog.Info("record exists with different content, updating instead of creating",
-					"name", ep.DNSName, "type", ep.RecordType,
-					"old_content", existing[0].Content, "new_content", target)
-				if err = p.client.updateRecord(existing[0].ID, rec); err != nil {
-					errs = append(errs, err)
-					slog.Error("failed to update existing record", "rec", rec, "err", err)
-				}
-				continue
-			}
-
-			if err = p.client.createRecord(rec); err != nil {
-				if isObjectExistsError(err) {
-					slog.Debug("record already exists in INWX, skipping",
-						"name", ep.DNSName, "type", ep.RecordType, "content", target)
-				} else {
-					errs = append(errs, err)
-					slog.Error("failed to create record", "rec", rec, "err", err)
-				}
-			}
+	recordsCache := map[string]*[]zoneRecord{}
+	if p.config.atomicOwnership {
+		errs = append(errs, p.createWithOwnership(ctx, zones, recordsCache, changes.Create)...)
+	} else {
+		for _, ep := range changes.Create {
+			errs = append(errs, p.createEndpoint(ctx, zones, recordsCache, ep, nil)...)
 		}
 	}
+	errs = append(errs, p.applyDeletes(ctx, zones, deferredDeletes)...)
 
-	recordsCache = map[string]*[]inwx.NameserverRecord{}
+	recordsCache = map[string]*[]zoneRecord{}
 	for i, oldEp := range changes.UpdateOld {
 		newEp := changes.UpdateNew[i]
-		zone, err := getZone(zones, oldEp)
+		zone, err := p.zoneFor(zones, oldEp)
 		if err != nil {
 			errs = append(errs, err)
 			slog.Error("failed to update DNS record for endpoint", "err", err)
 		} else {
 			if _, ok := recordsCache[zone]; !ok {
-				if recs, err := p.client.getRecords(zone); err != nil {
+				if recs, err := p.getRecords(ctx, zone); err != nil {
 					errs = append(errs, err)
 					slog.Error("failed to query DNS zone info", "zone", zone, "err", err)
 					continue
@@ -207,26 +429,32 @@ func (p *INWXProvider) ApplyChanges(ctx context.Context, changes *plan.Changes)
 					recordsCache[zone] = recs
 				}
 			}
-			recIDs, err := getRecIDs(zone, recordsCache[zone], *oldEp)
-			name := extractRecordName(newEp.DNSName, zone)
+			// Updating an endpoint to no targets at all deletes every record of its name and type.
+			if len(newEp.Targets) == 0 {
+				errs = append(errs, p.deleteAllRecords(ctx, zone, p.registry.RecordName(oldEp.DNSName, zone), oldEp.RecordType, recordsCache[zone])...)
+				continue
+			}
+			recIDs, err := getRecIDsByName(p.registry.RecordName(oldEp.DNSName, zone), recordsCache[zone], *oldEp)
+			name := p.registry.RecordName(newEp.DNSName, zone)
 
 			// If old records not found, fall back to upsert for new targets
 			if err != nil {
 				slog.Warn("old records not found for update, falling back to upsert",
 					"endpoint", oldEp.DNSName, "err", err)
-				existing := findRecordsByNameAndType(zone, recordsCache[zone], newEp.DNSName, newEp.RecordType)
+				existing := findRecordsByNameAndType(name, recordsCache[zone], newEp.RecordType)
 				for _, target := range newEp.Targets {
 					if findExactRecord(existing, target) != "" {
 						continue
 					}
-					rec := &inwx.NameserverRecordRequest{
-						Domain:  zone,
-						Name:    name,
-						Type:    newEp.RecordType,
-						TTL:     int(newEp.RecordTTL),
-						Content: target,
+					rec := &recordRequest{
+						Domain:       zone,
+						Name:         name,
+						Type:         p.inwxRecordType(newEp, name),
+						RedirectType: redirectType(newEp),
+						TTL:          int(newEp.RecordTTL),
+						Content:      target,
 					}
-					if err = p.client.createRecord(rec); err != nil {
+					if err = p.createRecord(ctx, rec); err != nil {
 						if isObjectExistsError(err) {
 							slog.Debug("record already exists in INWX, skipping",
 								"name", newEp.DNSName, "type", newEp.RecordType, "content", target)
@@ -239,39 +467,46 @@ func (p *INWXProvider) ApplyChanges(ctx context.Context, changes *plan.Changes)
 				continue
 			}
 
-			for j := range max(len(oldEp.Targets), len(newEp.Targets), len(recIDs)) {
+			// Targets are paired up by position, unless set-based diffing leaves out those that didn't change.
+			oldTargets, newTargets := []string(oldEp.Targets), []string(newEp.Targets)
+			if p.config.features.Enabled(FeatureSetBasedDiff) && oldEp.RecordTTL == newEp.RecordTTL && redirectType(oldEp) == redirectType(newEp) {
+				oldTargets, newTargets, recIDs = changedTargets(oldTargets, newTargets, recIDs)
+			}
+			for j := range max(len(oldTargets), len(newTargets), len(recIDs)) {
 				switch {
-				case j >= len(newEp.Targets):
-					if err = p.client.deleteRecord(recIDs[j]); err != nil {
+				case j >= len(newTargets):
+					if err = p.deleteRecord(ctx, zone, p.registry.RecordName(oldEp.DNSName, zone), oldEp.RecordType, oldTargets[j], recIDs[j]); err != nil {
 						errs = append(errs, err)
-						slog.Error("failed to delete record", "target", oldEp.Targets[j], "ep", oldEp, "err", err)
+						logChangeError("failed to delete record", err, "target", oldTargets[j], "ep", oldEp)
 					}
-				case j >= len(oldEp.Targets):
-					rec := &inwx.NameserverRecordRequest{
-						Domain:  zone,
-						Name:    name,
-						Type:    newEp.RecordType,
-						TTL:     int(newEp.RecordTTL),
-						Content: newEp.Targets[j],
+				case j >= len(oldTargets):
+					rec := &recordRequest{
+						Domain:       zone,
+						Name:         name,
+						Type:         p.inwxRecordType(newEp, name),
+						RedirectType: redirectType(newEp),
+						TTL:          int(newEp.RecordTTL),
+						Content:      newTargets[j],
 					}
-					if err = p.client.createRecord(rec); err != nil {
+					if err = p.createRecord(ctx, rec); err != nil {
 						if isObjectExistsError(err) {
 							slog.Debug("record already exists in INWX, skipping",
-								"name", newEp.DNSName, "type", newEp.RecordType, "content", newEp.Targets[j])
+								"name", newEp.DNSName, "type", newEp.RecordType, "content", newTargets[j])
 						} else {
 							errs = append(errs, err)
 							slog.Error("failed to create record", "rec", rec, "err", err)
 						}
 					}
 				default:
-					rec := &inwx.NameserverRecordRequest{
-						Domain:  zone,
-						Name:    name,
-						Type:    newEp.RecordType,
-						TTL:     int(oldEp.RecordTTL),
-						Content: newEp.Targets[j],
+					rec := &recordRequest{
+						Domain:       zone,
+						Name:         name,
+						Type:         p.inwxRecordType(newEp, name),
+						RedirectType: redirectType(newEp),
+						TTL:          int(oldEp.RecordTTL),
+						Content:      newTargets[j],
 					}
-					if err = p.client.updateRecord(recIDs[j], rec); err != nil {
+					if err = p.updateRecord(ctx, recIDs[j], oldTargets[j], rec); err != nil {
 						errs = append(errs, err)
 						slog.Error("failed to update record", "rec", rec, "err", err)
 					}
@@ -279,6 +514,16 @@ func (p *INWXProvider) ApplyChanges(ctx context.Context, changes *plan.Changes)
 			}
 		}
 	}
+	errs = append(errs, p.applyReverseChanges(ctx, zones, reverse)...)
+
+	// Benign races don't fail the request, so that they don't show up as failed reconciles.
+	errs, warnings = splitErrors(errs)
+	if len(warnings) > 0 {
+		p.logger.Warn("changes raced with other writers, INWX is in the desired state regardless", "warnings", len(warnings))
+	}
+	if n := countDeadlineErrors(errs); n > 0 {
+		return fmt.Errorf("%w: %d changes not applied, %d other errors", ErrDeadlineExceeded, n, len(errs)-n)
+	}
 	if len(errs) > 0 {
 		return fmt.Errorf("encountered %d errors while applying changes", len(errs))
 	} else {
@@ -288,13 +533,19 @@ func (p *INWXProvider) ApplyChanges(ctx context.Context, changes *plan.Changes)
 
 // isObjectExistsError returns true if the error is an INWX API error with code 2302 (Object exists).
 func isObjectExistsError(err error) bool {
-	var apiErr *inwx.ErrorResponse
+	var apiErr *apiError
 	if errors.As(err, &apiErr) {
 		return apiErr.Code == 2302
 	}
 	return false
 }
 
+// isObjectDoesNotExistError reports whether err is an INWX API error with code 2303 (Object does not exist).
+func isObjectDoesNotExistError(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.Code == codeObjectDoesNotExist
+}
+
 // extractRecordName computes the INWX record name from a full DNS name and zone.
 // It also strips trailing zone labels that leak into the record name, which happens
 // with external-dns's apex domain ownership records (e.g., _edns.a-domain.com.domain.com
@@ -304,6 +555,9 @@ func extractRecordName(dnsName string, zone string) string {
 	if dnsName == zone {
 		return ""
 	}
+	if name, ok := classlessRecordName(dnsName, zone); ok {
+		return name
+	}
 	name := strings.TrimSuffix(dnsName, "."+zone)
 
 	// Strip trailing labels that match the zone's labels.
@@ -329,8 +583,12 @@ func extractRecordName(dnsName string, zone string) string {
 	return name
 }
 
-func getRecIDs(zone string, records *[]inwx.NameserverRecord, ep endpoint.Endpoint) ([]string, error) {
-	targetName := extractRecordName(ep.DNSName, zone)
+func getRecIDs(zone string, records *[]zoneRecord, ep endpoint.Endpoint) ([]string, error) {
+	return getRecIDsByName(extractRecordName(ep.DNSName, zone), records, ep)
+}
+
+// getRecIDsByName returns the IDs of the records named targetName matching the endpoint's type and targets.
+func getRecIDsByName(targetName string, records *[]zoneRecord, ep endpoint.Endpoint) ([]string, error) {
 	recIDs := []string{}
 	for _, target := range ep.Targets {
 		for _, record := range *records {
@@ -345,10 +603,27 @@ func getRecIDs(zone string, records *[]inwx.NameserverRecord, ep endpoint.Endpoi
 	return recIDs, nil
 }
 
-// findRecordsByNameAndType returns existing records matching the given DNS name and record type.
-func findRecordsByNameAndType(zone string, records *[]inwx.NameserverRecord, dnsName string, recordType string) []inwx.NameserverRecord {
-	targetName := extractRecordName(dnsName, zone)
-	var matches []inwx.NameserverRecord
+// changedTargets leaves out the targets before and after have in common, along with their record IDs, so
+// that an update touches only the records whose target changed instead of rewriting every record after a
+// removed target with the target of its successor.
+func changedTargets(before []string, after []string, recIDs []string) ([]string, []string, []string) {
+	var oldTargets, newTargets, ids []string
+	for i, target := range before {
+		if !slices.Contains(after, target) {
+			oldTargets, ids = append(oldTargets, target), append(ids, recIDs[i])
+		}
+	}
+	for _, target := range after {
+		if !slices.Contains(before, target) {
+			newTargets = append(newTargets, target)
+		}
+	}
+	return oldTargets, newTargets, ids
+}
+
+// findRecordsByNameAndType returns existing records matching the given record name and type.
+func findRecordsByNameAndType(targetName string, records *[]zoneRecord, recordType string) []zoneRecord {
+	var matches []zoneRecord
 	for _, record := range *records {
 		if recordType == record.Type && record.Name == targetName {
 			matches = append(matches, record)
@@ -358,7 +633,7 @@ func findRecordsByNameAndType(zone string, records *[]inwx.NameserverRecord, dns
 }
 
 // findExactRecord returns the ID of a record matching the given content, or empty string if not found.
-func findExactRecord(records []inwx.NameserverRecord, content string) string {
+func findExactRecord(records []zoneRecord, content string) string {
 	for _, rec := range records {
 		if rec.Content == content {
 			return rec.ID
@@ -368,6 +643,9 @@ func findExactRecord(records []inwx.NameserverRecord, content string) string {
 }
 
 func getZone(zones *[]string, endpoint *endpoint.Endpoint) (string, error) {
+	if zone, ok := classlessZone(zones, endpoint.DNSName); ok {
+		return zone, nil
+	}
 	var matchZoneName = ""
 	err := fmt.Errorf("unable find matching zone for the endpoint %s", endpoint)
 	for _, zone := range *zones {
@@ -389,3 +667,133 @@ func getZone(zones *[]string, endpoint *endpoint.Endpoint) (string, error) {
 	}
 	return matchZoneName, err
 }
+
+// applyDeletes deletes the records of eps, returning the errors and warnings encountered.
+func (p *INWXProvider) applyDeletes(ctx context.Context, zones *[]string, eps []*endpoint.Endpoint) []error {
+	errs := []error{}
+
+	recordsCache := map[string]*[]zoneRecord{}
+	for _, ep := range eps {
+		zone, err := p.zoneFor(zones, ep)
+		if err != nil {
+			errs = append(errs, err)
+			slog.Error("failed to find zone for endpoint", "err", err)
+		} else {
+			if _, ok := recordsCache[zone]; !ok {
+				if recs, err := p.getRecords(ctx, zone); err != nil {
+					errs = append(errs, err)
+					slog.Error("failed to query DNS zone info", "zone", zone, "err", err)
+					continue
+				} else {
+					recordsCache[zone] = recs
+				}
+			}
+			// An endpoint without targets stands for every record of its name and type.
+			if len(ep.Targets) == 0 {
+				errs = append(errs, p.deleteAllRecords(ctx, zone, p.registry.RecordName(ep.DNSName, zone), ep.RecordType, recordsCache[zone])...)
+				continue
+			}
+			name := p.registry.RecordName(ep.DNSName, zone)
+			existing := findRecordsByNameAndType(name, recordsCache[zone], ep.RecordType)
+			for _, target := range ep.Targets {
+				id := findExactRecord(existing, target)
+				if id == "" {
+					// Already gone, e.g. deleted by a concurrent reconcile; the desired state is reached.
+					err = &changeWarning{fmt.Errorf("record %s %s %s to delete not found in zone %s", name, ep.RecordType, target, zone)}
+					errs = append(errs, err)
+					slog.Warn("record to delete not found", "ep", ep, "target", target, "err", err)
+					continue
+				}
+				if err = p.deleteRecord(ctx, zone, name, ep.RecordType, target, id); err != nil {
+					errs = append(errs, err)
+					logChangeError("failed to delete record", err, "id", id, "ep", ep)
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// createEndpoint creates the records of ep that don't exist yet, updating a single existing record with
+// different content instead of duplicating it. The requests of records actually created are appended to
+// created, if given. Endpoints without targets are rejected with ErrNoTargets.
+func (p *INWXProvider) createEndpoint(ctx context.Context, zones *[]string, recordsCache map[string]*[]zoneRecord, ep *endpoint.Endpoint, created *[]*recordRequest) []error {
+	errs := []error{}
+	if len(ep.Targets) == 0 {
+		err := fmt.Errorf("unable to create %s record %s: %w", ep.RecordType, ep.DNSName, ErrNoTargets)
+		slog.Error("failed to create record", "err", err)
+		return append(errs, err)
+	}
+	zone, err := p.zoneFor(zones, ep)
+	if err != nil {
+		errs = append(errs, err)
+		slog.Error("failed to find zone for endpoint", "err", err)
+		return errs
+	}
+	if _, ok := recordsCache[zone]; !ok {
+		if recs, err := p.getRecords(ctx, zone); err != nil {
+			errs = append(errs, err)
+			slog.Error("failed to query DNS zone info", "zone", zone, "err", err)
+			return errs
+		} else {
+			recordsCache[zone] = recs
+		}
+	}
+	name := p.registry.RecordName(ep.DNSName, zone)
+	for _, target := range ep.Targets {
+		existing := findRecordsByNameAndType(name, recordsCache[zone], ep.RecordType)
+
+		rec := &recordRequest{
+			Domain:       zone,
+			Name:         name,
+			Type:         p.inwxRecordType(ep, name),
+			RedirectType: redirectType(ep),
+			TTL:          int(ep.RecordTTL),
+			Content:      target,
+		}
+
+		// If exact record (same content) already exists, skip
+		if findExactRecord(existing, target) != "" {
+			slog.Debug("record already exists, skipping create", "name", ep.DNSName, "type", ep.RecordType, "content", target)
+			continue
+		}
+
+		// If there's exactly one existing record with this name+type and the
+		// endpoint has a single target, update instead of creating a duplicate
+		if len(existing) == 1 && len(ep.Targets) == 1 {
+			slog.Info("record exists with different content, updating instead of creating",
+				"name", ep.DNSName, "type", ep.RecordType,
+				"old_content", existing[0].Content, "new_content", target)
+			if err = p.updateRecord(ctx, existing[0].ID, existing[0].Content, rec); err != nil {
+				errs = append(errs, err)
+				slog.Error("failed to update existing record", "rec", rec, "err", err)
+			}
+			continue
+		}
+
+		if err = p.createRecord(ctx, rec); err != nil {
+			if isObjectExistsError(err) {
+				slog.Debug("record already exists in INWX, skipping",
+					"name", ep.DNSName, "type", ep.RecordType, "content", target)
+			} else {
+				errs = append(errs, err)
+				slog.Error("failed to create record", "rec", rec, "err", err)
+			}
+		} else if created != nil {
+			*created = append(*created, rec)
+		}
+	}
+	return errs
+}
+
+// deleteAllRecords deletes every record of name and type in zone.
+func (p *INWXProvider) deleteAllRecords(ctx context.Context, zone string, name string, recordType string, records *[]zoneRecord) []error {
+	errs := []error{}
+	for _, rec := range findRecordsByNameAndType(name, records, recordType) {
+		if err := p.deleteRecord(ctx, zone, name, recordType, rec.Content, rec.ID); err != nil {
+			errs = append(errs, err)
+			logChangeError("failed to delete record", err, "id", rec.ID, "name", name, "type", recordType)
+		}
+	}
+	return errs
+}
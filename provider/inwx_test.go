@@ -2,25 +2,33 @@ package inwx
 
 import (
 	"context"
+	"errors"
 	"log/slog"
+	"math/rand/v2"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
-	inwx "github.com/nrdcg/goinwx"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"sigs.k8s.io/external-dns/endpoint"
 	"sigs.k8s.io/external-dns/plan"
 )
 
 func NewINWXProviderWithMockClient(domainFilter *[]string, logger *slog.Logger) (*MockClientWrapper, *INWXProvider) {
 	wrapper := &MockClientWrapper{
-		db:       make(map[string](*[]inwx.NameserverRecord)),
+		db:       make(map[string](*[]zoneRecord)),
 		idToZone: make(map[string]string),
 	}
 	return wrapper, &INWXProvider{
-		client:       wrapper,
-		domainFilter: endpoint.NewDomainFilter(*domainFilter),
-		logger:       logger,
+		client:   wrapper,
+		filter:   NewNameFilter(domainRules(*domainFilter)...),
+		logger:   logger,
+		metrics:  newMetrics(),
+		registry: LegacyRegistry{},
 	}
 }
 
@@ -31,9 +39,28 @@ func TestINWXProvider(t *testing.T) {
 	t.Run("CreateIsIdempotent", testCreateIsIdempotent)
 	t.Run("CreateUpsertsWhenDifferentContent", testCreateUpsertsWhenDifferentContent)
 	t.Run("UpdateFallsBackWhenOldRecordMissing", testUpdateFallsBackWhenOldRecordMissing)
+	t.Run("DeleteResolvesStaleID", testDeleteResolvesStaleID)
 	t.Run("ExtractRecordName", testExtractRecordName)
 	t.Run("GetZoneDotBoundary", testGetZoneDotBoundary)
 	t.Run("Records", testRecords)
+	t.Run("ChangeIDs", testChangeIDs)
+	t.Run("RecordKeys", testRecordKeys)
+	t.Run("SlowCallReporting", testSlowCallReporting)
+	t.Run("IgnoredEndpoints", testIgnoredEndpoints)
+	t.Run("FlapDetection", testFlapDetection)
+	t.Run("DuplicateApplySkipped", testDuplicateApplySkipped)
+	t.Run("Shutdown", testShutdown)
+	t.Run("DesiredEndpoints", testDesiredEndpoints)
+	t.Run("RecordsCache", testRecordsCache)
+	t.Run("StaleRecordsFallback", testStaleRecordsFallback)
+	t.Run("CacheAge", testCacheAge)
+	t.Run("StrongConsistency", testStrongConsistency)
+	t.Run("EmptyRecordsGuard", testEmptyRecordsGuard)
+	t.Run("ManualChanges", testManualChanges)
+	t.Run("ZeroTargets", testZeroTargets)
+	t.Run("ApplyOrder", testApplyOrder)
+	t.Run("DebugState", testDebugState)
+	t.Run("Clock", testClock)
 }
 
 func testEndpointZoneName(t *testing.T) {
@@ -87,35 +114,35 @@ func testEndpointZoneName(t *testing.T) {
 
 func testGetRecIDs(t *testing.T) {
 
-	inwx1 := inwx.NameserverRecord{
+	inwx1 := zoneRecord{
 		Name:    "foo",
 		Type:    "TXT",
 		Content: "heritage=external-dns,external-dns/owner=default,external-dns/resource=service/default/nginx",
 		ID:      "10",
 	}
 
-	inwx2 := inwx.NameserverRecord{
+	inwx2 := zoneRecord{
 		Name:    "foo",
 		Type:    "A",
 		Content: "5.5.5.5",
 		ID:      "11",
 	}
 
-	inwx3 := inwx.NameserverRecord{
+	inwx3 := zoneRecord{
 		Name:    "",
 		Type:    "A",
 		Content: "5.5.5.5",
 		ID:      "12",
 	}
 
-	inwx4 := inwx.NameserverRecord{
+	inwx4 := zoneRecord{
 		Name:    "",
 		Type:    "A",
 		Content: "5.5.5.6",
 		ID:      "13",
 	}
 
-	records := []inwx.NameserverRecord{inwx1, inwx2, inwx3, inwx4}
+	records := []zoneRecord{inwx1, inwx2, inwx3, inwx4}
 
 	recIDs, err := getRecIDs("example.com", &records, endpoint.Endpoint{
 		DNSName:    "foo.example.com",
@@ -147,7 +174,7 @@ func testApplyChanges(t *testing.T) {
 	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
 	w.CreateZone("example.com")
 	var err error
-	var recs *[]inwx.NameserverRecord
+	var recs *[]zoneRecord
 	ep1 := &endpoint.Endpoint{
 		DNSName:    "foo.example.com",
 		Targets:    []string{"1.1.1.1"},
@@ -163,7 +190,7 @@ func testApplyChanges(t *testing.T) {
 	assert.NoError(t, err)
 	recs, err = w.getRecords("example.com")
 	assert.NoError(t, err)
-	assert.Equal(t, &[]inwx.NameserverRecord{{
+	assert.Equal(t, &[]zoneRecord{{
 		ID:      "0",
 		Name:    "foo",
 		Type:    "A",
@@ -186,7 +213,7 @@ func testApplyChanges(t *testing.T) {
 	assert.NoError(t, err)
 	recs, err = w.getRecords("example.com")
 	assert.NoError(t, err)
-	assert.Equal(t, &[]inwx.NameserverRecord{{
+	assert.Equal(t, &[]zoneRecord{{
 		ID:      "0",
 		Name:    "foo",
 		Type:    "A",
@@ -203,7 +230,7 @@ func testApplyChanges(t *testing.T) {
 	assert.NoError(t, err)
 	recs, err = w.getRecords("example.com")
 	assert.NoError(t, err)
-	assert.Equal(t, &[]inwx.NameserverRecord{}, recs)
+	assert.Equal(t, &[]zoneRecord{}, recs)
 }
 
 func testCreateIsIdempotent(t *testing.T) {
@@ -301,6 +328,43 @@ func testUpdateFallsBackWhenOldRecordMissing(t *testing.T) {
 	assert.Equal(t, "2.2.2.2", (*recs)[0].Content)
 }
 
+func testDeleteResolvesStaleID(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	require.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "foo", Type: "A", Content: "192.0.2.1"}))
+	require.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "bar", Type: "A", Content: "192.0.2.2"}))
+
+	// Other automation recreates the record between reading and deleting it, changing its ID
+	recreated := false
+	w.deleteErr = func(recID string) error {
+		if !recreated {
+			recreated = true
+			w.deleteErr = nil
+			require.NoError(t, w.deleteRecord(recID))
+			require.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "foo", Type: "A", Content: "192.0.2.1"}))
+		}
+		return nil
+	}
+	before := testutil.ToFloat64(p.metrics.staleRecordIDsTotal)
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	require.NoError(t, p.ApplyChanges(ctx, &plan.Changes{Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.com", "A", "192.0.2.1")}}))
+	assert.True(t, recreated)
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.metrics.staleRecordIDsTotal)-before)
+	require.Len(t, recorder.Changes(), 1)
+	assert.Empty(t, recorder.Changes()[0].Error)
+
+	recs, err := w.getRecords("example.com")
+	require.NoError(t, err)
+	require.Len(t, *recs, 1)
+	assert.Equal(t, "bar", (*recs)[0].Name)
+
+	// A record gone for good is a benign race
+	err = p.deleteRecord(context.TODO(), "example.com", "foo", "A", "192.0.2.1", "0")
+	require.Error(t, err)
+	assert.True(t, isWarning(err))
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.metrics.staleRecordIDsTotal)-before)
+}
+
 func testExtractRecordName(t *testing.T) {
 	// Normal subdomain
 	assert.Equal(t, "foo", extractRecordName("foo.example.com", "example.com"))
@@ -380,3 +444,649 @@ func testRecords(t *testing.T) {
 	assert.Equal(t, []*endpoint.Endpoint{}, ep)
 	assert.NoError(t, err)
 }
+
+func testChangeIDs(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+
+	// IDs are stable and depend on every component of the change
+	id := changeID(actionCreate, "example.com", "foo", "A", "1.1.1.1")
+	assert.Len(t, id, 16)
+	assert.Equal(t, id, changeID(actionCreate, "example.com", "foo", "A", "1.1.1.1"))
+	assert.NotEqual(t, id, changeID(actionDelete, "example.com", "foo", "A", "1.1.1.1"))
+	assert.NotEqual(t, id, changeID(actionCreate, "example.com", "foo", "A", "1.1.1.2"))
+
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	err := p.ApplyChanges(ctx, &plan.Changes{
+		Create: []*endpoint.Endpoint{{
+			DNSName:    "foo.example.com",
+			Targets:    []string{"1.1.1.1"},
+			RecordType: "A",
+			RecordTTL:  60,
+		}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{id}, recorder.IDs())
+	assert.Equal(t, []AppliedChange{{
+		ID:        id,
+		Action:    "create",
+		Zone:      "example.com",
+		Name:      "foo",
+		Type:      "A",
+		Content:   "1.1.1.1",
+		TTL:       60,
+		RecordKey: recordKey("example.com", "foo", "A", "1.1.1.1"),
+		Outcome:   "applied",
+	}}, recorder.Changes())
+}
+
+func testRecordKeys(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+
+	// Keys depend on the record only, not on the action or the case of the name
+	key := recordKey("example.com", "foo", "A", "1.1.1.1")
+	assert.Len(t, key, 16)
+	assert.Equal(t, key, recordKey("Example.com", "FOO", "A", "1.1.1.1"))
+	assert.NotEqual(t, key, recordKey("example.com", "foo", "A", "1.1.1.2"))
+	assert.NotEqual(t, key, recordKey("example.com", "foo", "TXT", "1.1.1.1"))
+
+	foo := endpoint.NewEndpointWithTTL("foo.example.com", "A", 300, "1.1.1.1")
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	require.NoError(t, p.ApplyChanges(ctx, &plan.Changes{Create: []*endpoint.Endpoint{foo}}))
+	require.NoError(t, p.ApplyChanges(ctx, &plan.Changes{Delete: []*endpoint.Endpoint{foo}}))
+	require.NoError(t, p.ApplyChanges(ctx, &plan.Changes{Create: []*endpoint.Endpoint{foo}}))
+	records, err := p.Records(context.TODO())
+	require.NoError(t, err)
+	updated := endpoint.NewEndpointWithTTL("foo.example.com", "A", 300, "1.1.1.2")
+	require.NoError(t, p.ApplyChanges(ctx, &plan.Changes{UpdateOld: records, UpdateNew: []*endpoint.Endpoint{updated}}))
+
+	// The record keeps its key when it is recreated under another INWX ID
+	changes := recorder.Changes()
+	require.Len(t, changes, 4)
+	for _, change := range changes[:3] {
+		assert.Equal(t, key, change.RecordKey, change.Action)
+	}
+	assert.Empty(t, changes[2].OldRecordKey)
+	assert.NotEqual(t, changes[1].RecordID, changes[3].RecordID)
+
+	// Updates carry the keys of the record before and after
+	assert.Equal(t, key, changes[3].OldRecordKey)
+	assert.Equal(t, recordKey("example.com", "foo", "A", "1.1.1.2"), changes[3].RecordKey)
+}
+
+func testSlowCallReporting(t *testing.T) {
+	w := &ClientWrapper{logger: slog.Default(), metrics: newMetrics(), slowCallThreshold: time.Millisecond}
+	before := testutil.ToFloat64(w.metrics.slowCallsTotal.WithLabelValues("test.slow"))
+
+	err := w.call("test.slow", "example.com", func() error {
+		time.Sleep(2 * time.Millisecond)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, before+1, testutil.ToFloat64(w.metrics.slowCallsTotal.WithLabelValues("test.slow")))
+
+	// Fast calls and a disabled threshold are not counted
+	_ = w.call("test.slow", "example.com", func() error { return nil })
+	w.slowCallThreshold = 0
+	_ = w.call("test.slow", "example.com", func() error {
+		time.Sleep(2 * time.Millisecond)
+		return nil
+	})
+	assert.Equal(t, before+1, testutil.ToFloat64(w.metrics.slowCallsTotal.WithLabelValues("test.slow")))
+}
+
+func testIgnoredEndpoints(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	p.config.ignoreProperties = DefaultIgnoreProperties
+	p.config.ignoreLabels = map[string]string{"team": "legacy"}
+
+	ignoredByProperty := endpoint.NewEndpoint("foo.example.com", "A", "1.1.1.1").
+		WithProviderSpecific("webhook/inwx-ignore", "true")
+	ignoredByLabel := endpoint.NewEndpoint("bar.example.com", "A", "1.1.1.1").
+		WithLabel("team", "legacy")
+	notIgnored := endpoint.NewEndpoint("baz.example.com", "A", "1.1.1.1").
+		WithProviderSpecific("inwx/ignore", "false")
+
+	err := p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{ignoredByProperty, ignoredByLabel, notIgnored},
+	})
+	assert.NoError(t, err)
+
+	recs, _ := w.getRecords("example.com")
+	assert.Len(t, *recs, 1)
+	assert.Equal(t, "baz", (*recs)[0].Name)
+}
+
+func testFlapDetection(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	p.flaps = newFlapTracker(time.Hour, 3)
+
+	for _, target := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"} {
+		err := p.ApplyChanges(context.TODO(), &plan.Changes{
+			Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.com", "A", target)},
+		})
+		assert.NoError(t, err)
+	}
+	err := p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("bar.example.com", "A", "1.1.1.1")},
+	})
+	assert.NoError(t, err)
+
+	flaps := p.Flaps(0)
+	assert.Len(t, flaps, 2)
+	assert.Equal(t, "foo", flaps[0].Name)
+	assert.Equal(t, 3, flaps[0].Changes)
+	assert.True(t, flaps[0].Flapping)
+	assert.Equal(t, "bar", flaps[1].Name)
+	assert.False(t, flaps[1].Flapping)
+	assert.Len(t, p.Flaps(1), 1)
+
+	// Changes outside the window are forgotten
+	assert.Empty(t, p.flaps.top(0, time.Now().Add(2*time.Hour)))
+}
+
+func testDuplicateApplySkipped(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	p.applies = newApplyDedup(time.Minute)
+
+	changes := func() *plan.Changes {
+		return &plan.Changes{
+			Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.com", "A", "1.1.1.1")},
+		}
+	}
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes()))
+
+	// Remove the record behind the provider's back: a re-sent identical plan is not applied again
+	assert.NoError(t, w.deleteRecord("0"))
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	assert.NoError(t, p.ApplyChanges(ctx, changes()))
+	assert.Empty(t, recorder.Changes())
+	recs, _ := w.getRecords("example.com")
+	assert.Empty(t, *recs)
+
+	// Once the window has passed the plan is applied as usual
+	p.applies = newApplyDedup(time.Minute)
+	assert.NoError(t, p.ApplyChanges(context.TODO(), changes()))
+	recs, _ = w.getRecords("example.com")
+	assert.Len(t, *recs, 1)
+}
+
+func testShutdown(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+
+	// Shutdown waits for operations in flight
+	done, err := p.lifecycle.begin()
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, p.Shutdown(ctx), context.DeadlineExceeded)
+	done()
+	assert.NoError(t, p.Close())
+
+	// No new operations are accepted afterwards
+	_, err = p.Records(context.TODO())
+	assert.ErrorIs(t, err, ErrShutdown)
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.com", "A", "1.1.1.1")},
+	})
+	assert.ErrorIs(t, err, ErrShutdown)
+	recs, _ := w.getRecords("example.com")
+	assert.Empty(t, *recs)
+}
+
+func testDesiredEndpoints(t *testing.T) {
+	_, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	p.config.ignoreProperties = DefaultIgnoreProperties
+
+	ignored := endpoint.NewEndpoint("ignored.example.com", "A", "1.1.1.1").WithProviderSpecific("webhook/inwx-ignore", "true")
+	managed, excluded, err := p.DesiredEndpoints([]*endpoint.Endpoint{
+		endpoint.NewEndpoint("foo.example.com", "A", "1.1.1.1"),
+		endpoint.NewEndpoint("foo.example.org", "A", "1.1.1.1"),
+		ignored,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, managed, 1)
+	assert.Equal(t, "foo.example.com", managed[0].DNSName)
+	assert.Equal(t, []ExcludedEndpoint{
+		{Endpoint: endpoint.NewEndpoint("foo.example.org", "A", "1.1.1.1"), Reason: "domain_filter"},
+		{Endpoint: ignored, Reason: "ignored"},
+	}, excluded)
+}
+
+func testRecordsCache(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	p.records = newRecordsCache(time.Minute, 0)
+	w.CreateZone("example.com")
+	assert.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "foo", Type: "A", Content: "1.1.1.1", TTL: 300}))
+
+	eps, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, eps, 1)
+
+	// Served from the cache, so records created outside of the webhook aren't seen yet
+	assert.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "bar", Type: "A", Content: "1.1.1.1", TTL: 300}))
+	eps, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, eps, 1)
+
+	// Applied changes are patched into the cache without reading the records again
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("baz.example.com", "A", 300, "1.1.1.1")},
+	}))
+	eps, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"foo.example.com 1.1.1.1", "baz.example.com 1.1.1.1"}, endpointStrings(eps))
+
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("foo.example.com", "A", 300, "1.1.1.1")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("foo.example.com", "A", 300, "2.2.2.2")},
+		Delete:    []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("baz.example.com", "A", 300, "1.1.1.1")},
+	}))
+	eps, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"foo.example.com 2.2.2.2"}, endpointStrings(eps))
+
+	// A race with another writer, like deleting a record that is gone already, drops the cache without
+	// failing the apply
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("qux.example.com", "A", 300, "1.1.1.1")},
+	}))
+	eps, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"foo.example.com 2.2.2.2", "bar.example.com 1.1.1.1"}, endpointStrings(eps))
+
+	// Records read before an invalidation are not cached
+	generation := p.records.current()
+	p.records.invalidate()
+	p.records.store(nil, nil, nil, time.Now(), generation)
+	_, ok := p.records.get(time.Now())
+	assert.False(t, ok)
+}
+
+func testStaleRecordsFallback(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	assert.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "foo", Type: "A", Content: "1.1.1.1", TTL: 300}))
+
+	// Without the fallback a failing listing is an error
+	w.zonesErr = errors.New("connection reset")
+	_, err := p.Records(context.TODO())
+	assert.Error(t, err)
+
+	p.records = newRecordsCache(0, time.Hour)
+	w.zonesErr = nil
+	eps, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, eps, 1)
+
+	w.zonesErr = errors.New("connection reset")
+	before := testutil.ToFloat64(p.metrics.staleRecordsServedTotal)
+	eps, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, eps, 1)
+	assert.Equal(t, before+1, testutil.ToFloat64(p.metrics.staleRecordsServedTotal))
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.metrics.recordsStale))
+
+	// Applies don't drop the last known-good records
+	p.records.invalidate()
+	_, _, ok := p.records.stale(time.Now())
+	assert.True(t, ok)
+
+	// Too old records are not served
+	_, _, ok = p.records.stale(time.Now().Add(2 * time.Hour))
+	assert.False(t, ok)
+}
+
+func testCacheAge(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com", "example.org"}, slog.Default())
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	p.config.clock = clock
+	p.records = newRecordsCache(time.Minute, time.Hour)
+	for _, zone := range []string{"example.com", "example.org"} {
+		w.CreateZone(zone)
+		require.NoError(t, w.createRecord(&recordRequest{Domain: zone, Name: "www", Type: "A", Content: "192.0.2.1", TTL: 300}))
+	}
+	ages := func(com, org string) string {
+		return `
+			# HELP external_dns_inwx_cache_age_seconds Age of the records of a zone served last, by zone; it keeps growing while cached or last known-good records are served instead of current ones.
+			# TYPE external_dns_inwx_cache_age_seconds gauge
+			external_dns_inwx_cache_age_seconds{zone="example.com"} ` + com + `
+			external_dns_inwx_cache_age_seconds{zone="example.org"} ` + org + `
+		`
+	}
+
+	// Nothing is exported before the first read
+	assert.Zero(t, testutil.CollectAndCount(cacheAgeCollector{provider: p}))
+
+	_, err := p.Records(context.TODO())
+	require.NoError(t, err)
+	require.NoError(t, testutil.CollectAndCompare(cacheAgeCollector{provider: p}, strings.NewReader(ages("0", "0"))))
+
+	// Cached records age until they expire
+	clock.advance(30 * time.Second)
+	_, err = p.Records(context.TODO())
+	require.NoError(t, err)
+	require.NoError(t, testutil.CollectAndCompare(cacheAgeCollector{provider: p}, strings.NewReader(ages("30", "30"))))
+
+	// The last known-good records keep aging while they are served, counted per zone
+	clock.advance(2 * time.Minute)
+	w.zonesErr = errors.New("connection reset")
+	for range 2 {
+		_, err = p.Records(context.TODO())
+		require.NoError(t, err)
+	}
+	require.NoError(t, testutil.CollectAndCompare(cacheAgeCollector{provider: p}, strings.NewReader(ages("150", "150"))))
+	assert.Equal(t, 2.0, testutil.ToFloat64(p.metrics.staleZoneReadsTotal.WithLabelValues("example.com")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(p.metrics.staleZoneReadsTotal.WithLabelValues("example.org")))
+	assert.Equal(t, map[string]float64{"example.com": 150, "example.org": 150}, p.CacheState().Records.ZoneAgeSeconds)
+
+	// A successful read serves current records again
+	w.zonesErr = nil
+	_, err = p.Records(context.TODO())
+	require.NoError(t, err)
+	require.NoError(t, testutil.CollectAndCompare(cacheAgeCollector{provider: p}, strings.NewReader(ages("0", "0"))))
+}
+
+func testStrongConsistency(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	p.records = newRecordsCache(time.Minute, time.Hour)
+	w.CreateZone("example.com")
+	assert.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "foo", Type: "A", Content: "1.1.1.1", TTL: 300}))
+	strong := WithConsistency(context.TODO(), ConsistencyStrong)
+
+	_, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "bar", Type: "A", Content: "1.1.1.1", TTL: 300}))
+	eps, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, eps, 1)
+
+	// A strongly consistent read bypasses the cache and refreshes it
+	eps, err = p.Records(strong)
+	assert.NoError(t, err)
+	assert.Len(t, eps, 2)
+	eps, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, eps, 2)
+
+	// and fails rather than serving the last known-good records
+	w.zonesErr = errors.New("connection reset")
+	_, err = p.Records(strong)
+	assert.Error(t, err)
+
+	c, err := ParseConsistency("")
+	assert.NoError(t, err)
+	assert.Equal(t, ConsistencyCached, c)
+	_, err = ParseConsistency("eventual")
+	assert.Error(t, err)
+}
+
+func testEmptyRecordsGuard(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	p.records = newRecordsCache(0, 0)
+	p.config.emptyRecordsThreshold = 2
+	w.CreateZone("example.com")
+	for _, name := range []string{"foo", "bar"} {
+		assert.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: name, Type: "A", Content: "1.1.1.1", TTL: 300}))
+	}
+	eps, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, eps, 2)
+
+	// The zone suddenly appears empty
+	delete(w.db, "example.com")
+	w.CreateZone("example.com")
+	for range emptyRecordsConfirmations - 1 {
+		_, err = p.Records(context.TODO())
+		assert.ErrorContains(t, err, "no records although 2 were read before")
+	}
+	// until it is confirmed by consecutive reads
+	eps, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Empty(t, eps)
+	eps, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Empty(t, eps)
+
+	// The last known-good records are served instead if available
+	p.records = newRecordsCache(0, time.Hour)
+	p.records.store([]*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.com", "A", "1.1.1.1"), endpoint.NewEndpoint("bar.example.com", "A", "1.1.1.1")}, nil, nil, time.Now(), 0)
+	p.records.lastCount.Store(2)
+	eps, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, eps, 2)
+}
+
+func testManualChanges(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	p.drift = newDriftTracker()
+	w.CreateZone("example.com")
+	assert.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "foo", Type: "A", Content: "1.1.1.1", TTL: 300}))
+	manual := func(change string) float64 {
+		return testutil.ToFloat64(p.metrics.manualChangesTotal.WithLabelValues("example.com", change))
+	}
+	created, updated, deleted := manual("created"), manual("updated"), manual("deleted")
+
+	// The first refresh sets the baseline, changes by the webhook are not reported
+	_, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create:    []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("bar.example.com", "A", 300, "2.2.2.2")},
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("foo.example.com", "A", 300, "1.1.1.1")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("foo.example.com", "A", 300, "1.1.1.2")},
+	}))
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, created, manual("created"))
+	assert.Equal(t, updated, manual("updated"))
+
+	// Changes made directly in INWX are
+	assert.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "baz", Type: "A", Content: "3.3.3.3", TTL: 300}))
+	recs, _ := w.getRecords("example.com")
+	for _, rec := range *recs {
+		switch rec.Name {
+		case "foo":
+			assert.NoError(t, w.updateRecord(rec.ID, &recordRequest{Domain: "example.com", Name: "foo", Type: "A", Content: "9.9.9.9", TTL: 300}))
+		case "bar":
+			assert.NoError(t, w.deleteRecord(rec.ID))
+		}
+	}
+	_, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, created+1, manual("created"))
+	assert.Equal(t, updated+1, manual("updated"))
+	assert.Equal(t, deleted+1, manual("deleted"))
+}
+
+func testZeroTargets(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	for _, rec := range []recordRequest{
+		{Domain: "example.com", Name: "foo", Type: "A", Content: "1.1.1.1"},
+		{Domain: "example.com", Name: "foo", Type: "A", Content: "1.1.1.2"},
+		{Domain: "example.com", Name: "foo", Type: "AAAA", Content: "::1"},
+		{Domain: "example.com", Name: "bar", Type: "A", Content: "2.2.2.2"},
+	} {
+		assert.NoError(t, w.createRecord(&rec))
+	}
+	contents := func() []string {
+		recs, _ := w.getRecords("example.com")
+		contents := []string{}
+		for _, rec := range *recs {
+			contents = append(contents, rec.Name+" "+rec.Type+" "+rec.Content)
+		}
+		return contents
+	}
+
+	// Creates without targets are rejected with a clear error
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	err := p.ApplyChanges(ctx, &plan.Changes{Create: []*endpoint.Endpoint{{DNSName: "baz.example.com", RecordType: "A"}}})
+	assert.Error(t, err)
+	assert.Empty(t, recorder.Changes())
+
+	// Deletes without targets delete every record of the name and type
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Delete: []*endpoint.Endpoint{{DNSName: "foo.example.com", RecordType: "A"}}}))
+	assert.ElementsMatch(t, []string{"foo AAAA ::1", "bar A 2.2.2.2"}, contents())
+
+	// and so do updates to no targets
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("bar.example.com", "A", "2.2.2.2")},
+		UpdateNew: []*endpoint.Endpoint{{DNSName: "bar.example.com", RecordType: "A"}},
+	}))
+	assert.ElementsMatch(t, []string{"foo AAAA ::1"}, contents())
+}
+
+// endpointStrings returns the name and targets of every endpoint.
+func endpointStrings(eps []*endpoint.Endpoint) []string {
+	strs := []string{}
+	for _, ep := range eps {
+		strs = append(strs, ep.DNSName+" "+strings.Join(ep.Targets, ","))
+	}
+	return strs
+}
+
+// fakeClock is a Clock that only moves when advanced.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func testApplyOrder(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	var order []string
+	w.createErr = func(r *recordRequest) error {
+		order = append(order, "create "+r.Name+" "+r.Type)
+		return nil
+	}
+	w.deleteErr = func(recID string) error {
+		for _, rec := range *w.db["example.com"] {
+			if rec.ID == recID {
+				order = append(order, "delete "+rec.Name+" "+rec.Type)
+			}
+		}
+		return nil
+	}
+	setup := func() *plan.Changes {
+		w.db["example.com"] = &[]zoneRecord{}
+		for _, rec := range []recordRequest{{Name: "www", Type: "CNAME", Content: "lb.example.net"}, {Name: "old", Type: "A", Content: "192.0.2.1"}} {
+			rec.Domain = "example.com"
+			assert.NoError(t, w.createRecord(&rec))
+		}
+		order = nil
+		return &plan.Changes{
+			Create: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", "A", "192.0.2.2"), endpoint.NewEndpoint("new.example.com", "A", "192.0.2.1")},
+			Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("old.example.com", "A", "192.0.2.1"), endpoint.NewEndpoint("www.example.com", "CNAME", "lb.example.net")},
+		}
+	}
+
+	// By default every delete goes first
+	assert.NoError(t, p.ApplyChanges(context.TODO(), setup()))
+	assert.Equal(t, []string{"delete old A", "delete www CNAME", "create www A", "create new A"}, order)
+
+	// Create-first still deletes the CNAME replaced by an A record first
+	p.config.createFirst = true
+	p.applies = nil
+	assert.NoError(t, p.ApplyChanges(context.TODO(), setup()))
+	assert.Equal(t, []string{"delete www CNAME", "create www A", "create new A", "delete old A"}, order)
+}
+
+func testDebugState(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	p.config.clock = clock
+	p.records = newRecordsCache(time.Minute, time.Hour)
+	assert.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "foo", Type: "A", Content: "1.1.1.1"}))
+
+	for range 3 {
+		_, err := p.Records(context.TODO())
+		assert.NoError(t, err)
+	}
+	clock.advance(30 * time.Second)
+	state := p.CacheState().Records
+	assert.Equal(t, int64(2), state.Hits)
+	assert.Equal(t, int64(1), state.Misses)
+	assert.InDelta(t, 2.0/3, state.HitRatio, 0.001)
+	assert.Equal(t, &SnapshotState{Records: 1, Fetched: clock.now.Add(-30 * time.Second), AgeSeconds: 30}, state.Cached)
+	assert.Equal(t, 3600.0, state.StaleMaxAgeSeconds)
+	assert.NotNil(t, state.LastKnownGood)
+
+	assert.Empty(t, p.RateLimits())
+	for range 3 {
+		assert.NoError(t, p.waitForRateLimit(context.TODO(), "example.com", 100))
+	}
+	limits := p.RateLimits()
+	assert.Len(t, limits, 1)
+	assert.Equal(t, "example.com", limits[0].Zone)
+	assert.Equal(t, 100.0, limits[0].Limit)
+	assert.Equal(t, 100, limits[0].Burst)
+	assert.InDelta(t, 97, limits[0].Tokens, 1)
+
+	// The cache sizes are exported at scrape time
+	p.history = newOperationHistory(10)
+	changes := &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("bar.example.com", "A", "2.2.2.2")}}
+	require.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	expected := `
+		# HELP external_dns_inwx_cache_entries Number of entries held in memory, by cache: records and last_known_good (records), zones, flaps (records with changes tracked), apply_dedup (change sets), history (operations) and passthrough (endpoints with fields INWX doesn't store).
+		# TYPE external_dns_inwx_cache_entries gauge
+		external_dns_inwx_cache_entries{cache="apply_dedup"} 0
+		external_dns_inwx_cache_entries{cache="flaps"} 0
+		external_dns_inwx_cache_entries{cache="history"} 1
+		external_dns_inwx_cache_entries{cache="last_known_good"} 1
+		external_dns_inwx_cache_entries{cache="passthrough"} 0
+		external_dns_inwx_cache_entries{cache="records"} 2
+		external_dns_inwx_cache_entries{cache="zones"} 0
+	`
+	require.NoError(t, testutil.CollectAndCompare(cacheCollector{provider: p}, strings.NewReader(expected)))
+}
+
+func testClock(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	p.config.clock = clock
+	p.records = newRecordsCache(time.Minute, 0)
+	w.CreateZone("example.com")
+	assert.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "foo", Type: "A", Content: "1.1.1.1"}))
+
+	eps, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, eps, 1)
+	assert.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "bar", Type: "A", Content: "1.1.1.1"}))
+
+	// The cache TTL follows the clock rather than the wall time
+	clock.advance(time.Minute)
+	eps, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, eps, 1)
+	clock.advance(time.Second)
+	eps, err = p.Records(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, eps, 2)
+
+	// Seeded sources give reproducible jitter within the bounds
+	a, b := newLockedRand(rand.New(rand.NewPCG(1, 2))), newLockedRand(rand.New(rand.NewPCG(1, 2)))
+	for range 10 {
+		d := a.jitter(time.Minute, 0.1)
+		assert.Equal(t, d, b.jitter(time.Minute, 0.1))
+		assert.InDelta(t, time.Minute, d, float64(6*time.Second))
+	}
+	assert.Equal(t, time.Minute, (*lockedRand)(nil).jitter(time.Minute, 0.1))
+}
@@ -0,0 +1,207 @@
+package inwx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"strconv"
+)
+
+const (
+	jsonRPCURL        = "https://api.domrobot.com/jsonrpc/"
+	jsonRPCSandboxURL = "https://api.ote.domrobot.com/jsonrpc/"
+)
+
+// jsonRPCClient is the built-in DomRobot client, speaking JSON-RPC without goinwx. The session cookie
+// set by account.login is kept in a cookie jar.
+type jsonRPCClient struct {
+	http *http.Client
+	url  string
+}
+
+func newJSONRPCClient(sandbox bool, apiURL string, transport http.RoundTripper) *jsonRPCClient {
+	// cookiejar.New never fails without a public suffix list.
+	jar, _ := cookiejar.New(nil)
+	url := jsonRPCURL
+	if sandbox {
+		url = jsonRPCSandboxURL
+	}
+	if apiURL != "" {
+		url = apiURL
+	}
+	return &jsonRPCClient{
+		http: &http.Client{Transport: transport, Jar: jar},
+		url:  url,
+	}
+}
+
+// call calls method with params, decoding the resData of the response into result unless it is nil.
+func (c *jsonRPCClient) call(method string, params map[string]any, result any) error {
+	body, err := json.Marshal(map[string]any{"method": method, "params": params})
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Post(c.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %s", method, resp.Status)
+	}
+
+	var response struct {
+		Code       int             `json:"code"`
+		Message    string          `json:"msg"`
+		ReasonCode string          `json:"reasonCode"`
+		Reason     string          `json:"reason"`
+		Data       json.RawMessage `json:"resData"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("%s: unable to decode response: %w", method, err)
+	}
+	// Codes 1000 to 1500 report success.
+	if response.Code < 1000 || response.Code > 1500 {
+		return &apiError{Code: response.Code, Message: response.Message, ReasonCode: response.ReasonCode, Reason: response.Reason}
+	}
+	if result == nil || len(response.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(response.Data, result); err != nil {
+		return fmt.Errorf("%s: unable to decode response data: %w", method, err)
+	}
+	return nil
+}
+
+func (c *jsonRPCClient) login(username string, password string) (loginResult, error) {
+	var data struct {
+		TFA        any `json:"tfa"`
+		CustomerID any `json:"customerId"`
+		AccountID  any `json:"accountId"`
+	}
+	if err := c.call("account.login", map[string]any{"user": username, "pass": password, "lang": "en"}, &data); err != nil {
+		return loginResult{}, err
+	}
+	return loginResult{locked: tfaEnabled(data.TFA), customerID: intValue(data.CustomerID), accountID: intValue(data.AccountID)}, nil
+}
+
+func (c *jsonRPCClient) unlock(tan string) error {
+	return c.call("account.unlock", map[string]any{"tan": tan}, nil)
+}
+
+func (c *jsonRPCClient) logout() error {
+	return c.call("account.logout", map[string]any{}, nil)
+}
+
+func (c *jsonRPCClient) accountInfo() error {
+	return c.call("account.info", map[string]any{}, nil)
+}
+
+// jsonRPCID decodes the ID of a record, which DomRobot sends as a number, as a string.
+type jsonRPCID string
+
+func (id *jsonRPCID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*id = jsonRPCID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*id = jsonRPCID(n.String())
+	return nil
+}
+
+func (c *jsonRPCClient) nameserverInfo(domain string) ([]zoneRecord, error) {
+	var data struct {
+		Records []struct {
+			ID       jsonRPCID `json:"id"`
+			Name     string    `json:"name"`
+			Type     string    `json:"type"`
+			Content  string    `json:"content"`
+			TTL      int       `json:"ttl"`
+			Priority int       `json:"prio"`
+			Redirect string    `json:"urlRedirectType"`
+		} `json:"record"`
+	}
+	if err := c.call("nameserver.info", map[string]any{"domain": domain}, &data); err != nil {
+		return nil, err
+	}
+	records := make([]zoneRecord, 0, len(data.Records))
+	for _, rec := range data.Records {
+		records = append(records, zoneRecord{ID: string(rec.ID), Name: rec.Name, Type: rec.Type, Content: rec.Content, TTL: rec.TTL, Priority: rec.Priority,
+			RedirectType: rec.Redirect})
+	}
+	return records, nil
+}
+
+func (c *jsonRPCClient) nameserverList(page int, pageLimit int) ([]string, int, error) {
+	var data struct {
+		Count   int `json:"count"`
+		Domains []struct {
+			Domain string `json:"domain"`
+		} `json:"domains"`
+	}
+	if err := c.call("nameserver.list", map[string]any{"domain": "*", "page": page, "pagelimit": pageLimit}, &data); err != nil {
+		return nil, 0, err
+	}
+	zones := make([]string, 0, len(data.Domains))
+	for _, domain := range data.Domains {
+		zones = append(zones, domain.Domain)
+	}
+	return zones, data.Count, nil
+}
+
+func (c *jsonRPCClient) nameserverCreate(request *zoneRequest) error {
+	params := map[string]any{"domain": request.Domain, "type": "MASTER", "ns": request.Nameservers}
+	if request.SOAEmail != "" {
+		params["soaEmail"] = request.SOAEmail
+	}
+	return c.call("nameserver.create", params, nil)
+}
+
+// recordParams returns the parameters of nameserver.createRecord and nameserver.updateRecord for request.
+func recordParams(request *recordRequest) map[string]any {
+	params := map[string]any{"type": request.Type, "content": request.Content}
+	if request.Name != "" {
+		params["name"] = request.Name
+	}
+	if request.TTL != 0 {
+		params["ttl"] = request.TTL
+	}
+	if request.Priority != 0 {
+		params["prio"] = request.Priority
+	}
+	if request.RedirectType != "" {
+		params["urlRedirectType"] = request.RedirectType
+	}
+	return params
+}
+
+func (c *jsonRPCClient) createRecord(request *recordRequest) error {
+	params := recordParams(request)
+	params["domain"] = request.Domain
+	return c.call("nameserver.createRecord", params, nil)
+}
+
+func (c *jsonRPCClient) updateRecord(recID string, request *recordRequest) error {
+	id, err := strconv.Atoi(recID)
+	if err != nil {
+		return fmt.Errorf("invalid record ID %q: %w", recID, err)
+	}
+	params := recordParams(request)
+	params["id"] = id
+	return c.call("nameserver.updateRecord", params, nil)
+}
+
+func (c *jsonRPCClient) deleteRecord(recID string) error {
+	id, err := strconv.Atoi(recID)
+	if err != nil {
+		return fmt.Errorf("invalid record ID %q: %w", recID, err)
+	}
+	return c.call("nameserver.deleteRecord", map[string]any{"id": id}, nil)
+}
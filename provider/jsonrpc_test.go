@@ -0,0 +1,93 @@
+package inwx
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONRPCClient(t *testing.T) {
+	var calls []string
+	var params []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string         `json:"method"`
+			Params map[string]any `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		calls, params = append(calls, req.Method), append(params, req.Params)
+		if req.Method == "account.login" {
+			http.SetCookie(w, &http.Cookie{Name: "domrobot", Value: "session"})
+		} else if cookie, err := r.Cookie("domrobot"); err != nil || cookie.Value != "session" {
+			_, _ = w.Write([]byte(`{"code": 2200, "msg": "Authentication error"}`))
+			return
+		}
+		switch req.Method {
+		case "account.login":
+			_, _ = w.Write([]byte(`{"code": 1000, "resData": {"customerId": 12345, "accountId": 67890, "tfa": "0"}}`))
+		case "nameserver.info":
+			_, _ = w.Write([]byte(`{"code": 1000, "msg": "Command completed successfully", "resData": {"record": [
+				{"id": 42, "name": "foo.example.com", "type": "TXT", "content": "\"v=spf1 -all\"", "ttl": 300, "prio": 0},
+				{"id": 43, "name": "example.com", "type": "MX", "content": "mx.example.net", "ttl": 3600, "prio": 10}
+			]}}`))
+		case "nameserver.list":
+			page := req.Params["page"].(float64)
+			if page == 1 {
+				_, _ = w.Write([]byte(`{"code": 1000, "resData": {"count": 2, "domains": [{"domain": "example.com"}]}}`))
+			} else {
+				_, _ = w.Write([]byte(`{"code": 1000, "resData": {"count": 2, "domains": [{"domain": "example.org"}]}}`))
+			}
+		case "nameserver.createRecord":
+			_, _ = w.Write([]byte(`{"code": 2302, "msg": "Object exists"}`))
+		default:
+			_, _ = w.Write([]byte(`{"code": 1000, "msg": "Command completed successfully"}`))
+		}
+	}))
+	defer server.Close()
+
+	api := newJSONRPCClient(true, server.URL, server.Client().Transport)
+	w := &ClientWrapper{api: api, logger: slog.Default(), metrics: newMetrics(), rand: newLockedRand(nil), environment: "sandbox"}
+	w.credentials.Store(&Credentials{Username: "user", Password: "pass"})
+
+	require.NoError(t, w.login())
+	assert.Equal(t, map[string]any{"user": "user", "pass": "pass", "lang": "en"}, params[0])
+	account, ok := w.loggedInAccount()
+	require.True(t, ok)
+	assert.Equal(t, Account{CustomerID: 12345, AccountID: 67890, Environment: "sandbox"}, account)
+
+	records, err := w.getRecords("example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []zoneRecord{
+		{ID: "42", Name: "foo.example.com", Type: "TXT", Content: "v=spf1 -all", TTL: 300},
+		{ID: "43", Name: "example.com", Type: "MX", Content: "mx.example.net", TTL: 3600, Priority: 10},
+	}, *records)
+
+	zones, err := w.getZones()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"example.com", "example.org"}, *zones)
+
+	// API errors are reported like by goinwx
+	err = w.createRecord(&recordRequest{Domain: "example.com", Name: "foo", Type: "A", Content: "192.0.2.1"})
+	assert.True(t, isWarning(classifyChangeError(actionCreate, err)))
+	assert.Equal(t, "(2302) Object exists", err.Error())
+
+	require.NoError(t, w.updateRecord("42", &recordRequest{Domain: "example.com", Name: "foo", Type: "A", Content: "192.0.2.2", TTL: 300}))
+	assert.Equal(t, map[string]any{"id": 42.0, "name": "foo", "type": "A", "content": "192.0.2.2", "ttl": 300.0}, params[len(params)-1])
+	require.NoError(t, w.deleteRecord("42"))
+	assert.Equal(t, map[string]any{"id": 42.0}, params[len(params)-1])
+	assert.Error(t, w.deleteRecord("not-a-number"))
+	require.NoError(t, w.createZone(&zoneRequest{Domain: "example.net", Nameservers: []string{"ns.inwx.de", "ns2.inwx.de"}, SOAEmail: "hostmaster@example.net"}))
+	assert.Equal(t, map[string]any{"domain": "example.net", "type": "MASTER", "ns": []any{"ns.inwx.de", "ns2.inwx.de"}, "soaEmail": "hostmaster@example.net"},
+		params[len(params)-1])
+	_, _, cached := w.cachedZones()
+	assert.False(t, cached, "the zone list is read again after a zone was created")
+	require.NoError(t, w.logout())
+
+	assert.Equal(t, []string{"account.login", "nameserver.info", "nameserver.list", "nameserver.list", "nameserver.createRecord",
+		"nameserver.updateRecord", "nameserver.deleteRecord", "nameserver.create", "account.logout"}, calls)
+}
@@ -0,0 +1,68 @@
+package inwx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrShutdown is returned by operations started after the provider was shut down.
+var ErrShutdown = errors.New("provider is shut down")
+
+// lifecycle tracks in-flight operations so that Shutdown can wait for them to finish.
+type lifecycle struct {
+	mu       sync.Mutex
+	closed   bool
+	inflight sync.WaitGroup
+}
+
+// begin registers an operation; the returned function must be called once it is done.
+func (l *lifecycle) begin() (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil, ErrShutdown
+	}
+	l.inflight.Add(1)
+	return l.inflight.Done, nil
+}
+
+// Shutdown stops the provider from accepting new operations and waits until the in-flight ones have
+// completed and logged out of their INWX session, or ctx is done. It is safe to call more than once.
+func (p *INWXProvider) Shutdown(ctx context.Context) error {
+	p.lifecycle.mu.Lock()
+	if !p.lifecycle.closed && p.expiryStop != nil {
+		close(p.expiryStop)
+	}
+	p.lifecycle.closed = true
+	p.lifecycle.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.lifecycle.inflight.Wait()
+		close(done)
+	}()
+
+	// Subscribers of the change feed are released either way, so that their connections can be closed.
+	defer p.feed.close()
+
+	select {
+	case <-done:
+		p.client.close()
+		if p.config.auditStore != nil {
+			if err := p.config.auditStore.Close(); err != nil {
+				p.logger.Error("failed to close audit log", "err", err)
+			}
+		}
+		p.logger.Info("provider shut down")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("shutdown interrupted with operations in flight: %w", ctx.Err())
+	}
+}
+
+// Close shuts the provider down without a deadline; see Shutdown.
+func (p *INWXProvider) Close() error {
+	return p.Shutdown(context.Background())
+}
@@ -0,0 +1,100 @@
+package inwx
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaintenanceWindow is a scheduled INWX maintenance, from Start up to End.
+type MaintenanceWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+// ParseMaintenanceWindow parses a window given as <start>/<end> or <start>/<duration>, with start and
+// end in RFC 3339, e.g. 2026-11-03T22:00:00Z/2026-11-04T02:00:00Z or 2026-11-03T22:00:00Z/4h.
+func ParseMaintenanceWindow(s string) (MaintenanceWindow, error) {
+	startValue, endValue, ok := strings.Cut(s, "/")
+	if !ok {
+		return MaintenanceWindow{}, fmt.Errorf("invalid maintenance window %q: expected <start>/<end> or <start>/<duration>", s)
+	}
+	start, err := time.Parse(time.RFC3339, startValue)
+	if err != nil {
+		return MaintenanceWindow{}, fmt.Errorf("invalid maintenance window %q: %w", s, err)
+	}
+	end, err := time.Parse(time.RFC3339, endValue)
+	if err != nil {
+		d, derr := time.ParseDuration(endValue)
+		if derr != nil {
+			return MaintenanceWindow{}, fmt.Errorf("invalid maintenance window %q: end %q is neither a time nor a duration", s, endValue)
+		}
+		end = start.Add(d)
+	}
+	if !end.After(start) {
+		return MaintenanceWindow{}, fmt.Errorf("invalid maintenance window %q: end is not after start", s)
+	}
+	return MaintenanceWindow{Start: start, End: end}, nil
+}
+
+func (w MaintenanceWindow) String() string {
+	return w.Start.Format(time.RFC3339) + "/" + w.End.Format(time.RFC3339)
+}
+
+// contains reports whether t lies within the window.
+func (w MaintenanceWindow) contains(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// maintenanceTracker knows the configured maintenance windows and remembers the one in progress, so that
+// entering and leaving a window is logged once.
+type maintenanceTracker struct {
+	windows []MaintenanceWindow
+
+	mu      sync.Mutex
+	current *MaintenanceWindow
+}
+
+func newMaintenanceTracker(windows []MaintenanceWindow) *maintenanceTracker {
+	if len(windows) == 0 {
+		return nil
+	}
+	return &maintenanceTracker{windows: windows}
+}
+
+// inMaintenance returns the maintenance window in progress at the current time, logging when one starts
+// or ends.
+func (p *INWXProvider) inMaintenance() (MaintenanceWindow, bool) {
+	t := p.maintenance
+	if t == nil {
+		return MaintenanceWindow{}, false
+	}
+	now := now(p.config.clock)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var active *MaintenanceWindow
+	for i := range t.windows {
+		if t.windows[i].contains(now) {
+			active = &t.windows[i]
+			break
+		}
+	}
+	if active != t.current {
+		if t.current != nil {
+			p.logger.Info("INWX maintenance window ended, reading records and applying changes again", "window", t.current.String())
+		}
+		if active != nil {
+			p.logger.Warn("INWX maintenance window in progress, serving cached records and deferring changes until it ends",
+				"window", active.String(), "ends_in", active.End.Sub(now).Round(time.Second))
+		}
+		t.current = active
+	}
+	if active == nil {
+		p.metrics.maintenanceActive.Set(0)
+		return MaintenanceWindow{}, false
+	}
+	p.metrics.maintenanceActive.Set(1)
+	return *active, true
+}
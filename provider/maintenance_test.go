@@ -0,0 +1,69 @@
+package inwx
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestMaintenanceWindows(t *testing.T) {
+	t.Run("ParseMaintenanceWindow", func(t *testing.T) {
+		start := time.Date(2026, 11, 3, 22, 0, 0, 0, time.UTC)
+		for _, value := range []string{"2026-11-03T22:00:00Z/2026-11-04T02:00:00Z", "2026-11-03T22:00:00Z/4h"} {
+			window, err := ParseMaintenanceWindow(value)
+			require.NoError(t, err, value)
+			assert.Equal(t, MaintenanceWindow{Start: start, End: start.Add(4 * time.Hour)}, window)
+			assert.Equal(t, "2026-11-03T22:00:00Z/2026-11-04T02:00:00Z", window.String())
+		}
+		for _, value := range []string{"", "2026-11-03T22:00:00Z", "tonight/4h", "2026-11-03T22:00:00Z/soon", "2026-11-03T22:00:00Z/-1h"} {
+			_, err := ParseMaintenanceWindow(value)
+			assert.Error(t, err, value)
+		}
+	})
+
+	t.Run("ServesRecordsAndDefersChanges", func(t *testing.T) {
+		w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+		w.CreateZone("example.com")
+		require.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "foo", Type: "A", Content: "192.0.2.1", TTL: 300}))
+		clock := &fakeClock{now: time.Date(2026, 11, 3, 21, 0, 0, 0, time.UTC)}
+		p.config.clock = clock
+		p.maintenance = newMaintenanceTracker([]MaintenanceWindow{{Start: clock.now.Add(time.Hour), End: clock.now.Add(5 * time.Hour)}})
+		p.records = newRecordsCache(0, 0)
+		p.records.keepLastGood = true
+
+		eps, err := p.Records(context.TODO())
+		require.NoError(t, err)
+		require.Len(t, eps, 1)
+		assert.Equal(t, 0.0, testutil.ToFloat64(p.metrics.maintenanceActive))
+
+		// During the window, INWX isn't asked at all
+		clock.advance(2 * time.Hour)
+		w.zonesErr = errors.New("maintenance")
+		eps, err = p.Records(context.TODO())
+		require.NoError(t, err)
+		assert.Len(t, eps, 1)
+		assert.Equal(t, 1.0, testutil.ToFloat64(p.metrics.maintenanceActive))
+
+		before := testutil.ToFloat64(p.metrics.maintenanceDeferredAppliesTotal)
+		changes := &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("bar.example.com", "A", "192.0.2.2")}}
+		require.NoError(t, p.ApplyChanges(context.TODO(), changes))
+		assert.Equal(t, before+1, testutil.ToFloat64(p.metrics.maintenanceDeferredAppliesTotal))
+
+		// Once the window ended, the deferred changes are applied
+		clock.advance(4 * time.Hour)
+		w.zonesErr = nil
+		require.NoError(t, p.ApplyChanges(context.TODO(), changes))
+		eps, err = p.Records(context.TODO())
+		require.NoError(t, err)
+		assert.Len(t, eps, 2)
+		assert.Equal(t, 0.0, testutil.ToFloat64(p.metrics.maintenanceActive))
+	})
+}
@@ -0,0 +1,267 @@
+package inwx
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricsNamespace prefixes every metric exposed by the webhook.
+const MetricsNamespace = "external_dns_inwx"
+
+// metrics are the metrics of a provider. Every provider has its own, so that the providers of several
+// tenants served by one process are told apart by the labels they are registered with.
+type metrics struct {
+	changesTotal                    *prometheus.CounterVec
+	skippedChangesTotal             *prometheus.CounterVec
+	duplicateAppliesTotal           prometheus.Counter
+	operationDuration               *prometheus.HistogramVec
+	staleRecordsServedTotal         prometheus.Counter
+	recordsStale                    prometheus.Gauge
+	staleZoneReadsTotal             *prometheus.CounterVec
+	emptyRecordsRejectedTotal       prometheus.Counter
+	manualChangesTotal              *prometheus.CounterVec
+	auditWriteErrorsTotal           prometheus.Counter
+	adjustedEndpointsTotal          *prometheus.CounterVec
+	notificationErrorsTotal         prometheus.Counter
+	zoneRecords                     *prometheus.GaugeVec
+	duplicateOwnershipRecords       *prometheus.GaugeVec
+	emptyZones                      *prometheus.GaugeVec
+	changeFeedDropsTotal            prometheus.Counter
+	maintenanceActive               prometheus.Gauge
+	maintenanceDeferredAppliesTotal prometheus.Counter
+	sessionReloginsTotal            prometheus.Counter
+	heldDeletionsTotal              prometheus.Counter
+	staleRecordIDsTotal             prometheus.Counter
+	apiCallsTotal                   *prometheus.CounterVec
+	apiErrorsTotal                  *prometheus.CounterVec
+	apiCallDuration                 *prometheus.HistogramVec
+	slowCallsTotal                  *prometheus.CounterVec
+	clientCompatibility             *prometheus.GaugeVec
+}
+
+// newMetrics returns unregistered metrics for a provider.
+func newMetrics() *metrics {
+	return &metrics{
+		changesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "changes_total",
+			Help:      "Number of record mutations applied against INWX, by zone, action and result.",
+		}, []string{"zone", "action", "result"}),
+		skippedChangesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "skipped_changes_total",
+			Help:      "Number of record mutations deliberately not sent to INWX, by zone, action and reason.",
+		}, []string{"zone", "action", "reason"}),
+		duplicateAppliesTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "duplicate_applies_total",
+			Help:      "Number of change sets skipped because an identical one was applied successfully within the duplicate apply window.",
+		}),
+		operationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: MetricsNamespace,
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of webhook provider operations, by operation and result.",
+			Buckets:   prometheus.ExponentialBuckets(0.05, 2, 12),
+		}, []string{"operation", "result"}),
+		staleRecordsServedTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "stale_records_served_total",
+			Help:      "Number of times the last known-good records were served because listing the records failed.",
+		}),
+		recordsStale: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Name:      "records_stale",
+			Help:      "Whether the records served last were the last known-good ones instead of current ones (1) or not (0).",
+		}),
+		staleZoneReadsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "stale_zone_reads_total",
+			Help:      "Number of times the last known-good records of a zone were served because listing the records failed, by zone.",
+		}, []string{"zone"}),
+		emptyRecordsRejectedTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "empty_records_rejected_total",
+			Help:      "Number of reads rejected because INWX returned no records although many were read before.",
+		}),
+		manualChangesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "manual_changes_total",
+			Help:      "Number of record changes in INWX not made by the webhook, e.g. in the web panel, by zone and change.",
+		}, []string{"zone", "change"}),
+		auditWriteErrorsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "audit_write_errors_total",
+			Help:      "Number of change sets that could not be written to the audit log.",
+		}),
+		adjustedEndpointsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "adjusted_endpoints_total",
+			Help:      "Number of desired endpoints dropped, modified or flagged by AdjustEndpoints, by action and reason.",
+		}, []string{"action", "reason"}),
+		notificationErrorsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "notification_errors_total",
+			Help:      "Number of failure notifications that could not be sent.",
+		}),
+		zoneRecords: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Name:      "zone_records",
+			Help:      "Number of records in a zone, including SOA and NS, as last read from INWX, by zone.",
+		}, []string{"zone"}),
+		duplicateOwnershipRecords: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Name:      "duplicate_ownership_records",
+			Help:      "Ownership records referring to the same endpoint as another one, as last found before applying changes, by zone.",
+		}, []string{"zone"}),
+		emptyZones: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Name:      "empty_zones",
+			Help:      "Zones that have held no records besides SOA and NS for at least the configured period, by zone.",
+		}, []string{"zone"}),
+		changeFeedDropsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "change_feed_drops_total",
+			Help:      "Number of change feed subscribers dropped for falling behind.",
+		}),
+		maintenanceActive: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Name:      "maintenance_window_active",
+			Help:      "Whether a configured INWX maintenance window is in progress (1) or not (0).",
+		}),
+		maintenanceDeferredAppliesTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "maintenance_deferred_applies_total",
+			Help:      "Number of applies whose changes were deferred because an INWX maintenance window was in progress.",
+		}),
+		sessionReloginsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "session_relogins_total",
+			Help:      "Number of times an expired INWX session was logged in again to retry a call.",
+		}),
+		heldDeletionsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "held_deletions_total",
+			Help:      "Number of change sets held back for approval because they would delete more records than allowed.",
+		}),
+		staleRecordIDsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "stale_record_ids_total",
+			Help:      "Number of records deleted by their current ID after the ID read before had gone stale.",
+		}),
+		apiCallsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "api_calls_total",
+			Help:      "Number of INWX API calls, by method.",
+		}, []string{"method"}),
+		apiErrorsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "api_errors_total",
+			Help:      "Number of failed INWX API calls, by method and INWX result code, or \"transport\" for errors reaching the API.",
+		}, []string{"method", "code"}),
+		apiCallDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: MetricsNamespace,
+			Name:      "api_call_duration_seconds",
+			Help:      "Latency of INWX API calls, by method.",
+			Buckets:   prometheus.ExponentialBuckets(0.025, 2, 10),
+		}, []string{"method"}),
+		slowCallsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "slow_api_calls_total",
+			Help:      "Number of INWX API calls that exceeded the slow call threshold, by method.",
+		}, []string{"method"}),
+		clientCompatibility: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Name:      "client_compatibility",
+			Help:      "The external-dns instance last calling the webhook, by version, webhook protocol and compatibility status: supported, untested, unsupported or unknown.",
+		}, []string{"version", "protocol", "status"}),
+	}
+}
+
+// collectors returns the metrics as collectors to register.
+func (m *metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.changesTotal, m.skippedChangesTotal, m.duplicateAppliesTotal, m.operationDuration, m.staleRecordsServedTotal, m.recordsStale, m.staleZoneReadsTotal, m.emptyRecordsRejectedTotal, m.manualChangesTotal, m.auditWriteErrorsTotal, m.adjustedEndpointsTotal, m.notificationErrorsTotal, m.zoneRecords, m.duplicateOwnershipRecords, m.emptyZones, m.changeFeedDropsTotal, m.maintenanceActive, m.maintenanceDeferredAppliesTotal, m.sessionReloginsTotal, m.heldDeletionsTotal, m.staleRecordIDsTotal, m.apiCallsTotal, m.apiErrorsTotal, m.apiCallDuration, m.slowCallsTotal, m.clientCompatibility}
+}
+
+// Collectors returns the metrics collectors bound to this provider instance. Register them with a
+// registerer adding a tenant label, e.g. by prometheus.WrapRegistererWith, to serve several providers.
+func (p *INWXProvider) Collectors() []prometheus.Collector {
+	return append(p.metrics.collectors(), flapCollector{tracker: p.flaps, clock: p.config.clock}, healthCollector{provider: p}, cacheCollector{provider: p}, cacheAgeCollector{provider: p})
+}
+
+// resultLabel maps an error to the value of the "result" label.
+func resultLabel(err error) string {
+	if isWarning(err) {
+		return "warning"
+	}
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// observeOperation records the duration and result of a provider operation started at start, linked to
+// the trace of ctx.
+func (p *INWXProvider) observeOperation(ctx context.Context, operation string, start time.Time, err error) {
+	observe(ctx, p.metrics.operationDuration.WithLabelValues(operation, resultLabel(err)), time.Since(start).Seconds())
+}
+
+var cacheEntriesDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(MetricsNamespace, "", "cache_entries"),
+	"Number of entries held in memory, by cache: records and last_known_good (records), zones, flaps (records with changes tracked), apply_dedup (change sets), history (operations) and passthrough (endpoints with fields INWX doesn't store).",
+	[]string{"cache"}, nil)
+
+// cacheCollector exports the sizes of the in-memory caches and trackers at scrape time, to relate the
+// memory use of the webhook to what it holds.
+type cacheCollector struct {
+	provider *INWXProvider
+}
+
+func (c cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheEntriesDesc
+}
+
+func (c cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	p := c.provider
+	state := p.CacheState()
+	sizes := map[string]int{
+		"records":         0,
+		"last_known_good": 0,
+		"zones":           state.Zones.Zones,
+		"flaps":           p.flaps.len(),
+		"apply_dedup":     p.applies.len(),
+		"history":         p.history.len(),
+		"passthrough":     p.passthrough.len(),
+	}
+	if state.Records.Cached != nil {
+		sizes["records"] = state.Records.Cached.Records
+	}
+	if state.Records.LastKnownGood != nil {
+		sizes["last_known_good"] = state.Records.LastKnownGood.Records
+	}
+	for cache, n := range sizes {
+		ch <- prometheus.MustNewConstMetric(cacheEntriesDesc, prometheus.GaugeValue, float64(n), cache)
+	}
+}
+
+var cacheAgeDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(MetricsNamespace, "", "cache_age_seconds"),
+	"Age of the records of a zone served last, by zone; it keeps growing while cached or last known-good records are served instead of current ones.",
+	[]string{"zone"}, nil)
+
+// cacheAgeCollector exports the age of the records served last per zone at scrape time, so that serving
+// old records, e.g. with --stale-records-max-age while INWX fails, can be alerted on.
+type cacheAgeCollector struct {
+	provider *INWXProvider
+}
+
+func (c cacheAgeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheAgeDesc
+}
+
+func (c cacheAgeCollector) Collect(ch chan<- prometheus.Metric) {
+	for zone, age := range c.provider.records.servedAges(now(c.provider.config.clock)) {
+		ch <- prometheus.MustNewConstMetric(cacheAgeDesc, prometheus.GaugeValue, age.Seconds(), zone)
+	}
+}
@@ -0,0 +1,107 @@
+package inwx
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// ParseZoneFile reads RFC 1035 zone file records into endpoints, grouping records sharing a name and type.
+// SOA records and the NS records of origin are managed by INWX itself and returned as skipped, as are
+// record types that can't be represented as endpoint targets.
+func ParseZoneFile(r io.Reader, origin string) (endpoints []*endpoint.Endpoint, skipped []string, err error) {
+	origin = strings.TrimSuffix(strings.ToLower(origin), ".")
+	zp := dns.NewZoneParser(r, dns.Fqdn(origin), "")
+
+	byKey := map[string]*endpoint.Endpoint{}
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		hdr := rr.Header()
+		name := strings.TrimSuffix(strings.ToLower(hdr.Name), ".")
+		recordType := dns.TypeToString[hdr.Rrtype]
+
+		target, supported := zoneFileTarget(rr)
+		if !supported || hdr.Rrtype == dns.TypeSOA || (hdr.Rrtype == dns.TypeNS && name == origin) {
+			skipped = append(skipped, strings.ReplaceAll(rr.String(), "\t", " "))
+			continue
+		}
+
+		key := name + " " + recordType
+		if ep, ok := byKey[key]; ok {
+			ep.Targets = append(ep.Targets, target)
+			continue
+		}
+		ep := endpoint.NewEndpointWithTTL(name, recordType, endpoint.TTL(hdr.Ttl), target)
+		byKey[key] = ep
+		endpoints = append(endpoints, ep)
+	}
+	if err := zp.Err(); err != nil {
+		return nil, nil, fmt.Errorf("unable to parse zone file: %w", err)
+	}
+	return endpoints, skipped, nil
+}
+
+// zoneFileTarget returns the endpoint target for a zone file record in the format external-dns uses.
+func zoneFileTarget(rr dns.RR) (string, bool) {
+	switch rr := rr.(type) {
+	case *dns.A:
+		return rr.A.String(), true
+	case *dns.AAAA:
+		return rr.AAAA.String(), true
+	case *dns.CNAME:
+		return strings.TrimSuffix(rr.Target, "."), true
+	case *dns.NS:
+		return strings.TrimSuffix(rr.Ns, "."), true
+	case *dns.PTR:
+		return strings.TrimSuffix(rr.Ptr, "."), true
+	case *dns.MX:
+		return fmt.Sprintf("%d %s", rr.Preference, strings.TrimSuffix(rr.Mx, ".")), true
+	case *dns.SRV:
+		return fmt.Sprintf("%d %d %d %s", rr.Priority, rr.Weight, rr.Port, strings.TrimSuffix(rr.Target, ".")), true
+	case *dns.TXT:
+		return strings.Join(rr.Txt, ""), true
+	case *dns.CAA:
+		return fmt.Sprintf("%d %s %q", rr.Flag, rr.Tag, rr.Value), true
+	case *dns.SOA:
+		return "", true
+	}
+	return "", false
+}
+
+// MigrationPlan returns the changes importing desired into current: endpoints missing from current are
+// created and endpoints whose targets differ are updated. Nothing is ever deleted.
+func MigrationPlan(current []*endpoint.Endpoint, desired []*endpoint.Endpoint) *plan.Changes {
+	existing := map[string]*endpoint.Endpoint{}
+	for _, ep := range current {
+		key := strings.ToLower(ep.DNSName) + " " + ep.RecordType
+		if prev, ok := existing[key]; ok {
+			prev.Targets = append(prev.Targets, ep.Targets...)
+			continue
+		}
+		existing[key] = endpoint.NewEndpointWithTTL(ep.DNSName, ep.RecordType, ep.RecordTTL, ep.Targets...)
+	}
+
+	changes := &plan.Changes{}
+	for _, ep := range desired {
+		old, ok := existing[strings.ToLower(ep.DNSName)+" "+ep.RecordType]
+		switch {
+		case !ok:
+			changes.Create = append(changes.Create, ep)
+		case !sameTargets(old.Targets, ep.Targets):
+			changes.UpdateOld = append(changes.UpdateOld, old)
+			changes.UpdateNew = append(changes.UpdateNew, ep)
+		}
+	}
+	return changes
+}
+
+func sameTargets(a endpoint.Targets, b endpoint.Targets) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
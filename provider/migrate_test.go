@@ -0,0 +1,82 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+const testZoneFile = `$ORIGIN example.com.
+$TTL 3600
+@       IN SOA  ns1.cloudflare.com. dns.cloudflare.com. 1 10000 2400 604800 3600
+@       IN NS   ns1.cloudflare.com.
+@       IN A    1.1.1.1
+@       IN MX   10 mail.example.com.
+www 300 IN A    2.2.2.2
+www 300 IN A    3.3.3.3
+api     IN CNAME lb.example.net.
+@       IN TXT  "v=spf1 " "-all"
+sub     IN NS   ns.sub.example.com.
+key     IN DNSKEY 256 3 8 AwEAAaetidLzsKWUt4swWR8yu0wPHPiUi8LUsAD0QPWU+wzt89epO6tH zkMBVDkC7qphQO2hTY4hHn9npWFRw5BYubE=
+`
+
+func TestMigrate(t *testing.T) {
+	t.Run("ParseZoneFile", testParseZoneFile)
+	t.Run("Plan", testMigrationPlan)
+}
+
+func testParseZoneFile(t *testing.T) {
+	eps, skipped, err := ParseZoneFile(strings.NewReader(testZoneFile), "example.com")
+	require.NoError(t, err)
+
+	got := map[string]*endpoint.Endpoint{}
+	for _, ep := range eps {
+		got[ep.DNSName+" "+ep.RecordType] = ep
+	}
+	assert.Len(t, got, 6)
+	assert.Equal(t, endpoint.Targets{"1.1.1.1"}, got["example.com A"].Targets)
+	assert.Equal(t, endpoint.TTL(3600), got["example.com A"].RecordTTL)
+	assert.Equal(t, endpoint.Targets{"10 mail.example.com"}, got["example.com MX"].Targets)
+	assert.Equal(t, endpoint.Targets{"2.2.2.2", "3.3.3.3"}, got["www.example.com A"].Targets)
+	assert.Equal(t, endpoint.TTL(300), got["www.example.com A"].RecordTTL)
+	assert.Equal(t, endpoint.Targets{"lb.example.net"}, got["api.example.com CNAME"].Targets)
+	assert.Equal(t, endpoint.Targets{"v=spf1 -all"}, got["example.com TXT"].Targets)
+	assert.Equal(t, endpoint.Targets{"ns.sub.example.com"}, got["sub.example.com NS"].Targets)
+
+	// SOA, apex NS and unsupported types are left out
+	assert.Len(t, skipped, 3)
+
+	_, _, err = ParseZoneFile(strings.NewReader("www IN A not-an-ip\n"), "example.com")
+	assert.Error(t, err)
+}
+
+func testMigrationPlan(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	p.config.allowApexChanges = true
+	_ = w.createRecord(&recordRequest{Domain: "example.com", Name: "www", Type: "A", Content: "2.2.2.2", TTL: 300})
+	_ = w.createRecord(&recordRequest{Domain: "example.com", Name: "api", Type: "CNAME", Content: "old.example.net", TTL: 300})
+	_ = w.createRecord(&recordRequest{Domain: "example.com", Name: "keep", Type: "A", Content: "4.4.4.4", TTL: 300})
+
+	desired, _, err := ParseZoneFile(strings.NewReader(testZoneFile), "example.com")
+	require.NoError(t, err)
+	current, err := p.Records(context.TODO())
+	require.NoError(t, err)
+
+	changes := MigrationPlan(current, desired)
+	assert.Len(t, changes.Create, 4)
+	require.Len(t, changes.UpdateNew, 2)
+	assert.Empty(t, changes.Delete)
+
+	require.NoError(t, p.ApplyChanges(context.TODO(), changes))
+	current, err = p.Records(context.TODO())
+	require.NoError(t, err)
+	assert.False(t, MigrationPlan(current, desired).HasChanges())
+	recs, _ := w.getRecords("example.com")
+	assert.Len(t, *recs, 8)
+}
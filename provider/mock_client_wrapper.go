@@ -5,36 +5,56 @@ import (
 	"maps"
 	"slices"
 	"strconv"
-
-	inwx "github.com/nrdcg/goinwx"
+	"strings"
+	"time"
 )
 
 type MockClientWrapper struct {
-	db       map[string]*[]inwx.NameserverRecord
+	db       map[string]*[]zoneRecord
 	idToZone map[string]string
+	// loginErr, if set, is returned by login to simulate invalid credentials or an INWX outage.
+	loginErr error
+	// logins counts the calls of login.
+	logins int
+	// account, if set, is the account logged in to.
+	account *Account
+	// zonesErr, if set, is returned by getZones to simulate a failing INWX API.
+	zonesErr error
+	// createErr, if set, is called by createRecord to simulate failing creates.
+	createErr func(*recordRequest) error
+	// deleteErr, if set, is called by deleteRecord to simulate failing deletes.
+	deleteErr func(recID string) error
+	// createdZones are the requests of the zones created through createZone.
+	createdZones []zoneRequest
+}
+
+// newInMemoryClient returns a MockClientWrapper holding the empty zones, which serves the INWX API from
+// memory for local development.
+func newInMemoryClient(zones []string) *MockClientWrapper {
+	w := &MockClientWrapper{db: map[string]*[]zoneRecord{}, idToZone: map[string]string{}}
+	for _, zone := range zones {
+		w.db[zone] = &[]zoneRecord{}
+	}
+	return w
 }
 
-func (w *MockClientWrapper) login() (*inwx.LoginResponse, error) {
-	return &inwx.LoginResponse{
-		CustomerID: 1000,
-		AccountID:  1000,
-		TFA:        "",
-		BuildDate:  "2000-01-01 00:00:00",
-		Version:    "0.0.0",
-	}, nil
+func (w *MockClientWrapper) login() error {
+	w.logins++
+	return w.loginErr
 }
 
 func (w *MockClientWrapper) logout() error {
 	return nil
 }
 
-func (w *MockClientWrapper) getRecords(domain string) (*[]inwx.NameserverRecord, error) {
+func (w *MockClientWrapper) getRecords(domain string) (*[]zoneRecord, error) {
 	if recs, ok := w.db[domain]; !ok {
 		return nil, fmt.Errorf("unable to retrieve records for zone %s: key not found in mock db", domain)
 	} else {
-		undeletedRecs := []inwx.NameserverRecord{}
+		undeletedRecs := []zoneRecord{}
 		for _, rec := range *recs {
 			if rec.ID != "" {
+				rec.Content = endpointContent(rec)
 				undeletedRecs = append(undeletedRecs, rec)
 			}
 		}
@@ -43,22 +63,80 @@ func (w *MockClientWrapper) getRecords(domain string) (*[]inwx.NameserverRecord,
 }
 
 func (w *MockClientWrapper) getZones() (*[]string, error) {
+	if w.zonesErr != nil {
+		return nil, w.zonesErr
+	}
 	zones := slices.Collect(maps.Keys(w.db))
 	return &zones, nil
 }
 
-func (w *MockClientWrapper) createRecord(r *inwx.NameserverRecordRequest) error {
+func (w *MockClientWrapper) forgetZones() {}
+
+func (w *MockClientWrapper) cachedZones() (int, time.Time, bool) {
+	return 0, time.Time{}, false
+}
+
+func (w *MockClientWrapper) setCredentials(Credentials) {}
+
+func (w *MockClientWrapper) loggedInAccount() (Account, bool) {
+	if w.account != nil {
+		return *w.account, true
+	}
+	return Account{}, false
+}
+
+func (w *MockClientWrapper) checkSession() error {
+	return nil
+}
+
+// createZone creates the zone of r with the SOA and NS records INWX sets up for a new zone.
+func (w *MockClientWrapper) createZone(r *zoneRequest) error {
+	if _, ok := w.db[r.Domain]; ok {
+		return &apiError{Code: codeObjectExists, Message: "Object exists"}
+	}
+	w.createdZones = append(w.createdZones, *r)
+	w.db[r.Domain] = &[]zoneRecord{}
+	soaEmail := "hostmaster.inwx.de"
+	if r.SOAEmail != "" {
+		soaEmail = strings.Replace(r.SOAEmail, "@", ".", 1)
+	}
+	records := []zoneRecord{{Type: "SOA", Content: "ns.inwx.de " + soaEmail + " 2026101501 10800 3600 604800 3600", TTL: 86400}}
+	for _, ns := range r.Nameservers {
+		records = append(records, zoneRecord{Type: "NS", Content: ns, TTL: 86400})
+	}
+	for _, rec := range records {
+		id := strconv.Itoa(len(w.idToZone))
+		rec.ID = id
+		*w.db[r.Domain] = append(*w.db[r.Domain], rec)
+		w.idToZone[id] = r.Domain
+	}
+	return nil
+}
+
+func (w *MockClientWrapper) createRecord(r *recordRequest) error {
+	if w.createErr != nil {
+		if err := w.createErr(r); err != nil {
+			return err
+		}
+	}
+	// Records are stored the way INWX stores them.
+	r, err := inwxRecordRequest(r)
+	if err != nil {
+		return err
+	}
 	if recs, ok := w.db[r.Domain]; !ok {
 		return fmt.Errorf("zone %s not found", r.Domain)
 	} else {
-		id := strconv.Itoa(len(*recs))
-		newRecs := append(*recs, inwx.NameserverRecord{
-			ID:       id,
-			Name:     r.Name,
-			Type:     r.Type,
-			Content:  r.Content,
-			TTL:      r.TTL,
-			Priority: r.Priority,
+		// Record IDs are unique across zones, as in INWX.
+		id := strconv.Itoa(len(w.idToZone))
+		newRecs := append(*recs, zoneRecord{
+			ID:           id,
+			Name:         r.Name,
+			Type:         r.Type,
+			Content:      r.Content,
+			TTL:          r.TTL,
+			Priority:     r.Priority,
+			RedirectType: r.RedirectType,
 		})
 		w.idToZone[id] = r.Domain
 		w.db[r.Domain] = &newRecs
@@ -66,7 +144,7 @@ func (w *MockClientWrapper) createRecord(r *inwx.NameserverRecordRequest) error
 	}
 }
 
-func (w *MockClientWrapper) findRecord(recs *[]inwx.NameserverRecord, recID string) int {
+func (w *MockClientWrapper) findRecord(recs *[]zoneRecord, recID string) int {
 	for i, rec := range *recs {
 		if rec.ID == recID {
 			return i
@@ -75,7 +153,11 @@ func (w *MockClientWrapper) findRecord(recs *[]inwx.NameserverRecord, recID stri
 	return -1
 }
 
-func (w *MockClientWrapper) updateRecord(recID string, r *inwx.NameserverRecordRequest) error {
+func (w *MockClientWrapper) updateRecord(recID string, r *recordRequest) error {
+	r, err := inwxRecordRequest(r)
+	if err != nil {
+		return err
+	}
 	if recs, ok := w.db[r.Domain]; !ok {
 		return fmt.Errorf("zone %s not found", r.Domain)
 	} else {
@@ -83,31 +165,36 @@ func (w *MockClientWrapper) updateRecord(recID string, r *inwx.NameserverRecordR
 		if idx == -1 {
 			return fmt.Errorf("record ID %s not found", recID)
 		}
-		(*recs)[idx] = inwx.NameserverRecord{
-			ID:       recID,
-			Name:     r.Name,
-			Type:     r.Type,
-			Content:  r.Content,
-			TTL:      r.TTL,
-			Priority: r.Priority,
+		(*recs)[idx] = zoneRecord{
+			ID:           recID,
+			Name:         r.Name,
+			Type:         r.Type,
+			Content:      r.Content,
+			TTL:          r.TTL,
+			Priority:     r.Priority,
+			RedirectType: r.RedirectType,
 		}
 		return nil
 	}
 }
 
 func (w *MockClientWrapper) deleteRecord(recID string) error {
+	if w.deleteErr != nil {
+		if err := w.deleteErr(recID); err != nil {
+			return err
+		}
+	}
+	// INWX reports unknown and deleted record IDs alike.
+	notFound := &apiError{Code: codeObjectDoesNotExist, Message: "Object does not exist"}
 	if zone, ok := w.idToZone[recID]; !ok {
-		return fmt.Errorf("zone for record ID %s not found", recID)
+		return notFound
 	} else {
 		if recs, ok := w.db[zone]; !ok {
 			return fmt.Errorf("zone %s not found", zone)
 		} else {
 			idx := w.findRecord(recs, recID)
-			if idx == -1 {
-				return fmt.Errorf("record ID %s not found", recID)
-			}
-			if (*recs)[idx].ID == "" {
-				return fmt.Errorf("record ID %s has already been deleted", recID)
+			if idx == -1 || (*recs)[idx].ID == "" {
+				return notFound
 			}
 			(*recs)[idx].ID = ""
 			return nil
@@ -115,10 +202,12 @@ func (w *MockClientWrapper) deleteRecord(recID string) error {
 	}
 }
 
+func (w *MockClientWrapper) close() {}
+
 func (w *MockClientWrapper) CreateZone(zone string) {
 	if _, ok := w.db[zone]; ok {
 		panic(fmt.Errorf("zone %s already exists", zone))
 	} else {
-		w.db[zone] = &[]inwx.NameserverRecord{}
+		w.db[zone] = &[]zoneRecord{}
 	}
 }
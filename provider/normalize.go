@@ -0,0 +1,80 @@
+package inwx
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// normalizeEndpoint returns ep with its name and targets in the form INWX stores and Records reports them:
+// the name lower case without a trailing dot, addresses in their canonical form, e.g. IPv6 compressed,
+// host names of targets lower case without a trailing dot, and duplicate targets dropped. Otherwise
+// external-dns would plan to update the records of ep on every run.
+func (p *INWXProvider) normalizeEndpoint(ep *endpoint.Endpoint, adjust adjustFunc) *endpoint.Endpoint {
+	name := normalizeName(ep.DNSName)
+	targets := make(endpoint.Targets, 0, len(ep.Targets))
+	for _, target := range ep.Targets {
+		if redirectType(ep) == "" {
+			target = normalizeTarget(ep.RecordType, target)
+		}
+		if !slices.Contains(targets, target) {
+			targets = append(targets, target)
+		}
+	}
+	if name == ep.DNSName && slices.Equal(targets, ep.Targets) {
+		return ep
+	}
+	normalized := ep.DeepCopy()
+	normalized.DNSName, normalized.Targets = name, targets
+	adjust(normalized, adjustmentModified, "normalized", "%s %v normalized to %s %v", ep.DNSName, ep.Targets, name, targets)
+	return normalized
+}
+
+// normalizeTarget returns target of recordType in the form INWX stores it. Targets that don't parse are
+// returned as they are, to be flagged by validateTarget.
+func normalizeTarget(recordType string, target string) string {
+	host := func(s string) string { return strings.ToLower(strings.TrimSuffix(s, ".")) }
+	switch recordType {
+	case endpoint.RecordTypeA, endpoint.RecordTypeAAAA:
+		if addr, err := netip.ParseAddr(target); err == nil {
+			return addr.String()
+		}
+	case endpoint.RecordTypeCNAME, endpoint.RecordTypeNS, endpoint.RecordTypePTR:
+		return host(target)
+	case endpoint.RecordTypeMX, endpoint.RecordTypeSRV:
+		// The host name is the last field: "priority host" and "priority weight port host".
+		fields := strings.Fields(target)
+		if len(fields) == 2 && recordType == endpoint.RecordTypeMX || len(fields) == 4 && recordType == endpoint.RecordTypeSRV {
+			fields[len(fields)-1] = host(fields[len(fields)-1])
+			return strings.Join(fields, " ")
+		}
+	}
+	return target
+}
+
+// flagUnsupportedCombinations flags the endpoints INWX can't store side by side: CNAME endpoints with
+// several targets, and CNAME endpoints sharing their name with endpoints of other types, ownership records
+// aside. URL redirects, and CNAME endpoints that would be written as ALIAS records at the apex, aren't
+// CNAME records at INWX and are left alone. Flagged endpoints are passed on, as dropping them would make
+// external-dns delete their records.
+func (p *INWXProvider) flagUnsupportedCombinations(endpoints []*endpoint.Endpoint, adjust adjustFunc) {
+	types := map[string][]string{}
+	for _, ep := range endpoints {
+		if ep.RecordType != endpoint.RecordTypeTXT && !slices.Contains(types[ep.DNSName], ep.RecordType) {
+			types[ep.DNSName] = append(types[ep.DNSName], ep.RecordType)
+		}
+	}
+	for _, ep := range endpoints {
+		if ep.RecordType != endpoint.RecordTypeCNAME || p.inwxRecordType(ep, "") != endpoint.RecordTypeCNAME {
+			continue
+		}
+		if len(ep.Targets) > 1 {
+			adjust(ep, adjustmentFlagged, "unsupported_combination", "a CNAME record can't have several targets: %v", ep.Targets)
+		}
+		if others := slices.DeleteFunc(slices.Clone(types[ep.DNSName]), func(t string) bool { return t == endpoint.RecordTypeCNAME }); len(others) > 0 {
+			adjust(ep, adjustmentFlagged, "unsupported_combination", "a CNAME record can't coexist with the %s records of its name", strings.Join(others, ", "))
+		}
+	}
+}
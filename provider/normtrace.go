@@ -0,0 +1,35 @@
+package inwx
+
+import (
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// traceNormalization logs a step of turning an endpoint into an INWX record, or an INWX record into an
+// endpoint, if normalization tracing is enabled.
+func (p *INWXProvider) traceNormalization(step string, args ...any) {
+	if !p.config.traceNormalization {
+		return
+	}
+	p.logger.Info("normalization", append([]any{"step", step}, args...)...)
+}
+
+// traceTXT traces the TXT targets normalizeTXT turned original into normalized with.
+func (p *INWXProvider) traceTXT(original *plan.Changes, normalized *plan.Changes) {
+	if !p.config.traceNormalization {
+		return
+	}
+	trace := func(original []*endpoint.Endpoint, normalized []*endpoint.Endpoint) {
+		for i, ep := range original {
+			for j, target := range ep.Targets {
+				if unquoted := normalized[i].Targets[j]; unquoted != target {
+					p.traceNormalization("txt_unquote", "dns_name", ep.DNSName, "target", target, "content", unquoted)
+				}
+			}
+		}
+	}
+	trace(original.Create, normalized.Create)
+	trace(original.UpdateOld, normalized.UpdateOld)
+	trace(original.UpdateNew, normalized.UpdateNew)
+	trace(original.Delete, normalized.Delete)
+}
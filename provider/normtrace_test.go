@@ -0,0 +1,46 @@
+package inwx
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestNormalizationTrace(t *testing.T) {
+	var logs bytes.Buffer
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.New(slog.NewTextHandler(&logs, nil)))
+	w.CreateZone("example.com")
+	ttl := 600
+	p.config.zoneConfig = &ZoneConfig{Defaults: ZoneSettings{TTL: &ttl}}
+
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.com", "TXT", `"v=spf1 -all"`)},
+	}))
+	assert.NotContains(t, logs.String(), "normalization")
+
+	p.config.traceNormalization = true
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("bar.example.com", "TXT", `"v=spf1 -all"`)},
+	}))
+	for _, line := range []string{
+		`step=txt_unquote dns_name=bar.example.com target="\"v=spf1 -all\"" content="v=spf1 -all"`,
+		`step=zone_match dns_name=bar.example.com type=TXT zone=example.com by=suffix`,
+		`step=record_name dns_name=bar.example.com type=TXT zone=example.com record_name=bar`,
+		`step=ttl domain=example.com name=bar type=TXT ttl=600 source=zone_default`,
+		`step=payload action=create domain=example.com name=bar type=TXT ttl=600 content="v=spf1 -all"`,
+	} {
+		assert.Contains(t, logs.String(), line)
+	}
+
+	logs.Reset()
+	require.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "baz", Type: "A", Content: "1.1.1.1"}))
+	_, err := p.Records(context.TODO())
+	require.NoError(t, err)
+	assert.Contains(t, logs.String(), `step=endpoint_name zone=example.com record_name=baz type=A dns_name=baz.example.com`)
+}
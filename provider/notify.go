@@ -0,0 +1,137 @@
+package inwx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// notifyTimeout bounds a failure notification, which is sent before the webhook request is answered.
+const notifyTimeout = 10 * time.Second
+
+// notifyMaxErrors is the number of failed changes listed in a notification.
+const notifyMaxErrors = 10
+
+// NotifyFormat is a payload format failure notifications are posted in.
+type NotifyFormat string
+
+const (
+	// NotifyGeneric posts the report of the failed apply as JSON.
+	NotifyGeneric NotifyFormat = "generic"
+	// NotifySlack posts a message for a Slack incoming webhook, or a compatible one such as Mattermost's.
+	NotifySlack NotifyFormat = "slack"
+)
+
+// ParseNotifyFormat returns the NotifyFormat named s.
+func ParseNotifyFormat(s string) (NotifyFormat, error) {
+	switch f := NotifyFormat(s); f {
+	case NotifyGeneric, NotifySlack:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown notification format %q, expected generic or slack", s)
+	}
+}
+
+// Notifier is told about every ApplyChanges that failed, with its report.
+type Notifier interface {
+	Notify(ctx context.Context, report Report) error
+}
+
+// WebhookNotifier posts failure notifications to an HTTP endpoint.
+type WebhookNotifier struct {
+	url    string
+	format NotifyFormat
+	client *http.Client
+}
+
+// NewWebhookNotifier returns a WebhookNotifier posting to rawURL in format. A nil client means
+// http.DefaultClient.
+func NewWebhookNotifier(rawURL string, format NotifyFormat, client *http.Client) (*WebhookNotifier, error) {
+	if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid notification URL %q, expected an http or https URL", rawURL)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &WebhookNotifier{url: rawURL, format: format, client: client}, nil
+}
+
+// Notify posts report in the format of the notifier.
+func (n *WebhookNotifier) Notify(ctx context.Context, report Report) error {
+	var payload any = report
+	if n.format == NotifySlack {
+		payload = map[string]string{"text": report.slackText()}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to send notification: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unable to send notification: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// slackText summarizes a failed apply in Slack mrkdwn: the counts, the error and the first failed changes.
+func (r Report) slackText() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, ":warning: *external-dns-inwx: applying DNS changes failed* at %s\n", r.Time)
+	fmt.Fprintf(&b, "%d created, %d updated, %d deleted, %d skipped, %d warnings, %d errors\n", r.Summary.Created,
+		r.Summary.Updated, r.Summary.Deleted, r.Summary.Skipped, r.Summary.Warnings, r.Summary.Errors)
+	if r.Error != "" {
+		fmt.Fprintf(&b, "> %s\n", strings.ReplaceAll(r.Error, "\n", " "))
+	}
+	listed := 0
+	for _, c := range r.Changes {
+		if c.Error == "" || c.Severity == "warning" {
+			continue
+		}
+		if listed == notifyMaxErrors {
+			fmt.Fprintf(&b, "• … and %d more\n", r.Summary.Errors-listed)
+			break
+		}
+		name := c.Name
+		if name == "" {
+			name = "@"
+		}
+		fmt.Fprintf(&b, "• %s %s %s in %s `%s`: %s\n", c.Action, name, c.Type, c.Zone, strings.ReplaceAll(c.Content, "`", "'"), c.Error)
+		listed++
+	}
+	fmt.Fprintf(&b, "Plan %s", r.PlanHash)
+	if r.TraceID != "" {
+		fmt.Fprintf(&b, ", trace %s", r.TraceID)
+	}
+	return b.String()
+}
+
+// notifyFailure tells the notifier about an ApplyChanges that failed with err. Like the audit log, a
+// failing notification is only logged and counted.
+func (p *INWXProvider) notifyFailure(ctx context.Context, recorder *ChangeRecorder, hash string, start time.Time, err error) {
+	if p.config.notifier == nil || err == nil {
+		return
+	}
+	report := newReport(recorder.Changes(), hash, TraceID(ctx), p.timestamp(start), now(p.config.clock), err)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), notifyTimeout)
+	defer cancel()
+	if err := p.config.notifier.Notify(ctx, report); err != nil {
+		p.metrics.notificationErrorsTotal.Inc()
+		p.logger.Error("failed to send failure notification", "err", err)
+	}
+}
@@ -0,0 +1,100 @@
+package inwx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestNotify(t *testing.T) {
+	t.Run("Generic", testNotifyGeneric)
+	t.Run("Slack", testNotifySlack)
+	t.Run("Failing", testNotifyFailing)
+}
+
+// notifyServer returns a server collecting the bodies posted to it.
+func notifyServer(t *testing.T, status int) (*httptest.Server, *[][]byte) {
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, body)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &bodies
+}
+
+func testNotifyGeneric(t *testing.T) {
+	server, bodies := notifyServer(t, http.StatusOK)
+	notifier, err := NewWebhookNotifier(server.URL, NotifyGeneric, nil)
+	require.NoError(t, err)
+
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	p.config.notifier = notifier
+	p.config.clock = &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	w.CreateZone("example.com")
+
+	// Successful applies aren't notified
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("foo.example.com", "A", "1.1.1.1"),
+	}}))
+	assert.Empty(t, *bodies)
+
+	w.createErr = func(*recordRequest) error { return errors.New("quota exceeded") }
+	require.Error(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("bar.example.com", "A", "2.2.2.2"),
+	}}))
+	require.Len(t, *bodies, 1)
+	var report Report
+	require.NoError(t, json.Unmarshal((*bodies)[0], &report))
+	assert.Equal(t, "failed", report.Result)
+	assert.Equal(t, 1, report.Summary.Errors)
+	require.Len(t, report.Changes, 1)
+	assert.Equal(t, "bar", report.Changes[0].Name)
+	assert.Equal(t, "quota exceeded", report.Changes[0].Error)
+}
+
+func testNotifySlack(t *testing.T) {
+	server, bodies := notifyServer(t, http.StatusOK)
+	notifier, err := NewWebhookNotifier(server.URL, NotifySlack, nil)
+	require.NoError(t, err)
+
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	p.config.notifier = notifier
+	w.CreateZone("example.com")
+	w.createErr = func(*recordRequest) error { return errors.New("quota exceeded") }
+	require.Error(t, p.ApplyChanges(WithTraceID(context.TODO(), "4bf92f3577b34da6a3ce929d0e0e4736"), &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("www.example.com", "A", "2.2.2.2"),
+	}}))
+	require.Len(t, *bodies, 1)
+	var message map[string]string
+	require.NoError(t, json.Unmarshal((*bodies)[0], &message))
+	assert.Contains(t, message["text"], "applying DNS changes failed")
+	assert.Contains(t, message["text"], "0 created, 0 updated, 0 deleted, 0 skipped, 0 warnings, 1 errors")
+	assert.Contains(t, message["text"], "• create www A in example.com `2.2.2.2`: quota exceeded")
+	assert.Contains(t, message["text"], "trace 4bf92f3577b34da6a3ce929d0e0e4736")
+}
+
+func testNotifyFailing(t *testing.T) {
+	_, err := NewWebhookNotifier("hooks.slack.com/services/T0/B0/x", NotifySlack, nil)
+	assert.Error(t, err)
+
+	server, _ := notifyServer(t, http.StatusForbidden)
+	notifier, err := NewWebhookNotifier(server.URL, NotifyGeneric, nil)
+	require.NoError(t, err)
+	err = notifier.Notify(context.TODO(), Report{Result: "failed"})
+	assert.ErrorContains(t, err, "403 Forbidden")
+}
@@ -0,0 +1,589 @@
+package inwx
+
+import (
+	"crypto/x509"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// config holds the optional provider settings; see the With* options.
+type config struct {
+	slowCallThreshold time.Duration
+	ignoreLabels      map[string]string
+	ignoreProperties  map[string]string
+	filterRules       []FilterRule
+	namePrefixes      []string
+	nameSuffixes      []string
+	excludeDomains    []string
+	zoneConfig        *ZoneConfig
+	defaultTTL        int
+	minTTL            int
+	maxTTL            int
+	progressThreshold int
+	progressInterval  time.Duration
+	allowApexChanges  bool
+	recordLimit       int
+	blockOverLimit    bool
+	freezeRecord      string
+	flapWindow        time.Duration
+	flapThreshold     int
+
+	duplicateApplyWindow time.Duration
+
+	apiClient        APIClient
+	apiURL           string
+	sessionScope     SessionScope
+	sessionKeepalive time.Duration
+	totpSecret       TOTPSecret
+	maxIdleConns     int
+	idleConnTimeout  time.Duration
+	userAgent        string
+	proxy            *url.URL
+	transport        http.RoundTripper
+	rootCAs          *x509.CertPool
+	minTLSVersion    uint16
+
+	recordsCacheTTL    time.Duration
+	staleRecordsMaxAge time.Duration
+
+	emptyRecordsThreshold int
+
+	maxDeletes int
+
+	features *Features
+
+	registry        Registry
+	atomicOwnership bool
+	createFirst     bool
+
+	createPTR bool
+
+	createMissingZones bool
+
+	clock Clock
+	rand  *rand.Rand
+
+	inMemoryZones []string
+
+	auditStore AuditStore
+	notifier   Notifier
+	admission  AdmissionPolicy
+
+	emptyZonesAfter time.Duration
+
+	reportDir     string
+	reportFormats []ReportFormat
+
+	traceNormalization bool
+
+	maintenanceWindows []MaintenanceWindow
+
+	collisionStrategy CollisionStrategy
+
+	duplicateOwnership DuplicateOwnershipPolicy
+	txtOwnerID         string
+	ownerMigration     *ownerMigration
+
+	expiryInterval time.Duration
+
+	apexAlias bool
+
+	historySize int
+
+	sessionCheckInterval time.Duration
+
+	expectedAccountID   int
+	expectedEnvironment string
+
+	timestampLayout   string
+	timestampLocation *time.Location
+}
+
+func defaultConfig() config {
+	return config{
+		ignoreProperties: DefaultIgnoreProperties,
+		freezeRecord:     DefaultFreezeRecord,
+		flapWindow:       time.Hour,
+		flapThreshold:    5,
+
+		duplicateApplyWindow: 30 * time.Second,
+
+		emptyRecordsThreshold: 10,
+
+		apiClient:        APIClientGoinwx,
+		sessionScope:     SessionPersistent,
+		sessionKeepalive: 5 * time.Minute,
+		maxIdleConns:     4,
+		idleConnTimeout:  90 * time.Second,
+
+		registry: LegacyRegistry{},
+
+		clock: SystemClock{},
+
+		historySize: 100,
+
+		sessionCheckInterval: time.Minute,
+
+		collisionStrategy: CollisionMerge,
+
+		duplicateOwnership: DuplicateOwnershipWarn,
+	}
+}
+
+// Option configures optional provider behaviour.
+type Option func(*config)
+
+// WithSlowCallThreshold logs and counts every INWX API call that takes at least d.
+// A zero duration disables slow call reporting.
+func WithSlowCallThreshold(d time.Duration) Option {
+	return func(c *config) {
+		c.slowCallThreshold = d
+	}
+}
+
+// WithIgnoreLabels exempts endpoints carrying any of the given label key/value pairs from INWX management.
+func WithIgnoreLabels(labels map[string]string) Option {
+	return func(c *config) {
+		c.ignoreLabels = labels
+	}
+}
+
+// WithIgnoreProperties exempts endpoints carrying any of the given provider-specific property name/value
+// pairs from INWX management, replacing DefaultIgnoreProperties.
+func WithIgnoreProperties(properties map[string]string) Option {
+	return func(c *config) {
+		c.ignoreProperties = properties
+	}
+}
+
+// WithFilterRules adds ordered include and exclude rules deciding which DNS names the provider manages,
+// evaluated after the domains of the domain filter. The last rule matching a name decides.
+func WithFilterRules(rules ...FilterRule) Option {
+	return func(c *config) {
+		c.filterRules = append(c.filterRules, rules...)
+	}
+}
+
+// WithExcludeDomains excludes zones and every name below domains, even if an included parent domain
+// or filter rule matches them: excluded zones are never read, and changes to their names are dropped.
+func WithExcludeDomains(domains ...string) Option {
+	return func(c *config) {
+		c.excludeDomains = append(c.excludeDomains, domains...)
+	}
+}
+
+// WithNameAffixes restricts the DNS names the provider manages to those starting with one of prefixes and
+// ending in one of suffixes, e.g. ".apps.example.com" in a shared zone. Other records are neither listed
+// nor changed, and zones that can't hold such names aren't read at all. Empty lists match every name.
+func WithNameAffixes(prefixes []string, suffixes []string) Option {
+	return func(c *config) {
+		c.namePrefixes = append(c.namePrefixes, prefixes...)
+		c.nameSuffixes = append(c.nameSuffixes, suffixes...)
+	}
+}
+
+// WithZoneConfig applies global and per-zone settings such as default TTL, policy, rate limits,
+// protected names and dry-run.
+func WithZoneConfig(zoneConfig *ZoneConfig) Option {
+	return func(c *config) {
+		c.zoneConfig = zoneConfig
+	}
+}
+
+// WithDefaultTTL sets the TTL of the records of endpoints without a TTL in every zone that doesn't
+// override it in the zone config; 0 leaves it to INWX.
+func WithDefaultTTL(ttl int) Option {
+	return func(c *config) {
+		c.defaultTTL = ttl
+	}
+}
+
+// WithTTLRange clamps the TTLs of the endpoints to [minTTL, maxTTL] when external-dns adjusts them, so that
+// it doesn't plan to update records whose TTL INWX raised to its minimum on every run; 0 disables a bound.
+func WithTTLRange(minTTL int, maxTTL int) Option {
+	return func(c *config) {
+		c.minTTL = minTTL
+		c.maxTTL = maxTTL
+	}
+}
+
+// WithProgressLogging logs the progress of applies of at least threshold mutations every interval, with
+// the creates of identical content across names reported as batches; a threshold of 0 disables it.
+func WithProgressLogging(threshold int, interval time.Duration) Option {
+	return func(c *config) {
+		c.progressThreshold = threshold
+		c.progressInterval = interval
+	}
+}
+
+// WithAllowApexChanges permits A, AAAA and TXT mutations at the apex of every zone that doesn't
+// override it in the zone config.
+func WithAllowApexChanges(allow bool) Option {
+	return func(c *config) {
+		c.allowApexChanges = allow
+	}
+}
+
+// WithRecordLimit sets the number of records every zone that doesn't override it in the zone config may
+// hold; 0 means unlimited. Changes bringing a zone close to or over the limit are logged; with block, the
+// creates of changes exceeding it are skipped.
+func WithRecordLimit(limit int, block bool) Option {
+	return func(c *config) {
+		c.recordLimit = limit
+		c.blockOverLimit = block
+	}
+}
+
+// WithFreezeRecord sets the name, relative to the zone, of the TXT record whose presence freezes a zone,
+// replacing DefaultFreezeRecord. An empty name disables freezing zones through a record.
+func WithFreezeRecord(name string) Option {
+	return func(c *config) {
+		c.freezeRecord = name
+	}
+}
+
+// WithFlapDetection tracks how often each record changes within window and reports records changing
+// at least threshold times as flapping. A zero window disables tracking.
+func WithFlapDetection(window time.Duration, threshold int) Option {
+	return func(c *config) {
+		c.flapWindow = window
+		c.flapThreshold = threshold
+	}
+}
+
+// WithDuplicateApplyWindow skips change sets identical to one that was applied successfully within d.
+// A zero duration disables the check.
+func WithDuplicateApplyWindow(d time.Duration) Option {
+	return func(c *config) {
+		c.duplicateApplyWindow = d
+	}
+}
+
+// WithConnectionPool keeps up to maxIdleConns idle connections to the INWX API open for up to
+// idleConnTimeout, so that consecutive API calls reuse them instead of re-handshaking.
+func WithConnectionPool(maxIdleConns int, idleConnTimeout time.Duration) Option {
+	return func(c *config) {
+		c.maxIdleConns = maxIdleConns
+		c.idleConnTimeout = idleConnTimeout
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every INWX API call, so that API-side logs can be
+// correlated with a specific build.
+func WithUserAgent(userAgent string) Option {
+	return func(c *config) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithProxy sends the INWX API calls through proxy instead of the proxy of the HTTPS_PROXY and NO_PROXY
+// environment variables.
+func WithProxy(proxy *url.URL) Option {
+	return func(c *config) {
+		c.proxy = proxy
+	}
+}
+
+// WithTLS verifies the INWX API, or a TLS-intercepting proxy in front of it, against rootCAs instead of the
+// system certificates, and requires at least minVersion, e.g. tls.VersionTLS13. A nil pool keeps the system
+// certificates; TLS 1.2 is always required.
+func WithTLS(rootCAs *x509.CertPool, minVersion uint16) Option {
+	return func(c *config) {
+		c.rootCAs = rootCAs
+		c.minTLSVersion = minVersion
+	}
+}
+
+// WithTransport sends the INWX API calls through transport instead of the built-in pooled one, e.g. to
+// reach INWX through an authenticating proxy or a custom TLS setup. The connection pool and proxy settings
+// don't apply to it; the User-Agent is still set.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *config) {
+		c.transport = transport
+	}
+}
+
+// WithRecordsCacheTTL serves the records read from INWX for up to d from memory, including while
+// changes are being applied. Applied changes are patched into the cached records; a failed apply drops
+// them. A zero duration disables the cache.
+func WithRecordsCacheTTL(d time.Duration) Option {
+	return func(c *config) {
+		c.recordsCacheTTL = d
+	}
+}
+
+// WithStaleRecordsFallback serves the last records read successfully, if they are at most maxAge old,
+// when listing the records fails, instead of an error that makes external-dns treat every record as
+// missing. A zero duration disables the fallback.
+func WithStaleRecordsFallback(maxAge time.Duration) Option {
+	return func(c *config) {
+		c.staleRecordsMaxAge = maxAge
+	}
+}
+
+// WithEmptyRecordsGuard rejects a read returning no records at all after at least threshold records were
+// read before, since that rather points to an API anomaly and would make external-dns plan to recreate
+// every record. The last known-good records are served instead if the stale records fallback allows it.
+// An empty result is accepted once it was returned by several consecutive reads. A threshold of 0
+// disables the guard.
+func WithEmptyRecordsGuard(threshold int) Option {
+	return func(c *config) {
+		c.emptyRecordsThreshold = threshold
+	}
+}
+
+// WithMaxDeletes holds back changes deleting more than max records, e.g. because a source of external-dns
+// stopped producing its endpoints by mistake, until they are approved through
+// INWXProvider.ApproveDeletions. The deletes held back are written to the audit log. A limit of 0 disables
+// the check.
+func WithMaxDeletes(max int) Option {
+	return func(c *config) {
+		c.maxDeletes = max
+	}
+}
+
+// WithFeatures sets the experimental features, which can then be toggled at runtime through
+// INWXProvider.Features.
+func WithFeatures(features *Features) Option {
+	return func(c *config) {
+		c.features = features
+	}
+}
+
+// WithAtomicOwnership creates every record immediately followed by its ownership record and deletes the
+// record again if its ownership record can't be created, so that no record is left without ownership.
+func WithAtomicOwnership(atomic bool) Option {
+	return func(c *config) {
+		c.atomicOwnership = atomic
+	}
+}
+
+// WithCreateFirst applies creates before deletes, so that a name moving from one record to another keeps
+// resolving. Deletes of records conflicting with a create at the same name, such as a CNAME replaced by an
+// A record, still go first, as INWX rejects the create otherwise.
+func WithCreateFirst(createFirst bool) Option {
+	return func(c *config) {
+		c.createFirst = createFirst
+	}
+}
+
+// WithPTRRecords maintains the PTR records of the addresses of created, updated and deleted A and AAAA
+// records in the reverse zones hosted at INWX.
+func WithPTRRecords(create bool) Option {
+	return func(c *config) {
+		c.createPTR = create
+	}
+}
+
+// WithCreateMissingZones creates the domains of --domain-filter and the include rules that no zone hosted at
+// INWX covers before applying changes, as described by the zone template the zone config selects for them.
+func WithCreateMissingZones(create bool) Option {
+	return func(c *config) {
+		c.createMissingZones = create
+	}
+}
+
+// WithRegistry sets how record names and ownership records of the external-dns registry in use are
+// interpreted, replacing the built-in LegacyRegistry heuristics.
+func WithRegistry(registry Registry) Option {
+	return func(c *config) {
+		c.registry = registry
+	}
+}
+
+// WithClock replaces the system clock used for cache TTLs, duplicate apply suppression, flap detection
+// and the zones cache, e.g. to test time-dependent behaviour deterministically.
+func WithClock(clock Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// WithRand sets the source of randomness for jitter. It is used behind a lock, so it needn't be safe for
+// concurrent use. By default a randomly seeded source is used.
+func WithRand(r *rand.Rand) Option {
+	return func(c *config) {
+		c.rand = r
+	}
+}
+
+// WithAuditStore writes every change applied, or deliberately skipped, to store. The provider closes
+// the store when it shuts down.
+func WithAuditStore(store AuditStore) Option {
+	return func(c *config) {
+		c.auditStore = store
+	}
+}
+
+// WithNotifier tells notifier about every ApplyChanges that fails, so that on-call engineers hear about
+// DNS drift without watching the logs. Changes that only raced with other writers don't count as failures.
+func WithNotifier(notifier Notifier) Option {
+	return func(c *config) {
+		c.notifier = notifier
+	}
+}
+
+// WithAdmissionPolicy asks policy about every mutation that passed the zone settings, skipping those it
+// denies.
+func WithAdmissionPolicy(policy AdmissionPolicy) Option {
+	return func(c *config) {
+		c.admission = policy
+	}
+}
+
+// WithEmptyZoneReporting flags zones that have held no records besides their SOA and NS records for at
+// least d in the logs and the empty_zones metric. A zero duration disables it.
+func WithEmptyZoneReporting(d time.Duration) Option {
+	return func(c *config) {
+		c.emptyZonesAfter = d
+	}
+}
+
+// WithReports writes a report of every ApplyChanges to dir, in each of formats, for pipelines attaching
+// DNS change summaries to the pull request or release that triggered them. An empty dir disables it.
+func WithReports(dir string, formats ...ReportFormat) Option {
+	return func(c *config) {
+		c.reportDir = dir
+		c.reportFormats = formats
+	}
+}
+
+// WithNormalizationTrace logs every step of turning an endpoint into an INWX record and back: the TXT
+// unquoting, the zone match, the record name, the TTL and the final INWX request.
+func WithNormalizationTrace(enabled bool) Option {
+	return func(c *config) {
+		c.traceNormalization = enabled
+	}
+}
+
+// WithAPIClient selects the implementation of the client talking to the INWX API.
+func WithAPIClient(client APIClient) Option {
+	return func(c *config) {
+		c.apiClient = client
+	}
+}
+
+// WithAPIURL sends the API calls to url instead of the production or sandbox endpoint of the selected
+// client, e.g. a proxy gateway or a recorded mock server. It must speak the protocol of that client.
+func WithAPIURL(url string) Option {
+	return func(c *config) {
+		c.apiURL = url
+	}
+}
+
+// WithSessionScope decides whether the INWX session lasts for a single API call, a Records or
+// ApplyChanges request, or the lifetime of the provider. Persistent sessions are kept alive by a cheap
+// call whenever they were idle for keepalive; 0 disables the keepalive.
+func WithSessionScope(scope SessionScope, keepalive time.Duration) Option {
+	return func(c *config) {
+		c.sessionScope = scope
+		c.sessionKeepalive = keepalive
+	}
+}
+
+// WithTOTPSecret unlocks an INWX account with two-factor authentication after every login with a TAN
+// generated from secret.
+func WithTOTPSecret(secret TOTPSecret) Option {
+	return func(c *config) {
+		c.totpSecret = secret
+	}
+}
+
+// WithMaintenanceWindows configures scheduled INWX maintenance windows. During a window, the last
+// known-good records are served and changes are deferred until it ends, instead of failing against INWX.
+func WithMaintenanceWindows(windows ...MaintenanceWindow) Option {
+	return func(c *config) {
+		c.maintenanceWindows = append(c.maintenanceWindows, windows...)
+	}
+}
+
+// WithHistorySize keeps the last size applied operations with their changes in memory, for History. A
+// size of 0 keeps none.
+func WithHistorySize(size int) Option {
+	return func(c *config) {
+		c.historySize = size
+	}
+}
+
+// WithCollisionStrategy decides what AdjustEndpoints does with desired endpoints of the same name and type
+// but different targets: merge their targets, keep the first, or fail.
+func WithCollisionStrategy(strategy CollisionStrategy) Option {
+	return func(c *config) {
+		c.collisionStrategy = strategy
+	}
+}
+
+// WithDuplicateOwnership decides what happens to ownership records referring to the same endpoint as
+// another one in the zones touched by changes: ignore them, log them, or delete all but the canonical one.
+// The canonical record is the newest of those carrying ownerID, the --txt-owner-id of external-dns, if
+// given, or of all of them.
+func WithDuplicateOwnership(policy DuplicateOwnershipPolicy, ownerID string) Option {
+	return func(c *config) {
+		c.duplicateOwnership = policy
+		c.txtOwnerID = ownerID
+	}
+}
+
+// WithOwnerMigration rewrites the ownership records owned by from to the owner to before the first read of
+// the records, e.g. to hand the records of a renamed cluster over to it. A failed migration is retried with
+// the next read.
+func WithOwnerMigration(from string, to string) Option {
+	return func(c *config) {
+		if from != "" && to != "" && from != to {
+			c.ownerMigration = &ownerMigration{from: from, to: to}
+		}
+	}
+}
+
+// WithRecordExpiry deletes the records of endpoints created with an expiry property once it has passed,
+// checking every interval; 0 ignores the expiry properties. See ExpiryProperties.
+func WithRecordExpiry(interval time.Duration) Option {
+	return func(c *config) {
+		c.expiryInterval = interval
+	}
+}
+
+// WithApexAlias writes CNAME endpoints at the zone apex as INWX ALIAS records, which INWX accepts there,
+// unless an endpoint disables it with an alias property. See AliasProperties.
+func WithApexAlias(enabled bool) Option {
+	return func(c *config) {
+		c.apexAlias = enabled
+	}
+}
+
+// WithSessionCheckInterval reuses the result of CheckSession for d, so that frequent probes don't log in
+// to INWX every time.
+func WithSessionCheckInterval(d time.Duration) Option {
+	return func(c *config) {
+		c.sessionCheckInterval = d
+	}
+}
+
+// WithExpectedAccount refuses to work with any INWX account but the one of accountID, and with any
+// environment but environment: "production", "sandbox" or the API URL. Zero values aren't checked.
+func WithExpectedAccount(accountID int, environment string) Option {
+	return func(c *config) {
+		c.expectedAccountID = accountID
+		c.expectedEnvironment = environment
+	}
+}
+
+// WithTimestampFormat writes the times of the audit log and reports in layout, a Go time layout, and in the
+// time zone location, instead of RFC 3339 in UTC, e.g. for compliance teams requiring local time.
+func WithTimestampFormat(layout string, location *time.Location) Option {
+	return func(c *config) {
+		c.timestampLayout = layout
+		c.timestampLocation = location
+	}
+}
+
+// WithInMemoryAPI serves the INWX API from memory, holding the given zones empty at first, instead of
+// talking to INWX, for developing and trying out the provider without an INWX account. Nothing is
+// persisted.
+func WithInMemoryAPI(zones ...string) Option {
+	return func(c *config) {
+		c.inMemoryZones = append([]string{}, zones...)
+	}
+}
@@ -0,0 +1,144 @@
+package inwx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// After a cluster is renamed, external-dns runs with a new --txt-owner-id and treats every record owned by
+// the old one as foreign: it neither updates nor deletes them anymore. Rewriting the owner of their
+// ownership records hands them over to the renamed cluster.
+
+// ownerLabelPrefix precedes the owner ID in the content of an ownership record.
+const ownerLabelPrefix = "external-dns/" + endpoint.OwnerLabelKey + "="
+
+// OwnerRewrite is an ownership record whose owner ID is rewritten.
+type OwnerRewrite struct {
+	Zone string `json:"zone"`
+	Name string `json:"name"`
+	ID   string `json:"id"`
+	// RecordKey is the stable identifier of the record before the rewrite, see AppliedChange.
+	RecordKey  string `json:"recordKey"`
+	TTL        int    `json:"ttl"`
+	OldContent string `json:"oldContent"`
+	Content    string `json:"content"`
+}
+
+// ownerMigration is the owner rewrite the provider runs before its first read, see WithOwnerMigration.
+type ownerMigration struct {
+	from string
+	to   string
+
+	mu   sync.Mutex
+	done bool
+}
+
+// rewriteOwner returns the content of an ownership record owned by from with the owner replaced by to.
+// Content of other records is reported as not rewritten.
+func rewriteOwner(content string, from string, to string) (string, bool) {
+	if !isHeritageRecord(endpoint.RecordTypeTXT, content) {
+		return content, false
+	}
+	labels := strings.Split(content, ",")
+	rewritten := false
+	for i, label := range labels {
+		if strings.Trim(label, `"`) == ownerLabelPrefix+from {
+			labels[i] = strings.Replace(label, ownerLabelPrefix+from, ownerLabelPrefix+to, 1)
+			rewritten = true
+		}
+	}
+	return strings.Join(labels, ","), rewritten
+}
+
+// OwnerRewrites returns the ownership records owned by from in every zone within the domain filter, with
+// their content rewritten to the owner to.
+func (p *INWXProvider) OwnerRewrites(ctx context.Context, from string, to string) ([]OwnerRewrite, error) {
+	if from == "" || to == "" || from == to {
+		return nil, fmt.Errorf("invalid owner migration from %q to %q, expected two different owner IDs", from, to)
+	}
+	if err := p.login(ctx); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := p.client.logout(); err != nil {
+			slog.Error("error encountered while logging out", "err", err)
+		}
+	}()
+	zones, err := p.getZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rewrites := []OwnerRewrite{}
+	for _, zone := range *zones {
+		if !p.filter.MatchZone(zone) {
+			continue
+		}
+		records, err := p.getRecords(ctx, zone)
+		if err != nil {
+			return nil, fmt.Errorf("unable to query DNS zone info for zone '%v': %w", zone, err)
+		}
+		for _, rec := range *records {
+			if rec.Type != endpoint.RecordTypeTXT || !p.managesName(p.registry.EndpointName(rec.Name, zone, rec.Type)) {
+				continue
+			}
+			if content, ok := rewriteOwner(rec.Content, from, to); ok {
+				rewrites = append(rewrites, OwnerRewrite{Zone: zone, Name: rec.Name, ID: rec.ID,
+					RecordKey: recordKey(zone, rec.Name, rec.Type, rec.Content), TTL: rec.TTL, OldContent: rec.Content, Content: content})
+			}
+		}
+	}
+	return rewrites, nil
+}
+
+// MigrateOwner rewrites the ownership records owned by from in every zone within the domain filter to the
+// owner to, returning the records rewritten. The updates go through the zone settings like any other
+// change, so dry-run zones only log them; pass a ChangeRecorder in ctx to see their outcome.
+func (p *INWXProvider) MigrateOwner(ctx context.Context, from string, to string) ([]OwnerRewrite, error) {
+	rewrites, err := p.OwnerRewrites(ctx, from, to)
+	if err != nil || len(rewrites) == 0 {
+		return rewrites, err
+	}
+	if err := p.login(ctx); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := p.client.logout(); err != nil {
+			slog.Error("error encountered while logging out", "err", err)
+		}
+	}()
+	errs := []error{}
+	for _, rewrite := range rewrites {
+		rec := &recordRequest{Domain: rewrite.Zone, Name: rewrite.Name, Type: endpoint.RecordTypeTXT, TTL: rewrite.TTL, Content: rewrite.Content}
+		if err := p.updateRecord(ctx, rewrite.ID, rewrite.OldContent, rec); err != nil {
+			errs = append(errs, err)
+			logChangeError("failed to rewrite the owner of an ownership record", err, "zone", rewrite.Zone, "name", rewrite.Name, "id", rewrite.ID)
+		}
+	}
+	return rewrites, errors.Join(errs...)
+}
+
+// migrateOwnerOnce runs the owner migration of WithOwnerMigration, until it succeeded once.
+func (p *INWXProvider) migrateOwnerOnce(ctx context.Context) {
+	m := p.config.ownerMigration
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.done {
+		return
+	}
+	rewrites, err := p.MigrateOwner(ctx, m.from, m.to)
+	if err != nil {
+		p.logger.Warn("failed to migrate ownership records, retrying with the next read", "from", m.from, "to", m.to, "err", err)
+		return
+	}
+	m.done = true
+	p.logger.Info("migrated ownership records", "from", m.from, "to", m.to, "records", len(rewrites))
+}
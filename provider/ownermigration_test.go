@@ -0,0 +1,98 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOwnerMigration(t *testing.T) {
+	t.Run("Rewrite", testRewriteOwner)
+	t.Run("Migrate", testMigrateOwner)
+	t.Run("BeforeFirstRead", testOwnerMigrationBeforeFirstRead)
+}
+
+func testRewriteOwner(t *testing.T) {
+	content, ok := rewriteOwner(heritageOld, "old", "default")
+	assert.True(t, ok)
+	assert.Equal(t, heritageCurrent, content)
+
+	// Quoted contents keep their quotes
+	content, ok = rewriteOwner(`"heritage=external-dns,external-dns/owner=old"`, "old", "new")
+	assert.True(t, ok)
+	assert.Equal(t, `"heritage=external-dns,external-dns/owner=new"`, content)
+
+	for _, content := range []string{heritageCurrent, "heritage=external-dns,external-dns/owner=older", "v=spf1 external-dns/owner=old"} {
+		_, ok := rewriteOwner(content, "old", "default")
+		assert.False(t, ok, content)
+	}
+}
+
+// zoneWithOwners returns a provider with records owned by the owners old and default in two zones.
+func zoneWithOwners(t *testing.T, domainFilter []string) (*MockClientWrapper, *INWXProvider) {
+	w, p := NewINWXProviderWithMockClient(&domainFilter, slog.Default())
+	for _, zone := range []string{"example.com", "example.org"} {
+		w.CreateZone(zone)
+		for _, rec := range []recordRequest{
+			{Name: "www", Type: "A", Content: "192.0.2.1"},
+			{Name: "www", Type: "TXT", Content: heritageOld},
+			{Name: "api", Type: "TXT", Content: heritageCurrent},
+		} {
+			rec.Domain, rec.TTL = zone, 300
+			require.NoError(t, w.createRecord(&rec))
+		}
+	}
+	return w, p
+}
+
+func testMigrateOwner(t *testing.T) {
+	w, p := zoneWithOwners(t, []string{"example.com"})
+
+	_, err := p.OwnerRewrites(context.TODO(), "old", "old")
+	assert.Error(t, err)
+
+	// Previewing changes nothing
+	rewrites, err := p.OwnerRewrites(context.TODO(), "old", "default")
+	require.NoError(t, err)
+	require.Len(t, rewrites, 1)
+	assert.Equal(t, OwnerRewrite{Zone: "example.com", Name: "www", ID: "1", RecordKey: recordKey("example.com", "www", "TXT", heritageOld), TTL: 300, OldContent: heritageOld, Content: heritageCurrent}, rewrites[0])
+	assert.Equal(t, heritageOld, (*w.db["example.com"])[1].Content)
+
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	rewrites, err = p.MigrateOwner(ctx, "old", "default")
+	require.NoError(t, err)
+	require.Len(t, rewrites, 1)
+	require.Len(t, recorder.Changes(), 1)
+	assert.Equal(t, "update", recorder.Changes()[0].Action)
+	assert.Equal(t, heritageCurrent, (*w.db["example.com"])[1].Content)
+	// Zones outside of the domain filter are left alone
+	assert.Equal(t, heritageOld, (*w.db["example.org"])[1].Content)
+
+	rewrites, err = p.OwnerRewrites(context.TODO(), "old", "default")
+	require.NoError(t, err)
+	assert.Empty(t, rewrites)
+}
+
+func testOwnerMigrationBeforeFirstRead(t *testing.T) {
+	w, p := zoneWithOwners(t, []string{"example.com"})
+	WithOwnerMigration("old", "default")(&p.config)
+
+	records, err := p.Records(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, heritageCurrent, (*w.db["example.com"])[1].Content)
+	for _, ep := range records {
+		if ep.RecordType == "TXT" {
+			assert.Equal(t, heritageCurrent, ep.Targets[0], ep.DNSName)
+		}
+	}
+
+	// The migration only runs once
+	require.NoError(t, w.updateRecord("1", &recordRequest{Domain: "example.com", Name: "www", Type: "TXT", Content: heritageOld, TTL: 300}))
+	p.records.invalidate()
+	_, err = p.Records(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, heritageOld, (*w.db["example.com"])[1].Content)
+}
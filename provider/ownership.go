@@ -0,0 +1,113 @@
+package inwx
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// ownershipPair is an endpoint to create together with its ownership record, if any.
+type ownershipPair struct {
+	record    *endpoint.Endpoint
+	ownership *endpoint.Endpoint
+}
+
+// pairOwnership groups the endpoints to create with the ownership records referring to them, keeping the
+// order of the records. Ownership records without a record to create, e.g. for records that already
+// exist, are kept on their own.
+func (p *INWXProvider) pairOwnership(creates []*endpoint.Endpoint) []ownershipPair {
+	var pairs []ownershipPair
+	paired := map[*endpoint.Endpoint]bool{}
+	for _, ownership := range creates {
+		if ownership.RecordType != endpoint.RecordTypeTXT || !p.isOwnershipEndpoint(ownership) {
+			continue
+		}
+		name, recordType, ok := p.registry.OwnedEndpoint(ownership.DNSName)
+		if !ok {
+			// Without a naming scheme, ownership records share the name of the record they refer to.
+			name = ownership.DNSName
+		}
+		for _, record := range creates {
+			if record.RecordType == endpoint.RecordTypeTXT || paired[record] || record.DNSName != name || (ok && record.RecordType != recordType) {
+				continue
+			}
+			paired[record], paired[ownership] = true, true
+			pairs = append(pairs, ownershipPair{record: record, ownership: ownership})
+			break
+		}
+	}
+
+	groups := make([]ownershipPair, 0, len(creates))
+	for _, ep := range creates {
+		if !paired[ep] {
+			groups = append(groups, ownershipPair{record: ep})
+		}
+	}
+	return append(groups, pairs...)
+}
+
+// isOwnershipEndpoint reports whether every target of ep is an ownership record.
+func (p *INWXProvider) isOwnershipEndpoint(ep *endpoint.Endpoint) bool {
+	for _, target := range ep.Targets {
+		if !p.registry.IsOwnershipRecord(ep.DNSName, ep.RecordType, target) {
+			return false
+		}
+	}
+	return len(ep.Targets) > 0
+}
+
+// createWithOwnership creates each record immediately followed by its ownership record. If the ownership
+// record fails, the records just created are deleted again, so that no record is left without ownership
+// and treated as foreign by external-dns afterwards; it will retry both on its next run.
+func (p *INWXProvider) createWithOwnership(ctx context.Context, zones *[]string, recordsCache map[string]*[]zoneRecord, creates []*endpoint.Endpoint) []error {
+	errs := []error{}
+	for _, pair := range p.pairOwnership(creates) {
+		if pair.ownership == nil {
+			errs = append(errs, p.createEndpoint(ctx, zones, recordsCache, pair.record, nil)...)
+			continue
+		}
+
+		var created []*recordRequest
+		if recordErrs := p.createEndpoint(ctx, zones, recordsCache, pair.record, &created); len(recordErrs) > 0 {
+			// The ownership record is only created along with its record.
+			errs = append(errs, recordErrs...)
+			errs = append(errs, p.rollbackCreates(ctx, created)...)
+			continue
+		}
+		if ownershipErrs := p.createEndpoint(ctx, zones, recordsCache, pair.ownership, nil); len(ownershipErrs) > 0 {
+			errs = append(errs, ownershipErrs...)
+			slog.Warn("failed to create ownership record, rolling back its record", "name", pair.record.DNSName, "type", pair.record.RecordType, "err", errors.Join(ownershipErrs...))
+			errs = append(errs, p.rollbackCreates(ctx, created)...)
+		}
+	}
+	return errs
+}
+
+// rollbackCreates deletes the records created from the given requests.
+func (p *INWXProvider) rollbackCreates(ctx context.Context, created []*recordRequest) []error {
+	errs := []error{}
+	records := map[string]*[]zoneRecord{}
+	for _, rec := range created {
+		if _, ok := records[rec.Domain]; !ok {
+			recs, err := p.getRecords(ctx, rec.Domain)
+			if err != nil {
+				errs = append(errs, err)
+				slog.Error("failed to query DNS zone info for rollback", "zone", rec.Domain, "err", err)
+				continue
+			}
+			records[rec.Domain] = recs
+		}
+		for _, existing := range findRecordsByNameAndType(rec.Name, records[rec.Domain], endpointType(rec.Name, rec.Type)) {
+			if existing.Content != rec.Content {
+				continue
+			}
+			if err := p.deleteRecord(ctx, rec.Domain, rec.Name, rec.Type, rec.Content, existing.ID); err != nil {
+				errs = append(errs, err)
+				slog.Error("failed to roll back record", "rec", rec, "err", err)
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,149 @@
+package inwx
+
+import (
+	"maps"
+	"slices"
+	"sync"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// INWX records have nothing to store the set identifier, labels and provider-specific properties of an
+// endpoint in, e.g. those of a DNSEndpoint resource. Listing the records without them would make
+// external-dns plan to create, or update, the endpoints over and over, so the provider remembers them per
+// name and type and reports them with the records it lists.
+
+// passthroughKey identifies the endpoints sharing passthrough fields.
+type passthroughKey struct {
+	name       string
+	recordType string
+}
+
+// passthroughFields are the fields of an endpoint INWX can't store.
+type passthroughFields struct {
+	setIdentifier    string
+	labels           endpoint.Labels
+	providerSpecific endpoint.ProviderSpecific
+}
+
+// passthroughStore remembers the passthrough fields of the endpoints written. They are kept in memory
+// only; after a restart external-dns updates the endpoints once, which only sets them again. The zero
+// value is ready to use.
+type passthroughStore struct {
+	mu     sync.RWMutex
+	fields map[passthroughKey]passthroughFields
+}
+
+func passthroughKeyOf(ep *endpoint.Endpoint) passthroughKey {
+	return passthroughKey{name: normalizeName(ep.DNSName), recordType: ep.RecordType}
+}
+
+// applied remembers the fields of the endpoints created or updated by changes and forgets those of the
+// endpoints deleted. Endpoints that failed to be written are remembered as well, which is harmless, as
+// only the records listed are decorated.
+func (s *passthroughStore) applied(changes *plan.Changes) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ep := range changes.Delete {
+		delete(s.fields, passthroughKeyOf(ep))
+	}
+	for _, ep := range slices.Concat(changes.Create, changes.UpdateNew) {
+		fields := passthroughFields{setIdentifier: ep.SetIdentifier, labels: maps.Clone(ep.Labels), providerSpecific: slices.Clone(ep.ProviderSpecific)}
+		if fields.setIdentifier == "" && len(fields.labels) == 0 && len(fields.providerSpecific) == 0 {
+			delete(s.fields, passthroughKeyOf(ep))
+			continue
+		}
+		if s.fields == nil {
+			s.fields = map[passthroughKey]passthroughFields{}
+		}
+		s.fields[passthroughKeyOf(ep)] = fields
+	}
+}
+
+// decorate returns endpoints with the remembered passthrough fields set. Endpoints with fields are copied,
+// so that the cached endpoints are left alone.
+func (s *passthroughStore) decorate(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.fields) == 0 {
+		return endpoints
+	}
+	decorated := make([]*endpoint.Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if fields, ok := s.fields[passthroughKeyOf(ep)]; ok {
+			ep = ep.DeepCopy()
+			ep.SetIdentifier = fields.setIdentifier
+			ep.ProviderSpecific = slices.Clone(fields.providerSpecific)
+			if len(fields.labels) > 0 {
+				if ep.Labels == nil {
+					ep.Labels = endpoint.Labels{}
+				}
+				for key, value := range fields.labels {
+					if _, ok := ep.Labels[key]; !ok {
+						ep.Labels[key] = value
+					}
+				}
+			}
+		}
+		decorated = append(decorated, ep)
+	}
+	return decorated
+}
+
+// len returns the number of endpoints with remembered passthrough fields.
+func (s *passthroughStore) len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.fields)
+}
+
+// withoutPassthroughOnlyChanges drops the changes that only touch passthrough fields, which need no INWX
+// call: updates of only these fields, and deletes recreated with the same targets and TTL under
+// another set identifier, e.g. after a restart. Their new fields are remembered. The changes of the caller
+// are left alone.
+func (p *INWXProvider) withoutPassthroughOnlyChanges(changes *plan.Changes) *plan.Changes {
+	sameRecords := func(a, b *endpoint.Endpoint) bool {
+		return normalizeName(a.DNSName) == normalizeName(b.DNSName) && a.RecordType == b.RecordType &&
+			a.Targets.Same(b.Targets) && a.RecordTTL == b.RecordTTL && redirectType(a) == redirectType(b)
+	}
+	samePassthrough := func(a, b *endpoint.Endpoint) bool {
+		return a.SetIdentifier == b.SetIdentifier && maps.Equal(a.Labels, b.Labels) &&
+			slices.Equal(a.ProviderSpecific, b.ProviderSpecific)
+	}
+	remaining := &plan.Changes{}
+	passthroughOnly := &plan.Changes{}
+	for i, newEp := range changes.UpdateNew {
+		if i < len(changes.UpdateOld) && sameRecords(changes.UpdateOld[i], newEp) && !samePassthrough(changes.UpdateOld[i], newEp) {
+			passthroughOnly.UpdateNew = append(passthroughOnly.UpdateNew, newEp)
+			continue
+		}
+		if i < len(changes.UpdateOld) {
+			remaining.UpdateOld = append(remaining.UpdateOld, changes.UpdateOld[i])
+		}
+		remaining.UpdateNew = append(remaining.UpdateNew, newEp)
+	}
+	recreated := map[int]bool{}
+	for _, create := range changes.Create {
+		i := slices.IndexFunc(changes.Delete, func(del *endpoint.Endpoint) bool {
+			return sameRecords(del, create) && del.SetIdentifier != create.SetIdentifier
+		})
+		if i >= 0 && !recreated[i] {
+			recreated[i] = true
+			passthroughOnly.Create = append(passthroughOnly.Create, create)
+			continue
+		}
+		remaining.Create = append(remaining.Create, create)
+	}
+	for i, del := range changes.Delete {
+		if !recreated[i] {
+			remaining.Delete = append(remaining.Delete, del)
+		}
+	}
+	if passthroughOnly.HasChanges() {
+		p.logger.Debug("changes only touch fields INWX doesn't store, remembering them without calling INWX",
+			"updates", len(passthroughOnly.UpdateNew), "recreates", len(passthroughOnly.Create))
+		p.passthrough.applied(passthroughOnly)
+	}
+	return remaining
+}
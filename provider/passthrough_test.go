@@ -0,0 +1,92 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestPassthrough(t *testing.T) {
+	t.Run("AdjustEndpoints", testPassthroughAdjustEndpoints)
+	t.Run("Records", testPassthroughRecords)
+	t.Run("OnlyPassthroughChanges", testOnlyPassthroughChanges)
+}
+
+// crdEndpoint returns an endpoint as produced from a DNSEndpoint resource with a set identifier, labels
+// and provider-specific properties.
+func crdEndpoint(name string, target string, setIdentifier string) *endpoint.Endpoint {
+	ep := endpoint.NewEndpoint(name, "A", target).WithSetIdentifier(setIdentifier).
+		WithProviderSpecific("aws/weight", "10").WithProviderSpecific("inwx/ttl", "600")
+	ep.Labels = endpoint.Labels{endpoint.ResourceLabelKey: "crd/default/web"}
+	return ep
+}
+
+func testPassthroughAdjustEndpoints(t *testing.T) {
+	_, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	adjusted, err := p.AdjustEndpoints([]*endpoint.Endpoint{crdEndpoint("www.example.com", "1.1.1.1", "blue")})
+	require.NoError(t, err)
+	require.Len(t, adjusted, 1)
+	assert.Equal(t, "blue", adjusted[0].SetIdentifier)
+	assert.Equal(t, "crd/default/web", adjusted[0].Labels[endpoint.ResourceLabelKey])
+	// The TTL override is consumed, the other properties are kept
+	assert.Equal(t, endpoint.ProviderSpecific{{Name: "aws/weight", Value: "10"}}, adjusted[0].ProviderSpecific)
+	assert.Equal(t, endpoint.TTL(600), adjusted[0].RecordTTL)
+}
+
+func testPassthroughRecords(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	desired, err := p.AdjustEndpoints([]*endpoint.Endpoint{crdEndpoint("www.example.com", "1.1.1.1", "blue")})
+	require.NoError(t, err)
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: desired}))
+
+	records, err := p.Records(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "blue", records[0].SetIdentifier)
+	assert.Equal(t, "crd/default/web", records[0].Labels[endpoint.ResourceLabelKey])
+	assert.Equal(t, endpoint.ProviderSpecific{{Name: "aws/weight", Value: "10"}}, records[0].ProviderSpecific)
+
+	// external-dns plans nothing once the records carry the fields of the desired endpoints
+	changes := (&plan.Plan{Current: records, Desired: desired, Policies: []plan.Policy{&plan.SyncPolicy{}},
+		ManagedRecords: []string{"A"}}).Calculate().Changes
+	assert.False(t, changes.HasChanges())
+
+	// Deleted records are forgotten
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Delete: records}))
+	assert.Zero(t, p.passthrough.len())
+}
+
+func testOnlyPassthroughChanges(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	require.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "www", Type: "A", Content: "1.1.1.1", TTL: 600}))
+	current, err := p.Records(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, current, 1)
+
+	// As after a restart: the records lack the fields, so external-dns updates them, or recreates them
+	// under the set identifier. Neither calls INWX.
+	desired, err := p.AdjustEndpoints([]*endpoint.Endpoint{crdEndpoint("www.example.com", "1.1.1.1", "")})
+	require.NoError(t, err)
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	require.NoError(t, p.ApplyChanges(ctx, &plan.Changes{UpdateOld: current, UpdateNew: desired}))
+	assert.Empty(t, recorder.Changes())
+
+	desired, err = p.AdjustEndpoints([]*endpoint.Endpoint{crdEndpoint("www.example.com", "1.1.1.1", "green")})
+	require.NoError(t, err)
+	require.NoError(t, p.ApplyChanges(ctx, &plan.Changes{Create: desired, Delete: current}))
+	assert.Empty(t, recorder.Changes())
+
+	records, err := p.Records(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, []string{"1.1.1.1"}, []string(records[0].Targets))
+	assert.Equal(t, "green", records[0].SetIdentifier)
+	assert.Equal(t, endpoint.ProviderSpecific{{Name: "aws/weight", Value: "10"}}, records[0].ProviderSpecific)
+}
@@ -0,0 +1,136 @@
+package inwx
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// Wildcard-like setups create the same target for hundreds of names at once, which INWX takes one call
+// per record for, so that a single request can run for minutes. Such applies report their progress, with
+// the creates of identical content coalesced into batches, instead of staying silent until done.
+
+// minProgressBatch is the number of creates of identical content that are reported as a batch.
+const minProgressBatch = 10
+
+type applyProgressKey struct{}
+
+// progressBatch is a group of creates of the same type and target across names.
+type progressBatch struct {
+	recordType string
+	content    string
+	total      int
+	done       int
+}
+
+// applyProgress counts the mutations of an apply and logs how far it got.
+type applyProgress struct {
+	p        *INWXProvider
+	mu       sync.Mutex
+	total    int
+	done     int
+	start    time.Time
+	lastLog  time.Time
+	batches  map[string]*progressBatch
+	interval time.Duration
+}
+
+// withApplyProgress returns ctx tracking the progress of changes if they make at least the configured
+// threshold of mutations.
+func (p *INWXProvider) withApplyProgress(ctx context.Context, changes *plan.Changes) context.Context {
+	if p.config.progressThreshold <= 0 {
+		return ctx
+	}
+	total := 0
+	for _, ep := range changes.Create {
+		total += len(ep.Targets)
+	}
+	for _, ep := range changes.Delete {
+		total += max(len(ep.Targets), 1)
+	}
+	for i, ep := range changes.UpdateNew {
+		total += max(len(ep.Targets), len(changes.UpdateOld[i].Targets), 1)
+	}
+	if total < p.config.progressThreshold {
+		return ctx
+	}
+
+	progress := &applyProgress{p: p, total: total, start: now(p.config.clock), batches: map[string]*progressBatch{}, interval: p.config.progressInterval}
+	progress.lastLog = progress.start
+	for _, ep := range changes.Create {
+		for _, target := range ep.Targets {
+			key := ep.RecordType + " " + target
+			if progress.batches[key] == nil {
+				progress.batches[key] = &progressBatch{recordType: ep.RecordType, content: target}
+			}
+			progress.batches[key].total++
+		}
+	}
+	maps.DeleteFunc(progress.batches, func(_ string, batch *progressBatch) bool { return batch.total < minProgressBatch })
+	p.logger.Info("applying a large change set, logging its progress", "changes", total, "creates", len(changes.Create),
+		"updates", len(changes.UpdateNew), "deletes", len(changes.Delete), "batches", len(progress.batches))
+	for _, batch := range progress.sortedBatches() {
+		p.logger.Info("creating records of identical content as a batch", "type", batch.recordType, "content", batch.content, "records", batch.total)
+	}
+	return context.WithValue(ctx, applyProgressKey{}, progress)
+}
+
+func (a *applyProgress) sortedBatches() []*progressBatch {
+	batches := make([]*progressBatch, 0, len(a.batches))
+	for _, batch := range a.batches {
+		batches = append(batches, batch)
+	}
+	slices.SortFunc(batches, func(x, y *progressBatch) int {
+		return cmp.Or(cmp.Compare(y.total, x.total), cmp.Compare(x.recordType, y.recordType), cmp.Compare(x.content, y.content))
+	})
+	return batches
+}
+
+// stepProgress counts a mutation of the apply of ctx, if its progress is tracked, logging the progress
+// every progress interval.
+func stepProgress(ctx context.Context, change AppliedChange) {
+	a, ok := ctx.Value(applyProgressKey{}).(*applyProgress)
+	if !ok {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.done++
+	// Changes beyond the plan, e.g. ownership cleanups, extend it.
+	a.total = max(a.total, a.done)
+	var batch *progressBatch
+	if change.Action == string(actionCreate) {
+		if batch = a.batches[change.Type+" "+change.Content]; batch != nil {
+			batch.done++
+		}
+	}
+	at := now(a.p.config.clock)
+	if at.Sub(a.lastLog) < a.interval {
+		return
+	}
+	a.lastLog = at
+	elapsed := at.Sub(a.start)
+	eta := time.Duration(float64(elapsed) / float64(a.done) * float64(a.total-a.done))
+	args := []any{"done", a.done, "total", a.total, "percent", 100 * a.done / a.total, "elapsed", elapsed.Round(time.Second), "eta", eta.Round(time.Second)}
+	if batch != nil {
+		args = append(args, "batch_type", batch.recordType, "batch_content", batch.content, "batch_done", batch.done, "batch_total", batch.total)
+	}
+	a.p.logger.Info("applying changes", args...)
+}
+
+// finishProgress logs the end of the apply of ctx, if its progress is tracked.
+func finishProgress(ctx context.Context, err error) {
+	a, ok := ctx.Value(applyProgressKey{}).(*applyProgress)
+	if !ok {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.p.logger.Info("applied large change set", "done", a.done, "planned", a.total, "elapsed", now(a.p.config.clock).Sub(a.start).Round(time.Second),
+		"failed", err != nil)
+}
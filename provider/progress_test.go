@@ -0,0 +1,45 @@
+package inwx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestApplyProgress(t *testing.T) {
+	var logs bytes.Buffer
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.New(slog.NewTextHandler(&logs, nil)))
+	w.CreateZone("example.com")
+	p.config.progressThreshold = 20
+
+	// Small applies stay quiet
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", "A", "192.0.2.1")}}))
+	assert.NotContains(t, logs.String(), "large change set")
+
+	// Creates of the same target across names are reported as a batch, and every mutation as progress
+	creates := []*endpoint.Endpoint{endpoint.NewEndpoint("other.example.com", "A", "192.0.2.2")}
+	for i := range 24 {
+		creates = append(creates, endpoint.NewEndpoint(fmt.Sprintf("app-%d.example.com", i), "A", "192.0.2.1"))
+	}
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: creates}))
+	assert.Contains(t, logs.String(), `msg="applying a large change set, logging its progress" changes=25 creates=25 updates=0 deletes=0 batches=1`)
+	assert.Contains(t, logs.String(), `msg="creating records of identical content as a batch" type=A content=192.0.2.1 records=24`)
+	assert.Equal(t, 25, strings.Count(logs.String(), `msg="applying changes"`))
+	assert.Contains(t, logs.String(), "done=25 total=25 percent=100")
+	assert.Contains(t, logs.String(), "batch_done=24 batch_total=24")
+	assert.Contains(t, logs.String(), `msg="applied large change set" done=25 planned=25`)
+
+	// A threshold of 0 disables it
+	logs.Reset()
+	p.config.progressThreshold = 0
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Delete: creates}))
+	assert.NotContains(t, logs.String(), "applying changes")
+}
@@ -0,0 +1,66 @@
+package inwx
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// recordLimitWarnRatio is the share of its record limit a zone may reach before a warning is logged.
+const recordLimitWarnRatio = 0.9
+
+type recordLimitKey struct{}
+
+// checkRecordLimits counts the records every zone with a record limit would hold after changes and
+// warns about the zones approaching or exceeding their limit. The returned context marks the zones whose
+// creates must be blocked, so that a batch, e.g. of preview environments, is rejected up front instead of
+// failing halfway through at the hard limit of INWX. Zones that can't be read are left to the mutations
+// themselves to fail.
+func (p *INWXProvider) checkRecordLimits(ctx context.Context, zones *[]string, changes *plan.Changes) context.Context {
+	added := map[string]int{}
+	count := func(eps []*endpoint.Endpoint, sign int) {
+		for _, ep := range eps {
+			if zone, err := p.zoneFor(zones, ep); err == nil && p.settingsFor(zone).recordLimit > 0 {
+				added[zone] += sign * len(ep.Targets)
+			}
+		}
+	}
+	count(changes.Create, 1)
+	count(changes.UpdateNew, 1)
+	count(changes.UpdateOld, -1)
+	count(changes.Delete, -1)
+
+	blocked := map[string]bool{}
+	for _, zone := range slices.Sorted(maps.Keys(added)) {
+		if added[zone] <= 0 {
+			continue
+		}
+		records, err := p.getRecords(ctx, zone)
+		if err != nil {
+			continue
+		}
+		settings := p.settingsFor(zone)
+		current, after := len(*records), len(*records)+added[zone]
+		switch {
+		case after > settings.recordLimit && settings.blockOverLimit:
+			p.logger.Warn("changes would exceed the record limit of the zone, skipping its creates", "zone", zone,
+				"records", current, "added", added[zone], "limit", settings.recordLimit)
+			blocked[zone] = true
+		case after > settings.recordLimit:
+			p.logger.Warn("changes exceed the record limit of the zone, INWX may reject some of them", "zone", zone,
+				"records", current, "added", added[zone], "limit", settings.recordLimit)
+		case float64(after) >= recordLimitWarnRatio*float64(settings.recordLimit):
+			p.logger.Warn("zone is approaching its record limit", "zone", zone, "records", after, "limit", settings.recordLimit)
+		}
+	}
+	return context.WithValue(ctx, recordLimitKey{}, blocked)
+}
+
+// blockedByRecordLimit reports whether checkRecordLimits blocked the creates of zone.
+func blockedByRecordLimit(ctx context.Context, zone string) bool {
+	blocked, _ := ctx.Value(recordLimitKey{}).(map[string]bool)
+	return blocked[zone]
+}
@@ -0,0 +1,65 @@
+package inwx
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestRecordLimit(t *testing.T) {
+	var logs bytes.Buffer
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.New(slog.NewTextHandler(&logs, nil)))
+	w.CreateZone("example.com")
+	p.config.recordLimit = 3
+	require.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "www", Type: "A", Content: "192.0.2.1", TTL: 300}))
+
+	// Reaching 90% of the limit is warned about
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("a.example.com", "A", "192.0.2.2"),
+	}}))
+	assert.NotContains(t, logs.String(), "approaching")
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("b.example.com", "A", "192.0.2.3"),
+	}}))
+	assert.Contains(t, logs.String(), "zone is approaching its record limit")
+
+	// Exceeding it is only warned about unless blocked
+	over := &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("c.example.com", "A", "192.0.2.4")}}
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	require.NoError(t, p.ApplyChanges(ctx, over))
+	assert.Contains(t, logs.String(), "changes exceed the record limit of the zone")
+	require.Len(t, recorder.Changes(), 1)
+	assert.Equal(t, "applied", recorder.Changes()[0].Outcome)
+
+	p.config.blockOverLimit = true
+	ctx, recorder = WithChangeRecorder(context.TODO())
+	require.NoError(t, p.ApplyChanges(ctx, &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("d.example.com", "A", "192.0.2.5", "192.0.2.6"),
+	}}))
+	require.Len(t, recorder.Changes(), 2)
+	assert.Equal(t, "record_limit", recorder.Changes()[0].Skipped)
+	assert.Equal(t, "record_limit", recorder.Changes()[1].Skipped)
+
+	// Deletes in the same batch make room
+	ctx, recorder = WithChangeRecorder(context.TODO())
+	require.NoError(t, p.ApplyChanges(ctx, &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("d.example.com", "A", "192.0.2.5")},
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("c.example.com", "A", "192.0.2.4")},
+	}))
+	for _, change := range recorder.Changes() {
+		assert.Equal(t, "applied", change.Outcome, change.ID)
+	}
+
+	// Zones can lift the limit
+	p.config.zoneConfig = &ZoneConfig{Zones: map[string]ZoneSettings{"example.com": {RecordLimit: new(int)}}}
+	ctx, recorder = WithChangeRecorder(context.TODO())
+	require.NoError(t, p.ApplyChanges(ctx, &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("e.example.com", "A", "192.0.2.7")}}))
+	require.Len(t, recorder.Changes(), 1)
+	assert.Equal(t, "applied", recorder.Changes()[0].Outcome)
+}
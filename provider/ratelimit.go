@@ -0,0 +1,78 @@
+package inwx
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// zoneLimiter is the mutation rate limiter of a zone, with the time mutations spent waiting for it.
+type zoneLimiter struct {
+	limiter *rate.Limiter
+	waits   atomic.Int64
+	waited  atomic.Int64
+}
+
+// waitForRateLimit blocks until the zone's mutation rate limit allows another call.
+// A limit of 0 means unlimited.
+func (p *INWXProvider) waitForRateLimit(ctx context.Context, zone string, limit float64) error {
+	if limit <= 0 {
+		return nil
+	}
+
+	var zl *zoneLimiter
+	if l, ok := p.limiters.Load(zone); ok {
+		zl = l.(*zoneLimiter)
+		if zl.limiter.Limit() != rate.Limit(limit) {
+			zl.limiter.SetLimit(rate.Limit(limit))
+		}
+	} else {
+		l, _ := p.limiters.LoadOrStore(zone, &zoneLimiter{limiter: rate.NewLimiter(rate.Limit(limit), int(math.Max(1, limit)))})
+		zl = l.(*zoneLimiter)
+	}
+
+	start := time.Now()
+	err := zl.limiter.Wait(ctx)
+	if waited := time.Since(start); waited >= time.Millisecond {
+		zl.waits.Add(1)
+		zl.waited.Add(int64(waited))
+	}
+	return err
+}
+
+// RateLimitState is the state of the mutation rate limiter of a zone.
+type RateLimitState struct {
+	Zone string `json:"zone"`
+	// Limit is the number of mutations allowed per second, Burst the number allowed at once.
+	Limit float64 `json:"limit"`
+	Burst int     `json:"burst"`
+	// Tokens is the number of mutations that can be made right now without waiting.
+	Tokens float64 `json:"tokens"`
+	// Waits counts the mutations that had to wait for the limiter, WaitedSeconds their total wait.
+	Waits         int64   `json:"waits"`
+	WaitedSeconds float64 `json:"waitedSeconds"`
+}
+
+// RateLimits returns the state of the rate limiter of every zone mutated with a rate limit so far.
+func (p *INWXProvider) RateLimits() []RateLimitState {
+	states := []RateLimitState{}
+	p.limiters.Range(func(key, value any) bool {
+		zl := value.(*zoneLimiter)
+		states = append(states, RateLimitState{
+			Zone:          key.(string),
+			Limit:         float64(zl.limiter.Limit()),
+			Burst:         zl.limiter.Burst(),
+			Tokens:        zl.limiter.Tokens(),
+			Waits:         zl.waits.Load(),
+			WaitedSeconds: time.Duration(zl.waited.Load()).Seconds(),
+		})
+		return true
+	})
+	slices.SortFunc(states, func(a, b RateLimitState) int { return cmp.Compare(a.Zone, b.Zone) })
+	return states
+}
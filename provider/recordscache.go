@@ -0,0 +1,371 @@
+package inwx
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// recordsSnapshot is an immutable copy of the records read from INWX. ids holds the INWX record ID of
+// each endpoint, empty where it isn't known; zones holds the time the records of each zone were read.
+type recordsSnapshot struct {
+	endpoints []*endpoint.Endpoint
+	ids       []string
+	zones     map[string]time.Time
+	fetched   time.Time
+}
+
+// recordsCache serves the last records read from INWX for up to ttl. Snapshots are replaced as a whole
+// and never modified, so readers don't take any lock and are never blocked by a running apply. Changes
+// applied by the provider are patched into a copy of the snapshot, so the cache keeps reflecting INWX
+// without reading the records again.
+//
+// Independently of ttl, the last known-good records are kept for up to staleMaxAge as a fallback for
+// when listing the records fails; an apply doesn't drop them. With keepLastGood, they are kept regardless
+// of staleMaxAge, to be served during INWX maintenance windows.
+type recordsCache struct {
+	ttl      time.Duration
+	snapshot atomic.Pointer[recordsSnapshot]
+	// hits and misses count the reads served from the cache and those that weren't.
+	hits   atomic.Int64
+	misses atomic.Int64
+
+	staleMaxAge  time.Duration
+	keepLastGood bool
+	lastGood     atomic.Pointer[recordsSnapshot]
+	// served is the snapshot whose records were served last, to tell how old they are per zone.
+	served atomic.Pointer[recordsSnapshot]
+
+	// lastCount is the number of records read last time; emptyReads counts the consecutive empty reads
+	// that followed it.
+	lastCount  atomic.Int64
+	emptyReads atomic.Int64
+
+	// mu serializes writers only; generation is bumped on every invalidation so that a read started
+	// before an apply can't store records that the apply already changed.
+	mu         sync.Mutex
+	generation uint64
+}
+
+func newRecordsCache(ttl time.Duration, staleMaxAge time.Duration) *recordsCache {
+	return &recordsCache{ttl: ttl, staleMaxAge: staleMaxAge}
+}
+
+// get returns the cached records if they were read within the ttl before now.
+func (c *recordsCache) get(now time.Time) ([]*endpoint.Endpoint, bool) {
+	if c == nil || c.ttl <= 0 {
+		return nil, false
+	}
+	s := c.snapshot.Load()
+	if s == nil || now.Sub(s.fetched) > c.ttl {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	c.served.Store(s)
+	return slices.Clone(s.endpoints), true
+}
+
+// current returns the generation to pass to store for records about to be read.
+func (c *recordsCache) current() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// store replaces the cached records with the ones read from INWX at fetched, given with their record
+// IDs and the time the records of each zone were read, unless the cache was changed since generation was
+// obtained from current. The records are taken as served.
+func (c *recordsCache) store(endpoints []*endpoint.Endpoint, ids []string, zones map[string]time.Time, fetched time.Time, generation uint64) {
+	if c == nil {
+		return
+	}
+	if len(ids) != len(endpoints) {
+		ids = make([]string, len(endpoints))
+	}
+	snapshot := &recordsSnapshot{endpoints: slices.Clone(endpoints), ids: slices.Clone(ids), zones: zones, fetched: fetched}
+	c.served.Store(snapshot)
+	if c.ttl <= 0 && c.staleMaxAge <= 0 && !c.keepLastGood {
+		return
+	}
+	if c.staleMaxAge > 0 || c.keepLastGood {
+		c.lastGood.Store(snapshot)
+	}
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	c.snapshot.Store(snapshot)
+}
+
+// emptyRecordsConfirmations is the number of consecutive empty reads after which an empty result is
+// accepted as genuine.
+const emptyRecordsConfirmations = 3
+
+// suspiciouslyEmpty checks a read of count records: it reports whether the read is empty although at
+// least threshold records were read before, which points to an API anomaly rather than a genuinely empty
+// account, and how many records were read before. The empty result is accepted once it was confirmed by
+// emptyRecordsConfirmations consecutive reads. A threshold of 0 disables the check.
+func (c *recordsCache) suspiciouslyEmpty(count int, threshold int) (int, bool) {
+	if c == nil {
+		return 0, false
+	}
+	previous := int(c.lastCount.Load())
+	if count > 0 || threshold <= 0 || previous < threshold {
+		c.emptyReads.Store(0)
+		c.lastCount.Store(int64(count))
+		return previous, false
+	}
+	if c.emptyReads.Add(1) >= emptyRecordsConfirmations {
+		c.emptyReads.Store(0)
+		c.lastCount.Store(0)
+		return previous, false
+	}
+	return previous, true
+}
+
+// stale returns the last known-good records and their age if they were read within staleMaxAge before now.
+func (c *recordsCache) stale(now time.Time) ([]*endpoint.Endpoint, time.Duration, bool) {
+	if c == nil || c.staleMaxAge <= 0 {
+		return nil, 0, false
+	}
+	s := c.lastGood.Load()
+	if s == nil || now.Sub(s.fetched) > c.staleMaxAge {
+		return nil, 0, false
+	}
+	c.served.Store(s)
+	return slices.Clone(s.endpoints), now.Sub(s.fetched), true
+}
+
+// latest returns the last known-good records and their age, however old they are.
+func (c *recordsCache) latest(now time.Time) ([]*endpoint.Endpoint, time.Duration, bool) {
+	if c == nil {
+		return nil, 0, false
+	}
+	s := c.lastGood.Load()
+	if s == nil {
+		return nil, 0, false
+	}
+	c.served.Store(s)
+	return slices.Clone(s.endpoints), now.Sub(s.fetched), true
+}
+
+// servedAges returns the age of the records of each zone served last, by zone.
+func (c *recordsCache) servedAges(now time.Time) map[string]time.Duration {
+	if c == nil {
+		return nil
+	}
+	s := c.served.Load()
+	if s == nil {
+		return nil
+	}
+	ages := make(map[string]time.Duration, len(s.zones))
+	for zone, fetched := range s.zones {
+		ages[zone] = now.Sub(fetched)
+	}
+	return ages
+}
+
+// invalidate drops the cached records, e.g. once an apply changed them.
+func (c *recordsCache) invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.snapshot.Store(nil)
+}
+
+// patch applies a change to a copy of the cached records, keeping the time they were read. Reads started
+// before are not stored, as they may miss the change. If fn reports that the change can't be reflected
+// reliably, the cached records are dropped instead.
+func (c *recordsCache) patch(fn func(s *recordsSnapshot) bool) {
+	if c == nil || c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	s := c.snapshot.Load()
+	if s == nil {
+		return
+	}
+	patched := &recordsSnapshot{endpoints: slices.Clone(s.endpoints), ids: slices.Clone(s.ids), zones: s.zones, fetched: s.fetched}
+	if !fn(patched) {
+		c.snapshot.Store(nil)
+		return
+	}
+	c.snapshot.Store(patched)
+}
+
+// created adds a record created in INWX. Its ID isn't known.
+func (c *recordsCache) created(ep *endpoint.Endpoint) {
+	c.patch(func(s *recordsSnapshot) bool {
+		s.endpoints = append(s.endpoints, ep)
+		s.ids = append(s.ids, "")
+		return true
+	})
+}
+
+// updated replaces the record with the given ID; records of unknown ID drop the cache.
+func (c *recordsCache) updated(id string, ep *endpoint.Endpoint) {
+	c.patch(func(s *recordsSnapshot) bool {
+		i := slices.Index(s.ids, id)
+		if id == "" || i < 0 {
+			return false
+		}
+		s.endpoints[i] = ep
+		return true
+	})
+}
+
+// deleted removes the record with the given ID, or failing that the record with the name, type and
+// content of ep, e.g. one created since the records were read.
+func (c *recordsCache) deleted(id string, ep *endpoint.Endpoint) {
+	c.patch(func(s *recordsSnapshot) bool {
+		i := slices.Index(s.ids, id)
+		if id == "" || i < 0 {
+			i = slices.IndexFunc(s.endpoints, func(cached *endpoint.Endpoint) bool {
+				return cached.DNSName == ep.DNSName && cached.RecordType == ep.RecordType && cached.Targets.Same(ep.Targets)
+			})
+		}
+		if i >= 0 {
+			s.endpoints = slices.Delete(s.endpoints, i, i+1)
+			s.ids = slices.Delete(s.ids, i, i+1)
+		}
+		return true
+	})
+}
+
+// Consistency is the consistency level of a Records read.
+type Consistency string
+
+const (
+	// ConsistencyCached allows serving the records from the cache, or the last known-good records when
+	// reading them fails.
+	ConsistencyCached Consistency = "cached"
+	// ConsistencyStrong reads the zones and records from INWX, bypassing every cache.
+	ConsistencyStrong Consistency = "strong"
+)
+
+// ParseConsistency returns the Consistency named s, ConsistencyCached for an empty s.
+func ParseConsistency(s string) (Consistency, error) {
+	switch c := Consistency(s); c {
+	case "":
+		return ConsistencyCached, nil
+	case ConsistencyCached, ConsistencyStrong:
+		return c, nil
+	default:
+		return "", fmt.Errorf("unknown consistency %q, expected cached or strong", s)
+	}
+}
+
+type consistencyKey struct{}
+
+// WithConsistency returns a context requesting the consistency level c from the Records reads started
+// with it, e.g. ConsistencyStrong when the cached records are suspected to be wrong.
+func WithConsistency(ctx context.Context, c Consistency) context.Context {
+	return context.WithValue(ctx, consistencyKey{}, c)
+}
+
+// consistencyOf returns the consistency level requested by ctx, ConsistencyCached by default.
+func consistencyOf(ctx context.Context) Consistency {
+	if c, ok := ctx.Value(consistencyKey{}).(Consistency); ok {
+		return c
+	}
+	return ConsistencyCached
+}
+
+// RecordsCacheState is the state of the records cache.
+type RecordsCacheState struct {
+	TTLSeconds float64 `json:"ttlSeconds"`
+	// Cached describes the records served from the cache, if any are cached.
+	Cached *SnapshotState `json:"cached,omitempty"`
+	Hits   int64          `json:"hits"`
+	Misses int64          `json:"misses"`
+	// HitRatio is the share of reads served from the cache, 0 before the first read.
+	HitRatio float64 `json:"hitRatio"`
+
+	StaleMaxAgeSeconds float64 `json:"staleMaxAgeSeconds"`
+	// LastKnownGood describes the records kept as a fallback for failing reads, if any are kept.
+	LastKnownGood *SnapshotState `json:"lastKnownGood,omitempty"`
+	// ZoneAgeSeconds is the age of the records of each zone served last, which keeps growing while cached
+	// or last known-good records are served.
+	ZoneAgeSeconds map[string]float64 `json:"zoneAgeSeconds,omitempty"`
+}
+
+// SnapshotState describes records read from INWX.
+type SnapshotState struct {
+	Records    int       `json:"records"`
+	Fetched    time.Time `json:"fetched"`
+	AgeSeconds float64   `json:"ageSeconds"`
+}
+
+func snapshotState(s *recordsSnapshot, now time.Time) *SnapshotState {
+	if s == nil {
+		return nil
+	}
+	return &SnapshotState{Records: len(s.endpoints), Fetched: s.fetched, AgeSeconds: now.Sub(s.fetched).Seconds()}
+}
+
+// state returns the state of the cache at now.
+func (c *recordsCache) state(now time.Time) RecordsCacheState {
+	if c == nil {
+		return RecordsCacheState{}
+	}
+	state := RecordsCacheState{
+		TTLSeconds:         c.ttl.Seconds(),
+		Cached:             snapshotState(c.snapshot.Load(), now),
+		Hits:               c.hits.Load(),
+		Misses:             c.misses.Load(),
+		StaleMaxAgeSeconds: c.staleMaxAge.Seconds(),
+		LastKnownGood:      snapshotState(c.lastGood.Load(), now),
+	}
+	if total := state.Hits + state.Misses; total > 0 {
+		state.HitRatio = float64(state.Hits) / float64(total)
+	}
+	for zone, age := range c.servedAges(now) {
+		if state.ZoneAgeSeconds == nil {
+			state.ZoneAgeSeconds = map[string]float64{}
+		}
+		state.ZoneAgeSeconds[zone] = age.Seconds()
+	}
+	return state
+}
+
+// CacheState is the state of the caches of the provider.
+type CacheState struct {
+	Records RecordsCacheState `json:"records"`
+	Zones   ZonesCacheState   `json:"zones"`
+}
+
+// ZonesCacheState is the state of the cached list of zones.
+type ZonesCacheState struct {
+	Cached bool `json:"cached"`
+	Zones  int  `json:"zones"`
+	// ExpiresInSeconds is how long the zones are cached for; negative once they expired.
+	ExpiresInSeconds float64 `json:"expiresInSeconds"`
+}
+
+// CacheState returns the state of the records and zones caches.
+func (p *INWXProvider) CacheState() CacheState {
+	now := now(p.config.clock)
+	state := CacheState{Records: p.records.state(now)}
+	if count, expires, ok := p.client.cachedZones(); ok {
+		state.Zones = ZonesCacheState{Cached: true, Zones: count, ExpiresInSeconds: expires.Sub(now).Seconds()}
+	}
+	return state
+}
@@ -0,0 +1,94 @@
+package inwx
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// INWX offers URL records, which its web servers answer with a redirect to their content, e.g. to move an
+// old domain to a new one without running a server for it. external-dns knows no such records, so CNAME
+// endpoints with a redirect property are written as URL records redirecting to their target, and URL
+// records are reported as CNAME endpoints carrying the property.
+
+// urlRecordType is the INWX record type of URL redirects.
+const urlRecordType = "URL"
+
+// RedirectProperties are the provider-specific properties making a CNAME endpoint a URL redirect with the
+// given mode: "301" or "302" for permanent or temporary HTTP redirects, or "frame" for a page showing the
+// target in a frame. "webhook/inwx-url-redirect" is what external-dns derives from the
+// external-dns.alpha.kubernetes.io/webhook-inwx-url-redirect annotation, "inwx/url-redirect" can be set
+// directly on DNSEndpoint resources.
+var RedirectProperties = []string{"inwx/url-redirect", "webhook/inwx-url-redirect"}
+
+// redirectTypes maps the redirect modes of RedirectProperties to the redirect types of INWX.
+var redirectTypes = map[string]string{
+	"301":   "HEADER301",
+	"302":   "HEADER302",
+	"frame": "FRAME",
+}
+
+// parseRedirect returns the INWX redirect type ep asks for, "" if it isn't a redirect.
+func parseRedirect(ep *endpoint.Endpoint) (string, error) {
+	if ep.RecordType != endpoint.RecordTypeCNAME {
+		return "", nil
+	}
+	for _, property := range RedirectProperties {
+		if value, ok := ep.GetProviderSpecificProperty(property); ok {
+			redirect, ok := redirectTypes[strings.ToLower(value)]
+			if !ok {
+				return "", fmt.Errorf("invalid redirect mode %q, expected 301, 302 or frame", value)
+			}
+			return redirect, nil
+		}
+	}
+	return "", nil
+}
+
+// redirectType returns the INWX redirect type ep asks for, "" if it isn't a redirect or its mode is
+// invalid, which AdjustEndpoints flags.
+func redirectType(ep *endpoint.Endpoint) string {
+	redirect, _ := parseRedirect(ep)
+	return redirect
+}
+
+// redirectMode returns the redirect mode of RedirectProperties an INWX redirect type stands for.
+func redirectMode(redirect string) string {
+	for mode, t := range redirectTypes {
+		if t == redirect {
+			return mode
+		}
+	}
+	return strings.ToLower(redirect)
+}
+
+// withRedirect returns ep, read from INWX, with the redirect property of the redirect type redirect set,
+// if any.
+func withRedirect(ep *endpoint.Endpoint, redirect string) *endpoint.Endpoint {
+	if redirect != "" {
+		ep.WithProviderSpecific(RedirectProperties[1], redirectMode(redirect))
+	}
+	return ep
+}
+
+// validateRedirect checks that target, the target of a URL redirect, is an absolute http or https URL.
+func validateRedirect(target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("expected an http or https URL to redirect to")
+	}
+	return nil
+}
+
+// validateTarget checks the syntax of a target of ep: the URL of redirects, the content of other records.
+func validateTarget(ep *endpoint.Endpoint, target string) error {
+	if redirectType(ep) != "" {
+		return validateRedirect(target)
+	}
+	return validateContent(ep.RecordType, target)
+}
@@ -0,0 +1,90 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestURLRedirect(t *testing.T) {
+	t.Run("RoundTrip", testURLRedirectRoundTrip)
+	t.Run("Validation", testURLRedirectValidation)
+}
+
+func testURLRedirectRoundTrip(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+
+	old := endpoint.NewEndpointWithTTL("old.example.com", "CNAME", 300, "https://new.example.org/").
+		WithProviderSpecific("webhook/inwx-url-redirect", "301")
+	www := endpoint.NewEndpointWithTTL("www.example.com", "CNAME", 300, "lb.example.net")
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	require.NoError(t, p.ApplyChanges(ctx, &plan.Changes{Create: []*endpoint.Endpoint{old, www}}))
+
+	// Only the endpoint with the property is written as a URL record, and changes report the CNAME it stands for
+	stored := *w.db["example.com"]
+	require.Len(t, stored, 2)
+	assert.Equal(t, "URL", stored[0].Type)
+	assert.Equal(t, "HEADER301", stored[0].RedirectType)
+	assert.Equal(t, "https://new.example.org/", stored[0].Content)
+	assert.Equal(t, "CNAME", stored[1].Type)
+	assert.Empty(t, stored[1].RedirectType)
+	assert.Equal(t, "CNAME", recorder.Changes()[0].Type)
+
+	// Records reports a CNAME with the property, so external-dns plans nothing, also after a restart
+	for _, restarted := range []bool{false, true} {
+		if restarted {
+			p.passthrough = passthroughStore{}
+		}
+		p.records.invalidate()
+		records, err := p.Records(context.TODO())
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, "CNAME", records[0].RecordType)
+		changes := (&plan.Plan{Current: records, Desired: []*endpoint.Endpoint{old, www}, Policies: []plan.Policy{&plan.SyncPolicy{}},
+			ManagedRecords: []string{"CNAME"}}).Calculate().Changes
+		assert.False(t, changes.HasChanges(), "restarted: %v", restarted)
+	}
+
+	// Changing the mode updates the record, although the target stays the same
+	temporary := old.DeepCopy()
+	temporary.ProviderSpecific = endpoint.ProviderSpecific{{Name: "webhook/inwx-url-redirect", Value: "302"}}
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{UpdateOld: []*endpoint.Endpoint{old}, UpdateNew: []*endpoint.Endpoint{temporary}}))
+	assert.Equal(t, "HEADER302", (*w.db["example.com"])[0].RedirectType)
+
+	// Deletes find the URL record
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Delete: []*endpoint.Endpoint{temporary}}))
+	records, err := p.Records(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "www.example.com", records[0].DNSName)
+}
+
+func testURLRedirectValidation(t *testing.T) {
+	_, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+
+	// Redirects need a URL to redirect to, which isn't a valid CNAME target, and a valid mode
+	redirect := endpoint.NewEndpoint("old.example.com", "CNAME", "https://new.example.org/path").
+		WithProviderSpecific("inwx/url-redirect", "frame")
+	_, adjustments, err := p.AdjustEndpointsWithReasons([]*endpoint.Endpoint{
+		redirect,
+		endpoint.NewEndpoint("host.example.com", "CNAME", "new.example.org").WithProviderSpecific("inwx/url-redirect", "302"),
+		endpoint.NewEndpoint("mode.example.com", "CNAME", "https://new.example.org/").WithProviderSpecific("inwx/url-redirect", "307"),
+	})
+	require.NoError(t, err)
+	require.Len(t, adjustments, 2)
+	assert.Equal(t, "host.example.com", adjustments[0].Name)
+	assert.Equal(t, "invalid_content", adjustments[0].Reason)
+	assert.Equal(t, "mode.example.com", adjustments[1].Name)
+	assert.Equal(t, "invalid_redirect", adjustments[1].Reason)
+
+	assert.Equal(t, "FRAME", redirectType(redirect))
+	assert.Equal(t, "URL", p.inwxRecordType(redirect, "old"))
+	// Only CNAME endpoints can be redirects
+	assert.Empty(t, redirectType(endpoint.NewEndpoint("a.example.com", "A", "192.0.2.1").WithProviderSpecific("inwx/url-redirect", "301")))
+}
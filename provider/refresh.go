@@ -0,0 +1,62 @@
+package inwx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+)
+
+// ErrZoneNotFound is returned by RefreshZone for zones that aren't hosted at INWX or are outside of the
+// domain filter.
+var ErrZoneNotFound = errors.New("zone not found")
+
+// ZoneRefresh is the result of a RefreshZone.
+type ZoneRefresh struct {
+	Zone    string `json:"zone"`
+	Records int    `json:"records"`
+	// ManualChanges are the changes made outside of the webhook since the zone was read last.
+	ManualChanges []ManualChange `json:"manualChanges"`
+}
+
+// RefreshZone reads zone from INWX right away and drops the cached zone list and records, so that the next
+// Records reads everything from INWX instead of serving what was cached before, e.g. after records were
+// changed in the INWX web panel. Changes made outside of the webhook are reported like in Records.
+func (p *INWXProvider) RefreshZone(ctx context.Context, zone string) (*ZoneRefresh, error) {
+	done, err := p.lifecycle.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	zone = normalizeName(zone)
+	if !p.filter.MatchZone(zone) {
+		return nil, fmt.Errorf("%w: %s is outside of the domain filter", ErrZoneNotFound, zone)
+	}
+	if err := p.login(ctx); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := p.client.logout(); err != nil {
+			slog.Error("error encountered while logging out", "err", err)
+		}
+	}()
+
+	p.client.forgetZones()
+	p.records.invalidate()
+	zones, err := p.getZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(*zones, zone) {
+		return nil, fmt.Errorf("%w: %s is not hosted at INWX", ErrZoneNotFound, zone)
+	}
+	records, err := p.getRecords(ctx, zone)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query DNS zone info for zone '%v': %w", zone, err)
+	}
+	changes := p.observeZone(zone, *records)
+	p.logger.Info("zone refreshed", "zone", zone, "records", len(*records), "manual_changes", len(changes))
+	return &ZoneRefresh{Zone: zone, Records: len(*records), ManualChanges: append([]ManualChange{}, changes...)}, nil
+}
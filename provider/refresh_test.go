@@ -0,0 +1,45 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshZone(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	p.records = newRecordsCache(time.Hour, 0)
+	p.drift = newDriftTracker()
+	require.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "www", Type: "A", Content: "192.0.2.1", TTL: 300}))
+	records, err := p.Records(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+
+	// A record created in the web panel isn't seen until the cache expires
+	require.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "panel", Type: "A", Content: "192.0.2.2", TTL: 300}))
+	records, err = p.Records(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+
+	// unless the zone is refreshed, which reports the change
+	refreshed, err := p.RefreshZone(context.TODO(), "Example.com.")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", refreshed.Zone)
+	assert.Equal(t, 2, refreshed.Records)
+	require.Len(t, refreshed.ManualChanges, 1)
+	assert.Equal(t, ManualChange{Zone: "example.com", Name: "panel", Type: "A", Change: "created", NewContent: "192.0.2.2"}, refreshed.ManualChanges[0])
+	records, err = p.Records(context.TODO())
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	// Zones not hosted at INWX or outside of the domain filter aren't found
+	_, err = p.RefreshZone(context.TODO(), "sub.example.com")
+	assert.ErrorIs(t, err, ErrZoneNotFound)
+	_, err = p.RefreshZone(context.TODO(), "example.org")
+	assert.ErrorIs(t, err, ErrZoneNotFound)
+}
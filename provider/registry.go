@@ -0,0 +1,278 @@
+package inwx
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// Registry captures how the external-dns registry in use encodes record ownership, so that features
+// depending on ownership records are implemented once regardless of the registry external-dns runs with.
+type Registry interface {
+	// RecordName returns the INWX record name for dnsName within zone.
+	RecordName(dnsName string, zone string) string
+	// EndpointName returns the DNS name external-dns knows an INWX record by; the inverse of RecordName.
+	EndpointName(name string, zone string, recordType string) string
+	// BelongsToZone reports whether dnsName, which doesn't end in zone, is nevertheless stored in it
+	// (e.g. an apex ownership record).
+	BelongsToZone(dnsName string, zone string) bool
+	// IsOwnershipRecord reports whether the record is an ownership record written by the registry.
+	IsOwnershipRecord(dnsName string, recordType string, content string) bool
+	// OwnedEndpoint returns the name and type of the endpoint an ownership record refers to.
+	OwnedEndpoint(dnsName string) (name string, recordType string, ok bool)
+}
+
+// isHeritageRecord reports whether a record holds an external-dns ownership label set.
+func isHeritageRecord(recordType string, content string) bool {
+	return recordType == endpoint.RecordTypeTXT && strings.Contains(strings.Trim(content, `"`), "heritage=external-dns")
+}
+
+// plainRecordName strips the zone from dnsName without any further interpretation.
+func plainRecordName(dnsName string, zone string) string {
+	if dnsName == zone {
+		return ""
+	}
+	if name, ok := classlessRecordName(dnsName, zone); ok {
+		return name
+	}
+	return strings.TrimSuffix(dnsName, "."+zone)
+}
+
+// plainEndpointName appends the zone to an INWX record name; the apex is reported as the zone itself.
+func plainEndpointName(name string, zone string) string {
+	if name == "" {
+		return zone
+	}
+	if parent, ok := classlessParent(zone); ok {
+		return name + "." + parent
+	}
+	return name + "." + zone
+}
+
+// NoopRegistry is used when external-dns runs with --registry=noop: there are no ownership records and
+// names are never rewritten. It is also the right choice for registries that keep ownership outside of
+// DNS, such as the DynamoDB registry.
+type NoopRegistry struct{}
+
+func (NoopRegistry) RecordName(dnsName string, zone string) string {
+	return plainRecordName(dnsName, zone)
+}
+
+func (NoopRegistry) EndpointName(name string, zone string, _ string) string {
+	return plainEndpointName(name, zone)
+}
+
+func (NoopRegistry) BelongsToZone(string, string) bool {
+	return false
+}
+
+func (NoopRegistry) IsOwnershipRecord(string, string, string) bool {
+	return false
+}
+
+func (NoopRegistry) OwnedEndpoint(string) (string, string, bool) {
+	return "", "", false
+}
+
+// LegacyRegistry keeps the built-in heuristics for apex ownership records: trailing zone labels leaking
+// into record names are stripped (see extractRecordName) and zones are also matched after a hyphen
+// boundary (see getZone).
+type LegacyRegistry struct{}
+
+func (LegacyRegistry) RecordName(dnsName string, zone string) string {
+	return extractRecordName(dnsName, zone)
+}
+
+func (LegacyRegistry) EndpointName(name string, zone string, _ string) string {
+	return plainEndpointName(name, zone)
+}
+
+func (LegacyRegistry) BelongsToZone(dnsName string, zone string) bool {
+	return strings.HasSuffix(dnsName, "-"+zone)
+}
+
+func (LegacyRegistry) IsOwnershipRecord(_ string, recordType string, content string) bool {
+	return isHeritageRecord(recordType, content)
+}
+
+func (LegacyRegistry) OwnedEndpoint(string) (string, string, bool) {
+	return "", "", false
+}
+
+// txtRecordTemplate is the placeholder external-dns replaces with the lower-cased record type in TXT affixes.
+const txtRecordTemplate = "%{record_type}"
+
+// txtRegistryRecordTypes are the record types external-dns creates ownership TXT records for.
+var txtRegistryRecordTypes = []string{"A", "AAAA", "CNAME", "NS", "MX", "PTR"}
+
+// TXTRegistry mirrors the naming scheme of the external-dns TXT registry, configured with the same
+// --txt-prefix, --txt-suffix and --txt-wildcard-replacement values as external-dns itself.
+type TXTRegistry struct {
+	prefix              string
+	suffix              string
+	wildcardReplacement string
+}
+
+func NewTXTRegistry(prefix string, suffix string, wildcardReplacement string) *TXTRegistry {
+	return &TXTRegistry{
+		prefix:              strings.ToLower(prefix),
+		suffix:              strings.ToLower(suffix),
+		wildcardReplacement: strings.ToLower(wildcardReplacement),
+	}
+}
+
+func (r *TXTRegistry) recordTypeInAffix() bool {
+	return strings.Contains(r.prefix, txtRecordTemplate) || strings.Contains(r.suffix, txtRecordTemplate)
+}
+
+// txtName returns the name of the ownership TXT record external-dns creates for an endpoint.
+func (r *TXTRegistry) txtName(endpointName string, recordType string) string {
+	labels := strings.SplitN(endpointName, ".", 2)
+	recordType = strings.ToLower(recordType)
+	prefix := strings.ReplaceAll(r.prefix, txtRecordTemplate, recordType)
+	suffix := strings.ReplaceAll(r.suffix, txtRecordTemplate, recordType)
+
+	if r.wildcardReplacement != "" && labels[0] == "*" {
+		labels[0] = r.wildcardReplacement
+	}
+	if !r.recordTypeInAffix() {
+		labels[0] = recordType + "-" + labels[0]
+	}
+	if len(labels) < 2 {
+		return prefix + labels[0] + suffix
+	}
+	return prefix + labels[0] + suffix + "." + labels[1]
+}
+
+// apexTXTName returns the ownership TXT name for the apex endpoint of zone if dnsName is one,
+// either as generated by external-dns or with the zone appended once more.
+func (r *TXTRegistry) apexTXTName(dnsName string, zone string) (string, bool) {
+	for _, recordType := range txtRegistryRecordTypes {
+		name := r.txtName(zone, recordType)
+		if dnsName == name || dnsName == name+"."+zone {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// apexRecordName returns the INWX record name for the apex ownership TXT name txtName of zone.
+// external-dns inserts its affixes into the first zone label, so the generated name ends in the
+// zone's parent labels instead of the zone itself (e.g. _edns.a-example.com for example.com).
+func apexRecordName(txtName string, zone string) string {
+	if _, parent, ok := strings.Cut(zone, "."); ok {
+		return strings.TrimSuffix(txtName, "."+parent)
+	}
+	return txtName
+}
+
+func (r *TXTRegistry) RecordName(dnsName string, zone string) string {
+	if txtName, ok := r.apexTXTName(dnsName, zone); ok {
+		return apexRecordName(txtName, zone)
+	}
+	return plainRecordName(dnsName, zone)
+}
+
+func (r *TXTRegistry) EndpointName(name string, zone string, recordType string) string {
+	if recordType == endpoint.RecordTypeTXT {
+		for _, t := range txtRegistryRecordTypes {
+			if txtName := r.txtName(zone, t); name == apexRecordName(txtName, zone) {
+				return txtName
+			}
+		}
+	}
+	return plainEndpointName(name, zone)
+}
+
+func (r *TXTRegistry) BelongsToZone(dnsName string, zone string) bool {
+	_, ok := r.apexTXTName(dnsName, zone)
+	return ok
+}
+
+func (r *TXTRegistry) IsOwnershipRecord(dnsName string, recordType string, content string) bool {
+	if !isHeritageRecord(recordType, content) {
+		return false
+	}
+	_, _, ok := r.OwnedEndpoint(dnsName)
+	return ok
+}
+
+// OwnedEndpoint reverses txtName, following the external-dns affix name mapper.
+func (r *TXTRegistry) OwnedEndpoint(dnsName string) (string, string, bool) {
+	dnsName = strings.ToLower(dnsName)
+	first, rest, hasRest := strings.Cut(dnsName, ".")
+
+	if r.suffix == "" {
+		// Prefixes may contain dots, so match them against the full name.
+		for _, t := range txtRegistryRecordTypes {
+			prefix := strings.ReplaceAll(r.prefix, txtRecordTemplate, strings.ToLower(t))
+			if !strings.HasPrefix(dnsName, prefix) {
+				continue
+			}
+			name := strings.TrimPrefix(dnsName, prefix)
+			if r.recordTypeInAffix() {
+				return name, t, true
+			}
+			if trimmed, ok := strings.CutPrefix(name, strings.ToLower(t)+"-"); ok {
+				return trimmed, t, true
+			}
+		}
+		return "", "", false
+	}
+
+	// Suffixes are appended to the first label only.
+	for _, t := range txtRegistryRecordTypes {
+		suffix := strings.ReplaceAll(r.suffix, txtRecordTemplate, strings.ToLower(t))
+		label, ok := strings.CutSuffix(first, suffix)
+		if !ok || strings.Contains(suffix, ".") {
+			continue
+		}
+		if !r.recordTypeInAffix() {
+			if label, ok = strings.CutPrefix(label, strings.ToLower(t)+"-"); !ok {
+				continue
+			}
+		}
+		if !hasRest {
+			return label, t, true
+		}
+		return label + "." + rest, t, true
+	}
+	return "", "", false
+}
+
+// zoneFor returns the zone an endpoint belongs to: the longest zone it ends in, the classless reverse zone
+// holding its address, or failing that the longest zone the registry stores it in.
+func (p *INWXProvider) zoneFor(zones *[]string, ep *endpoint.Endpoint) (string, error) {
+	match, by := "", "suffix"
+	for _, zone := range *zones {
+		if (ep.DNSName == zone || strings.HasSuffix(ep.DNSName, "."+zone)) && len(zone) > len(match) {
+			match = zone
+		}
+	}
+	// PTR records in classless reverse zones, and their ownership records, only end in the parent zone.
+	name := ep.DNSName
+	if owned, _, ok := p.registry.OwnedEndpoint(name); ok {
+		name = owned
+	}
+	if zone, ok := classlessZone(zones, name); ok {
+		match, by = zone, "address"
+	}
+	if match == "" {
+		by = "registry"
+		for _, zone := range *zones {
+			if p.registry.BelongsToZone(ep.DNSName, zone) && len(zone) > len(match) {
+				match = zone
+			}
+		}
+	}
+	if match == "" {
+		p.traceNormalization("zone_match", "dns_name", ep.DNSName, "type", ep.RecordType, "zone", "")
+		return "", fmt.Errorf("unable find matching zone for the endpoint %s", ep)
+	}
+	if p.config.traceNormalization {
+		p.traceNormalization("zone_match", "dns_name", ep.DNSName, "type", ep.RecordType, "zone", match, "by", by)
+		p.traceNormalization("record_name", "dns_name", ep.DNSName, "type", ep.RecordType, "zone", match, "record_name", p.registry.RecordName(ep.DNSName, match))
+	}
+	return match, nil
+}
@@ -0,0 +1,169 @@
+package inwx
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestTXTRegistry(t *testing.T) {
+	t.Run("TXTName", testTXTName)
+	t.Run("RecordNameRoundTrip", testRegistryRecordNameRoundTrip)
+	t.Run("OwnedEndpoint", testRegistryOwnedEndpoint)
+	t.Run("Noop", testNoopRegistry)
+	t.Run("ZoneFor", testRegistryZoneFor)
+	t.Run("ApplyAndRecords", testRegistryApplyAndRecords)
+	t.Run("AtomicOwnership", testAtomicOwnership)
+}
+
+func testTXTName(t *testing.T) {
+	assert.Equal(t, "a-foo.example.com", NewTXTRegistry("", "", "").txtName("foo.example.com", "A"))
+	assert.Equal(t, "_edns.a-example.com", NewTXTRegistry("_edns.", "", "").txtName("example.com", "A"))
+	assert.Equal(t, "cname-foo-owner.example.com", NewTXTRegistry("", "-owner", "").txtName("foo.example.com", "CNAME"))
+	assert.Equal(t, "aaaa._edns.foo.example.com", NewTXTRegistry("%{record_type}._edns.", "", "").txtName("foo.example.com", "AAAA"))
+	assert.Equal(t, "_edns.a-wild.example.com", NewTXTRegistry("_edns.", "", "wild").txtName("*.example.com", "A"))
+}
+
+func testRegistryRecordNameRoundTrip(t *testing.T) {
+	for _, registry := range []*TXTRegistry{
+		NewTXTRegistry("", "", ""),
+		NewTXTRegistry("_edns.", "", ""),
+		NewTXTRegistry("", "-owner", ""),
+		NewTXTRegistry("%{record_type}-", "", ""),
+	} {
+		for _, zone := range []string{"example.com", "example.co.uk"} {
+			for _, dnsName := range []string{zone, "foo." + zone, "foo.bar." + zone, registry.txtName(zone, "A"), registry.txtName("foo."+zone, "CNAME")} {
+				name := registry.RecordName(dnsName, zone)
+				assert.Equal(t, dnsName, registry.EndpointName(name, zone, "TXT"), "prefix=%q suffix=%q name=%s", registry.prefix, registry.suffix, dnsName)
+			}
+		}
+	}
+
+	registry := NewTXTRegistry("_edns.", "", "")
+	assert.Equal(t, "_edns.a-example", registry.RecordName("_edns.a-example.com", "example.com"))
+	assert.Equal(t, "_edns.a-example", registry.RecordName("_edns.a-example.com.example.com", "example.com"))
+	// Unlike the heuristic, names that merely resemble the zone are not mangled
+	assert.Equal(t, "foo.com", registry.RecordName("foo.com.example.com", "example.com"))
+}
+
+func testRegistryOwnedEndpoint(t *testing.T) {
+	owner := "\"heritage=external-dns,external-dns/owner=default\""
+	for _, registry := range []*TXTRegistry{
+		NewTXTRegistry("", "", ""),
+		NewTXTRegistry("_edns.", "", ""),
+		NewTXTRegistry("", "-owner", ""),
+		NewTXTRegistry("%{record_type}._edns.", "", ""),
+	} {
+		for _, dnsName := range []string{"foo.example.com", "example.com"} {
+			name, recordType, ok := registry.OwnedEndpoint(registry.txtName(dnsName, "AAAA"))
+			assert.True(t, ok, "prefix=%q suffix=%q name=%s", registry.prefix, registry.suffix, dnsName)
+			assert.Equal(t, dnsName, name)
+			assert.Equal(t, "AAAA", recordType)
+			assert.True(t, registry.IsOwnershipRecord(registry.txtName(dnsName, "AAAA"), "TXT", owner))
+		}
+	}
+
+	registry := NewTXTRegistry("_edns.", "", "")
+	_, _, ok := registry.OwnedEndpoint("foo.example.com")
+	assert.False(t, ok)
+	assert.False(t, registry.IsOwnershipRecord("_edns.a-foo.example.com", "TXT", "v=spf1 -all"))
+	assert.False(t, registry.IsOwnershipRecord("foo.example.com", "TXT", owner))
+	assert.True(t, LegacyRegistry{}.IsOwnershipRecord("foo.example.com", "TXT", owner))
+}
+
+func testNoopRegistry(t *testing.T) {
+	registry := NoopRegistry{}
+	assert.Equal(t, "", registry.RecordName("example.com", "example.com"))
+	assert.Equal(t, "_edns.a-example.com", registry.RecordName("_edns.a-example.com.example.com", "example.com"))
+	assert.Equal(t, "example.com", registry.EndpointName("", "example.com", "TXT"))
+	assert.False(t, registry.IsOwnershipRecord("a-foo.example.com", "TXT", "heritage=external-dns"))
+
+	p := &INWXProvider{registry: registry}
+	_, err := p.zoneFor(&[]string{"example.com"}, &endpoint.Endpoint{DNSName: "_edns.a-example.com"})
+	assert.Error(t, err)
+}
+
+func testRegistryZoneFor(t *testing.T) {
+	zones := &[]string{"example.com", "sub.example.com", "example.org"}
+	p := &INWXProvider{registry: NewTXTRegistry("_edns.", "", "")}
+
+	for dnsName, zone := range map[string]string{
+		"foo.example.com":         "example.com",
+		"foo.sub.example.com":     "sub.example.com",
+		"_edns.a-example.org":     "example.org",
+		"_edns.cname-example.com": "example.com",
+	} {
+		z, err := p.zoneFor(zones, &endpoint.Endpoint{DNSName: dnsName})
+		assert.NoError(t, err)
+		assert.Equal(t, zone, z, dnsName)
+	}
+
+	// The hyphen heuristic is not applied to names that aren't registry records
+	_, err := p.zoneFor(zones, &endpoint.Endpoint{DNSName: "my-example.org"})
+	assert.Error(t, err)
+}
+
+func testRegistryApplyAndRecords(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	p.registry = NewTXTRegistry("_edns.", "", "")
+
+	owner := "heritage=external-dns,external-dns/owner=default"
+	err := p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("example.com", "CNAME", "lb.example.net"),
+			endpoint.NewEndpoint("_edns.cname-example.com", "TXT", owner),
+		},
+	})
+	assert.NoError(t, err)
+
+	recs, _ := w.getRecords("example.com")
+	require.Len(t, *recs, 2)
+	assert.Equal(t, "", (*recs)[0].Name)
+	assert.Equal(t, "_edns.cname-example", (*recs)[1].Name)
+
+	eps, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	require.Len(t, eps, 2)
+	assert.Equal(t, "example.com", eps[0].DNSName)
+	assert.Equal(t, "_edns.cname-example.com", eps[1].DNSName)
+}
+
+func testAtomicOwnership(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	p.registry = NewTXTRegistry("", "", "")
+	p.config.atomicOwnership = true
+	w.CreateZone("example.com")
+	w.createErr = func(r *recordRequest) error {
+		if r.Name == "a-bar" {
+			return errors.New("quota exceeded")
+		}
+		return nil
+	}
+
+	heritage := `"heritage=external-dns,external-dns/owner=default"`
+	err := p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("foo.example.com", "A", "1.1.1.1"),
+			endpoint.NewEndpoint("bar.example.com", "A", "2.2.2.2"),
+			endpoint.NewEndpoint("a-foo.example.com", "TXT", heritage),
+			endpoint.NewEndpoint("a-bar.example.com", "TXT", heritage),
+			endpoint.NewEndpoint("baz.example.com", "CNAME", "example.org"),
+		},
+	})
+	assert.Error(t, err)
+
+	// bar.example.com was rolled back since its ownership record failed
+	recs, _ := w.getRecords("example.com")
+	names := []string{}
+	for _, rec := range *recs {
+		names = append(names, rec.Name)
+	}
+	assert.ElementsMatch(t, []string{"foo", "a-foo", "baz"}, names)
+}
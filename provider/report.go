@@ -0,0 +1,181 @@
+package inwx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ReportFormat is a format reports are written in.
+type ReportFormat string
+
+const (
+	ReportJSON     ReportFormat = "json"
+	ReportMarkdown ReportFormat = "markdown"
+)
+
+// ParseReportFormat returns the ReportFormat named s.
+func ParseReportFormat(s string) (ReportFormat, error) {
+	switch f := ReportFormat(s); f {
+	case ReportJSON, ReportMarkdown:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown report format %q, expected json or markdown", s)
+	}
+}
+
+// extension returns the file extension of reports in format f.
+func (f ReportFormat) extension() string {
+	if f == ReportMarkdown {
+		return ".md"
+	}
+	return ".json"
+}
+
+// Report summarizes a single ApplyChanges.
+type Report struct {
+	Time            Timestamp       `json:"time"`
+	TraceID         string          `json:"traceId,omitempty"`
+	PlanHash        string          `json:"planHash"`
+	DurationSeconds float64         `json:"durationSeconds"`
+	Result          string          `json:"result"`
+	Error           string          `json:"error,omitempty"`
+	Summary         ReportSummary   `json:"summary"`
+	Changes         []AppliedChange `json:"changes"`
+}
+
+// ReportSummary counts the changes of a Report. Created, Updated and Deleted count the changes applied
+// successfully.
+type ReportSummary struct {
+	Created  int `json:"created"`
+	Updated  int `json:"updated"`
+	Deleted  int `json:"deleted"`
+	Skipped  int `json:"skipped"`
+	Warnings int `json:"warnings"`
+	Errors   int `json:"errors"`
+}
+
+// newReport builds the report of an ApplyChanges with the plan hash, started at start and ended at end
+// with err.
+func newReport(changes []AppliedChange, hash string, traceID string, start Timestamp, end time.Time, err error) Report {
+	report := Report{
+		Time:            start,
+		TraceID:         traceID,
+		PlanHash:        hash,
+		DurationSeconds: end.Sub(start.Time).Seconds(),
+		Result:          "success",
+		Changes:         changes,
+	}
+	if err != nil {
+		report.Result, report.Error = "failed", err.Error()
+	}
+	for _, change := range changes {
+		switch {
+		case change.Skipped != "":
+			report.Summary.Skipped++
+		case change.Severity == "warning":
+			report.Summary.Warnings++
+		case change.Error != "":
+			report.Summary.Errors++
+		case change.Action == string(actionCreate):
+			report.Summary.Created++
+		case change.Action == string(actionUpdate):
+			report.Summary.Updated++
+		case change.Action == string(actionDelete):
+			report.Summary.Deleted++
+		}
+	}
+	return report
+}
+
+// Markdown renders the report for a pull request comment or release notes.
+func (r Report) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## DNS changes\n\n")
+	fmt.Fprintf(&b, "**%s** at %s: %d created, %d updated, %d deleted, %d skipped, %d warnings, %d errors\n\n", r.Result,
+		r.Time, r.Summary.Created, r.Summary.Updated, r.Summary.Deleted, r.Summary.Skipped, r.Summary.Warnings, r.Summary.Errors)
+	if r.Error != "" {
+		fmt.Fprintf(&b, "> %s\n\n", markdownCell(r.Error))
+	}
+	if len(r.Changes) > 0 {
+		b.WriteString("| Action | Zone | Name | Type | Content | Result |\n|---|---|---|---|---|---|\n")
+		for _, c := range r.Changes {
+			result := "applied"
+			switch {
+			case c.Skipped != "":
+				result = "skipped: " + c.Skipped
+			case c.Error != "":
+				result = c.Severity + ": " + c.Error
+			}
+			name := c.Name
+			if name == "" {
+				name = "@"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | `%s` | %s |\n", c.Action, markdownCell(c.Zone), markdownCell(name), c.Type,
+				strings.ReplaceAll(markdownCell(c.Content), "`", "'"), markdownCell(result))
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "<sub>Plan %s", r.PlanHash)
+	if r.TraceID != "" {
+		fmt.Fprintf(&b, ", trace %s", r.TraceID)
+	}
+	b.WriteString("</sub>\n")
+	return b.String()
+}
+
+// markdownCell escapes s for a markdown table cell.
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ", "\r", "").Replace(s)
+}
+
+// writeReport writes the report of an ApplyChanges to the report directory, as a file per apply named
+// after its time and plan hash, and as latest.json or latest.md. A failure is only logged, as the
+// changes were applied already.
+func (p *INWXProvider) writeReport(ctx context.Context, recorder *ChangeRecorder, hash string, start time.Time, err error) {
+	if p.config.reportDir == "" {
+		return
+	}
+	report := newReport(recorder.Changes(), hash, TraceID(ctx), p.timestamp(start), now(p.config.clock), err)
+	// File names stay in UTC, so that they sort by time whatever the configured time zone.
+	name := start.UTC().Format("20060102T150405.000Z") + "-" + hash[:min(len(hash), 12)]
+	for _, format := range p.config.reportFormats {
+		var data []byte
+		if format == ReportMarkdown {
+			data = []byte(report.Markdown())
+		} else {
+			var err error
+			if data, err = json.MarshalIndent(report, "", "  "); err != nil {
+				p.logger.Error("failed to encode report", "err", err)
+				continue
+			}
+		}
+		for _, file := range []string{name, "latest"} {
+			if err := writeFileAtomic(filepath.Join(p.config.reportDir, file+format.extension()), data); err != nil {
+				p.logger.Error("failed to write report", "err", err)
+			}
+		}
+	}
+}
+
+// writeFileAtomic writes data to path through a temporary file, so that readers never see a partial
+// file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".report-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
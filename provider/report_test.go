@@ -0,0 +1,117 @@
+package inwx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestReport(t *testing.T) {
+	t.Run("Write", testWriteReport)
+	t.Run("Markdown", testReportMarkdown)
+	t.Run("Timestamps", testReportTimestamps)
+}
+
+func testWriteReport(t *testing.T) {
+	dir := t.TempDir()
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	p.config.reportDir, p.config.reportFormats = dir, []ReportFormat{ReportJSON, ReportMarkdown}
+	p.config.clock = &fakeClock{now: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	p.config.zoneConfig = &ZoneConfig{Zones: map[string]ZoneSettings{"example.com": {ProtectedNames: []string{"www"}}}}
+	w.CreateZone("example.com")
+	require.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "old", Type: "A", Content: "3.3.3.3"}))
+
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("foo.example.com", "A", "1.1.1.1"),
+			endpoint.NewEndpoint("www.example.com", "A", "2.2.2.2"),
+		},
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("old.example.com", "A", "3.3.3.3")},
+	}))
+
+	files, err := filepath.Glob(filepath.Join(dir, "20240102T030405.000Z-*"))
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+	data, err := os.ReadFile(filepath.Join(dir, "latest.json"))
+	require.NoError(t, err)
+	var report Report
+	require.NoError(t, json.Unmarshal(data, &report))
+	assert.Equal(t, "success", report.Result)
+	assert.Equal(t, ReportSummary{Created: 1, Deleted: 1, Skipped: 1}, report.Summary)
+	assert.Len(t, report.Changes, 3)
+	assert.NotEmpty(t, report.PlanHash)
+	markdown, err := os.ReadFile(filepath.Join(dir, "latest.md"))
+	require.NoError(t, err)
+	assert.Contains(t, string(markdown), "| create | example.com | www | A | `2.2.2.2` | skipped: protected |")
+}
+
+func testReportMarkdown(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	report := newReport([]AppliedChange{
+		{Action: "create", Zone: "example.com", Name: "", Type: "TXT", Content: "a|b", Error: "quota exceeded", Severity: "error"},
+		{Action: "delete", Zone: "example.com", Name: "gone", Type: "A", Content: "1.1.1.1", Error: "Object does not exist", Severity: "warning"},
+	}, "0123456789abcdef", "4bf92f3577b34da6a3ce929d0e0e4736", Timestamp{Time: start}, start.Add(time.Second), errors.New("encountered 1 errors while applying changes"))
+	assert.Equal(t, ReportSummary{Warnings: 1, Errors: 1}, report.Summary)
+	assert.Equal(t, 1.0, report.DurationSeconds)
+	assert.Equal(t, "## DNS changes\n\n"+
+		"**failed** at 2024-01-02T03:04:05Z: 0 created, 0 updated, 0 deleted, 0 skipped, 1 warnings, 1 errors\n\n"+
+		"> encountered 1 errors while applying changes\n\n"+
+		"| Action | Zone | Name | Type | Content | Result |\n|---|---|---|---|---|---|\n"+
+		"| create | example.com | @ | TXT | `a\\|b` | error: quota exceeded |\n"+
+		"| delete | example.com | gone | A | `1.1.1.1` | warning: Object does not exist |\n\n"+
+		"<sub>Plan 0123456789abcdef, trace 4bf92f3577b34da6a3ce929d0e0e4736</sub>\n", report.Markdown())
+
+	_, err := ParseReportFormat("html")
+	assert.Error(t, err)
+}
+
+func testReportTimestamps(t *testing.T) {
+	layout, err := ParseTimestampLayout("rfc3339")
+	require.NoError(t, err)
+	assert.Equal(t, time.RFC3339, layout)
+	layout, err = ParseTimestampLayout("02.01.2006 15:04:05 MST")
+	require.NoError(t, err)
+	assert.Equal(t, "02.01.2006 15:04:05 MST", layout)
+	_, err = ParseTimestampLayout("dd.mm.yyyy")
+	assert.Error(t, err)
+
+	dir := t.TempDir()
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	p.config.timestampLayout, p.config.timestampLocation = "02.01.2006 15:04:05 MST", berlin
+	p.config.reportDir, p.config.reportFormats = dir, []ReportFormat{ReportJSON, ReportMarkdown}
+	p.config.clock = &fakeClock{now: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	auditLog := filepath.Join(t.TempDir(), "audit.log")
+	p.config.auditStore, err = NewFileAuditStore(auditLog)
+	require.NoError(t, err)
+	w.CreateZone("example.com")
+
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.com", "A", "1.1.1.1")}}))
+
+	// File names stay in UTC
+	files, err := filepath.Glob(filepath.Join(dir, "20240102T030405.000Z-*"))
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+	data, err := os.ReadFile(filepath.Join(dir, "latest.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"time": "02.01.2024 04:04:05 CET"`)
+	markdown, err := os.ReadFile(filepath.Join(dir, "latest.md"))
+	require.NoError(t, err)
+	assert.Contains(t, string(markdown), "**success** at 02.01.2024 04:04:05 CET")
+
+	require.NoError(t, p.Close())
+	lines, err := os.ReadFile(auditLog)
+	require.NoError(t, err)
+	assert.Contains(t, string(lines), `"time":"02.01.2024 04:04:05 CET"`)
+}
@@ -0,0 +1,270 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+const (
+	reverseSuffixIPv4 = "in-addr.arpa"
+	reverseSuffixIPv6 = "ip6.arpa"
+)
+
+// ReverseName returns the name of the PTR record for addr, e.g. 5.2.0.192.in-addr.arpa for 192.0.2.5.
+func ReverseName(addr netip.Addr) string {
+	addr = addr.Unmap()
+	suffix := reverseSuffixIPv6
+	if addr.Is4() {
+		suffix = reverseSuffixIPv4
+	}
+	return strings.Join(append(reverseLabels(addr), suffix), ".")
+}
+
+// reverseLabels returns the octets (IPv4) or nibbles (IPv6) of addr as reverse name labels, least
+// significant first.
+func reverseLabels(addr netip.Addr) []string {
+	var labels []string
+	if addr.Is4() {
+		for _, b := range addr.As4() {
+			labels = append(labels, strconv.Itoa(int(b)))
+		}
+	} else {
+		for _, b := range addr.As16() {
+			labels = append(labels, strconv.FormatUint(uint64(b>>4), 16), strconv.FormatUint(uint64(b&0xf), 16))
+		}
+	}
+	slices.Reverse(labels)
+	return labels
+}
+
+// isReverseZone reports whether zone lies below in-addr.arpa or ip6.arpa.
+func isReverseZone(zone string) bool {
+	return strings.HasSuffix(zone, "."+reverseSuffixIPv4) || strings.HasSuffix(zone, "."+reverseSuffixIPv6)
+}
+
+// reverseZonePrefix returns the addresses a reverse zone holds the PTR records of, and the number of
+// address labels (octets or nibbles) the zone name consists of. Classless IPv4 zones as described in
+// RFC 2317 are supported in both the 0/25.2.0.192.in-addr.arpa and the 0-25.2.0.192.in-addr.arpa form.
+func reverseZonePrefix(zone string) (netip.Prefix, int, bool) {
+	if labels, ok := strings.CutSuffix(zone, "."+reverseSuffixIPv4); ok {
+		octets := strings.Split(labels, ".")
+		bits := -1
+		if first, size, ok := strings.Cut(octets[0], "/"); ok || strings.Contains(octets[0], "-") {
+			if !ok {
+				first, size, _ = strings.Cut(octets[0], "-")
+			}
+			n, err := strconv.ParseUint(size, 10, 8)
+			if err != nil || n <= 24 || n > 32 || len(octets) != 4 {
+				return netip.Prefix{}, 0, false
+			}
+			bits, octets[0] = int(n), first
+		}
+		if len(octets) > 4 {
+			return netip.Prefix{}, 0, false
+		}
+		var b [4]byte
+		for i, octet := range slices.Backward(octets) {
+			n, err := strconv.ParseUint(octet, 10, 8)
+			if err != nil || octet != strconv.FormatUint(n, 10) {
+				return netip.Prefix{}, 0, false
+			}
+			b[len(octets)-1-i] = byte(n)
+		}
+		if bits < 0 {
+			return netip.PrefixFrom(netip.AddrFrom4(b), 8*len(octets)), len(octets), true
+		}
+		prefix := netip.PrefixFrom(netip.AddrFrom4(b), bits)
+		if prefix.Masked() != prefix {
+			return netip.Prefix{}, 0, false
+		}
+		return prefix, len(octets) - 1, true
+	}
+
+	if labels, ok := strings.CutSuffix(zone, "."+reverseSuffixIPv6); ok {
+		nibbles := strings.Split(labels, ".")
+		if len(nibbles) > 32 {
+			return netip.Prefix{}, 0, false
+		}
+		var b [16]byte
+		for i, nibble := range slices.Backward(nibbles) {
+			n, err := strconv.ParseUint(nibble, 16, 4)
+			if err != nil || len(nibble) != 1 {
+				return netip.Prefix{}, 0, false
+			}
+			pos := len(nibbles) - 1 - i
+			b[pos/2] |= byte(n) << (4 * (1 - pos%2))
+		}
+		return netip.PrefixFrom(netip.AddrFrom16(b), 4*len(nibbles)), len(nibbles), true
+	}
+	return netip.Prefix{}, 0, false
+}
+
+// parseReverseName returns the IPv4 address whose PTR record is named name, e.g. 192.0.2.5 for
+// 5.2.0.192.in-addr.arpa.
+func parseReverseName(name string) (netip.Addr, bool) {
+	if !strings.HasSuffix(name, "."+reverseSuffixIPv4) {
+		return netip.Addr{}, false
+	}
+	prefix, _, ok := reverseZonePrefix(name)
+	if !ok || prefix.Bits() != 32 {
+		return netip.Addr{}, false
+	}
+	return prefix.Addr(), true
+}
+
+// classlessParent returns the zone a classless reverse zone as described in RFC 2317 is carved out of,
+// e.g. 2.0.192.in-addr.arpa for 0-25.2.0.192.in-addr.arpa. external-dns names the PTR records of the
+// addresses in a classless zone as in the parent, 5.2.0.192.in-addr.arpa for 192.0.2.5, so the record
+// names of classless zones are relative to the parent.
+func classlessParent(zone string) (string, bool) {
+	prefix, _, ok := reverseZonePrefix(zone)
+	if !ok || !prefix.Addr().Is4() || prefix.Bits()%8 == 0 {
+		return "", false
+	}
+	_, parent, _ := strings.Cut(zone, ".")
+	return parent, true
+}
+
+// classlessRecordName returns the record name of dnsName in the classless reverse zone zone, e.g. 5 for
+// 5.2.0.192.in-addr.arpa in 0-25.2.0.192.in-addr.arpa. Names spelled out within the zone, such as
+// 5.0-25.2.0.192.in-addr.arpa, are left to the usual suffix matching.
+func classlessRecordName(dnsName string, zone string) (string, bool) {
+	parent, ok := classlessParent(zone)
+	if !ok || strings.HasSuffix(dnsName, "."+zone) {
+		return "", false
+	}
+	return strings.CutSuffix(dnsName, "."+parent)
+}
+
+// classlessZone returns the most specific classless reverse zone of zones holding the PTR record dnsName,
+// e.g. 0-25.2.0.192.in-addr.arpa for 5.2.0.192.in-addr.arpa. As these names don't end with the name of
+// their zone, they are matched by their address.
+func classlessZone(zones *[]string, dnsName string) (string, bool) {
+	addr, ok := parseReverseName(dnsName)
+	if !ok {
+		return "", false
+	}
+	match, matchBits := "", -1
+	for _, zone := range *zones {
+		if _, ok := classlessParent(zone); !ok {
+			continue
+		}
+		if prefix, _, _ := reverseZonePrefix(zone); prefix.Contains(addr) && prefix.Bits() > matchBits {
+			match, matchBits = zone, prefix.Bits()
+		}
+	}
+	return match, match != ""
+}
+
+// reverseRecordName returns the name of the PTR record for addr in the most specific of zones holding
+// it, e.g. 5.0-25.2.0.192.in-addr.arpa for 192.0.2.5 if the classless zone 0-25.2.0.192.in-addr.arpa
+// is hosted at INWX.
+func reverseRecordName(zones *[]string, addr netip.Addr) (string, bool) {
+	addr = addr.Unmap()
+	match, matchBits, matchLabels := "", -1, 0
+	for _, zone := range *zones {
+		if !isReverseZone(zone) {
+			continue
+		}
+		prefix, labels, ok := reverseZonePrefix(zone)
+		if ok && prefix.Contains(addr) && prefix.Bits() > matchBits {
+			match, matchBits, matchLabels = zone, prefix.Bits(), labels
+		}
+	}
+	if match == "" {
+		return "", false
+	}
+	hostLabels := reverseLabels(addr)
+	hostLabels = hostLabels[:len(hostLabels)-matchLabels]
+	if len(hostLabels) == 0 {
+		return match, true
+	}
+	return strings.Join(hostLabels, ".") + "." + match, true
+}
+
+// reverseChanges derives the PTR records to create and delete in the reverse zones hosted at INWX from
+// the A and AAAA records in changes. Records whose address lies in none of the zones are left alone.
+func (p *INWXProvider) reverseChanges(zones *[]string, changes *plan.Changes) *plan.Changes {
+	reverse := &plan.Changes{}
+	if !p.config.createPTR {
+		return reverse
+	}
+	ptrs := func(ep *endpoint.Endpoint, exclude *endpoint.Endpoint) []*endpoint.Endpoint {
+		var eps []*endpoint.Endpoint
+		if (ep.RecordType != endpoint.RecordTypeA && ep.RecordType != endpoint.RecordTypeAAAA) || strings.HasPrefix(ep.DNSName, "*") {
+			return nil
+		}
+		for _, target := range ep.Targets {
+			if exclude != nil && exclude.DNSName == ep.DNSName && slices.Contains(exclude.Targets, target) {
+				continue
+			}
+			addr, err := netip.ParseAddr(target)
+			if err != nil {
+				continue
+			}
+			if name, ok := reverseRecordName(zones, addr); ok {
+				eps = append(eps, endpoint.NewEndpointWithTTL(name, endpoint.RecordTypePTR, ep.RecordTTL, ep.DNSName))
+			}
+		}
+		return eps
+	}
+
+	for _, ep := range changes.Create {
+		reverse.Create = append(reverse.Create, ptrs(ep, nil)...)
+	}
+	for i, oldEp := range changes.UpdateOld {
+		newEp := changes.UpdateNew[i]
+		reverse.Delete = append(reverse.Delete, ptrs(oldEp, newEp)...)
+		reverse.Create = append(reverse.Create, ptrs(newEp, oldEp)...)
+	}
+	for _, ep := range changes.Delete {
+		reverse.Delete = append(reverse.Delete, ptrs(ep, nil)...)
+	}
+	return reverse
+}
+
+// applyReverseChanges applies the PTR records derived by reverseChanges. PTR records to delete that
+// don't exist are skipped, since they may predate enabling PTR records.
+func (p *INWXProvider) applyReverseChanges(ctx context.Context, zones *[]string, reverse *plan.Changes) []error {
+	errs := []error{}
+	recordsCache := map[string]*[]zoneRecord{}
+	for _, ep := range reverse.Delete {
+		zone, err := p.zoneFor(zones, ep)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, ok := recordsCache[zone]; !ok {
+			recs, err := p.getRecords(ctx, zone)
+			if err != nil {
+				errs = append(errs, err)
+				slog.Error("failed to query DNS zone info", "zone", zone, "err", err)
+				continue
+			}
+			recordsCache[zone] = recs
+		}
+		name := p.registry.RecordName(ep.DNSName, zone)
+		id := findExactRecord(findRecordsByNameAndType(name, recordsCache[zone], ep.RecordType), ep.Targets[0])
+		if id == "" {
+			slog.Debug("PTR record to delete doesn't exist, skipping", "name", ep.DNSName, "content", ep.Targets[0])
+			continue
+		}
+		if err := p.deleteRecord(ctx, zone, name, ep.RecordType, ep.Targets[0], id); err != nil {
+			errs = append(errs, err)
+			slog.Error("failed to delete PTR record", "id", id, "ep", ep, "err", err)
+		}
+	}
+
+	recordsCache = map[string]*[]zoneRecord{}
+	for _, ep := range reverse.Create {
+		errs = append(errs, p.createEndpoint(ctx, zones, recordsCache, ep, nil)...)
+	}
+	return errs
+}
@@ -0,0 +1,157 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestReverse(t *testing.T) {
+	t.Run("ReverseName", testReverseName)
+	t.Run("ZonePrefix", testReverseZonePrefix)
+	t.Run("RecordName", testReverseRecordName)
+	t.Run("ApplyChanges", testReverseApplyChanges)
+	t.Run("Zones", testReverseZones)
+	t.Run("ClasslessRecords", testClasslessRecords)
+}
+
+func testReverseName(t *testing.T) {
+	assert.Equal(t, "5.2.0.192.in-addr.arpa", ReverseName(netip.MustParseAddr("192.0.2.5")))
+	assert.Equal(t, "5.2.0.192.in-addr.arpa", ReverseName(netip.MustParseAddr("::ffff:192.0.2.5")))
+	assert.Equal(t, "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa", ReverseName(netip.MustParseAddr("2001:db8::1")))
+}
+
+func testReverseZonePrefix(t *testing.T) {
+	for zone, expected := range map[string]string{
+		"2.0.192.in-addr.arpa":        "192.0.2.0/24",
+		"10.in-addr.arpa":             "10.0.0.0/8",
+		"0/25.2.0.192.in-addr.arpa":   "192.0.2.0/25",
+		"128-26.2.0.192.in-addr.arpa": "192.0.2.128/26",
+		"8.b.d.0.1.0.0.2.ip6.arpa":    "2001:db8::/32",
+	} {
+		prefix, _, ok := reverseZonePrefix(zone)
+		assert.True(t, ok, zone)
+		assert.Equal(t, expected, prefix.String(), zone)
+	}
+	for _, zone := range []string{"example.com", "in-addr.arpa", "256.in-addr.arpa", "01.in-addr.arpa", "1-25.2.0.192.in-addr.arpa", "0-16.2.0.192.in-addr.arpa", "ab.ip6.arpa"} {
+		_, _, ok := reverseZonePrefix(zone)
+		assert.False(t, ok, zone)
+	}
+}
+
+func testReverseRecordName(t *testing.T) {
+	zones := []string{"example.com", "0.192.in-addr.arpa", "2.0.192.in-addr.arpa", "128-25.2.0.192.in-addr.arpa", "8.b.d.0.1.0.0.2.ip6.arpa"}
+	for addr, expected := range map[string]string{
+		"192.0.2.5":   "5.2.0.192.in-addr.arpa",
+		"192.0.2.200": "200.128-25.2.0.192.in-addr.arpa",
+		"192.0.3.1":   "1.3.0.192.in-addr.arpa",
+		"2001:db8::1": "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa",
+	} {
+		name, ok := reverseRecordName(&zones, netip.MustParseAddr(addr))
+		assert.True(t, ok, addr)
+		assert.Equal(t, expected, name, addr)
+	}
+	_, ok := reverseRecordName(&zones, netip.MustParseAddr("198.51.100.1"))
+	assert.False(t, ok)
+}
+
+func testReverseApplyChanges(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com", "2.0.192.in-addr.arpa"}, slog.Default())
+	p.config.createPTR = true
+	w.CreateZone("example.com")
+	w.CreateZone("2.0.192.in-addr.arpa")
+	ptrs := func() []string {
+		recs, _ := w.getRecords("2.0.192.in-addr.arpa")
+		ptrs := []string{}
+		for _, rec := range *recs {
+			if rec.Type == endpoint.RecordTypePTR {
+				ptrs = append(ptrs, rec.Name+" "+rec.Content)
+			}
+		}
+		return ptrs
+	}
+
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("foo.example.com", endpoint.RecordTypeA, "192.0.2.5", "198.51.100.1"),
+		endpoint.NewEndpoint("foo.example.com", endpoint.RecordTypeTXT, "\"heritage=external-dns\""),
+	}}))
+	assert.Equal(t, []string{"5 foo.example.com"}, ptrs())
+
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.com", endpoint.RecordTypeA, "192.0.2.5", "198.51.100.1")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.com", endpoint.RecordTypeA, "192.0.2.6")},
+	}))
+	assert.Equal(t, []string{"6 foo.example.com"}, ptrs())
+
+	// PTR records that don't exist, e.g. predating --create-ptr, are skipped
+	assert.NoError(t, w.deleteRecord(findExactRecord(findRecordsByNameAndType("6", w.db["2.0.192.in-addr.arpa"], endpoint.RecordTypePTR), "foo.example.com")))
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.com", endpoint.RecordTypeA, "192.0.2.6")},
+	}))
+	assert.Empty(t, ptrs())
+
+	assert.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "bar", Type: "A", Content: "192.0.2.7"}))
+	assert.NoError(t, w.createRecord(&recordRequest{Domain: "2.0.192.in-addr.arpa", Name: "7", Type: "PTR", Content: "bar.example.com"}))
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("bar.example.com", endpoint.RecordTypeA, "192.0.2.7")},
+	}))
+	assert.Empty(t, ptrs())
+}
+
+func testReverseZones(t *testing.T) {
+	_, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	p.registry = NewTXTRegistry("", "", "")
+	zones := []string{"example.com", "2.0.192.in-addr.arpa", "0-25.2.0.192.in-addr.arpa", "128/26.2.0.192.in-addr.arpa", "8.b.d.0.1.0.0.2.ip6.arpa"}
+	for name, expected := range map[string]string{
+		"5.2.0.192.in-addr.arpa":      "0-25.2.0.192.in-addr.arpa",
+		"130.2.0.192.in-addr.arpa":    "128/26.2.0.192.in-addr.arpa",
+		"200.2.0.192.in-addr.arpa":    "2.0.192.in-addr.arpa",
+		"5.0-25.2.0.192.in-addr.arpa": "0-25.2.0.192.in-addr.arpa",
+		"ptr-5.2.0.192.in-addr.arpa":  "0-25.2.0.192.in-addr.arpa",
+		"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa": "8.b.d.0.1.0.0.2.ip6.arpa",
+	} {
+		ep := endpoint.NewEndpoint(name, endpoint.RecordTypePTR, "foo.example.com")
+		zone, err := p.zoneFor(&zones, ep)
+		assert.NoError(t, err, name)
+		assert.Equal(t, expected, zone, name)
+		if name != "ptr-5.2.0.192.in-addr.arpa" {
+			zone, err = getZone(&zones, ep)
+			assert.NoError(t, err, name)
+			assert.Equal(t, expected, zone, name)
+		}
+	}
+
+	// Record names in classless zones are relative to the parent zone
+	assert.Equal(t, "5", p.registry.RecordName("5.2.0.192.in-addr.arpa", "0-25.2.0.192.in-addr.arpa"))
+	assert.Equal(t, "5", p.registry.RecordName("5.0-25.2.0.192.in-addr.arpa", "0-25.2.0.192.in-addr.arpa"))
+	assert.Equal(t, "5.2.0.192.in-addr.arpa", p.registry.EndpointName("5", "0-25.2.0.192.in-addr.arpa", endpoint.RecordTypePTR))
+	assert.Equal(t, "5", p.registry.RecordName("5.2.0.192.in-addr.arpa", "2.0.192.in-addr.arpa"))
+}
+
+func testClasslessRecords(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"2.0.192.in-addr.arpa"}, slog.Default())
+	w.CreateZone("0-25.2.0.192.in-addr.arpa")
+
+	ptr := endpoint.NewEndpointWithTTL("5.2.0.192.in-addr.arpa", endpoint.RecordTypePTR, 300, "foo.example.com")
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{ptr}}))
+	recs, err := w.getRecords("0-25.2.0.192.in-addr.arpa")
+	assert.NoError(t, err)
+	assert.Equal(t, []zoneRecord{{ID: "0", Name: "5", Type: endpoint.RecordTypePTR, Content: "foo.example.com", TTL: 300}}, *recs)
+
+	// The record is reported under the name external-dns knows it by, so that it plans nothing
+	records, err := p.Records(context.TODO())
+	assert.NoError(t, err)
+	changes := (&plan.Plan{Current: records, Desired: []*endpoint.Endpoint{ptr}, Policies: []plan.Policy{&plan.SyncPolicy{}},
+		ManagedRecords: []string{endpoint.RecordTypePTR}}).Calculate().Changes
+	assert.False(t, changes.HasChanges())
+
+	assert.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Delete: records}))
+	recs, err = w.getRecords("0-25.2.0.192.in-addr.arpa")
+	assert.NoError(t, err)
+	assert.Empty(t, *recs)
+}
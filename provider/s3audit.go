@@ -0,0 +1,192 @@
+package inwx
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// S3Config configures an S3AuditStore.
+type S3Config struct {
+	// Endpoint is the base URL of the S3-compatible API, e.g. https://s3.eu-central-1.amazonaws.com or
+	// https://minio.example.com:9000.
+	Endpoint string
+	Region   string
+	Bucket   string
+	// Prefix is prepended to the object keys, e.g. external-dns/.
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// PathStyle addresses the bucket in the path (endpoint/bucket/key) instead of the host
+	// (bucket.endpoint/key), as most self-hosted S3-compatible stores require.
+	PathStyle bool
+
+	Client *http.Client
+	Clock  Clock
+}
+
+// S3AuditStore writes the audit log to an S3-compatible object store, one object of JSON lines per
+// ApplyChanges, so that retention can be handled by bucket lifecycle rules and object locks. Requests
+// are signed with AWS Signature Version 4.
+type S3AuditStore struct {
+	cfg S3Config
+}
+
+// NewS3AuditStore returns an S3AuditStore; nothing is sent until the first Append.
+func NewS3AuditStore(cfg S3Config) (*S3AuditStore, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 audit log requires a bucket")
+	}
+	if _, err := url.Parse(cfg.Endpoint); err != nil || cfg.Endpoint == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &S3AuditStore{cfg: cfg}, nil
+}
+
+// Append puts entries into a new object named after the time of the first entry and its change ID.
+func (s *S3AuditStore) Append(ctx context.Context, entries []AuditEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	data, err := marshalAuditEntries(entries)
+	if err != nil {
+		return err
+	}
+	first := entries[0]
+	key := s.cfg.Prefix + first.Time.UTC().Format("2006/01/02/150405.000000000") + "-" + first.ID + ".jsonl"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	signS3Request(req, data, s.cfg.AccessKeyID, s.cfg.SecretAccessKey, s.cfg.Region, now(s.cfg.Clock))
+
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to write audit log to S3: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unable to write audit log to S3: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (s *S3AuditStore) Close() error {
+	return nil
+}
+
+// objectURL returns the URL of the object named key.
+func (s *S3AuditStore) objectURL(key string) string {
+	endpoint, _ := url.Parse(strings.TrimSuffix(s.cfg.Endpoint, "/"))
+	if s.cfg.PathStyle {
+		endpoint.Path += "/" + s.cfg.Bucket
+	} else {
+		endpoint.Host = s.cfg.Bucket + "." + endpoint.Host
+	}
+	endpoint.RawPath = s3URIEncode(endpoint.Path+"/"+key, false)
+	endpoint.Path += "/" + key
+	return endpoint.String()
+}
+
+// signS3Request adds the headers and the AWS Signature Version 4 authorization for the S3 service to
+// req, whose body is payload.
+func signS3Request(req *http.Request, payload []byte, accessKeyID string, secretAccessKey string, region string, at time.Time) {
+	at = at.UTC()
+	amzDate, date := at.Format("20060102T150405Z"), at.Format("20060102")
+	payloadHash := sha256Hex(payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.Join(strings.Fields(headers[name]), " ") + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	var canonicalQuery []string
+	for _, key := range keys {
+		for _, value := range query[key] {
+			canonicalQuery = append(canonicalQuery, s3URIEncode(key, true)+"="+s3URIEncode(value, true))
+		}
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3URIEncode(req.URL.Path, false),
+		strings.Join(canonicalQuery, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	for _, part := range []string{region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKeyID+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// s3URIEncode encodes everything but the unreserved characters of RFC 3986 and, unless encodeSlash is
+// set, slashes.
+func s3URIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,207 @@
+package inwx
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SessionScope decides how long an INWX API session lasts.
+type SessionScope string
+
+const (
+	// SessionPerReconcile logs in for every Records and ApplyChanges request and out after it.
+	SessionPerReconcile SessionScope = "per-reconcile"
+	// SessionPerOperation logs in and out around every single API call, one call at a time.
+	SessionPerOperation SessionScope = "per-operation"
+	// SessionPersistent logs in once and keeps the session until the provider is shut down.
+	SessionPersistent SessionScope = "persistent"
+)
+
+// ParseSessionScope returns the SessionScope named s.
+func ParseSessionScope(s string) (SessionScope, error) {
+	switch scope := SessionScope(s); scope {
+	case SessionPerReconcile, SessionPerOperation, SessionPersistent:
+		return scope, nil
+	default:
+		return "", fmt.Errorf("unknown INWX session scope %q, expected per-reconcile, per-operation or persistent", s)
+	}
+}
+
+// INWX API result codes of a call outside of a valid session, e.g. once the session expired.
+const (
+	codeAuthenticationError = 2200
+	codeAuthorizationError  = 2201
+)
+
+// sessionExpired reports whether err means that the session is no longer valid.
+func sessionExpired(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && (apiErr.Code == codeAuthenticationError || apiErr.Code == codeAuthorizationError)
+}
+
+// session tracks the INWX session of a ClientWrapper for the per-operation and persistent scopes.
+type session struct {
+	scope     SessionScope
+	keepalive time.Duration
+
+	// mu serializes the calls of per-operation sessions, so that one call doesn't log out another, and
+	// guards the state of persistent ones.
+	mu       sync.Mutex
+	loggedIn bool
+	used     bool
+	lastUsed time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newSession(scope SessionScope, keepalive time.Duration) *session {
+	return &session{scope: scope, keepalive: keepalive, stop: make(chan struct{})}
+}
+
+// scopeOf returns the scope of s, per-reconcile if there is none.
+func (s *session) scopeOf() SessionScope {
+	if s == nil || s.scope == "" {
+		return SessionPerReconcile
+	}
+	return s.scope
+}
+
+// persistentLogin logs in unless the persistent session is logged in already.
+func (w *ClientWrapper) persistentLogin() error {
+	w.session.mu.Lock()
+	defer w.session.mu.Unlock()
+	if w.session.loggedIn {
+		return nil
+	}
+	if err := w.authenticate(); err != nil {
+		return err
+	}
+	w.session.loggedIn = true
+	w.session.used = false
+	w.session.lastUsed = now(w.clock)
+	w.logger.Debug("logged in to a persistent INWX session")
+	return nil
+}
+
+// inSession runs fn as required by the session scope: between a login and a logout of its own for
+// per-operation sessions, and recording the use of persistent ones for the keepalive.
+//
+// If the session expired, which INWX reports before carrying out the call, it is logged in again and fn
+// is retried once. Per-operation sessions are fresh for every call, so they aren't retried.
+func (w *ClientWrapper) inSession(fn func() error) error {
+	err := w.inSessionOnce(fn)
+	if !sessionExpired(err) || w.session.scopeOf() == SessionPerOperation {
+		return err
+	}
+	w.logger.Info("INWX session expired, logging in again", "err", err)
+	w.metrics.sessionReloginsTotal.Inc()
+	if err := w.relogin(); err != nil {
+		return err
+	}
+	return w.inSessionOnce(fn)
+}
+
+func (w *ClientWrapper) inSessionOnce(fn func() error) error {
+	switch w.session.scopeOf() {
+	case SessionPerOperation:
+		w.session.mu.Lock()
+		defer w.session.mu.Unlock()
+		if err := w.authenticate(); err != nil {
+			return err
+		}
+		defer func() {
+			if err := w.timed("account.logout", "", w.api.logout); err != nil {
+				w.logger.Error("error encountered while logging out", "err", err)
+			}
+		}()
+		return fn()
+	case SessionPersistent:
+		err := fn()
+		w.session.mu.Lock()
+		w.session.used = true
+		w.session.lastUsed = now(w.clock)
+		w.session.mu.Unlock()
+		return err
+	default:
+		return fn()
+	}
+}
+
+// relogin replaces an expired session with a new one.
+func (w *ClientWrapper) relogin() error {
+	if w.session.scopeOf() != SessionPersistent {
+		return w.authenticate()
+	}
+	w.session.mu.Lock()
+	w.session.loggedIn = false
+	w.session.mu.Unlock()
+	return w.persistentLogin()
+}
+
+// checkSession confirms that a persistent session is still valid with a cheap authenticated call before
+// an apply, logging in again if it silently expired, so that the changes don't fail one by one. A session
+// not used since its login is known to be valid.
+func (w *ClientWrapper) checkSession() error {
+	if w.session.scopeOf() != SessionPersistent {
+		return nil
+	}
+	w.session.mu.Lock()
+	fresh := w.session.loggedIn && !w.session.used
+	w.session.mu.Unlock()
+	if fresh {
+		return nil
+	}
+	return w.call("account.info", "", w.api.accountInfo)
+}
+
+// keepAlive keeps a persistent session from expiring by a cheap authenticated call whenever it was idle
+// for the keepalive interval, until the client is closed.
+func (w *ClientWrapper) keepAlive() {
+	ticker := time.NewTicker(w.session.keepalive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.session.stop:
+			return
+		case <-ticker.C:
+			w.ping()
+		}
+	}
+}
+
+// ping calls account.info if the persistent session is logged in and was idle for the keepalive interval.
+func (w *ClientWrapper) ping() {
+	w.session.mu.Lock()
+	idle := w.session.loggedIn && now(w.clock).Sub(w.session.lastUsed) >= w.session.keepalive
+	w.session.mu.Unlock()
+	if !idle {
+		return
+	}
+	if err := w.call("account.info", "", w.api.accountInfo); err != nil {
+		// The session is established again by the next login.
+		w.logger.Warn("INWX session keepalive failed, logging in again on next use", "err", err)
+		w.session.mu.Lock()
+		w.session.loggedIn = false
+		w.session.mu.Unlock()
+	}
+}
+
+// endSession stops the keepalive and logs out of a persistent session.
+func (w *ClientWrapper) endSession() {
+	if w.session.scopeOf() != SessionPersistent {
+		return
+	}
+	w.session.stopOnce.Do(func() { close(w.session.stop) })
+	w.session.mu.Lock()
+	defer w.session.mu.Unlock()
+	if !w.session.loggedIn {
+		return
+	}
+	if err := w.timed("account.logout", "", w.api.logout); err != nil {
+		w.logger.Error("error encountered while logging out", "err", err)
+	}
+	w.session.loggedIn = false
+}
@@ -0,0 +1,219 @@
+package inwx
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingDomRobot is a domRobot recording the methods called.
+type recordingDomRobot struct {
+	calls   []string
+	listErr error
+	infoErr error
+	// expired makes every call but login fail with an authentication error.
+	expired bool
+	// locked makes login report an account with two-factor authentication.
+	locked bool
+}
+
+func (r *recordingDomRobot) login(username string, _ string) (loginResult, error) {
+	r.calls = append(r.calls, strings.TrimSpace("login "+username))
+	r.expired = false
+	return loginResult{locked: r.locked, customerID: 12345, accountID: 67890}, nil
+}
+
+func (r *recordingDomRobot) unlock(tan string) error {
+	r.calls = append(r.calls, "unlock "+tan)
+	return nil
+}
+
+func (r *recordingDomRobot) logout() error {
+	r.calls = append(r.calls, "logout")
+	return nil
+}
+
+func (r *recordingDomRobot) accountInfo() error {
+	r.calls = append(r.calls, "account")
+	if r.expired {
+		return &apiError{Code: codeAuthenticationError, Message: "Authentication error"}
+	}
+	return r.infoErr
+}
+
+func (r *recordingDomRobot) nameserverInfo(string) ([]zoneRecord, error) {
+	r.calls = append(r.calls, "info")
+	if r.expired {
+		return nil, &apiError{Code: codeAuthenticationError, Message: "Authentication error"}
+	}
+	return nil, nil
+}
+
+func (r *recordingDomRobot) nameserverList(int, int) ([]string, int, error) {
+	r.calls = append(r.calls, "list")
+	return []string{"example.com"}, 1, r.listErr
+}
+
+func (r *recordingDomRobot) nameserverCreate(*zoneRequest) error {
+	r.calls = append(r.calls, "create zone")
+	return nil
+}
+
+func (r *recordingDomRobot) createRecord(*recordRequest) error {
+	r.calls = append(r.calls, "create")
+	return nil
+}
+
+func (r *recordingDomRobot) updateRecord(string, *recordRequest) error {
+	r.calls = append(r.calls, "update")
+	return nil
+}
+
+func (r *recordingDomRobot) deleteRecord(string) error {
+	r.calls = append(r.calls, "delete")
+	return nil
+}
+
+func TestSessionScopes(t *testing.T) {
+	// reconcile runs the API calls of a Records request.
+	reconcile := func(w *ClientWrapper) {
+		require.NoError(t, w.login())
+		_, err := w.getRecords("example.com")
+		require.NoError(t, err)
+		require.NoError(t, w.deleteRecord("1"))
+		require.NoError(t, w.logout())
+	}
+	newWrapper := func(scope SessionScope, keepalive time.Duration) (*ClientWrapper, *recordingDomRobot) {
+		api := &recordingDomRobot{}
+		return &ClientWrapper{api: api, logger: slog.Default(), metrics: newMetrics(), clock: &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+			rand: newLockedRand(nil), session: newSession(scope, keepalive)}, api
+	}
+
+	t.Run("PerReconcile", func(t *testing.T) {
+		for _, w := range []*ClientWrapper{{session: nil}, {session: newSession(SessionPerReconcile, 0)}} {
+			api := &recordingDomRobot{}
+			w.api, w.logger, w.metrics = api, slog.Default(), newMetrics()
+			reconcile(w)
+			reconcile(w)
+			assert.Equal(t, []string{"login", "info", "delete", "logout", "login", "info", "delete", "logout"}, api.calls)
+		}
+	})
+
+	t.Run("PerOperation", func(t *testing.T) {
+		w, api := newWrapper(SessionPerOperation, 0)
+		reconcile(w)
+		assert.Equal(t, []string{"login", "info", "logout", "login", "delete", "logout"}, api.calls)
+	})
+
+	t.Run("Persistent", func(t *testing.T) {
+		w, api := newWrapper(SessionPersistent, time.Minute)
+		reconcile(w)
+		reconcile(w)
+		assert.Equal(t, []string{"login", "info", "delete", "info", "delete"}, api.calls)
+
+		// The keepalive only pings idle sessions
+		api.calls = nil
+		w.ping()
+		assert.Empty(t, api.calls)
+		w.clock.(*fakeClock).advance(time.Minute)
+		w.ping()
+		assert.Equal(t, []string{"account"}, api.calls)
+
+		// A failed keepalive makes the next request log in again
+		api.calls, api.infoErr = nil, errors.New("connection reset")
+		w.clock.(*fakeClock).advance(time.Minute)
+		w.ping()
+		reconcile(w)
+		assert.Equal(t, []string{"account", "login", "info", "delete"}, api.calls)
+		api.infoErr = nil
+
+		// An expired session is logged in again and the call retried
+		api.calls, api.expired = nil, true
+		reconcile(w)
+		assert.Equal(t, []string{"info", "login", "info", "delete"}, api.calls)
+
+		// Before an apply, a session used before is checked, and logged in again if it silently expired
+		api.calls = nil
+		require.NoError(t, w.checkSession())
+		api.expired = true
+		require.NoError(t, w.checkSession())
+		assert.Equal(t, []string{"account", "account", "login", "account"}, api.calls)
+
+		// A session not used since its login isn't
+		w.session.loggedIn = false
+		require.NoError(t, w.login())
+		api.calls = nil
+		require.NoError(t, w.checkSession())
+		assert.Empty(t, api.calls)
+
+		// Closing the client logs out
+		api.calls = nil
+		w.endSession()
+		w.endSession()
+		assert.Equal(t, []string{"logout"}, api.calls)
+	})
+
+	t.Run("ReloginOnExpiry", func(t *testing.T) {
+		// Mid-request, a per-reconcile session is logged in again too
+		w, api := newWrapper(SessionPerReconcile, 0)
+		require.NoError(t, w.login())
+		api.expired = true
+		_, err := w.getRecords("example.com")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"login", "info", "login", "info"}, api.calls)
+
+		// An error other than an expired session isn't retried
+		api.calls, api.listErr = nil, &apiError{Code: 2400, Message: "Command failed"}
+		w.forgetZones()
+		_, err = w.getZones()
+		assert.Error(t, err)
+		assert.Equal(t, []string{"list"}, api.calls)
+
+		// Neither is a per-operation session, which is logged in for every call anyway
+		w, api = newWrapper(SessionPerOperation, 0)
+		w.api = &expiringDomRobot{api}
+		_, err = w.getRecords("example.com")
+		assert.True(t, sessionExpired(err))
+		assert.Equal(t, []string{"login", "info", "logout"}, api.calls)
+	})
+
+	t.Run("CredentialRotation", func(t *testing.T) {
+		w, api := newWrapper(SessionPersistent, 0)
+		w.setCredentials(Credentials{Username: "old", Password: "secret"})
+		reconcile(w)
+		w.setCredentials(Credentials{Username: "new", Password: "rotated"})
+		reconcile(w)
+		assert.Equal(t, []string{"login old", "info", "delete", "login new", "info", "delete"}, api.calls)
+
+		w, api = newWrapper(SessionPerReconcile, 0)
+		w.setCredentials(Credentials{Username: "old", Password: "secret"})
+		reconcile(w)
+		w.setCredentials(Credentials{Username: "new", Password: "rotated"})
+		reconcile(w)
+		assert.Equal(t, []string{"login old", "info", "delete", "logout", "login new", "info", "delete", "logout"}, api.calls)
+	})
+
+	t.Run("ParseSessionScope", func(t *testing.T) {
+		scope, err := ParseSessionScope("persistent")
+		require.NoError(t, err)
+		assert.Equal(t, SessionPersistent, scope)
+		_, err = ParseSessionScope("per-request")
+		assert.Error(t, err)
+	})
+}
+
+// expiringDomRobot is a recordingDomRobot whose sessions are expired right after the login.
+type expiringDomRobot struct {
+	*recordingDomRobot
+}
+
+func (r *expiringDomRobot) login(username string, password string) (loginResult, error) {
+	result, err := r.recordingDomRobot.login(username, password)
+	r.expired = true
+	return result, err
+}
@@ -0,0 +1,77 @@
+package inwx
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// INWX API result codes of benign races.
+const (
+	codeObjectExists       = 2302
+	codeObjectDoesNotExist = 2303
+)
+
+// changeWarning wraps the failure of a change that nevertheless leaves INWX in the desired state, e.g. a
+// create racing with another writer that created the same record first, or a delete of a record that is
+// already gone. Warnings are logged and counted, but don't fail ApplyChanges.
+type changeWarning struct {
+	err error
+}
+
+func (w *changeWarning) Error() string {
+	return w.err.Error()
+}
+
+func (w *changeWarning) Unwrap() error {
+	return w.err
+}
+
+// isWarning reports whether err is a benign race rather than a hard error.
+func isWarning(err error) bool {
+	var w *changeWarning
+	return errors.As(err, &w)
+}
+
+// classifyChangeError wraps err in a changeWarning if it only means the action was already carried out.
+func classifyChangeError(action changeAction, err error) error {
+	var apiErr *apiError
+	if err == nil || !errors.As(err, &apiErr) {
+		return err
+	}
+	if (action == actionCreate && apiErr.Code == codeObjectExists) || (action == actionDelete && apiErr.Code == codeObjectDoesNotExist) {
+		return &changeWarning{err}
+	}
+	return err
+}
+
+// severity returns the severity of a failed change as recorded in AppliedChange.
+func severity(err error) string {
+	if isWarning(err) {
+		return "warning"
+	}
+	return "error"
+}
+
+// logChangeError logs a failed change at error level, or at warn level if it is a benign race.
+func logChangeError(msg string, err error, args ...any) {
+	level := slog.LevelError
+	if isWarning(err) {
+		level = slog.LevelWarn
+	}
+	slog.Log(context.Background(), level, msg, append(args, "err", err)...)
+}
+
+// splitErrors separates the benign races among errs from the hard errors.
+func splitErrors(errs []error) (hard []error, warnings []error) {
+	for _, err := range errs {
+		switch {
+		case err == nil:
+		case isWarning(err):
+			warnings = append(warnings, err)
+		default:
+			hard = append(hard, err)
+		}
+	}
+	return hard, warnings
+}
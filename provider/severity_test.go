@@ -0,0 +1,70 @@
+package inwx
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestSeverity(t *testing.T) {
+	t.Run("Classify", testClassifyChangeError)
+	t.Run("BenignRaces", testBenignRaces)
+	t.Run("HardErrors", testHardErrors)
+}
+
+func testClassifyChangeError(t *testing.T) {
+	exists := &apiError{Code: codeObjectExists, Message: "Object exists"}
+	missing := &apiError{Code: codeObjectDoesNotExist, Message: "Object does not exist"}
+	assert.True(t, isWarning(classifyChangeError(actionCreate, exists)))
+	assert.True(t, isWarning(classifyChangeError(actionDelete, missing)))
+	assert.False(t, isWarning(classifyChangeError(actionDelete, exists)))
+	assert.False(t, isWarning(classifyChangeError(actionUpdate, missing)))
+	assert.False(t, isWarning(classifyChangeError(actionCreate, errors.New("connection reset"))))
+	assert.NoError(t, classifyChangeError(actionCreate, nil))
+	assert.Equal(t, "warning", resultLabel(&changeWarning{exists}))
+}
+
+func testBenignRaces(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	require.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "old", Type: "A", Content: "1.1.1.1"}))
+
+	// Another writer deleted the record between the read and the delete
+	w.deleteErr = func(string) error {
+		return &apiError{Code: codeObjectDoesNotExist, Message: "Object does not exist"}
+	}
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	require.NoError(t, p.ApplyChanges(ctx, &plan.Changes{Delete: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("old.example.com", "A", "1.1.1.1"),
+	}}))
+	changes := recorder.Changes()
+	require.Len(t, changes, 1)
+	assert.Equal(t, "warning", changes[0].Severity)
+	assert.NotEmpty(t, changes[0].Error)
+}
+
+func testHardErrors(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	require.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "old", Type: "A", Content: "1.1.1.1"}))
+	w.deleteErr = func(string) error {
+		return &apiError{Code: 2400, Message: "Command failed"}
+	}
+
+	// A hard error fails the apply, even alongside a benign race
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	err := p.ApplyChanges(ctx, &plan.Changes{Delete: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("old.example.com", "A", "1.1.1.1"),
+		endpoint.NewEndpoint("gone.example.com", "A", "1.1.1.1"),
+	}})
+	assert.ErrorContains(t, err, "encountered 1 errors")
+	changes := recorder.Changes()
+	require.Len(t, changes, 1)
+	assert.Equal(t, "error", changes[0].Severity)
+}
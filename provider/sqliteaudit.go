@@ -0,0 +1,94 @@
+package inwx
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	// The pure-Go SQLite driver keeps the binary free of cgo.
+	_ "modernc.org/sqlite"
+)
+
+// sqliteAuditSchema creates the audit log table of a SQLiteAuditStore, with an index for looking up the
+// changes of a zone over time and one for those of a change set.
+const sqliteAuditSchema = `
+CREATE TABLE IF NOT EXISTS audit_log (
+	seq            INTEGER PRIMARY KEY AUTOINCREMENT,
+	time           TEXT NOT NULL,
+	trace_id       TEXT NOT NULL DEFAULT '',
+	plan_hash      TEXT NOT NULL DEFAULT '',
+	change_id      TEXT NOT NULL,
+	action         TEXT NOT NULL,
+	zone           TEXT NOT NULL,
+	name           TEXT NOT NULL,
+	type           TEXT NOT NULL,
+	content        TEXT NOT NULL,
+	ttl            INTEGER NOT NULL DEFAULT 0,
+	record_id      TEXT NOT NULL DEFAULT '',
+	record_key     TEXT NOT NULL DEFAULT '',
+	old_content    TEXT NOT NULL DEFAULT '',
+	old_record_key TEXT NOT NULL DEFAULT '',
+	outcome        TEXT NOT NULL,
+	skipped        TEXT NOT NULL DEFAULT '',
+	error          TEXT NOT NULL DEFAULT '',
+	severity       TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS audit_log_zone_time ON audit_log (zone, time);
+CREATE INDEX IF NOT EXISTS audit_log_plan_hash ON audit_log (plan_hash);
+`
+
+// SQLiteAuditStore writes the audit log to the audit_log table of a SQLite database, so that it can be
+// queried with SQL, e.g. for every change of a zone or change set. Times are stored in UTC as RFC 3339
+// with nanoseconds, which sorts chronologically, regardless of the configured timestamp format.
+type SQLiteAuditStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteAuditStore opens the database at path, creating it and the audit log table if they don't exist.
+func NewSQLiteAuditStore(path string) (*SQLiteAuditStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open audit log: %w", err)
+	}
+	// SQLite has a single writer; one connection keeps the pool from running into its own locks, and the
+	// write-ahead log lets readers query the audit log while it is written.
+	db.SetMaxOpenConns(1)
+	for _, stmt := range []string{"PRAGMA journal_mode=WAL", "PRAGMA busy_timeout=5000", sqliteAuditSchema} {
+		if _, err := db.Exec(stmt); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("unable to set up audit log: %w", err)
+		}
+	}
+	return &SQLiteAuditStore{db: db}, nil
+}
+
+// Append inserts entries in a single transaction.
+func (s *SQLiteAuditStore) Append(ctx context.Context, entries []AuditEntry) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("unable to write audit log: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO audit_log (time, trace_id, plan_hash, change_id, action, zone, name, type,
+		content, ttl, record_id, record_key, old_content, old_record_key, outcome, skipped, error, severity)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("unable to write audit log: %w", err)
+	}
+	defer stmt.Close()
+	for _, e := range entries {
+		if _, err := stmt.ExecContext(ctx, e.Time.UTC().Format(time.RFC3339Nano), e.TraceID, e.PlanHash, e.ID, e.Action, e.Zone, e.Name,
+			e.Type, e.Content, e.TTL, e.RecordID, e.RecordKey, e.OldContent, e.OldRecordKey, e.Outcome, e.Skipped, e.Error, e.Severity); err != nil {
+			return fmt.Errorf("unable to write audit log: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("unable to write audit log: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteAuditStore) Close() error {
+	return s.db.Close()
+}
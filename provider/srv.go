@@ -0,0 +1,52 @@
+package inwx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// external-dns carries SRV targets as "priority weight port target", e.g. "10 5 5060 sip.example.com",
+// while INWX stores the priority in a field of its own and "weight port target" as the content. The
+// client converts between the two, so that the provider only sees the targets of external-dns.
+
+// parseSRV splits an SRV target of external-dns into its priority and the content INWX stores.
+func parseSRV(target string) (int, string, error) {
+	fields := strings.Fields(target)
+	if len(fields) != 4 {
+		return 0, "", fmt.Errorf("invalid SRV target %q, expected priority, weight, port and target", target)
+	}
+	for i, name := range []string{"priority", "weight", "port"} {
+		if _, err := strconv.ParseUint(fields[i], 10, 16); err != nil {
+			return 0, "", fmt.Errorf("invalid SRV target %q: %s %q is not a number between 0 and 65535", target, name, fields[i])
+		}
+	}
+	priority, _ := strconv.Atoi(fields[0])
+	return priority, strings.Join(fields[1:], " "), nil
+}
+
+// inwxRecordRequest returns request as INWX expects it: SRV targets are split into priority and content.
+// Other requests are returned as they are.
+func inwxRecordRequest(request *recordRequest) (*recordRequest, error) {
+	if request.Type != endpoint.RecordTypeSRV {
+		return request, nil
+	}
+	priority, content, err := parseSRV(request.Content)
+	if err != nil {
+		return nil, err
+	}
+	converted := *request
+	converted.Priority, converted.Content = priority, content
+	return &converted, nil
+}
+
+// endpointContent returns the content of a record read from INWX as the target of external-dns: the
+// priority is prepended to the content of SRV records.
+func endpointContent(rec zoneRecord) string {
+	if rec.Type == endpoint.RecordTypeSRV {
+		return strconv.Itoa(rec.Priority) + " " + rec.Content
+	}
+	return rec.Content
+}
@@ -0,0 +1,94 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestSRV(t *testing.T) {
+	t.Run("Parse", testParseSRV)
+	t.Run("RoundTrip", testSRVRoundTrip)
+}
+
+func testParseSRV(t *testing.T) {
+	priority, content, err := parseSRV("10 5 5060 sip.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, 10, priority)
+	assert.Equal(t, "5 5060 sip.example.com", content)
+
+	// Extra whitespace is dropped
+	priority, content, err = parseSRV(" 0  0 443  . ")
+	require.NoError(t, err)
+	assert.Equal(t, 0, priority)
+	assert.Equal(t, "0 443 .", content)
+
+	for _, target := range []string{"", "5060 sip.example.com", "10 5 5060", "a 5 5060 sip.example.com", "10 5 70000 sip.example.com", "10 -1 5060 sip.example.com"} {
+		_, _, err := parseSRV(target)
+		assert.Error(t, err, target)
+	}
+
+	rec := zoneRecord{Type: "SRV", Priority: 10, Content: "5 5060 sip.example.com"}
+	assert.Equal(t, "10 5 5060 sip.example.com", endpointContent(rec))
+	rec = zoneRecord{Type: "MX", Priority: 10, Content: "mail.example.com"}
+	assert.Equal(t, "mail.example.com", endpointContent(rec))
+}
+
+func testSRVRoundTrip(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+
+	sip := endpoint.NewEndpointWithTTL("_sip._tcp.example.com", "SRV", 300, "10 5 5060 sip1.example.com", "20 5 5060 sip2.example.com")
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{sip}}))
+
+	// INWX stores the priority on its own
+	stored := *w.db["example.com"]
+	require.Len(t, stored, 2)
+	assert.Equal(t, "_sip._tcp", stored[0].Name)
+	assert.Equal(t, 10, stored[0].Priority)
+	assert.Equal(t, "5 5060 sip1.example.com", stored[0].Content)
+	assert.Equal(t, 20, stored[1].Priority)
+
+	// Records lists a target per record
+	records, err := p.Records(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "_sip._tcp.example.com", records[0].DNSName)
+	assert.Equal(t, endpoint.Targets{"10 5 5060 sip1.example.com", "20 5 5060 sip2.example.com"},
+		endpoint.Targets{records[0].Targets[0], records[1].Targets[0]})
+
+	// Creating the endpoint again finds the existing records
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	require.NoError(t, p.ApplyChanges(ctx, &plan.Changes{Create: []*endpoint.Endpoint{sip}}))
+	assert.Empty(t, recorder.Changes())
+
+	// Changing the priority updates the record
+	updated := endpoint.NewEndpointWithTTL("_sip._tcp.example.com", "SRV", 300, "30 5 5060 sip1.example.com")
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{UpdateOld: records[:1], UpdateNew: []*endpoint.Endpoint{updated}}))
+	assert.Equal(t, 30, (*w.db["example.com"])[0].Priority)
+	records, err = p.Records(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "30 5 5060 sip1.example.com", records[0].Targets[0])
+
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Delete: records}))
+	records, err = p.Records(context.TODO())
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	// Malformed targets are rejected before they reach INWX
+	ctx, recorder = WithChangeRecorder(context.TODO())
+	assert.Error(t, p.ApplyChanges(ctx, &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("_xmpp._tcp.example.com", "SRV", "5 5222 xmpp.example.com"),
+	}}))
+	require.Len(t, recorder.Changes(), 1)
+	assert.Contains(t, recorder.Changes()[0].Error, "expected priority, weight, port and target")
+	stillStored, err := w.getRecords("example.com")
+	require.NoError(t, err)
+	assert.Empty(t, *stillStored)
+}
@@ -0,0 +1,60 @@
+package inwx
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ClosestMatch returns the candidate most similar to name, for "did you mean" hints on typo'd keys,
+// or "" if none is close enough to be a plausible typo.
+func ClosestMatch(name string, candidates []string) string {
+	best, bestDistance := "", max(2, len(name)/3)+1
+	for _, candidate := range candidates {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(candidate)); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// jsonKeys returns the JSON keys of the fields of struct type t.
+func jsonKeys(t reflect.Type) []string {
+	keys := []string{}
+	for i := range t.NumField() {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" && name != "-" {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
+
+// unknownKeyError describes a key that isn't part of a configuration format, with a hint if it looks
+// like a typo of a known one.
+func unknownKeyError(key string, where string, known []string) error {
+	if match := ClosestMatch(key, known); match != "" {
+		return fmt.Errorf("unknown key %q in %s, did you mean %q?", key, where, match)
+	}
+	return fmt.Errorf("unknown key %q in %s, expected one of %s", key, where, strings.Join(known, ", "))
+}
@@ -0,0 +1,147 @@
+package inwx
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Platform teams can serve the external-dns instances of several clusters from one webhook. Each tenant
+// gets a provider of its own, with its own credentials, filter and policy, and so its own caches, rate
+// limiters and metrics, which are told apart by a tenant label.
+
+// tenantNamePattern restricts tenant names to what fits into a URL path segment and a label value.
+var tenantNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// Tenant is a tenant of the --tenants-config file. Settings left empty fall back to the flags.
+type Tenant struct {
+	// Name is the URL path segment the tenant is served under, and the value of its tenant label.
+	Name string `json:"name"`
+	// Username is the login username for the INWX API, unless given in UsernameFile.
+	Username     string `json:"username,omitempty"`
+	UsernameFile string `json:"usernameFile,omitempty"`
+	// PasswordFile and TOTPSecretFile hold the password and the two-factor secret, e.g. mounted from a
+	// Kubernetes secret, so that the tenants config holds no secrets itself.
+	PasswordFile   string `json:"passwordFile"`
+	TOTPSecretFile string `json:"totpSecretFile,omitempty"`
+	// DomainFilter limits the zones of the tenant, like --domain-filter; empty allows every zone of its
+	// account.
+	DomainFilter []string `json:"domainFilter,omitempty"`
+	// ZoneConfig is the path of the zone config with the policy of the tenant; --zone-config by default.
+	ZoneConfig string `json:"zoneConfig,omitempty"`
+	// ExpectedAccountID is the INWX account the credentials must belong to; --expected-account-id by
+	// default.
+	ExpectedAccountID int `json:"expectedAccountId,omitempty"`
+	// TXTOwnerID is the --txt-owner-id of the external-dns of the tenant; --txt-owner-id by default.
+	TXTOwnerID string `json:"txtOwnerId,omitempty"`
+	// AuditLog is where to write the audit log of the tenant, like --audit-log; empty disables.
+	AuditLog string `json:"auditLog,omitempty"`
+	// SigningSecretFile holds the secret the mutating webhook requests of the tenant must be signed with;
+	// --webhook-signing-secret by default.
+	SigningSecretFile string `json:"signingSecretFile,omitempty"`
+	// AllowedCIDRs are the addresses the webhook requests of the tenant may come from;
+	// --webhook-allowed-cidr by default.
+	AllowedCIDRs []string `json:"allowedCIDRs,omitempty"`
+}
+
+// tenantsConfig is the on-disk format of the --tenants-config file.
+type tenantsConfig struct {
+	Tenants []Tenant `json:"tenants"`
+}
+
+// LoadTenants reads and validates a tenants config file in YAML or JSON format.
+func LoadTenants(path string) ([]Tenant, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read tenants config %s: %w", path, err)
+	}
+	var cfg tenantsConfig
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("unable to parse tenants config %s: %w", path, err)
+	}
+	if len(cfg.Tenants) == 0 {
+		return nil, fmt.Errorf("tenants config %s defines no tenants", path)
+	}
+	seen := map[string]bool{}
+	for _, tenant := range cfg.Tenants {
+		if err := tenant.validate(); err != nil {
+			return nil, fmt.Errorf("invalid tenant %q in tenants config %s: %w", tenant.Name, path, err)
+		}
+		if seen[tenant.Name] {
+			return nil, fmt.Errorf("duplicate tenant %q in tenants config %s", tenant.Name, path)
+		}
+		seen[tenant.Name] = true
+	}
+	return cfg.Tenants, nil
+}
+
+func (t Tenant) validate() error {
+	if !tenantNamePattern.MatchString(t.Name) {
+		return fmt.Errorf("name must consist of lower case letters, digits and dashes")
+	}
+	if (t.Username == "") == (t.UsernameFile == "") {
+		return fmt.Errorf("exactly one of username and usernameFile is required")
+	}
+	if t.PasswordFile == "" {
+		return fmt.Errorf("passwordFile is required")
+	}
+	return nil
+}
+
+// readFile reads the file of a setting of the tenant, dropping a single trailing line break.
+func (t Tenant) readFile(key string, file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("unable to read %s of tenant %s: %w", key, t.Name, err)
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r"), nil
+}
+
+// SigningSecret reads the signing secret of the tenant from its file, if set.
+func (t Tenant) SigningSecret() (string, error) {
+	if t.SigningSecretFile == "" {
+		return "", nil
+	}
+	secret, err := t.readFile("signingSecretFile", t.SigningSecretFile)
+	if err != nil {
+		return "", err
+	}
+	if secret == "" {
+		return "", fmt.Errorf("empty signingSecretFile of tenant %s", t.Name)
+	}
+	return secret, nil
+}
+
+// Credentials reads the INWX credentials of the tenant from their files. A single trailing line break of
+// a file is dropped.
+func (t Tenant) Credentials() (Credentials, error) {
+	read := t.readFile
+	creds := Credentials{Username: t.Username}
+	var err error
+	if t.UsernameFile != "" {
+		if creds.Username, err = read("usernameFile", t.UsernameFile); err != nil {
+			return Credentials{}, err
+		}
+	}
+	if creds.Password, err = read("passwordFile", t.PasswordFile); err != nil {
+		return Credentials{}, err
+	}
+	if creds.Username == "" || creds.Password == "" {
+		return Credentials{}, fmt.Errorf("empty username or password for tenant %s", t.Name)
+	}
+	if t.TOTPSecretFile != "" {
+		secret, err := read("totpSecretFile", t.TOTPSecretFile)
+		if err != nil {
+			return Credentials{}, err
+		}
+		if strings.TrimSpace(secret) != "" {
+			if creds.TOTPSecret, err = ParseTOTPSecret(secret); err != nil {
+				return Credentials{}, fmt.Errorf("invalid totpSecretFile of tenant %s: %w", t.Name, err)
+			}
+		}
+	}
+	return creds, nil
+}
@@ -0,0 +1,122 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestTenants(t *testing.T) {
+	t.Run("Load", testLoadTenants)
+	t.Run("Credentials", testTenantCredentials)
+	t.Run("IsolatedMetrics", testTenantMetrics)
+}
+
+func testLoadTenants(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "tenants.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	tenants, err := LoadTenants(write(`
+tenants:
+  - name: cluster-a
+    username: a
+    passwordFile: /secrets/a/password
+    domainFilter: [a.example.com]
+  - name: cluster-b
+    usernameFile: /secrets/b/username
+    passwordFile: /secrets/b/password
+    zoneConfig: /config/b/zones.yaml
+    txtOwnerId: cluster-b
+    signingSecretFile: /secrets/b/signing
+    allowedCIDRs: [10.1.0.0/16]
+`))
+	require.NoError(t, err)
+	require.Len(t, tenants, 2)
+	assert.Equal(t, Tenant{Name: "cluster-a", Username: "a", PasswordFile: "/secrets/a/password", DomainFilter: []string{"a.example.com"}}, tenants[0])
+	assert.Equal(t, "/config/b/zones.yaml", tenants[1].ZoneConfig)
+	assert.Equal(t, "cluster-b", tenants[1].TXTOwnerID)
+	assert.Equal(t, "/secrets/b/signing", tenants[1].SigningSecretFile)
+	assert.Equal(t, []string{"10.1.0.0/16"}, tenants[1].AllowedCIDRs)
+
+	for content, expected := range map[string]string{
+		"tenants: []": "defines no tenants",
+		"tenants:\n  - name: Cluster_A\n    username: a\n    passwordFile: p":                                            "lower case letters",
+		"tenants:\n  - name: a\n    passwordFile: p":                                                                     "exactly one of username and usernameFile",
+		"tenants:\n  - name: a\n    username: a":                                                                         "passwordFile is required",
+		"tenants:\n  - name: a\n    username: a\n    password: secret":                                                   "unknown field",
+		"tenants:\n  - name: a\n    username: a\n    passwordFile: p\n  - name: a\n    username: b\n    passwordFile: p": "duplicate tenant",
+	} {
+		_, err := LoadTenants(write(content))
+		assert.ErrorContains(t, err, expected, content)
+	}
+}
+
+func testTenantCredentials(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "username"), []byte("user\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "password"), []byte("secret\r\n"), 0o600))
+
+	creds, err := Tenant{Name: "a", UsernameFile: filepath.Join(dir, "username"), PasswordFile: filepath.Join(dir, "password")}.Credentials()
+	require.NoError(t, err)
+	assert.Equal(t, "user", creds.Username)
+	assert.Equal(t, "secret", creds.Password)
+	assert.Empty(t, creds.TOTPSecret)
+
+	_, err = Tenant{Name: "a", Username: "user", PasswordFile: filepath.Join(dir, "missing")}.Credentials()
+	assert.ErrorContains(t, err, "unable to read passwordFile of tenant a")
+
+	// The signing secret is optional, but mustn't be empty when its file is given
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "signing"), []byte("sign\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "empty"), []byte("\n"), 0o600))
+	secret, err := Tenant{Name: "a", SigningSecretFile: filepath.Join(dir, "signing")}.SigningSecret()
+	require.NoError(t, err)
+	assert.Equal(t, "sign", secret)
+	secret, err = Tenant{Name: "a"}.SigningSecret()
+	require.NoError(t, err)
+	assert.Empty(t, secret)
+	_, err = Tenant{Name: "a", SigningSecretFile: filepath.Join(dir, "empty")}.SigningSecret()
+	assert.ErrorContains(t, err, "empty signingSecretFile of tenant a")
+}
+
+func testTenantMetrics(t *testing.T) {
+	// Every provider counts in its own metrics, which are told apart by the label they are registered with
+	reg := prometheus.NewRegistry()
+	providers := map[string]*INWXProvider{}
+	for _, tenant := range []string{"a", "b"} {
+		w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+		w.CreateZone("example.com")
+		prometheus.WrapRegistererWith(prometheus.Labels{"tenant": tenant}, reg).MustRegister(p.metrics.collectors()...)
+		providers[tenant] = p
+	}
+
+	require.NoError(t, providers["a"].ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.com", "A", "192.0.2.1")}}))
+	assert.Equal(t, 1.0, testutil.ToFloat64(providers["a"].metrics.changesTotal.WithLabelValues("example.com", "create", "success")))
+	assert.Zero(t, testutil.CollectAndCount(providers["b"].metrics.changesTotal))
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "external_dns_inwx_changes_total" {
+			continue
+		}
+		require.Len(t, family.GetMetric(), 1)
+		labels := map[string]string{}
+		for _, label := range family.GetMetric()[0].GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		assert.Equal(t, "a", labels["tenant"])
+	}
+}
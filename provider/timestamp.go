@@ -0,0 +1,63 @@
+package inwx
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timestampLayouts are the named layouts accepted by ParseTimestampLayout.
+var timestampLayouts = map[string]string{
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"rfc1123z":    time.RFC1123Z,
+	"datetime":    time.DateTime,
+}
+
+// ParseTimestampLayout returns the Go time layout named s, e.g. rfc3339, or s itself if it is a layout
+// already, e.g. "02.01.2006 15:04:05 MST".
+func ParseTimestampLayout(s string) (string, error) {
+	if layout, ok := timestampLayouts[strings.ToLower(s)]; ok {
+		return layout, nil
+	}
+	if !strings.Contains(s, "2006") {
+		return "", fmt.Errorf("invalid timestamp format %q, expected rfc3339, rfc3339nano, rfc1123z, datetime or a Go time layout including the year 2006", s)
+	}
+	return s, nil
+}
+
+// Timestamp is a time written to the audit log and reports, in the configured time zone and layout.
+type Timestamp struct {
+	time.Time
+	layout string
+}
+
+// timestamp returns t as a Timestamp in the configured time zone and layout, RFC 3339 in UTC by default.
+func (p *INWXProvider) timestamp(t time.Time) Timestamp {
+	location := p.config.timestampLocation
+	if location == nil {
+		location = time.UTC
+	}
+	return Timestamp{Time: t.In(location), layout: p.config.timestampLayout}
+}
+
+// String formats t in its layout, RFC 3339 without one.
+func (t Timestamp) String() string {
+	if t.layout == "" {
+		return t.Time.Format(time.RFC3339)
+	}
+	return t.Time.Format(t.layout)
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.layout == "" {
+		return t.Time.MarshalJSON()
+	}
+	return json.Marshal(t.Time.Format(t.layout))
+}
+
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	t.layout = ""
+	return t.Time.UnmarshalJSON(data)
+}
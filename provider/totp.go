@@ -0,0 +1,86 @@
+package inwx
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// totpStep is the period for which a TAN is valid, as used by INWX and authenticator apps.
+const totpStep = 30 * time.Second
+
+// TOTPSecret is the shared secret of an INWX account with two-factor authentication, from which the TANs
+// unlocking the account after a login are generated.
+type TOTPSecret []byte
+
+// ParseTOTPSecret decodes a secret as shown by INWX when setting up two-factor authentication: base32,
+// case-insensitive, with optional spaces and padding.
+func ParseTOTPSecret(s string) (TOTPSecret, error) {
+	s = strings.ToUpper(strings.Join(strings.Fields(s), ""))
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("invalid TOTP secret: empty")
+	}
+	return secret, nil
+}
+
+// code returns the TAN valid at t, following RFC 6238 with HMAC-SHA1 and 6 digits.
+func (s TOTPSecret) code(t time.Time) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix()/int64(totpStep/time.Second)))
+	mac := hmac.New(sha1.New, s)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+// tfaEnabled reports whether the tfa field of a login response names a two-factor authentication
+// method, rather than being "0" for none.
+func tfaEnabled(tfa any) bool {
+	switch tfa := tfa.(type) {
+	case string:
+		return tfa != "" && tfa != "0"
+	case int:
+		return tfa != 0
+	case int64:
+		return tfa != 0
+	case float64:
+		return tfa != 0
+	default:
+		return false
+	}
+}
+
+// authenticate logs in with the current credentials, unlocking an account with two-factor authentication
+// with the current TAN.
+func (w *ClientWrapper) authenticate() error {
+	creds := w.currentCredentials()
+	var result loginResult
+	err := w.timed("account.login", "", func() (err error) {
+		result, err = w.api.login(creds.Username, creds.Password)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	w.loggedInTo(result)
+	if !result.locked {
+		return nil
+	}
+	if len(creds.TOTPSecret) == 0 {
+		return errors.New("the INWX account requires two-factor authentication, but no TOTP secret is configured")
+	}
+	return w.timed("account.unlock", "", func() error {
+		return w.api.unlock(creds.TOTPSecret.code(now(w.clock)))
+	})
+}
@@ -0,0 +1,48 @@
+package inwx
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOTP(t *testing.T) {
+	t.Run("ParseTOTPSecret", func(t *testing.T) {
+		// The SHA1 secret of the RFC 6238 test vectors, "12345678901234567890"
+		for _, value := range []string{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", "gezd gnbv gy3t qojq gezd gnbv gy3t qojq\n"} {
+			secret, err := ParseTOTPSecret(value)
+			require.NoError(t, err, value)
+			assert.Equal(t, TOTPSecret("12345678901234567890"), secret)
+		}
+		for _, value := range []string{"", "not base32!"} {
+			_, err := ParseTOTPSecret(value)
+			assert.Error(t, err, value)
+		}
+	})
+
+	t.Run("Code", func(t *testing.T) {
+		secret := TOTPSecret("12345678901234567890")
+		assert.Equal(t, "287082", secret.code(time.Unix(59, 0)))
+		assert.Equal(t, "081804", secret.code(time.Unix(1111111109, 0)))
+		assert.Equal(t, "005924", secret.code(time.Unix(1234567890, 0)))
+	})
+
+	t.Run("Unlock", func(t *testing.T) {
+		api := &recordingDomRobot{locked: true}
+		w := &ClientWrapper{api: api, logger: slog.Default(), metrics: newMetrics(), clock: &fakeClock{now: time.Unix(59, 0)}}
+		assert.ErrorContains(t, w.login(), "requires two-factor authentication")
+
+		api.calls = nil
+		w.credentials.Store(&Credentials{TOTPSecret: TOTPSecret("12345678901234567890")})
+		require.NoError(t, w.login())
+		assert.Equal(t, []string{"login", "unlock 287082"}, api.calls)
+
+		// Accounts without two-factor authentication aren't unlocked
+		api.calls, api.locked = nil, false
+		require.NoError(t, w.login())
+		assert.Equal(t, []string{"login"}, api.calls)
+	})
+}
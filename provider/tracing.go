@@ -0,0 +1,114 @@
+package inwx
+
+import (
+	"context"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of the provider operations and INWX API calls. They are discarded unless a
+// tracer provider is registered with otel.SetTracerProvider.
+var tracer = otel.Tracer("github.com/orbit-online/external-dns-inwx-webhook/provider")
+
+// TraceparentHeader is the W3C Trace Context header identifying the trace a request belongs to.
+const TraceparentHeader = "traceparent"
+
+type traceIDKey struct{}
+
+// WithTraceID returns a context carrying the ID of the trace the operations started with it belong to.
+// The ID is attached as an exemplar to the operation metrics, so a latency spike can be followed to its
+// trace.
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// TraceID returns the trace ID carried by ctx, or the one of its span, if any.
+func TraceID(ctx context.Context) string {
+	if id, ok := ctx.Value(traceIDKey{}).(string); ok {
+		return id
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
+
+// startSpan starts a span named name as a child of the span of ctx. The returned function ends it,
+// recording err, if any, as its status.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, func(err error)) {
+	ctx, span := tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// traceCall runs fn, the INWX API call method, in a span of its own.
+func traceCall(ctx context.Context, method string, fn func() error, attrs ...attribute.KeyValue) error {
+	_, end := startSpan(ctx, "inwx "+method, append(attrs, attribute.String("rpc.method", method))...)
+	err := fn()
+	end(err)
+	return err
+}
+
+// ParseTraceparent returns the trace ID of a W3C traceparent header value, e.g.
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func ParseTraceparent(header string) (string, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return "", false
+	}
+	traceID, parentID := parts[1], parts[2]
+	if !isLowerHex(parts[0]) || !isLowerHex(traceID) || len(traceID) != 32 || !isLowerHex(parentID) || len(parentID) != 16 || !isLowerHex(parts[3]) || len(parts[3]) != 2 {
+		return "", false
+	}
+	if strings.Trim(traceID, "0") == "" || strings.Trim(parentID, "0") == "" {
+		return "", false
+	}
+	return traceID, true
+}
+
+func isLowerHex(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789abcdef") == ""
+}
+
+// exemplar returns the exemplar labels linking a metric update to the trace of ctx, or nil without one.
+func exemplar(ctx context.Context) prometheus.Labels {
+	if ctx == nil {
+		return nil
+	}
+	if id := TraceID(ctx); id != "" {
+		return prometheus.Labels{"trace_id": id}
+	}
+	return nil
+}
+
+// observe records v, with the trace of ctx as exemplar if there is one.
+func observe(ctx context.Context, o prometheus.Observer, v float64) {
+	if e := exemplar(ctx); e != nil {
+		if eo, ok := o.(prometheus.ExemplarObserver); ok {
+			eo.ObserveWithExemplar(v, e)
+			return
+		}
+	}
+	o.Observe(v)
+}
+
+// inc increments c, with the trace of ctx as exemplar if there is one.
+func inc(ctx context.Context, c prometheus.Counter) {
+	if e := exemplar(ctx); e != nil {
+		if ea, ok := c.(prometheus.ExemplarAdder); ok {
+			ea.AddWithExemplar(1, e)
+			return
+		}
+	}
+	c.Inc()
+}
@@ -0,0 +1,120 @@
+package inwx
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestTracing(t *testing.T) {
+	t.Run("ParseTraceparent", testParseTraceparent)
+	t.Run("Exemplars", testExemplars)
+	t.Run("Spans", testSpans)
+}
+
+func testParseTraceparent(t *testing.T) {
+	id, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.True(t, ok)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", id)
+
+	// Future versions may append fields
+	_, ok = ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future")
+	assert.True(t, ok)
+
+	for _, header := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6-00f067aa0ba902b7-01",
+	} {
+		_, ok := ParseTraceparent(header)
+		assert.False(t, ok, header)
+	}
+}
+
+func testExemplars(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(p.metrics.changesTotal, p.metrics.operationDuration)
+	w.CreateZone("example.com")
+	ctx := WithTraceID(context.TODO(), "4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, p.ApplyChanges(ctx, &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("traced.example.com", "A", "1.1.1.1")}}))
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	found := map[string]bool{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			if e := metric.GetCounter().GetExemplar(); e != nil && e.GetLabel()[0].GetValue() == TraceID(ctx) {
+				found[family.GetName()] = true
+			}
+			for _, bucket := range metric.GetHistogram().GetBucket() {
+				if e := bucket.GetExemplar(); e != nil && e.GetLabel()[0].GetValue() == TraceID(ctx) {
+					found[family.GetName()] = true
+				}
+			}
+		}
+	}
+	assert.True(t, found["external_dns_inwx_changes_total"])
+	assert.True(t, found["external_dns_inwx_operation_duration_seconds"])
+}
+
+func testSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+
+	ctx, end := startSpan(context.TODO(), "reconcile")
+	changes := &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.com", "A", "192.0.2.1")}}
+	require.NoError(t, p.ApplyChanges(ctx, changes))
+	_, err := p.Records(ctx)
+	require.NoError(t, err)
+	assert.NotEmpty(t, TraceID(ctx))
+	end(nil)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	root := spans["reconcile"]
+	require.NotNil(t, root)
+	for _, name := range []string{"ApplyChanges", "Records"} {
+		require.Contains(t, spans, name)
+		assert.Equal(t, root.SpanContext().SpanID(), spans[name].Parent().SpanID(), name)
+	}
+	for _, name := range []string{"inwx account.login", "inwx nameserver.list", "inwx nameserver.info", "inwx nameserver.createRecord"} {
+		require.Contains(t, spans, name)
+		assert.Equal(t, root.SpanContext().TraceID(), spans[name].SpanContext().TraceID(), name)
+	}
+	create := spans["inwx nameserver.createRecord"]
+	assert.Equal(t, spans["ApplyChanges"].SpanContext().SpanID(), create.Parent().SpanID())
+	assert.Contains(t, create.Attributes(), attribute.String("dns.name", "foo"))
+
+	recorder.Reset()
+	w.createErr = func(*recordRequest) error { return errors.New("quota exceeded") }
+	changes = &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("bar.example.com", "A", "192.0.2.2")}}
+	require.Error(t, p.ApplyChanges(context.TODO(), changes))
+	for _, span := range recorder.Ended() {
+		if span.Name() == "inwx nameserver.createRecord" || span.Name() == "ApplyChanges" {
+			assert.Equal(t, codes.Error, span.Status().Code, span.Name())
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package inwx
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/kolo/xmlrpc"
+	inwx "github.com/nrdcg/goinwx"
+)
+
+// tlsSessionCacheSize is the number of TLS sessions kept for resumption; the client only talks to a
+// single DomRobot host, so a small cache suffices.
+const tlsSessionCacheSize = 16
+
+// ParseProxyURL checks that s is the URL of an http, https or socks5 proxy.
+func ParseProxyURL(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %q: %w", s, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q, expected an http, https or socks5 URL", s)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q, expected a host", s)
+	}
+	return u, nil
+}
+
+// LoadCABundle returns the system certificate pool extended by the PEM certificates in the file at path,
+// e.g. the CA of a TLS-intercepting egress proxy.
+func LoadCABundle(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates found in CA bundle %s", path)
+	}
+	return pool, nil
+}
+
+// ParseTLSVersion returns the TLS version named s, "1.2" or "1.3".
+func ParseTLSVersion(s string) (uint16, error) {
+	switch s {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version %q, expected 1.2 or 1.3", s)
+	}
+}
+
+// newTransport returns an HTTP transport tuned for back-to-back DomRobot calls: idle connections are
+// kept alive for reuse and TLS sessions are resumed when a connection has to be re-established. The
+// calls go through proxy, or the proxy of the HTTPS_PROXY and NO_PROXY environment variables if it is nil.
+// Servers are verified against rootCAs, or the system certificates if it is nil, with TLS 1.2 at least
+// unless minTLSVersion is higher.
+func newTransport(maxIdleConns int, idleConnTimeout time.Duration, proxy *url.URL, rootCAs *x509.CertPool, minTLSVersion uint16) *http.Transport {
+	proxyFunc := http.ProxyFromEnvironment
+	if proxy != nil {
+		proxyFunc = http.ProxyURL(proxy)
+	}
+	minTLSVersion = max(minTLSVersion, tls.VersionTLS12)
+	return &http.Transport{
+		Proxy: proxyFunc,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          maxIdleConns,
+		MaxIdleConnsPerHost:   maxIdleConns,
+		IdleConnTimeout:       idleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig: &tls.Config{
+			MinVersion:         minTLSVersion,
+			RootCAs:            rootCAs,
+			ClientSessionCache: tls.NewLRUClientSessionCache(tlsSessionCacheSize),
+		},
+	}
+}
+
+// userAgentTransport sets the User-Agent header of every request sent through next.
+type userAgentTransport struct {
+	next      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("User-Agent", t.userAgent)
+	return t.next.RoundTrip(r)
+}
+
+// withUserAgent returns next, sending userAgent as User-Agent header unless it is empty.
+func withUserAgent(next http.RoundTripper, userAgent string) http.RoundTripper {
+	if userAgent == "" {
+		return next
+	}
+	return &userAgentTransport{next: next, userAgent: userAgent}
+}
+
+// newDomRobot returns the DomRobot client implementation selected by apiClient, sending its requests
+// through transport to apiURL, or the production or sandbox endpoint if it is empty.
+func newDomRobot(apiClient APIClient, sandbox bool, apiURL string, transport http.RoundTripper, logger *slog.Logger) domRobot {
+	if apiClient == APIClientJSONRPC {
+		return newJSONRPCClient(sandbox, apiURL, transport)
+	}
+	return goinwxClient{newINWXClient(sandbox, apiURL, transport, logger)}
+}
+
+// newINWXClient returns a goinwx client sending its requests through transport. The credentials are
+// passed on login instead, so that they can change.
+func newINWXClient(sandbox bool, apiURL string, transport http.RoundTripper, logger *slog.Logger) *inwx.Client {
+	client := inwx.NewClient("", "", &inwx.ClientOptions{Sandbox: sandbox})
+
+	baseURL := inwx.APIBaseURL
+	if sandbox {
+		baseURL = inwx.APISandboxBaseURL
+	}
+	if apiURL != "" {
+		baseURL = apiURL
+	}
+	// goinwx doesn't accept a transport, so the XML-RPC client it created is swapped for one using ours.
+	rpcClient, err := xmlrpc.NewClient(baseURL, transport)
+	if err != nil {
+		logger.Error("failed to create tuned INWX API client, using the default transport", "err", err)
+		return client
+	}
+	client.RPCClient = rpcClient
+	return client
+}
@@ -0,0 +1,164 @@
+package inwx
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestTransport(t *testing.T) {
+	t.Run("ConnectionReuse", testTransportConnectionReuse)
+	t.Run("ClientUsesTransport", testClientUsesTransport)
+	t.Run("UserAgent", testUserAgent)
+	t.Run("ParseAPIURL", testParseAPIURL)
+	t.Run("Proxy", testProxy)
+	t.Run("TLS", testTLS)
+}
+
+func testTransportConnectionReuse(t *testing.T) {
+	var conns atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	transport := newTransport(4, time.Minute, nil, nil, 0)
+	transport.TLSClientConfig.RootCAs = server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	for range 3 {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+	assert.Equal(t, int32(1), conns.Load())
+}
+
+func testClientUsesTransport(t *testing.T) {
+	errRoundTrip := errors.New("round trip")
+	var host string
+	transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		host = r.URL.Host
+		return nil, errRoundTrip
+	})
+
+	client := newINWXClient(true, "", transport, slog.Default())
+	_, err := client.Account.Login()
+	assert.ErrorIs(t, err, errRoundTrip)
+	assert.Equal(t, "api.ote.domrobot.com", host)
+
+	client = newINWXClient(true, "http://inwx-gateway.corp.example:8080/xmlrpc/", transport, slog.Default())
+	_, err = client.Account.Login()
+	assert.ErrorIs(t, err, errRoundTrip)
+	assert.Equal(t, "inwx-gateway.corp.example:8080", host)
+}
+
+func testUserAgent(t *testing.T) {
+	errRoundTrip := errors.New("round trip")
+	var userAgent string
+	transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		userAgent = r.Header.Get("User-Agent")
+		return nil, errRoundTrip
+	})
+
+	client := newINWXClient(true, "", withUserAgent(transport, "external-dns-inwx-webhook/v1.0.0"), slog.Default())
+	_, err := client.Account.Login()
+	assert.ErrorIs(t, err, errRoundTrip)
+	assert.Equal(t, "external-dns-inwx-webhook/v1.0.0", userAgent)
+
+	assert.IsType(t, roundTripperFunc(nil), withUserAgent(transport, ""))
+}
+
+func testParseAPIURL(t *testing.T) {
+	url, err := ParseAPIURL("https://api.ote.domrobot.com/jsonrpc/")
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.ote.domrobot.com/jsonrpc/", url)
+	for _, value := range []string{"api.domrobot.com/xmlrpc/", "ftp://api.domrobot.com/", "https://", "http://%zz"} {
+		_, err := ParseAPIURL(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func testProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer proxy.Close()
+
+	proxyURL, err := ParseProxyURL(proxy.URL)
+	require.NoError(t, err)
+	api := newJSONRPCClient(false, "http://api.domrobot.test/jsonrpc/", newTransport(4, time.Minute, proxyURL, nil, 0))
+	assert.Error(t, api.accountInfo())
+	assert.Equal(t, "http://api.domrobot.test/jsonrpc/", proxied)
+
+	for _, value := range []string{"proxy.corp.example:3128", "ftp://proxy.corp.example", "http://"} {
+		_, err := ParseProxyURL(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func testTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	get := func(transport *http.Transport) error {
+		defer transport.CloseIdleConnections()
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		return err
+	}
+
+	// The test server's certificate isn't trusted by the system
+	assert.Error(t, get(newTransport(4, time.Minute, nil, nil, 0)))
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+	rootCAs, err := LoadCABundle(bundle)
+	require.NoError(t, err)
+	assert.NoError(t, get(newTransport(4, time.Minute, nil, rootCAs, 0)))
+
+	minVersion, err := ParseTLSVersion("1.3")
+	require.NoError(t, err)
+	assert.Error(t, get(newTransport(4, time.Minute, nil, rootCAs, minVersion)))
+
+	_, err = ParseTLSVersion("1.1")
+	assert.Error(t, err)
+	require.NoError(t, os.WriteFile(bundle, []byte("not a certificate"), 0o600))
+	_, err = LoadCABundle(bundle)
+	assert.Error(t, err)
+}
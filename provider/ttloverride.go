@@ -0,0 +1,96 @@
+package inwx
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// TTLProperties are the provider-specific properties overriding the TTL of an endpoint for INWX only.
+// "webhook/inwx-ttl" is what external-dns derives from the external-dns.alpha.kubernetes.io/webhook-inwx-ttl
+// annotation, "inwx/ttl" can be set directly on DNSEndpoint resources.
+var TTLProperties = []string{"inwx/ttl", "webhook/inwx-ttl"}
+
+// applyTTLOverride returns ep with the TTL of its TTL override, if any, and without the TTL properties.
+// Invalid overrides are ignored.
+func (p *INWXProvider) applyTTLOverride(ep *endpoint.Endpoint, adjust adjustFunc) *endpoint.Endpoint {
+	if !slices.ContainsFunc(ep.ProviderSpecific, isTTLProperty) {
+		return ep
+	}
+	ep = ep.DeepCopy()
+	for _, property := range ep.ProviderSpecific {
+		if !isTTLProperty(property) {
+			continue
+		}
+		ttl, err := strconv.ParseInt(property.Value, 10, 64)
+		if err != nil || ttl <= 0 {
+			adjust(ep, adjustmentModified, "invalid_ttl_override", "ignoring invalid TTL override %s=%q", property.Name, property.Value)
+			continue
+		}
+		adjust(ep, adjustmentModified, "ttl_override", "TTL %d overridden with %d by %s", ep.RecordTTL, ttl, property.Name)
+		ep.RecordTTL = endpoint.TTL(ttl)
+	}
+	ep.ProviderSpecific = slices.DeleteFunc(ep.ProviderSpecific, isTTLProperty)
+	return ep
+}
+
+func isTTLProperty(property endpoint.ProviderSpecificProperty) bool {
+	return slices.Contains(TTLProperties, property.Name)
+}
+
+// applyEndpointDefaultTTL returns ep with the default TTL of its zone if it has no TTL, so that external-dns
+// compares it with the TTL the records are created with instead of planning no TTL.
+func (p *INWXProvider) applyEndpointDefaultTTL(ep *endpoint.Endpoint, adjust adjustFunc) *endpoint.Endpoint {
+	if ep.RecordTTL.IsConfigured() {
+		return ep
+	}
+	ttl := p.settingsFor(p.configuredZone(ep.DNSName)).ttl
+	if ttl <= 0 {
+		return ep
+	}
+	ep = ep.DeepCopy()
+	ep.RecordTTL = endpoint.TTL(ttl)
+	adjust(ep, adjustmentModified, "default_ttl", "TTL unset, using the default TTL %d", ttl)
+	return ep
+}
+
+// clampTTL returns ep with its TTL raised to the minimum or lowered to the maximum TTL, if it lies outside
+// of them. INWX stores at least its minimum TTL, so external-dns would otherwise plan to update the records
+// of an endpoint with a lower TTL on every run.
+func (p *INWXProvider) clampTTL(ep *endpoint.Endpoint, adjust adjustFunc) *endpoint.Endpoint {
+	if !ep.RecordTTL.IsConfigured() {
+		return ep
+	}
+	ttl := int64(ep.RecordTTL)
+	switch {
+	case p.config.minTTL > 0 && ttl < int64(p.config.minTTL):
+		ttl = int64(p.config.minTTL)
+	case p.config.maxTTL > 0 && ttl > int64(p.config.maxTTL):
+		ttl = int64(p.config.maxTTL)
+	default:
+		return ep
+	}
+	ep = ep.DeepCopy()
+	adjust(ep, adjustmentModified, "ttl_clamped", "TTL %d clamped to %d", ep.RecordTTL, ttl)
+	ep.RecordTTL = endpoint.TTL(ttl)
+	return ep
+}
+
+// configuredZone returns the longest zone of the zone config name lies in, "" if there is none. The zones
+// hosted at INWX aren't known without listing them, but only those of the zone config can have a TTL of
+// their own.
+func (p *INWXProvider) configuredZone(name string) string {
+	if p.config.zoneConfig == nil {
+		return ""
+	}
+	name = normalizeName(name)
+	match := ""
+	for zone := range p.config.zoneConfig.Zones {
+		if (name == zone || strings.HasSuffix(name, "."+zone)) && len(zone) > len(match) {
+			match = zone
+		}
+	}
+	return match
+}
@@ -0,0 +1,107 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestTTLOverride(t *testing.T) {
+	_, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+
+	annotated := endpoint.NewEndpointWithTTL("foo.example.com", "A", 300, "192.0.2.1").WithProviderSpecific("webhook/inwx-ttl", "3600")
+	direct := endpoint.NewEndpoint("bar.example.com", "A", "192.0.2.1").WithProviderSpecific("inwx/ttl", "60").WithProviderSpecific("aws/weight", "10")
+	invalid := endpoint.NewEndpointWithTTL("baz.example.com", "A", 300, "192.0.2.1").WithProviderSpecific("inwx/ttl", "soon")
+	plain := endpoint.NewEndpointWithTTL("qux.example.com", "A", 300, "192.0.2.1")
+
+	adjusted, err := p.AdjustEndpoints([]*endpoint.Endpoint{annotated, direct, invalid, plain})
+	require.NoError(t, err)
+	require.Len(t, adjusted, 4)
+	assert.Equal(t, endpoint.TTL(3600), adjusted[0].RecordTTL)
+	assert.Empty(t, adjusted[0].ProviderSpecific)
+	assert.Equal(t, endpoint.TTL(60), adjusted[1].RecordTTL)
+	assert.Equal(t, endpoint.ProviderSpecific{{Name: "aws/weight", Value: "10"}}, adjusted[1].ProviderSpecific)
+	assert.Equal(t, endpoint.TTL(300), adjusted[2].RecordTTL)
+	assert.Empty(t, adjusted[2].ProviderSpecific)
+	assert.Same(t, plain, adjusted[3])
+
+	// The endpoints of the caller are left alone
+	assert.Equal(t, endpoint.TTL(300), annotated.RecordTTL)
+	assert.Len(t, annotated.ProviderSpecific, 1)
+}
+
+func TestDefaultTTL(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	p.config.defaultTTL = 600
+	previewTTL := 60
+	p.config.zoneConfig = &ZoneConfig{Zones: map[string]ZoneSettings{"preview.example.com": {TTL: &previewTTL}}}
+
+	unset := endpoint.NewEndpoint("foo.example.com", "A", "192.0.2.1")
+	preview := endpoint.NewEndpoint("app.preview.example.com", "A", "192.0.2.2")
+	configured := endpoint.NewEndpointWithTTL("bar.example.com", "A", 300, "192.0.2.3")
+
+	// Endpoints without a TTL get the default of their zone, so that external-dns compares TTLs
+	adjusted, adjustments, err := p.AdjustEndpointsWithReasons([]*endpoint.Endpoint{unset, preview, configured})
+	require.NoError(t, err)
+	require.Len(t, adjusted, 3)
+	assert.Equal(t, endpoint.TTL(600), adjusted[0].RecordTTL)
+	assert.Equal(t, endpoint.TTL(60), adjusted[1].RecordTTL)
+	assert.Same(t, configured, adjusted[2])
+	assert.False(t, unset.RecordTTL.IsConfigured())
+	require.Len(t, adjustments, 2)
+	assert.Equal(t, "default_ttl", adjustments[0].Reason)
+
+	// Changes without a TTL are created with the default too
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{unset}}))
+	recs, err := w.getRecords("example.com")
+	require.NoError(t, err)
+	require.Len(t, *recs, 1)
+	assert.Equal(t, 600, (*recs)[0].TTL)
+
+	// Without a default, INWX picks the TTL
+	p.config.defaultTTL = 0
+	adjusted, err = p.AdjustEndpoints([]*endpoint.Endpoint{unset})
+	require.NoError(t, err)
+	assert.Same(t, unset, adjusted[0])
+}
+
+func TestTTLClamping(t *testing.T) {
+	_, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	p.config.minTTL = 300
+	p.config.maxTTL = 86400
+
+	low := endpoint.NewEndpointWithTTL("low.example.com", "A", 60, "192.0.2.1")
+	high := endpoint.NewEndpointWithTTL("high.example.com", "A", 604800, "192.0.2.2")
+	within := endpoint.NewEndpointWithTTL("within.example.com", "A", 3600, "192.0.2.3")
+	unset := endpoint.NewEndpoint("unset.example.com", "A", "192.0.2.4")
+	override := endpoint.NewEndpointWithTTL("override.example.com", "A", 3600, "192.0.2.5").WithProviderSpecific("inwx/ttl", "30")
+
+	// TTLs outside of the range are clamped to it, after the overrides were applied
+	adjusted, adjustments, err := p.AdjustEndpointsWithReasons([]*endpoint.Endpoint{low, high, within, unset, override})
+	require.NoError(t, err)
+	require.Len(t, adjusted, 5)
+	assert.Equal(t, endpoint.TTL(300), adjusted[0].RecordTTL)
+	assert.Equal(t, endpoint.TTL(86400), adjusted[1].RecordTTL)
+	assert.Same(t, within, adjusted[2])
+	assert.Same(t, unset, adjusted[3])
+	assert.Equal(t, endpoint.TTL(300), adjusted[4].RecordTTL)
+	assert.Equal(t, endpoint.TTL(60), low.RecordTTL)
+	reasons := []string{}
+	for _, adjustment := range adjustments {
+		reasons = append(reasons, adjustment.Reason)
+	}
+	assert.Equal(t, []string{"ttl_clamped", "ttl_clamped", "ttl_override", "ttl_clamped"}, reasons)
+
+	// Zero disables a bound
+	p.config.minTTL, p.config.maxTTL = 0, 0
+	adjusted, err = p.AdjustEndpoints([]*endpoint.Endpoint{low, high})
+	require.NoError(t, err)
+	assert.Same(t, low, adjusted[0])
+	assert.Same(t, high, adjusted[1])
+}
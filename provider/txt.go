@@ -0,0 +1,85 @@
+package inwx
+
+import (
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// unquoteTXT returns the text of a TXT record content. Content made up entirely of quoted character
+// strings, as external-dns writes ownership records and zone files present long contents (e.g.
+// "v=DKIM1; k=rsa; " "p=MIIB..."), is unquoted: the strings are concatenated and the escapes \", \\ and
+// \DDD are resolved. Anything else is taken literally, so plain contents with semicolons, backslashes,
+// quotes or UTF-8 pass through unchanged.
+func unquoteTXT(content string) string {
+	var text strings.Builder
+	rest := strings.TrimSpace(content)
+	if rest == "" {
+		return content
+	}
+	for rest != "" {
+		if rest[0] != '"' {
+			return content
+		}
+		i, closed := 1, false
+		for i < len(rest) && !closed {
+			switch c := rest[i]; c {
+			case '"':
+				closed = true
+				i++
+			case '\\':
+				if i+3 < len(rest) && isDigits(rest[i+1:i+4]) {
+					n, err := strconv.ParseUint(rest[i+1:i+4], 10, 8)
+					if err != nil {
+						return content
+					}
+					text.WriteByte(byte(n))
+					i += 4
+				} else if i+1 < len(rest) {
+					text.WriteByte(rest[i+1])
+					i += 2
+				} else {
+					return content
+				}
+			default:
+				text.WriteByte(c)
+				i++
+			}
+		}
+		if !closed {
+			return content
+		}
+		rest = strings.TrimLeft(rest[i:], " \t")
+	}
+	return text.String()
+}
+
+func isDigits(s string) bool {
+	return strings.Trim(s, "0123456789") == ""
+}
+
+// normalizeTXT returns changes with the targets of TXT endpoints unquoted, the way they are stored in
+// INWX, so that they compare equal to the records read back.
+func normalizeTXT(changes *plan.Changes) *plan.Changes {
+	normalize := func(eps []*endpoint.Endpoint) []*endpoint.Endpoint {
+		normalized := make([]*endpoint.Endpoint, 0, len(eps))
+		for _, ep := range eps {
+			if ep.RecordType == endpoint.RecordTypeTXT {
+				ep = ep.DeepCopy()
+				for i, target := range ep.Targets {
+					ep.Targets[i] = unquoteTXT(target)
+				}
+			}
+			normalized = append(normalized, ep)
+		}
+		return normalized
+	}
+	return &plan.Changes{
+		Create:    normalize(changes.Create),
+		UpdateOld: normalize(changes.UpdateOld),
+		UpdateNew: normalize(changes.UpdateNew),
+		Delete:    normalize(changes.Delete),
+	}
+}
@@ -0,0 +1,85 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestTXT(t *testing.T) {
+	t.Run("Unquote", testUnquoteTXT)
+	t.Run("RoundTrip", testTXTRoundTrip)
+}
+
+func testUnquoteTXT(t *testing.T) {
+	for content, expected := range map[string]string{
+		`v=spf1 include:_spf.example.com ~all`:               `v=spf1 include:_spf.example.com ~all`,
+		`v=DKIM1; k=rsa; p=MIIBIjANBg`:                       `v=DKIM1; k=rsa; p=MIIBIjANBg`,
+		`"v=DKIM1; k=rsa; " "p=MIIBIjANBg"`:                  `v=DKIM1; k=rsa; p=MIIBIjANBg`,
+		`"heritage=external-dns,external-dns/owner=default"`: `heritage=external-dns,external-dns/owner=default`,
+		`C:\path\to;file`:                                    `C:\path\to;file`,
+		`"C:\\path\\to;file"`:                                `C:\path\to;file`,
+		`say "hello"`:                                        `say "hello"`,
+		`"say \"hello\""`:                                    `say "hello"`,
+		`"hello" world`:                                      `"hello" world`,
+		`"unterminated`:                                      `"unterminated`,
+		`"trailing\`:                                         `"trailing\`,
+		`grüße, 你好`:                                          `grüße, 你好`,
+		`"gr\195\188\195\159e"`:                              `grüße`,
+		`"\999"`:                                             `"\999"`,
+		`""`:                                                 ``,
+		``:                                                   ``,
+	} {
+		assert.Equal(t, expected, unquoteTXT(content), content)
+	}
+}
+
+func testTXTRoundTrip(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+
+	targets := []string{
+		`v=DKIM1; k=rsa; p=MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA`,
+		`google-site-verification=a\b;c`,
+		`say "hello"`,
+		`grüße, 你好`,
+	}
+	ownership := `"heritage=external-dns,external-dns/owner=default"`
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("foo.example.com", endpoint.RecordTypeTXT, targets...),
+		endpoint.NewEndpoint("bar.example.com", endpoint.RecordTypeTXT, ownership),
+	}}))
+
+	recs, err := w.getRecords("example.com")
+	require.NoError(t, err)
+	contents := []string{}
+	for _, rec := range *recs {
+		contents = append(contents, rec.Content)
+	}
+	assert.ElementsMatch(t, append(targets, "heritage=external-dns,external-dns/owner=default"), contents)
+
+	// The records read back compare equal, so external-dns doesn't update them on every run
+	endpoints, err := p.Records(context.TODO())
+	require.NoError(t, err)
+	read := []string{}
+	for _, ep := range endpoints {
+		if ep.DNSName == "foo.example.com" {
+			read = append(read, ep.Targets...)
+		}
+	}
+	assert.ElementsMatch(t, targets, read)
+
+	// and deleting them with the quoted targets external-dns knows them by works
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Delete: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("foo.example.com", endpoint.RecordTypeTXT, targets...),
+		endpoint.NewEndpoint("bar.example.com", endpoint.RecordTypeTXT, ownership),
+	}}))
+	recs, err = w.getRecords("example.com")
+	require.NoError(t, err)
+	assert.Empty(t, *recs)
+}
@@ -0,0 +1,154 @@
+package inwx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// supportedRecordTypes are the record types the provider manages in INWX.
+var supportedRecordTypes = []string{
+	endpoint.RecordTypeA,
+	endpoint.RecordTypeAAAA,
+	endpoint.RecordTypeCNAME,
+	endpoint.RecordTypeMX,
+	endpoint.RecordTypeNAPTR,
+	endpoint.RecordTypeNS,
+	endpoint.RecordTypePTR,
+	endpoint.RecordTypeSRV,
+	endpoint.RecordTypeTXT,
+	"CAA",
+}
+
+// ValidationProblem is a reason an endpoint would fail, or be skipped, at reconcile time. Check names
+// the failed check: targets, type, zone, content or policy.
+type ValidationProblem struct {
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+// ValidationResult is the outcome of validating a single endpoint. Endpoints the provider leaves alone
+// are not checked; Skipped holds the reason, domain_filter or ignored, like for DesiredEndpoints.
+type ValidationResult struct {
+	Endpoint *endpoint.Endpoint  `json:"endpoint"`
+	Valid    bool                `json:"valid"`
+	Zone     string              `json:"zone,omitempty"`
+	Skipped  string              `json:"skipped,omitempty"`
+	Problems []ValidationProblem `json:"problems,omitempty"`
+}
+
+// ValidateEndpoints checks endpoints the way creating them would, so that CI can reject changes that
+// would fail at reconcile time: that they have targets of a supported type, fall into one of the INWX
+// zones, have targets of valid syntax for their type, and aren't skipped by the zone settings. Only the
+// zone list is read from INWX; freeze records are not checked.
+func (p *INWXProvider) ValidateEndpoints(ctx context.Context, endpoints []*endpoint.Endpoint) ([]ValidationResult, error) {
+	done, err := p.lifecycle.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if err := p.login(ctx); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := p.client.logout(); err != nil {
+			p.logger.Error("error encountered while logging out", "err", err)
+		}
+	}()
+	zones, err := p.getZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ValidationResult, 0, len(endpoints))
+	for _, ep := range endpoints {
+		results = append(results, p.validateEndpoint(zones, ep))
+	}
+	return results, nil
+}
+
+func (p *INWXProvider) validateEndpoint(zones *[]string, ep *endpoint.Endpoint) ValidationResult {
+	result := ValidationResult{Endpoint: ep}
+	switch {
+	case !p.managesName(ep.DNSName):
+		result.Skipped = "domain_filter"
+	case p.isIgnored(ep):
+		result.Skipped = "ignored"
+	}
+	if result.Skipped != "" {
+		result.Valid = true
+		return result
+	}
+
+	problem := func(check string, format string, args ...any) {
+		result.Problems = append(result.Problems, ValidationProblem{Check: check, Message: fmt.Sprintf(format, args...)})
+	}
+	if len(ep.Targets) == 0 {
+		problem("targets", "%s", ErrNoTargets)
+	}
+	supported := SupportedRecordType(ep.RecordType)
+	if !supported {
+		problem("type", "record type %q is not supported, expected one of %s", ep.RecordType, strings.Join(supportedRecordTypes, ", "))
+	}
+	zone, err := p.zoneFor(zones, ep)
+	if err != nil {
+		problem("zone", "no INWX zone matches %s", ep.DNSName)
+	}
+	result.Zone = zone
+
+	if supported {
+		for _, target := range ep.Targets {
+			if err := validateTarget(ep, target); err != nil {
+				problem("content", "invalid %s target %q: %v", ep.RecordType, target, err)
+			}
+		}
+		if _, err := parseRedirect(ep); err != nil {
+			problem("redirect", "%v", err)
+		}
+	}
+	if zone != "" {
+		if reason := p.settingsFor(zone).skipReason(actionCreate, p.registry.RecordName(ep.DNSName, zone), ep.RecordType); reason != "" {
+			problem("policy", "creating the record would be skipped: %s", reason)
+		}
+	}
+	result.Valid = len(result.Problems) == 0
+	return result
+}
+
+// SupportedRecordTypes returns the record types the provider manages in INWX. Creates and updates of
+// other types are skipped by ApplyChanges.
+func SupportedRecordTypes() []string {
+	return slices.Clone(supportedRecordTypes)
+}
+
+// SupportedRecordType reports whether the provider manages records of recordType in INWX.
+func SupportedRecordType(recordType string) bool {
+	return slices.Contains(supportedRecordTypes, recordType)
+}
+
+// validateContent checks the syntax of a target of recordType by parsing it as a zone file record.
+// TXT targets are stored as plain text and accepted as they are.
+func validateContent(recordType string, target string) error {
+	if recordType == endpoint.RecordTypeTXT {
+		return nil
+	}
+	if strings.TrimSpace(target) == "" {
+		return fmt.Errorf("empty target")
+	}
+	rr, err := dns.NewRR(". 300 IN " + recordType + " " + target)
+	if err != nil {
+		// The position refers to the zone file line built above, not to the target.
+		message, _, _ := strings.Cut(strings.TrimPrefix(err.Error(), "dns: "), " at line:")
+		return errors.New(message)
+	}
+	if rr == nil {
+		return fmt.Errorf("empty target")
+	}
+	return nil
+}
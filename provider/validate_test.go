@@ -0,0 +1,108 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestValidateEndpoints(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	p.config.ignoreProperties = DefaultIgnoreProperties
+	p.config.zoneConfig = &ZoneConfig{Defaults: ZoneSettings{ProtectedNames: []string{"www"}}}
+
+	ignored := endpoint.NewEndpoint("legacy.example.com", "A", "192.0.2.1").WithProviderSpecific("inwx/ignore", "true")
+	results, err := p.ValidateEndpoints(context.TODO(), []*endpoint.Endpoint{
+		endpoint.NewEndpoint("foo.example.com", "A", "192.0.2.1"),
+		endpoint.NewEndpoint("mail.example.com", "MX", "10 mx.example.net"),
+		endpoint.NewEndpoint("spf.example.com", "TXT", "v=spf1 -all"),
+		endpoint.NewEndpoint("bar.example.com", "A", "192.0.2.300"),
+		endpoint.NewEndpoint("mx.example.com", "MX", "mx.example.net"),
+		endpoint.NewEndpoint("foo.sub.example.net", "A", "192.0.2.1"),
+		endpoint.NewEndpoint("foo.example.com", "SPF", "v=spf1 -all"),
+		endpoint.NewEndpoint("empty.example.com", "A"),
+		endpoint.NewEndpoint("www.example.com", "CNAME", "lb.example.net"),
+		ignored,
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 10)
+
+	checks := func(r ValidationResult) []string {
+		checks := []string{}
+		for _, problem := range r.Problems {
+			checks = append(checks, problem.Check)
+		}
+		return checks
+	}
+	for i, expected := range [][]string{{}, {}, {}, {"content"}, {"content"}, {}, {"type"}, {"targets"}, {"policy"}, {}} {
+		assert.Equal(t, expected, checks(results[i]), results[i].Endpoint.DNSName)
+		assert.Equal(t, len(expected) == 0, results[i].Valid, results[i].Endpoint.DNSName)
+	}
+	assert.Equal(t, "example.com", results[0].Zone)
+	assert.Equal(t, "domain_filter", results[5].Skipped)
+	assert.Equal(t, "ignored", results[9].Skipped)
+	assert.Equal(t, `invalid A target "192.0.2.300": bad A A: "192.0.2.300"`, results[3].Problems[0].Message)
+
+	// An endpoint in no zone at all
+	p.filter = nil
+	results, err = p.ValidateEndpoints(context.TODO(), []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.net", "A", "192.0.2.1")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"zone"}, checks(results[0]))
+
+	// Nothing was written to INWX
+	recs, err := w.getRecords("example.com")
+	require.NoError(t, err)
+	assert.Empty(t, *recs)
+}
+
+func TestUnsupportedRecordTypes(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	require.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: "legacy", Type: "DNAME", Content: "example.net", TTL: 300}))
+
+	assert.True(t, SupportedRecordType("CAA"))
+	assert.False(t, SupportedRecordType("DNAME"))
+	assert.NotContains(t, SupportedRecordTypes(), "DNAME")
+
+	// Creates and updates of unsupported types are skipped and counted instead of being sent to INWX
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	require.NoError(t, p.ApplyChanges(ctx, &plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("other.example.com", "DNAME", "example.org"),
+		endpoint.NewEndpoint("www.example.com", "A", "192.0.2.1"),
+	}}))
+	changes := recorder.Changes()
+	require.Len(t, changes, 2)
+	assert.Equal(t, "unsupported_type", changes[0].Skipped)
+	assert.Equal(t, "applied", changes[1].Outcome)
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.metrics.skippedChangesTotal.WithLabelValues("example.com", "create", "unsupported_type")))
+
+	// Existing records of unsupported types can still be deleted
+	require.NoError(t, p.ApplyChanges(context.TODO(), &plan.Changes{Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("legacy.example.com", "DNAME", "example.net")}}))
+	assert.Empty(t, (*w.db["example.com"])[0].ID)
+}
+
+func TestNAPTRRecords(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	w.CreateZone("example.com")
+	target := `100 10 "u" "E2U+sip" "!^.*$!sip:info@example.com!" .`
+
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	require.NoError(t, p.ApplyChanges(ctx, &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("sip.example.com", endpoint.RecordTypeNAPTR, target)}}))
+	require.Len(t, recorder.Changes(), 1)
+	assert.Equal(t, "applied", recorder.Changes()[0].Outcome)
+
+	recs, err := w.getRecords("example.com")
+	require.NoError(t, err)
+	require.Len(t, *recs, 1)
+	assert.Equal(t, "sip", (*recs)[0].Name)
+	assert.Equal(t, endpoint.RecordTypeNAPTR, (*recs)[0].Type)
+	assert.Equal(t, target, (*recs)[0].Content)
+	assert.NoError(t, validateContent(endpoint.RecordTypeNAPTR, target))
+}
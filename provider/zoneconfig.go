@@ -0,0 +1,294 @@
+package inwx
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Zone policies, mirroring the external-dns --policy semantics.
+const (
+	PolicySync       = "sync"
+	PolicyUpsertOnly = "upsert-only"
+	PolicyCreateOnly = "create-only"
+)
+
+// ZoneSettings holds settings that can be set globally and overridden per zone.
+// Unset (nil) fields inherit the global value.
+type ZoneSettings struct {
+	// TTL applied to records whose endpoint has no TTL configured.
+	TTL *int `json:"ttl,omitempty"`
+	// Policy restricting which mutations are allowed: sync, upsert-only or create-only.
+	Policy *string `json:"policy,omitempty"`
+	// DryRun logs mutations instead of sending them to INWX.
+	DryRun *bool `json:"dryRun,omitempty"`
+	// RateLimit caps the number of mutations per second; 0 means unlimited.
+	RateLimit *float64 `json:"rateLimit,omitempty"`
+	// ProtectedNames are record names (relative to the zone, "" or "@" for the apex) that are never modified.
+	ProtectedNames []string `json:"protectedNames,omitempty"`
+	// AllowApexChanges permits A, AAAA and TXT mutations at the zone apex.
+	AllowApexChanges *bool `json:"allowApexChanges,omitempty"`
+	// Frozen skips every mutation, pausing automation for the zone.
+	Frozen *bool `json:"frozen,omitempty"`
+	// RecordLimit is the number of records the zone may hold; 0 means unlimited.
+	RecordLimit *int `json:"recordLimit,omitempty"`
+	// BlockOverRecordLimit skips the creates of changes that would exceed RecordLimit instead of only
+	// warning about them.
+	BlockOverRecordLimit *bool `json:"blockOverRecordLimit,omitempty"`
+	// ZoneTemplate names the entry of zoneTemplates --create-missing-zones creates the zone with.
+	ZoneTemplate *string `json:"zoneTemplate,omitempty"`
+}
+
+// ZoneTemplate is how --create-missing-zones sets up a zone, so that new zones follow the same standards.
+type ZoneTemplate struct {
+	// Nameservers are the NS records of the zone.
+	Nameservers []string    `json:"nameservers"`
+	SOA         SOATemplate `json:"soa,omitempty"`
+	// Records are created in the zone right after it, e.g. SPF or CAA records.
+	Records []TemplateRecord `json:"records,omitempty"`
+}
+
+// SOATemplate holds the SOA values of a created zone; unset (zero) values are left to INWX.
+type SOATemplate struct {
+	// Email is the mailbox responsible for the zone, e.g. hostmaster@example.com.
+	Email   string `json:"email,omitempty"`
+	Refresh int    `json:"refresh,omitempty"`
+	Retry   int    `json:"retry,omitempty"`
+	Expire  int    `json:"expire,omitempty"`
+	// Minimum is the TTL of negative answers.
+	Minimum int `json:"minimum,omitempty"`
+}
+
+// TemplateRecord is a record of a ZoneTemplate.
+type TemplateRecord struct {
+	// Name is relative to the zone, "" or "@" for the apex.
+	Name string `json:"name,omitempty"`
+	Type string `json:"type"`
+	// Content is written like the target of an endpoint, e.g. "10 mx.example.com" for MX records.
+	Content string `json:"content"`
+	TTL     int    `json:"ttl,omitempty"`
+}
+
+// ZoneConfig is the on-disk format of the --zone-config file.
+type ZoneConfig struct {
+	Defaults ZoneSettings            `json:"defaults"`
+	Zones    map[string]ZoneSettings `json:"zones"`
+	// Features enables or disables experimental features; --feature-gate flags take precedence.
+	Features map[Feature]bool `json:"features,omitempty"`
+	// ZoneTemplates are the templates zones can be created with, by name.
+	ZoneTemplates map[string]ZoneTemplate `json:"zoneTemplates,omitempty"`
+}
+
+// apexGuardedTypes are the record types that may only be changed at the zone apex when explicitly allowed,
+// since they routinely hold mail and domain verification records.
+var apexGuardedTypes = []string{"A", "AAAA", "TXT"}
+
+// zoneSettings are the effective settings for a single zone.
+type zoneSettings struct {
+	ttl            int
+	policy         string
+	dryRun         bool
+	rateLimit      float64
+	protectedNames []string
+	allowApex      bool
+	frozen         bool
+	recordLimit    int
+	blockOverLimit bool
+	zoneTemplate   string
+}
+
+// LoadZoneConfig reads and validates a zone configuration file in YAML or JSON format.
+func LoadZoneConfig(path string) (*ZoneConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read zone config %s: %w", path, err)
+	}
+	if err := checkZoneConfigKeys(data); err != nil {
+		return nil, fmt.Errorf("invalid zone config %s: %w", path, err)
+	}
+	var cfg ZoneConfig
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("unable to parse zone config %s: %w", path, err)
+	}
+	if err := cfg.Defaults.validate(cfg.ZoneTemplates); err != nil {
+		return nil, fmt.Errorf("invalid defaults in zone config %s: %w", path, err)
+	}
+	for name, template := range cfg.ZoneTemplates {
+		if err := template.validate(); err != nil {
+			return nil, fmt.Errorf("invalid zone template %s in zone config %s: %w", name, path, err)
+		}
+	}
+	for feature := range cfg.Features {
+		if _, ok := knownFeatures[feature]; !ok {
+			return nil, fmt.Errorf("unknown feature %q in zone config %s", feature, path)
+		}
+	}
+	for zone, settings := range cfg.Zones {
+		if err := settings.validate(cfg.ZoneTemplates); err != nil {
+			return nil, fmt.Errorf("invalid settings for zone %s in zone config %s: %w", zone, path, err)
+		}
+	}
+	return &cfg, nil
+}
+
+// checkZoneConfigKeys rejects keys that aren't part of the zone config format, pointing out likely typos.
+func checkZoneConfigKeys(data []byte) error {
+	var raw struct {
+		Defaults map[string]any            `json:"defaults"`
+		Zones    map[string]map[string]any `json:"zones"`
+	}
+	var top map[string]any
+	if err := yaml.Unmarshal(data, &top); err != nil {
+		return err
+	}
+	// Type mismatches are left to the strict decoding of the whole file.
+	_ = yaml.Unmarshal(data, &raw)
+
+	configKeys := jsonKeys(reflect.TypeFor[ZoneConfig]())
+	settingsKeys := jsonKeys(reflect.TypeFor[ZoneSettings]())
+	for _, key := range slices.Sorted(maps.Keys(top)) {
+		if !slices.Contains(configKeys, key) {
+			return unknownKeyError(key, "the top level", configKeys)
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(raw.Defaults)) {
+		if !slices.Contains(settingsKeys, key) {
+			return unknownKeyError(key, "defaults", settingsKeys)
+		}
+	}
+	for _, zone := range slices.Sorted(maps.Keys(raw.Zones)) {
+		for _, key := range slices.Sorted(maps.Keys(raw.Zones[zone])) {
+			if !slices.Contains(settingsKeys, key) {
+				return unknownKeyError(key, "zone "+zone, settingsKeys)
+			}
+		}
+	}
+	return nil
+}
+
+func (s ZoneSettings) validate(templates map[string]ZoneTemplate) error {
+	if s.Policy != nil && !slices.Contains([]string{PolicySync, PolicyUpsertOnly, PolicyCreateOnly}, *s.Policy) {
+		return fmt.Errorf("unknown policy %q", *s.Policy)
+	}
+	if s.TTL != nil && *s.TTL < 0 {
+		return fmt.Errorf("ttl must not be negative")
+	}
+	if s.RateLimit != nil && *s.RateLimit < 0 {
+		return fmt.Errorf("rateLimit must not be negative")
+	}
+	if s.RecordLimit != nil && *s.RecordLimit < 0 {
+		return fmt.Errorf("recordLimit must not be negative")
+	}
+	if s.ZoneTemplate != nil && *s.ZoneTemplate != "" {
+		if _, ok := templates[*s.ZoneTemplate]; !ok {
+			return fmt.Errorf("unknown zoneTemplate %q", *s.ZoneTemplate)
+		}
+	}
+	return nil
+}
+
+func (t ZoneTemplate) validate() error {
+	if len(t.Nameservers) == 0 {
+		return fmt.Errorf("nameservers must not be empty")
+	}
+	for _, value := range []int{t.SOA.Refresh, t.SOA.Retry, t.SOA.Expire, t.SOA.Minimum} {
+		if value < 0 {
+			return fmt.Errorf("soa values must not be negative")
+		}
+	}
+	for _, rec := range t.Records {
+		if !SupportedRecordType(rec.Type) {
+			return fmt.Errorf("unsupported record type %q", rec.Type)
+		}
+		if rec.Content == "" {
+			return fmt.Errorf("record %s %s has no content", rec.Name, rec.Type)
+		}
+		if rec.TTL < 0 {
+			return fmt.Errorf("record %s %s: ttl must not be negative", rec.Name, rec.Type)
+		}
+	}
+	return nil
+}
+
+// merge returns s with every field set in override replaced.
+func (s zoneSettings) merge(override ZoneSettings) zoneSettings {
+	if override.TTL != nil {
+		s.ttl = *override.TTL
+	}
+	if override.Policy != nil {
+		s.policy = *override.Policy
+	}
+	if override.DryRun != nil {
+		s.dryRun = *override.DryRun
+	}
+	if override.RateLimit != nil {
+		s.rateLimit = *override.RateLimit
+	}
+	if override.ProtectedNames != nil {
+		s.protectedNames = override.ProtectedNames
+	}
+	if override.AllowApexChanges != nil {
+		s.allowApex = *override.AllowApexChanges
+	}
+	if override.Frozen != nil {
+		s.frozen = *override.Frozen
+	}
+	if override.RecordLimit != nil {
+		s.recordLimit = *override.RecordLimit
+	}
+	if override.BlockOverRecordLimit != nil {
+		s.blockOverLimit = *override.BlockOverRecordLimit
+	}
+	if override.ZoneTemplate != nil {
+		s.zoneTemplate = *override.ZoneTemplate
+	}
+	return s
+}
+
+// settingsFor returns the effective settings for zone.
+func (p *INWXProvider) settingsFor(zone string) zoneSettings {
+	settings := zoneSettings{ttl: p.config.defaultTTL, policy: PolicySync, allowApex: p.config.allowApexChanges,
+		recordLimit: p.config.recordLimit, blockOverLimit: p.config.blockOverLimit}
+	if p.config.zoneConfig == nil {
+		return settings
+	}
+	settings = settings.merge(p.config.zoneConfig.Defaults)
+	if override, ok := p.config.zoneConfig.Zones[zone]; ok {
+		settings = settings.merge(override)
+	}
+	return settings
+}
+
+// skipReason returns why a mutation must not be sent to INWX under these settings, or "" if it may.
+func (s zoneSettings) skipReason(action changeAction, name string, recordType string) string {
+	switch {
+	case s.frozen:
+		return "frozen"
+	case name == "" && !s.allowApex && slices.Contains(apexGuardedTypes, recordType):
+		return "apex"
+	case s.policy == PolicyUpsertOnly && action == actionDelete:
+		return "policy"
+	case s.policy == PolicyCreateOnly && action != actionCreate:
+		return "policy"
+	case s.isProtected(name):
+		return "protected"
+	}
+	return ""
+}
+
+func (s zoneSettings) isProtected(name string) bool {
+	for _, protected := range s.protectedNames {
+		if protected == "@" {
+			protected = ""
+		}
+		if strings.EqualFold(protected, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestZoneConfig(t *testing.T) {
+	t.Run("Load", testLoadZoneConfig)
+	t.Run("SettingsResolution", testZoneSettingsResolution)
+	t.Run("Enforcement", testZoneSettingsEnforcement)
+	t.Run("ApexGuard", testApexGuard)
+	t.Run("Freeze", testFreeze)
+}
+
+func writeZoneConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "zones.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func testLoadZoneConfig(t *testing.T) {
+	cfg, err := LoadZoneConfig(writeZoneConfig(t, `
+defaults:
+  ttl: 300
+zones:
+  preview.example.com:
+    ttl: 60
+    dryRun: true
+`))
+	require.NoError(t, err)
+	assert.Equal(t, 300, *cfg.Defaults.TTL)
+	assert.Equal(t, 60, *cfg.Zones["preview.example.com"].TTL)
+
+	_, err = LoadZoneConfig(writeZoneConfig(t, "zones:\n  example.com:\n    policy: yolo\n"))
+	assert.ErrorContains(t, err, `unknown policy "yolo"`)
+
+	_, err = LoadZoneConfig(writeZoneConfig(t, "defaults:\n  tll: 300\n"))
+	assert.ErrorContains(t, err, `unknown key "tll" in defaults, did you mean "ttl"?`)
+
+	_, err = LoadZoneConfig(writeZoneConfig(t, "zones:\n  example.com:\n    ratelimit: 5\n"))
+	assert.ErrorContains(t, err, `unknown key "ratelimit" in zone example.com, did you mean "rateLimit"?`)
+
+	_, err = LoadZoneConfig(writeZoneConfig(t, "zone:\n  example.com: {}\n"))
+	assert.ErrorContains(t, err, `unknown key "zone" in the top level, did you mean "zones"?`)
+
+	_, err = LoadZoneConfig(writeZoneConfig(t, "defaults:\n  owner: me\n"))
+	assert.ErrorContains(t, err, `unknown key "owner" in defaults, expected one of ttl, policy`)
+
+	cfg, err = LoadZoneConfig(writeZoneConfig(t, `
+defaults:
+  zoneTemplate: standard
+zoneTemplates:
+  standard:
+    nameservers: [ns.inwx.de, ns2.inwx.de]
+    soa: {email: hostmaster@example.com, refresh: 7200}
+    records:
+      - {name: "@", type: TXT, content: "v=spf1 -all"}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "standard", *cfg.Defaults.ZoneTemplate)
+	assert.Equal(t, []TemplateRecord{{Name: "@", Type: "TXT", Content: "v=spf1 -all"}}, cfg.ZoneTemplates["standard"].Records)
+
+	_, err = LoadZoneConfig(writeZoneConfig(t, "zones:\n  example.com:\n    zoneTemplate: missing\n"))
+	assert.ErrorContains(t, err, `unknown zoneTemplate "missing"`)
+
+	_, err = LoadZoneConfig(writeZoneConfig(t, "zoneTemplates:\n  empty: {}\n"))
+	assert.ErrorContains(t, err, "invalid zone template empty")
+
+	_, err = LoadZoneConfig(writeZoneConfig(t, "zoneTemplates:\n  standard:\n    nameservers: [ns.inwx.de]\n    records: [{type: HINFO, content: x}]\n"))
+	assert.ErrorContains(t, err, `unsupported record type "HINFO"`)
+}
+
+func testZoneSettingsResolution(t *testing.T) {
+	_, p := NewINWXProviderWithMockClient(&[]string{}, slog.Default())
+	p.config.allowApexChanges = true
+	assert.Equal(t, zoneSettings{policy: PolicySync, allowApex: true}, p.settingsFor("example.com"))
+
+	ttl, overrideTTL, dryRun, policy := 300, 60, true, PolicyUpsertOnly
+	p.config.zoneConfig = &ZoneConfig{
+		Defaults: ZoneSettings{TTL: &ttl, Policy: &policy, ProtectedNames: []string{"@"}},
+		Zones: map[string]ZoneSettings{
+			"preview.example.com": {TTL: &overrideTTL, DryRun: &dryRun, ProtectedNames: []string{}},
+		},
+	}
+	assert.Equal(t, zoneSettings{ttl: 300, policy: PolicyUpsertOnly, protectedNames: []string{"@"}, allowApex: true}, p.settingsFor("example.com"))
+	assert.Equal(t, zoneSettings{ttl: 60, policy: PolicyUpsertOnly, dryRun: true, protectedNames: []string{}, allowApex: true}, p.settingsFor("preview.example.com"))
+}
+
+func testZoneSettingsEnforcement(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{}, slog.Default())
+	w.CreateZone("example.com")
+	w.CreateZone("preview.example.com")
+
+	ttl, dryRun, policy := 600, true, PolicyUpsertOnly
+	p.config.zoneConfig = &ZoneConfig{
+		Defaults: ZoneSettings{TTL: &ttl, Policy: &policy, ProtectedNames: []string{"www"}},
+		Zones: map[string]ZoneSettings{
+			"preview.example.com": {DryRun: &dryRun},
+		},
+	}
+
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	err := p.ApplyChanges(ctx, &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("foo.example.com", "A", "1.1.1.1"),
+			endpoint.NewEndpoint("www.example.com", "A", "1.1.1.1"),
+			endpoint.NewEndpoint("foo.preview.example.com", "A", "1.1.1.1"),
+		},
+	})
+	assert.NoError(t, err)
+
+	// Only the unprotected record in the non-dry-run zone is created, with the default TTL
+	recs, _ := w.getRecords("example.com")
+	require.Len(t, *recs, 1)
+	assert.Equal(t, "foo", (*recs)[0].Name)
+	assert.Equal(t, 600, (*recs)[0].TTL)
+	recs, _ = w.getRecords("preview.example.com")
+	assert.Empty(t, *recs)
+
+	changes := recorder.Changes()
+	require.Len(t, changes, 3)
+	assert.Equal(t, "", changes[0].Skipped)
+	assert.Equal(t, "protected", changes[1].Skipped)
+	assert.Equal(t, "dry_run", changes[2].Skipped)
+
+	// upsert-only never deletes
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.com", "A", "1.1.1.1")},
+	})
+	assert.NoError(t, err)
+	recs, _ = w.getRecords("example.com")
+	assert.Len(t, *recs, 1)
+}
+
+func testApexGuard(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{}, slog.Default())
+	w.CreateZone("example.com")
+	w.CreateZone("preview.example.com")
+
+	allow := true
+	p.config.zoneConfig = &ZoneConfig{
+		Zones: map[string]ZoneSettings{"preview.example.com": {AllowApexChanges: &allow}},
+	}
+
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	err := p.ApplyChanges(ctx, &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("example.com", "A", "1.1.1.1"),
+			endpoint.NewEndpoint("example.com", "MX", "10 mail.example.com"),
+			endpoint.NewEndpoint("www.example.com", "A", "1.1.1.1"),
+			endpoint.NewEndpoint("preview.example.com", "A", "1.1.1.1"),
+		},
+	})
+	assert.NoError(t, err)
+
+	changes := recorder.Changes()
+	require.Len(t, changes, 4)
+	assert.Equal(t, "apex", changes[0].Skipped)
+	assert.Equal(t, "", changes[1].Skipped)
+	assert.Equal(t, "", changes[2].Skipped)
+	assert.Equal(t, "", changes[3].Skipped)
+
+	recs, _ := w.getRecords("example.com")
+	assert.Len(t, *recs, 2)
+	recs, _ = w.getRecords("preview.example.com")
+	assert.Len(t, *recs, 1)
+}
+
+func testFreeze(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{}, slog.Default())
+	p.config.freezeRecord = DefaultFreezeRecord
+	w.CreateZone("example.com")
+	w.CreateZone("example.org")
+	w.CreateZone("example.net")
+	require.NoError(t, w.createRecord(&recordRequest{Domain: "example.com", Name: DefaultFreezeRecord, Type: "TXT", Content: "incident 42"}))
+	frozen := true
+	p.config.zoneConfig = &ZoneConfig{Zones: map[string]ZoneSettings{"example.org": {Frozen: &frozen}}}
+
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	err := p.ApplyChanges(ctx, &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("foo.example.com", "A", "1.1.1.1"),
+			endpoint.NewEndpoint("foo.example.org", "A", "1.1.1.1"),
+			endpoint.NewEndpoint("foo.example.net", "A", "1.1.1.1"),
+		},
+	})
+	assert.NoError(t, err)
+
+	skipped := map[string]string{}
+	for _, change := range recorder.Changes() {
+		skipped[change.Zone] = change.Skipped
+	}
+	assert.Equal(t, map[string]string{"example.com": "frozen", "example.org": "frozen", "example.net": ""}, skipped)
+	recs, _ := w.getRecords("example.net")
+	assert.Len(t, *recs, 1)
+}
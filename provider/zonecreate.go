@@ -0,0 +1,124 @@
+package inwx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// createMissingZones creates the domains of the include rules, e.g. of --domain-filter, that no zone hosted at
+// INWX covers yet, as described by the zone template selected for them in the zone config. It returns the
+// zones including the created ones, and the errors of the zones that couldn't be created.
+func (p *INWXProvider) createMissingZones(ctx context.Context, zones *[]string) (*[]string, []error) {
+	var errs []error
+	created := false
+	for _, domain := range p.missingZones(*zones) {
+		settings := p.settingsFor(domain)
+		if settings.dryRun {
+			p.logger.Info("dry run: would create missing zone", "zone", domain, "template", settings.zoneTemplate)
+			continue
+		}
+		if err := p.createZone(ctx, domain, settings.zoneTemplate); err != nil {
+			p.logger.Error("failed to create missing zone", "zone", domain, "err", err)
+			errs = append(errs, err)
+			continue
+		}
+		created = true
+	}
+	if !created {
+		return zones, errs
+	}
+	// The records of the created zones aren't cached yet.
+	p.records.invalidate()
+	all, err := p.getZones(ctx)
+	if err != nil {
+		return zones, append(errs, err)
+	}
+	return all, errs
+}
+
+// missingZones returns the domains of the include rules that none of zones is or holds, in order.
+func (p *INWXProvider) missingZones(zones []string) []string {
+	var missing []string
+	for _, rule := range p.filter.Rules() {
+		domain := rule.Domain
+		if rule.Exclude || rule.Regex != nil || domain == "" || slices.Contains(missing, domain) || !p.filter.MatchZone(domain) {
+			continue
+		}
+		if !slices.ContainsFunc(zones, func(zone string) bool { return domain == zone || strings.HasSuffix(domain, "."+zone) }) {
+			missing = append(missing, domain)
+		}
+	}
+	return missing
+}
+
+// createZone creates the zone domain with the nameservers and SOA values of the zone template named
+// template, followed by its records.
+func (p *INWXProvider) createZone(ctx context.Context, domain string, template string) error {
+	var tmpl ZoneTemplate
+	var ok bool
+	if p.config.zoneConfig != nil {
+		tmpl, ok = p.config.zoneConfig.ZoneTemplates[template]
+	}
+	if !ok {
+		return fmt.Errorf("unable to create zone %s: no zoneTemplate is configured for it", domain)
+	}
+	request := &zoneRequest{Domain: domain, Nameservers: tmpl.Nameservers, SOAEmail: tmpl.SOA.Email}
+	if err := traceCall(ctx, "nameserver.create", func() error { return p.client.createZone(request) }, attribute.String("dns.zone", domain)); err != nil {
+		return fmt.Errorf("unable to create zone %s: %w", domain, err)
+	}
+	p.logger.Info("created missing zone", "zone", domain, "template", template, "nameservers", strings.Join(tmpl.Nameservers, ", "))
+
+	var errs []error
+	if err := p.applySOATemplate(ctx, domain, tmpl.SOA); err != nil {
+		errs = append(errs, fmt.Errorf("unable to set the SOA values of zone %s: %w", domain, err))
+	}
+	// The records are part of the zone rather than changes of endpoints, so the zone settings don't apply.
+	for _, rec := range tmpl.Records {
+		name := rec.Name
+		if name == "@" {
+			name = ""
+		}
+		request := &recordRequest{Domain: domain, Name: name, Type: rec.Type, Content: rec.Content, TTL: rec.TTL}
+		p.applyDefaultTTL(request)
+		err := traceCall(ctx, "nameserver.createRecord", func() error { return p.client.createRecord(request) }, attribute.String("dns.zone", domain))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to create the %s record %q of zone %s: %w", rec.Type, rec.Name, domain, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applySOATemplate replaces the timer values of the SOA record of zone by those set in soa.
+func (p *INWXProvider) applySOATemplate(ctx context.Context, zone string, soa SOATemplate) error {
+	if soa.Refresh == 0 && soa.Retry == 0 && soa.Expire == 0 && soa.Minimum == 0 {
+		return nil
+	}
+	records, err := p.getRecords(ctx, zone)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(*records, func(rec zoneRecord) bool { return rec.Type == "SOA" })
+	if i < 0 {
+		return fmt.Errorf("zone has no SOA record")
+	}
+	rec := (*records)[i]
+	// The SOA record holds the primary nameserver, the mailbox, the serial and the refresh, retry, expire and
+	// minimum values, in this order.
+	fields := strings.Fields(rec.Content)
+	if len(fields) != 7 {
+		return fmt.Errorf("unexpected SOA record %q", rec.Content)
+	}
+	for j, value := range []int{soa.Refresh, soa.Retry, soa.Expire, soa.Minimum} {
+		if value > 0 {
+			fields[3+j] = strconv.Itoa(value)
+		}
+	}
+	request := &recordRequest{Domain: zone, Type: rec.Type, Content: strings.Join(fields, " "), TTL: rec.TTL}
+	return traceCall(ctx, "nameserver.updateRecord", func() error { return p.client.updateRecord(rec.ID, request) }, attribute.String("dns.zone", zone))
+}
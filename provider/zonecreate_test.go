@@ -0,0 +1,124 @@
+package inwx
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestCreateMissingZones(t *testing.T) {
+	t.Run("FromTemplate", testCreateZoneFromTemplate)
+	t.Run("Disabled", testCreateMissingZonesDisabled)
+	t.Run("WithoutTemplate", testCreateZoneWithoutTemplate)
+	t.Run("CoveredByParent", testMissingZoneCoveredByParent)
+	t.Run("DryRun", testCreateMissingZonesDryRun)
+}
+
+// zoneTemplateConfig selects the standard template for every zone.
+func zoneTemplateConfig() *ZoneConfig {
+	template := "standard"
+	return &ZoneConfig{
+		Defaults: ZoneSettings{ZoneTemplate: &template},
+		ZoneTemplates: map[string]ZoneTemplate{
+			"standard": {
+				Nameservers: []string{"ns.inwx.de", "ns2.inwx.de"},
+				SOA:         SOATemplate{Email: "hostmaster@example.com", Refresh: 7200, Minimum: 300},
+				Records: []TemplateRecord{
+					{Name: "@", Type: "TXT", Content: "v=spf1 -all", TTL: 3600},
+					{Name: "_dmarc", Type: "TXT", Content: "v=DMARC1; p=reject"},
+				},
+			},
+		},
+	}
+}
+
+func testCreateZoneFromTemplate(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com", "example.org"}, slog.Default())
+	w.CreateZone("example.org")
+	p.config.createMissingZones = true
+	p.config.zoneConfig = zoneTemplateConfig()
+	p.config.defaultTTL = 600
+
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	err := p.ApplyChanges(ctx, &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.com", "A", "192.0.2.1")}})
+	require.NoError(t, err)
+
+	// Only the zone INWX didn't host is created, with the nameservers and SOA mailbox of the template
+	assert.Equal(t, []zoneRequest{{Domain: "example.com", Nameservers: []string{"ns.inwx.de", "ns2.inwx.de"}, SOAEmail: "hostmaster@example.com"}},
+		w.createdZones)
+	recs, err := w.getRecords("example.com")
+	require.NoError(t, err)
+	require.Len(t, *recs, 6)
+	assert.Equal(t, "ns.inwx.de hostmaster.example.com 2026101501 7200 3600 604800 300", (*recs)[0].Content, "the SOA values of the template are set")
+	assert.Equal(t, []zoneRecord{
+		{ID: "1", Type: "NS", Content: "ns.inwx.de", TTL: 86400},
+		{ID: "2", Type: "NS", Content: "ns2.inwx.de", TTL: 86400},
+		{ID: "3", Type: "TXT", Content: "v=spf1 -all", TTL: 3600},
+		{ID: "4", Name: "_dmarc", Type: "TXT", Content: "v=DMARC1; p=reject", TTL: 600},
+		{ID: "5", Name: "foo", Type: "A", Content: "192.0.2.1", TTL: 600},
+	}, (*recs)[1:])
+
+	// The records of the template aren't changes of endpoints; the change lands in the created zone
+	changes := recorder.Changes()
+	require.Len(t, changes, 1)
+	assert.Equal(t, "example.com", changes[0].Zone)
+	assert.Equal(t, "applied", changes[0].Outcome)
+
+	// Zones are created once
+	err = p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("bar.example.com", "A", "192.0.2.2")}})
+	require.NoError(t, err)
+	assert.Len(t, w.createdZones, 1)
+}
+
+func testCreateMissingZonesDisabled(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	p.config.zoneConfig = zoneTemplateConfig()
+
+	err := p.ApplyChanges(context.TODO(), &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.com", "A", "192.0.2.1")}})
+	assert.Error(t, err)
+	assert.Empty(t, w.createdZones)
+}
+
+func testCreateZoneWithoutTemplate(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com", "example.org"}, slog.Default())
+	w.CreateZone("example.org")
+	p.config.createMissingZones = true
+
+	// The other zones are still changed
+	ctx, recorder := WithChangeRecorder(context.TODO())
+	err := p.ApplyChanges(ctx, &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("foo.example.org", "A", "192.0.2.1")}})
+	assert.ErrorContains(t, err, "encountered 1 errors")
+	assert.Empty(t, w.createdZones)
+	require.Len(t, recorder.Changes(), 1)
+	assert.Equal(t, "applied", recorder.Changes()[0].Outcome)
+
+	err = p.createZone(context.TODO(), "example.com", "")
+	assert.EqualError(t, err, "unable to create zone example.com: no zoneTemplate is configured for it")
+}
+
+func testMissingZoneCoveredByParent(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"app.example.com", "example.net"}, slog.Default())
+	w.CreateZone("example.com")
+	p.config.createMissingZones = true
+	p.filter.withExclusions([]string{"example.net"})
+
+	assert.Empty(t, p.missingZones([]string{"example.com"}), "neither names below a hosted zone nor excluded domains are created")
+}
+
+func testCreateMissingZonesDryRun(t *testing.T) {
+	w, p := NewINWXProviderWithMockClient(&[]string{"example.com"}, slog.Default())
+	p.config.createMissingZones = true
+	p.config.zoneConfig = zoneTemplateConfig()
+	dryRun := true
+	p.config.zoneConfig.Defaults.DryRun = &dryRun
+
+	zones, errs := p.createMissingZones(context.TODO(), &[]string{})
+	assert.Empty(t, errs)
+	assert.Empty(t, *zones)
+	assert.Empty(t, w.createdZones)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	provider "github.com/orbit-online/external-dns-inwx-webhook/provider"
+)
+
+// readSecret returns the value of the flag --name, given directly or, e.g. mounted from a Kubernetes
+// secret, in the file of --name-file. A single trailing line break of the file is dropped.
+func readSecret(name string, value string, file string) (string, error) {
+	if file == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", fmt.Errorf("--%s and --%s-file are mutually exclusive", name, name)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("unable to read --%s-file: %w", name, err)
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r"), nil
+}
+
+// requireSecret is readSecret for secrets that must be given.
+func requireSecret(name string, value string, file string) (string, error) {
+	secret, err := readSecret(name, value, file)
+	if err == nil && secret == "" {
+		err = fmt.Errorf("--%s or --%s-file is required", name, name)
+	}
+	return secret, err
+}
+
+// loadTOTPSecret returns the TOTP secret given directly or in file, nil if there is none.
+func loadTOTPSecret(secret string, file string) (provider.TOTPSecret, error) {
+	secret, err := readSecret("inwx-totp-secret", secret, file)
+	if err != nil || strings.TrimSpace(secret) == "" {
+		return nil, err
+	}
+	return provider.ParseTOTPSecret(secret)
+}
+
+// readCredentials reads the INWX credentials from their flags, or the files given instead.
+func readCredentials() (provider.Credentials, error) {
+	user, err := requireSecret("inwx-username", *username, *usernameFile)
+	if err != nil {
+		return provider.Credentials{}, err
+	}
+	pass, err := requireSecret("inwx-password", *password, *passwordFile)
+	if err != nil {
+		return provider.Credentials{}, err
+	}
+	totp, err := loadTOTPSecret(*totpSecret, *totpFile)
+	if err != nil {
+		return provider.Credentials{}, err
+	}
+	return provider.Credentials{Username: user, Password: pass, TOTPSecret: totp}, nil
+}
+
+// watchCredentials reads the credentials files by read every interval until ctx is done, handing rotated
+// credentials to the provider. Kubernetes replaces mounted secret files atomically, so they are read
+// again rather than watched for events.
+func watchCredentials(ctx context.Context, p *provider.INWXProvider, read func() (provider.Credentials, error), interval time.Duration, logger *slog.Logger) {
+	current, err := read()
+	if err != nil {
+		logger.Error("failed to read INWX credentials, not reloading them", "error", err.Error())
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		creds, err := read()
+		if err != nil {
+			logger.Error("failed to reload INWX credentials, keeping the current ones", "error", err.Error())
+			continue
+		}
+		if creds.Username == current.Username && creds.Password == current.Password && bytes.Equal(creds.TOTPSecret, current.TOTPSecret) {
+			continue
+		}
+		p.SetCredentials(creds)
+		current = creds
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	provider "github.com/orbit-online/external-dns-inwx-webhook/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecrets(t *testing.T) {
+	dir := t.TempDir()
+	file := func(content string) string {
+		f, err := os.CreateTemp(dir, "secret")
+		require.NoError(t, err)
+		_, err = f.WriteString(content)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		return f.Name()
+	}
+
+	t.Run("ReadSecret", func(t *testing.T) {
+		secret, err := readSecret("inwx-password", "direct", "")
+		require.NoError(t, err)
+		assert.Equal(t, "direct", secret)
+
+		// A single trailing line break is dropped, of either style
+		for content, want := range map[string]string{
+			"from-file":         "from-file",
+			"from-file\n":       "from-file",
+			"from-file\r\n":     "from-file",
+			"from-file\n\n":     "from-file\n",
+			" from-file \n":     " from-file ",
+			"from\nfile\n":      "from\nfile",
+			"from-file\r\n\r\n": "from-file\r\n",
+		} {
+			secret, err := readSecret("inwx-password", "", file(content))
+			require.NoError(t, err)
+			assert.Equal(t, want, secret, content)
+		}
+
+		_, err = readSecret("inwx-password", "direct", file("from-file"))
+		assert.EqualError(t, err, "--inwx-password and --inwx-password-file are mutually exclusive")
+
+		_, err = readSecret("inwx-password", "", filepath.Join(dir, "missing"))
+		assert.ErrorContains(t, err, "unable to read --inwx-password-file")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("RequireSecret", func(t *testing.T) {
+		for _, f := range []string{"", file(""), file("\n")} {
+			_, err := requireSecret("inwx-username", "", f)
+			assert.EqualError(t, err, "--inwx-username or --inwx-username-file is required", f)
+		}
+		secret, err := requireSecret("inwx-username", "", file("user\n"))
+		require.NoError(t, err)
+		assert.Equal(t, "user", secret)
+
+		_, err = requireSecret("inwx-username", "user", file("user"))
+		assert.ErrorContains(t, err, "mutually exclusive")
+	})
+
+	t.Run("TOTPSecret", func(t *testing.T) {
+		for _, tt := range []struct{ secret, file string }{{"", ""}, {" ", ""}, {"", file(" \n")}} {
+			secret, err := loadTOTPSecret(tt.secret, tt.file)
+			require.NoError(t, err)
+			assert.Nil(t, secret)
+		}
+
+		want, err := provider.ParseTOTPSecret("JBSWY3DPEHPK3PXP")
+		require.NoError(t, err)
+		secret, err := loadTOTPSecret("", file("jbsw y3dp ehpk 3pxp\r\n"))
+		require.NoError(t, err)
+		assert.Equal(t, want, secret)
+
+		_, err = loadTOTPSecret("not base32!", "")
+		assert.ErrorContains(t, err, "invalid TOTP secret")
+		_, err = loadTOTPSecret("JBSWY3DPEHPK3PXP", file("JBSWY3DPEHPK3PXP"))
+		assert.EqualError(t, err, "--inwx-totp-secret and --inwx-totp-secret-file are mutually exclusive")
+	})
+}
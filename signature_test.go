@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignatureMiddleware(t *testing.T) {
+	secret := []byte("s3cret")
+	var received string
+	handler := signatureMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+	}), secret, 5*time.Minute, slog.Default())
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	request := func(method string, target string, body string, timestamp string, signature string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if timestamp != "" {
+			r.Header.Set(timestampHeader, timestamp)
+		}
+		if signature != "" {
+			r.Header.Set(signatureHeader, signature)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	sign := func(timestamp string, uri string, body string) string {
+		return signRequest(secret, timestamp, http.MethodPost, uri, []byte(body))
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		received = ""
+		w := request(http.MethodPost, "/refresh?zone=a.example.com", `{"a":1}`, now, sign(now, "/refresh?zone=a.example.com", `{"a":1}`))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `{"a":1}`, received, "the body is passed on")
+
+		// The hex digest is compared case-insensitively
+		w = request(http.MethodPost, "/records", "", now, "sha256="+strings.ToUpper(strings.TrimPrefix(sign(now, "/records", ""), "sha256=")))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Timestamp", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, request(http.MethodPost, "/records", "", "", sign(now, "/records", "")).Code)
+		assert.Equal(t, http.StatusUnauthorized, request(http.MethodPost, "/records", "", "yesterday", sign("yesterday", "/records", "")).Code)
+	})
+
+	t.Run("Skew", func(t *testing.T) {
+		for _, offset := range []time.Duration{-6 * time.Minute, 6 * time.Minute} {
+			ts := strconv.FormatInt(time.Now().Add(offset).Unix(), 10)
+			assert.Equal(t, http.StatusUnauthorized, request(http.MethodPost, "/records", "", ts, sign(ts, "/records", "")).Code, offset)
+		}
+		ts := strconv.FormatInt(time.Now().Add(-4*time.Minute).Unix(), 10)
+		assert.Equal(t, http.StatusOK, request(http.MethodPost, "/records", "", ts, sign(ts, "/records", "")).Code)
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		signature := sign(now, "/refresh?zone=a.example.com", `{"a":1}`)
+		assert.Equal(t, http.StatusUnauthorized, request(http.MethodPost, "/refresh?zone=a.example.com", `{"a":2}`, now, signature).Code)
+		assert.Equal(t, http.StatusUnauthorized, request(http.MethodPost, "/refresh?zone=b.example.com", `{"a":1}`, now, signature).Code)
+		assert.Equal(t, http.StatusUnauthorized, request(http.MethodPost, "/refresh", `{"a":1}`, now, signature).Code)
+		assert.Equal(t, http.StatusUnauthorized, request(http.MethodPut, "/refresh?zone=a.example.com", `{"a":1}`, now, signature).Code)
+		assert.Equal(t, http.StatusUnauthorized, request(http.MethodPost, "/records", "", now, "").Code)
+	})
+
+	t.Run("Oversized", func(t *testing.T) {
+		body := strings.Repeat("x", maxSignedBodySize+1)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, request(http.MethodPost, "/records", body, now, sign(now, "/records", body)).Code)
+	})
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, request(http.MethodGet, "/records", "", "", "").Code)
+		assert.Equal(t, http.StatusOK, request(http.MethodHead, "/records", "", "", "").Code)
+	})
+}
@@ -0,0 +1,12 @@
+package main
+
+import (
+	provider "github.com/orbit-online/external-dns-inwx-webhook/provider"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// desiredSnapshot is the output of the snapshot command.
+type desiredSnapshot struct {
+	Endpoints []*endpoint.Endpoint        `json:"endpoints"`
+	Excluded  []provider.ExcludedEndpoint `json:"excluded"`
+}
@@ -0,0 +1,15 @@
+//go:build !snapshot
+
+package main
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+)
+
+// runSnapshot fails in builds without the snapshot build tag. They leave out the external-dns sources and
+// the Kubernetes clients they bring along, which would grow the binary by half for a development command.
+func runSnapshot(io.Writer, *slog.Logger) error {
+	return errors.New("this binary was built without the snapshot command, build it with -tags snapshot")
+}
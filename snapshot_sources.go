@@ -0,0 +1,70 @@
+//go:build snapshot
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	provider "github.com/orbit-online/external-dns-inwx-webhook/provider"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/source"
+)
+
+// snapshotRequestTimeout bounds each request to the Kubernetes API server.
+const snapshotRequestTimeout = 30 * time.Second
+
+// runSnapshot reads the configured external-dns sources from the cluster and prints the endpoints
+// this provider would be asked to manage, along with the ones it would leave alone. Filtering and adjusting
+// the endpoints makes no INWX calls, so the provider is served from an empty in-memory API and needs no
+// credentials.
+func runSnapshot(out io.Writer, logger *slog.Logger) error {
+	p, err := newProvider(provider.Tenant{DomainFilter: *domainFilter, ZoneConfig: *zoneConfigFile, TXTOwnerID: *txtOwnerID}, provider.Credentials{}, "", logger, provider.WithInMemoryAPI())
+	if err != nil {
+		return err
+	}
+	labelFilter, err := labels.Parse(*snapshotLabelFilter)
+	if err != nil {
+		return fmt.Errorf("invalid --label-filter: %w", err)
+	}
+	cfg := &source.Config{
+		Namespace:           *snapshotNamespace,
+		AnnotationFilter:    *snapshotAnnotationFilter,
+		LabelFilter:         labelFilter,
+		KubeConfig:          *snapshotKubeConfig,
+		CRDSourceAPIVersion: *snapshotCRDAPIVersion,
+		CRDSourceKind:       *snapshotCRDKind,
+		RequestTimeout:      snapshotRequestTimeout,
+	}
+	clients := &source.SingletonClientGenerator{
+		KubeConfig:     *snapshotKubeConfig,
+		RequestTimeout: snapshotRequestTimeout,
+	}
+
+	ctx := context.Background()
+	sources, err := source.ByNames(ctx, clients, *snapshotSources, cfg)
+	if err != nil {
+		return fmt.Errorf("unable to set up sources: %w", err)
+	}
+	var endpoints []*endpoint.Endpoint
+	for i, src := range sources {
+		eps, err := src.Endpoints(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to read endpoints from source %s: %w", (*snapshotSources)[i], err)
+		}
+		endpoints = append(endpoints, eps...)
+	}
+
+	var snapshot desiredSnapshot
+	if snapshot.Endpoints, snapshot.Excluded, err = p.DesiredEndpoints(endpoints); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(snapshot)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"path/filepath"
+	"strings"
+
+	provider "github.com/orbit-online/external-dns-inwx-webhook/provider"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// tenantsPath prefixes the paths of every tenant on the webhook and metrics listeners.
+const tenantsPath = "/tenants/"
+
+// servedTenant is a provider served by the listeners: the single provider of the flags, whose name is
+// empty, or a tenant of the --tenants-config file.
+type servedTenant struct {
+	name     string
+	provider *provider.INWXProvider
+	logger   *slog.Logger
+	// registry holds the metrics of a tenant, labeled with its name; nil for the single provider, whose
+	// metrics are registered with the default registry.
+	registry *prometheus.Registry
+	// readCredentials reads the credentials again, if reloadCredentials, to pick up rotated ones.
+	readCredentials   func() (provider.Credentials, error)
+	reloadCredentials bool
+	// signingSecret and allowed protect the webhook API of the tenant, see protectWebhook.
+	signingSecret []byte
+	allowed       []netip.Prefix
+}
+
+// checkTenantFlags rejects the flags that can't apply to several tenants at once.
+func checkTenantFlags(command string) error {
+	switch {
+	case command != serveCmd.FullCommand():
+		return fmt.Errorf("--tenants-config only works with the %s command", serveCmd.FullCommand())
+	case *grpcListenAddr != "":
+		return errors.New("--grpc-listen-address can't serve several tenants")
+	case *auditLog != "":
+		return errors.New("--audit-log would mix the changes of the tenants, set auditLog per tenant instead")
+	case *migrateOwnerFrom != "":
+		return errors.New("--migrate-owner-from would rewrite the ownership records of every tenant, use the migrate-owner command per tenant instead")
+	}
+	return nil
+}
+
+// buildTenants builds a provider per tenant of the tenants config at path, with the flags for the settings
+// it leaves empty, and verifies their accounts.
+func buildTenants(path string, logger *slog.Logger) ([]servedTenant, error) {
+	tenants, err := provider.LoadTenants(path)
+	if err != nil {
+		return nil, err
+	}
+	served := make([]servedTenant, 0, len(tenants))
+	for _, tenant := range tenants {
+		if len(tenant.DomainFilter) == 0 {
+			tenant.DomainFilter = *domainFilter
+		}
+		if tenant.ZoneConfig == "" {
+			tenant.ZoneConfig = *zoneConfigFile
+		}
+		if tenant.ExpectedAccountID == 0 {
+			tenant.ExpectedAccountID = *expectedAccount
+		}
+		if tenant.TXTOwnerID == "" {
+			tenant.TXTOwnerID = *txtOwnerID
+		}
+		if len(tenant.AllowedCIDRs) == 0 {
+			tenant.AllowedCIDRs = *allowedCIDRs
+		}
+		creds, err := tenant.Credentials()
+		if err != nil {
+			return nil, err
+		}
+		secret, err := tenant.SigningSecret()
+		if err != nil {
+			return nil, err
+		}
+		if secret == "" {
+			secret = *signingSecret
+		}
+		allowed, err := parseAllowlist(tenant.AllowedCIDRs)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant.Name, err)
+		}
+		reportDir := *reportDir
+		if reportDir != "" {
+			reportDir = filepath.Join(reportDir, tenant.Name)
+		}
+		tenantLogger := logger.With("tenant", tenant.Name)
+		p, err := newProvider(tenant, creds, reportDir, tenantLogger)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant.Name, err)
+		}
+		if err := p.VerifyAccount(); err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant.Name, err)
+		}
+		served = append(served, servedTenant{
+			name:              tenant.Name,
+			provider:          p,
+			logger:            tenantLogger,
+			registry:          prometheus.NewRegistry(),
+			readCredentials:   tenant.Credentials,
+			reloadCredentials: true,
+			signingSecret:     []byte(secret),
+			allowed:           allowed,
+		})
+	}
+	return served, nil
+}
+
+// registerTenantMetrics registers the metrics of the tenants and returns the gatherer of all metrics. The
+// metrics of each tenant go to its own registry, labeled with the tenant, so that a tenant sees only its
+// own at its prefix.
+func registerTenantMetrics(tenants []servedTenant) prometheus.Gatherer {
+	gatherers := prometheus.Gatherers{prometheus.DefaultGatherer}
+	for _, tenant := range tenants {
+		if tenant.registry == nil {
+			prometheus.DefaultRegisterer.MustRegister(tenant.provider.Collectors()...)
+			continue
+		}
+		prometheus.WrapRegistererWith(prometheus.Labels{"tenant": tenant.name}, tenant.registry).MustRegister(tenant.provider.Collectors()...)
+		gatherers = append(gatherers, tenant.registry)
+	}
+	return gatherers
+}
+
+// buildTenantsWebhookServer serves the webhook API of the single provider at the root, or of every tenant
+// under /tenants/<name>, the URL its external-dns is given as --webhook-provider-url. Each tenant is
+// protected by its own signing secret and allowlist, so that a caller can't apply changes with the
+// credentials of another tenant; signatures are made over the full path, including the prefix.
+func buildTenantsWebhookServer(tenants []servedTenant) http.Handler {
+	if len(tenants) == 1 && tenants[0].name == "" {
+		return protectWebhook(buildWebhookServer(tenants[0].provider, tenants[0].logger), tenants[0].signingSecret, tenants[0].allowed, tenants[0].logger)
+	}
+	mux := http.NewServeMux()
+	for _, tenant := range tenants {
+		handler := stripTenantPrefix(tenant.name, buildWebhookServer(tenant.provider, tenant.logger))
+		mountTenant(mux, tenant.name, protectWebhook(handler, tenant.signingSecret, tenant.allowed, tenant.logger))
+	}
+	return mux
+}
+
+// buildTenantsMetricsServer serves the metrics server of the single provider, or the metrics of every
+// tenant at /metrics, with the health, debug and metrics endpoints of each under /tenants/<name>.
+func buildTenantsMetricsServer(gatherer prometheus.Gatherer, tenants []servedTenant, logger *slog.Logger) *http.ServeMux {
+	if len(tenants) == 1 && tenants[0].name == "" {
+		return buildMetricsServer(gatherer, tenants[0].provider, logger)
+	}
+	mux := http.NewServeMux()
+	for _, tenant := range tenants {
+		mountTenant(mux, tenant.name, stripTenantPrefix(tenant.name, buildMetricsServer(tenant.registry, tenant.provider, tenant.logger)))
+	}
+	mux.HandleFunc("/healthz", tenantsProbeHandler(tenants, provider.Health.Alive))
+	mux.HandleFunc("/readyz", tenantsProbeHandler(tenants, provider.Health.Ready))
+	mux.Handle("/metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+	mux.HandleFunc("/debug/dashboard.json", dashboardHandler)
+	return mux
+}
+
+// mountTenant serves handler at /tenants/<name> and below.
+func mountTenant(mux *http.ServeMux, name string, handler http.Handler) {
+	prefix := tenantsPath + name
+	mux.Handle(prefix, handler)
+	mux.Handle(prefix+"/", handler)
+}
+
+// stripTenantPrefix passes requests on to handler with /tenants/<name> removed from their path.
+func stripTenantPrefix(name string, handler http.Handler) http.Handler {
+	prefix := tenantsPath + name
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = strings.TrimPrefix(r.URL.Path, prefix)
+		r2.URL.RawPath = ""
+		if r2.URL.Path == "" {
+			r2.URL.Path = "/"
+		}
+		handler.ServeHTTP(w, r2)
+	})
+}
+
+// tenantsProbeHandler serves a probe of several tenants, listing the state of each: it passes while any
+// tenant passes ok, so that the broken credentials of one tenant don't take the others down with the pod.
+// Each tenant has its own probes under its prefix.
+func tenantsProbeHandler(tenants []servedTenant, ok func(provider.Health) bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := http.StatusServiceUnavailable
+		var body strings.Builder
+		for _, tenant := range tenants {
+			health := tenant.provider.Health()
+			if ok(health) {
+				status = http.StatusOK
+			}
+			fmt.Fprintf(&body, "%s: %s\n", tenant.name, health.State)
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body.String()))
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	provider "github.com/orbit-online/external-dns-inwx-webhook/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantsWebhookServer(t *testing.T) {
+	defer func(skew time.Duration) { *signatureMaxSkew = skew }(*signatureMaxSkew)
+	*signatureMaxSkew = 5 * time.Minute
+
+	tenant := func(name string, zone string, secret string, allowed ...string) servedTenant {
+		prefixes, err := parseAllowlist(allowed)
+		require.NoError(t, err)
+		return servedTenant{
+			name:          name,
+			provider:      provider.NewINWXProvider(&[]string{zone}, "", "", false, slog.Default(), provider.WithInMemoryAPI(zone)),
+			logger:        slog.Default(),
+			signingSecret: []byte(secret),
+			allowed:       prefixes,
+		}
+	}
+	handler := buildTenantsWebhookServer([]servedTenant{
+		tenant("a", "a.example.com", "secret-a"),
+		tenant("b", "b.example.com", "secret-b", "10.0.0.0/8"),
+	})
+	serve := func(method string, target string, remote string, secret string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, nil)
+		if remote != "" {
+			r.RemoteAddr = remote
+		}
+		if secret != "" {
+			ts := strconv.FormatInt(time.Now().Unix(), 10)
+			r.Header.Set(timestampHeader, ts)
+			r.Header.Set(signatureHeader, signRequest([]byte(secret), ts, method, target, nil))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("PrefixStripping", func(t *testing.T) {
+		// The negotiation is served at the prefix, with and without a trailing slash
+		for _, target := range []string{"/tenants/a", "/tenants/a/"} {
+			w := serve(http.MethodGet, target, "", "")
+			assert.Equal(t, http.StatusOK, w.Code, target)
+			assert.NotEmpty(t, w.Header().Get(recordTypesHeader), target)
+		}
+		w := serve(http.MethodGet, "/tenants/a/records", "", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/tenants/c/records", "", "").Code)
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/records", "", "").Code)
+	})
+
+	t.Run("Signature", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/tenants/a/refresh?zone=a.example.com", "", "secret-a").Code)
+		// Each tenant is served by its own provider
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/tenants/a/refresh?zone=b.example.com", "", "secret-a").Code)
+		assert.Equal(t, http.StatusUnauthorized, serve(http.MethodPost, "/tenants/a/refresh?zone=a.example.com", "", "").Code)
+		// A tenant's secret doesn't sign requests for another tenant
+		assert.Equal(t, http.StatusUnauthorized, serve(http.MethodPost, "/tenants/b/refresh?zone=b.example.com", "10.0.0.1:1234", "secret-a").Code)
+		assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/tenants/b/refresh?zone=b.example.com", "10.0.0.1:1234", "secret-b").Code)
+	})
+
+	t.Run("Allowlist", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/tenants/b/records", "192.0.2.1:1234", "").Code)
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "/tenants/b/refresh?zone=b.example.com", "192.0.2.1:1234", "secret-b").Code)
+		assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/tenants/b/records", "10.0.0.1:1234", "").Code)
+		// Other tenants don't share the allowlist
+		assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/tenants/a/records", "192.0.2.1:1234", "").Code)
+	})
+
+	t.Run("SingleProvider", func(t *testing.T) {
+		single := tenant("", "example.com", "secret", "192.0.2.0/24")
+		handler := buildTenantsWebhookServer([]servedTenant{single})
+		r := httptest.NewRequest(http.MethodGet, "/records", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}, single.allowed)
+
+		r = httptest.NewRequest(http.MethodPost, "/refresh?zone=example.com", nil)
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	provider "github.com/orbit-online/external-dns-inwx-webhook/provider"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+	webhook "sigs.k8s.io/external-dns/provider/webhook/api"
+)
+
+// changeIDsHeader carries the IDs of the changes applied while handling a POST /records request.
+const changeIDsHeader = "X-Inwx-Change-Ids"
+
+// timeoutHeader carries how long external-dns, or a proxy in front of the webhook, waits for a response.
+const timeoutHeader = "X-Request-Timeout"
+
+// partialResult is the response to a POST /records request that ran out of time: every change with
+// its outcome, those not applied skipped with the reason "deadline".
+type partialResult struct {
+	Error   string                   `json:"error"`
+	Changes []provider.AppliedChange `json:"changes"`
+}
+
+// recordsHandler handles GET and POST /records like the upstream webhook server, but passes on the
+// request context and returns the applied change IDs to the caller.
+func recordsHandler(server *webhook.WebhookServer, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			consistency, err := provider.ParseConsistency(r.URL.Query().Get("consistency"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			records, err := server.Provider.Records(provider.WithConsistency(r.Context(), consistency))
+			if err != nil {
+				logger.Error("failed to get records", "error", err.Error())
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set(webhook.ContentTypeHeader, webhook.MediaTypeFormatAndVersion)
+			w.WriteHeader(http.StatusOK)
+			if err := json.NewEncoder(w).Encode(records); err != nil {
+				logger.Error("failed to encode records", "error", err.Error())
+			}
+			return
+		}
+		if r.Method != http.MethodPost {
+			server.RecordsHandler(w, r)
+			return
+		}
+
+		var changes plan.Changes
+		if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+			logger.Error("failed to decode changes", "error", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		ctx, recorder := provider.WithChangeRecorder(r.Context())
+		err := server.Provider.ApplyChanges(ctx, &changes)
+		if ids := recorder.IDs(); len(ids) > 0 {
+			w.Header().Set(changeIDsHeader, strings.Join(ids, ","))
+			logger.Info("applied changes", "change_ids", strings.Join(ids, ","), "trace_id", provider.TraceID(ctx))
+		}
+		if errors.Is(err, provider.ErrDeadlineExceeded) {
+			logger.Error("ran out of time applying changes", "error", err.Error())
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusGatewayTimeout)
+			if err := json.NewEncoder(w).Encode(partialResult{Error: err.Error(), Changes: recorder.Changes()}); err != nil {
+				logger.Error("failed to encode partial result", "error", err.Error())
+			}
+			return
+		}
+		if err != nil {
+			logger.Error("failed to apply changes", "error", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// validateHandler handles POST /validate: it checks a JSON list of endpoints like creating them would,
+// without changing anything in INWX, and returns a result per endpoint. The status is 422 if any
+// endpoint is invalid, so that CI can gate changes on it.
+func validateHandler(p *provider.INWXProvider, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		var endpoints []*endpoint.Endpoint
+		if err := json.NewDecoder(r.Body).Decode(&endpoints); err != nil {
+			http.Error(w, "invalid endpoints: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		results, err := p.ValidateEndpoints(r.Context(), endpoints)
+		if err != nil {
+			logger.Error("failed to validate endpoints", "error", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		status := http.StatusOK
+		for _, result := range results {
+			if !result.Valid {
+				status = http.StatusUnprocessableEntity
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(results); err != nil {
+			logger.Error("failed to encode validation results", "error", err.Error())
+		}
+	}
+}
+
+// approveHandler handles the approval of changes held back by --max-deletes: GET /approve/ lists the
+// changes pending approval with the records they would delete, POST /approve/<plan hash> approves them,
+// so that they are applied with the next reconcile of external-dns.
+func approveHandler(p *provider.INWXProvider, prefix string, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hash := strings.TrimPrefix(r.URL.Path, prefix)
+		switch {
+		case r.Method == http.MethodGet && hash == "":
+			writeJSON(w, p.PendingDeletions(), logger)
+		case r.Method == http.MethodPost && hash != "":
+			approved, err := p.ApproveDeletions(hash)
+			if errors.Is(err, provider.ErrNoPendingDeletions) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, approved, logger)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// refreshHandler serves POST /refresh?zone=<zone>: it reads the zone from INWX right away and drops the
+// cached records, e.g. after changes in the INWX web panel, answering with the changes found.
+func refreshHandler(p *provider.INWXProvider, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		zone := r.URL.Query().Get("zone")
+		if zone == "" {
+			http.Error(w, "missing zone parameter", http.StatusBadRequest)
+			return
+		}
+		refreshed, err := p.RefreshZone(r.Context(), zone)
+		if errors.Is(err, provider.ErrZoneNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logger.Error("failed to refresh zone", "zone", zone, "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, refreshed, logger)
+	}
+}
+
+// explainedAdjustment is the response to a POST /adjustendpoints?explain=true request: the adjusted
+// endpoints, and what was changed or found wrong with them.
+type explainedAdjustment struct {
+	Endpoints   []*endpoint.Endpoint  `json:"endpoints"`
+	Adjustments []provider.Adjustment `json:"adjustments"`
+}
+
+// adjustEndpointsHandler answers requests with ?explain=true with the adjustments of the endpoints next to
+// them, and hands the others, e.g. those of external-dns, to next.
+func adjustEndpointsHandler(p *provider.INWXProvider, next http.HandlerFunc, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if explain, _ := strconv.ParseBool(r.URL.Query().Get("explain")); !explain {
+			next(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		var endpoints []*endpoint.Endpoint
+		if err := json.NewDecoder(r.Body).Decode(&endpoints); err != nil {
+			http.Error(w, "invalid endpoints: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		adjusted, adjustments, err := p.AdjustEndpointsWithReasons(endpoints)
+		if err != nil {
+			logger.Error("failed to adjust endpoints", "error", err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(explainedAdjustment{Endpoints: adjusted, Adjustments: adjustments}); err != nil {
+			logger.Error("failed to encode adjusted endpoints", "error", err.Error())
+		}
+	}
+}
+
+// traceMiddleware links the provider operations of requests carrying a W3C traceparent header, e.g. set
+// by a service mesh, to their trace, also making their spans children of the span of the caller.
+func traceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if traceID, ok := provider.ParseTraceparent(r.Header.Get(provider.TraceparentHeader)); ok {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			r = r.WithContext(provider.WithTraceID(ctx, traceID))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// deadlineMiddleware gives requests a deadline after the timeout of their X-Request-Timeout header, a Go
+// duration or a number of seconds, or defaultTimeout without one, so that the provider can budget the
+// INWX calls and respond with a partial result before the caller gives up. Zero means no deadline.
+func deadlineMiddleware(next http.Handler, defaultTimeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := defaultTimeout
+		if v := r.Header.Get(timeoutHeader); v != "" {
+			var err error
+			if timeout, err = parseTimeout(v); err != nil {
+				http.Error(w, "invalid "+timeoutHeader+" header: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if timeout > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// compatibilityMiddleware rates the external-dns instance sending each request by its User-Agent and the
+// webhook media type of its Accept or Content-Type header, so that unsupported versions show up in the
+// logs, /status and the metrics rather than as JSON errors.
+func compatibilityMiddleware(p *provider.INWXProvider, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mediaType := r.Header.Get("Accept")
+		if mediaType == "" {
+			mediaType = r.Header.Get("Content-Type")
+		}
+		p.ObserveClient(provider.ParseExternalDNSClient(r.UserAgent(), mediaType))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// parseTimeout parses a timeout given as a Go duration, e.g. 30s, or a number of seconds.
+func parseTimeout(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		seconds, serr := strconv.ParseFloat(s, 64)
+		if serr != nil {
+			return 0, err
+		}
+		d = time.Duration(seconds * float64(time.Second))
+	}
+	if d < 0 {
+		return 0, errors.New("negative timeout")
+	}
+	return d, nil
+}